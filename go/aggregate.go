@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// sourceDBColumn is the column AggregateQuery adds to every table, naming the database a row
+// came from
+const sourceDBColumn = "_source_db"
+
+// AggregateQuery runs a query over several stored databases as if they were one. Every table
+// name in the query refers to the UNION ALL of that table across all databases that have it,
+// with the extra column _source_db holding the database name of each row. Columns are matched
+// by name, so databases may differ in column order or lack some columns.
+func (d *DuckDBStorage) AggregateQuery(ctx context.Context, query string, dbNames []string) (_ *Rows, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	if len(dbNames) > d.opts.MaxCrossQueryDatabases {
+		return nil, fmt.Errorf("%w: %d requested, limit is %d", ErrTooManyDatabases, len(dbNames), d.opts.MaxCrossQueryDatabases)
+	}
+
+	op := d.logOperation("aggregate_query", "databases", len(dbNames), "query", query)
+	defer func() { op.done(err) }()
+
+	var paths []string
+	removeAll := func() {
+		for _, path := range paths {
+			removeTempDatabase(path)
+		}
+	}
+
+	for _, name := range dbNames {
+		path, err := tempPath("duckdb-nats-*.db")
+		if err != nil {
+			removeAll()
+			return nil, err
+		}
+		paths = append(paths, path)
+
+		if err := d.getDatabase(ctx, d.objectKey(name), path); err != nil {
+			removeAll()
+			return nil, fmt.Errorf("failed to retrieve %s: %w", name, err)
+		}
+		if err := d.filterDatabase(ctx, path, query); err != nil {
+			removeAll()
+			return nil, err
+		}
+	}
+
+	db, err := openDuckDB("")
+	if err != nil {
+		removeAll()
+		return nil, err
+	}
+
+	// Each table becomes a view in the in-memory database unioning its copies, so the query
+	// runs unchanged. Views, unlike temp views, are visible to every connection of the pool.
+	sources := make(map[tableRef][]string)
+	var tables []tableRef
+	for i, name := range dbNames {
+		alias := fmt.Sprintf("db%d", i)
+		attach := fmt.Sprintf("ATTACH %s AS %s (READ_ONLY)", quoteLiteral(paths[i]), alias)
+		if _, err := db.ExecContext(ctx, attach); err != nil {
+			db.Close()
+			removeAll()
+			return nil, fmt.Errorf("failed to attach %s: %w", name, err)
+		}
+
+		attached, err := attachedTables(ctx, db, alias)
+		if err != nil {
+			db.Close()
+			removeAll()
+			return nil, err
+		}
+		for _, table := range attached {
+			if !slices.Contains(tables, table) {
+				tables = append(tables, table)
+			}
+			qualified := alias + "." + quoteIdent(table.schema) + "." + quoteIdent(table.name)
+			sources[table] = append(sources[table], fmt.Sprintf("SELECT %s AS %s, * FROM %s",
+				quoteLiteral(name), sourceDBColumn, qualified))
+		}
+	}
+
+	for _, table := range tables {
+		if _, err := db.ExecContext(ctx, "CREATE SCHEMA IF NOT EXISTS memory."+quoteIdent(table.schema)); err != nil {
+			db.Close()
+			removeAll()
+			return nil, fmt.Errorf("failed to create schema %s: %w", table.schema, err)
+		}
+		view := fmt.Sprintf("CREATE VIEW memory.%s.%s AS %s", quoteIdent(table.schema), quoteIdent(table.name),
+			strings.Join(sources[table], " UNION ALL BY NAME "))
+		if _, err := db.ExecContext(ctx, view); err != nil {
+			db.Close()
+			removeAll()
+			return nil, fmt.Errorf("failed to combine table %s: %w", table, err)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		db.Close()
+		removeAll()
+		return nil, fmt.Errorf("failed to query databases: %w", err)
+	}
+
+	return &Rows{Rows: rows, db: db, paths: paths}, nil
+}
+
+// AggregateQueryAll runs AggregateQuery over every database of the namespace listed by
+// ListDatabases. Objects without a store timestamp, such as CSV or Parquet exports, are skipped.
+func (d *DuckDBStorage) AggregateQueryAll(ctx context.Context, query string) (_ *Rows, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	entries, err := d.ListDatabases()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Timestamp != "" {
+			names = append(names, entry.Name)
+		}
+	}
+	return d.AggregateQuery(ctx, query, names)
+}
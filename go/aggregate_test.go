@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// storeCountsDatabases stores databases db1.db to db3.db on the server of s, where dbN holds N
+// rows in its counts table. db3.db has an extra column in a different order.
+func storeCountsDatabases(t *testing.T, s *DuckDBStorage) []string {
+	t.Helper()
+	var names []string
+	for i := 1; i <= 3; i++ {
+		name := fmt.Sprintf("db%d.db", i)
+		path := filepath.Join(t.TempDir(), name)
+		if i < 3 {
+			execTestDatabase(t, path,
+				"CREATE TABLE counts (n INTEGER)",
+				fmt.Sprintf("INSERT INTO counts SELECT * FROM range(%d)", i))
+		} else {
+			execTestDatabase(t, path,
+				"CREATE TABLE counts (label VARCHAR, n INTEGER)",
+				"INSERT INTO counts SELECT 'x', i FROM range(3) r(i)")
+		}
+		if err := newTestStorage(t, s.nc, WithDBName(name)).StoreDuckDB(path); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestAggregateQuery(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	names := storeCountsDatabases(t, s)
+
+	rows, err := s.AggregateQuery(context.Background(), "SELECT _source_db, count(*), count(label) FROM counts GROUP BY 1 ORDER BY 1", names)
+	if err != nil {
+		t.Fatalf("AggregateQuery: %v", err)
+	}
+	defer rows.Close()
+
+	type group struct {
+		source        string
+		count, labels int64
+	}
+	want := []group{{"db1.db", 1, 0}, {"db2.db", 2, 0}, {"db3.db", 3, 3}}
+	var got []group
+	for rows.Next() {
+		var g group
+		if err := rows.Scan(&g.source, &g.count, &g.labels); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, g)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("groups = %v, want %v", got, want)
+	}
+}
+
+func TestAggregateQueryAll(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	storeCountsDatabases(t, s)
+	// Exports are not databases and are skipped
+	if _, err := s.obs.PutString("export.csv", "a,b"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := s.AggregateQueryAll(context.Background(), "SELECT count(*), sum(n) FROM counts")
+	if err != nil {
+		t.Fatalf("AggregateQueryAll: %v", err)
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatalf("no rows: %v", rows.Err())
+	}
+	var count, sum int64
+	if err := rows.Scan(&count, &sum); err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 || sum != 0+0+1+0+1+2 {
+		t.Errorf("count, sum = %d, %d, want 6, 4", count, sum)
+	}
+}
+
+func TestAggregateQueryTooMany(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithMaxCrossQueryDatabases(2))
+	_, err := s.AggregateQuery(context.Background(), "SELECT 1", []string{"a.db", "b.db", "c.db"})
+	if !errors.Is(err, ErrTooManyDatabases) {
+		t.Errorf("AggregateQuery over the limit: got %v, want ErrTooManyDatabases", err)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+// NATSBackedAppender bulk-loads rows into a table of a local copy of the stored database with
+// the DuckDB Appender, then stores the database back to NATS on Close
+type NATSBackedAppender struct {
+	// RowsAppended is the number of rows appended so far
+	RowsAppended int64
+
+	storage   *DuckDBStorage
+	path      string
+	connector *duckdb.Connector
+	conn      driver.Conn
+	appender  *duckdb.Appender
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// OpenAppender retrieves the database and opens an appender on tableName, which must exist
+func (d *DuckDBStorage) OpenAppender(ctx context.Context, tableName string) (_ *NATSBackedAppender, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	path, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	connector, err := duckdb.NewConnector(path, nil)
+	if err != nil {
+		removeTempDatabase(path)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	conn, err := connector.Connect(ctx)
+	if err != nil {
+		connector.Close()
+		removeTempDatabase(path)
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	appender, err := duckdb.NewAppenderFromConn(conn, "", tableName)
+	if err != nil {
+		conn.Close()
+		connector.Close()
+		removeTempDatabase(path)
+		return nil, fmt.Errorf("failed to open appender on %s: %w", tableName, err)
+	}
+
+	a := &NATSBackedAppender{
+		storage:   d,
+		path:      path,
+		connector: connector,
+		conn:      conn,
+		appender:  appender,
+	}
+	d.trackResource(a)
+	return a, nil
+}
+
+// AppendRow appends a row with one value per table column
+func (a *NATSBackedAppender) AppendRow(vals ...any) error {
+	values := make([]driver.Value, len(vals))
+	for i, v := range vals {
+		values[i] = v
+	}
+	if err := a.appender.AppendRow(values...); err != nil {
+		return fmt.Errorf("failed to append row %d: %w", a.RowsAppended, err)
+	}
+	a.RowsAppended++
+	return nil
+}
+
+// Close flushes the appended rows, closes the database and stores it back to NATS
+func (a *NATSBackedAppender) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	a.storage.untrackResource(a)
+	defer removeTempDatabase(a.path)
+
+	err := a.appender.Close()
+	if err != nil {
+		err = fmt.Errorf("failed to flush appender: %w", err)
+	}
+	if closeErr := a.conn.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close database: %w", closeErr)
+	}
+	if closeErr := a.connector.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("failed to close database: %w", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := a.storage.storeFileLocked(context.Background(), a.path, true); err != nil && !errors.Is(err, ErrUnchanged) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// benchmarkRows is the number of rows each bulk-load benchmark iteration inserts
+const benchmarkRows = 100000
+
+func TestAppender(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+
+	a, err := s.OpenAppender(ctx, "users")
+	if err != nil {
+		t.Fatalf("OpenAppender: %v", err)
+	}
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		if err := a.AppendRow(int32(i+10), "user", now); err != nil {
+			t.Fatalf("AppendRow: %v", err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if a.RowsAppended != 1000 {
+		t.Errorf("RowsAppended = %d, want 1000", a.RowsAppended)
+	}
+
+	var n int
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1003 {
+		t.Errorf("stored table has %d rows, want 1003", n)
+	}
+}
+
+func TestAppenderErrors(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+	if _, err := s.OpenAppender(ctx, "missing"); err == nil {
+		t.Error("OpenAppender of a missing table succeeded")
+	}
+
+	a, err := s.OpenAppender(ctx, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.AppendRow(int32(10)); err == nil {
+		t.Error("AppendRow accepted a row with missing columns")
+	}
+	a.Close()
+}
+
+func BenchmarkBulkLoad(b *testing.B) {
+	ctx := context.Background()
+	now := time.Now()
+
+	b.Run("insert", func(b *testing.B) {
+		s, _ := storeTestDatabase(b)
+		for i := 0; i < b.N; i++ {
+			path := filepath.Join(b.TempDir(), "insert.db")
+			if err := s.RetrieveDuckDB(path); err != nil {
+				b.Fatal(err)
+			}
+			db, err := openDuckDB(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			stmt, err := tx.PrepareContext(ctx, "INSERT INTO users VALUES (?, ?, ?)")
+			if err != nil {
+				b.Fatal(err)
+			}
+			for row := 0; row < benchmarkRows; row++ {
+				if _, err := stmt.ExecContext(ctx, int32(i*benchmarkRows+row+10), "user", now); err != nil {
+					b.Fatal(err)
+				}
+			}
+			stmt.Close()
+			if err := tx.Commit(); err != nil {
+				b.Fatal(err)
+			}
+			db.Close()
+			if err := s.StoreDuckDB(path); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("appender", func(b *testing.B) {
+		s, _ := storeTestDatabase(b)
+		for i := 0; i < b.N; i++ {
+			a, err := s.OpenAppender(ctx, "users")
+			if err != nil {
+				b.Fatal(err)
+			}
+			for row := 0; row < benchmarkRows; row++ {
+				if err := a.AppendRow(int32(i*benchmarkRows+row+10), "user", now); err != nil {
+					b.Fatal(err)
+				}
+			}
+			if err := a.Close(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
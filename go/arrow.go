@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/apache/arrow/go/v17/arrow/ipc"
+	"github.com/marcboeker/go-duckdb"
+	"github.com/nats-io/nats.go"
+)
+
+// arrowContentType is the media type of Arrow IPC stream objects
+const arrowContentType = "application/vnd.apache.arrow.stream"
+
+// ExportQueryToArrow runs a query against the stored database and stores the result as an
+// Arrow IPC stream object named objectName. Column types come straight from DuckDB's Arrow
+// interface, so no values are converted through Go types.
+func (d *DuckDBStorage) ExportQueryToArrow(ctx context.Context, query, objectName string) (err error) {
+	op := d.logOperation("export_arrow", "query", query, "object", objectName)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	path, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(path)
+
+	if err := d.filterDatabase(ctx, path, query); err != nil {
+		return err
+	}
+	db, err := openDuckDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	var buf bytes.Buffer
+	err = conn.Raw(func(driverConn any) error {
+		arrow, err := duckdb.NewArrowFromConn(driverConn.(driver.Conn))
+		if err != nil {
+			return err
+		}
+		reader, err := arrow.QueryContext(ctx, query)
+		if err != nil {
+			return fmt.Errorf("failed to query database: %w", err)
+		}
+		defer reader.Release()
+
+		w := ipc.NewWriter(&buf, ipc.WithSchema(reader.Schema()))
+		for reader.Next() {
+			if err := w.Write(reader.Record()); err != nil {
+				return fmt.Errorf("failed to write Arrow record: %w", err)
+			}
+		}
+		if err := reader.Err(); err != nil {
+			return fmt.Errorf("failed to read Arrow records: %w", err)
+		}
+		return w.Close()
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = d.obs.Put(&nats.ObjectMeta{
+		Name:        d.objectKey(objectName),
+		Description: "Arrow export of query results",
+		Headers: nats.Header{
+			"Content-Type": []string{arrowContentType},
+		},
+	}, &buf, nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to store %s in NATS: %w", objectName, err)
+	}
+	return nil
+}
+
+// ImportArrowToTable loads a stored Arrow IPC stream object into a table of the local database
+// and stores the updated database. Rows are appended if the table already exists.
+func (d *DuckDBStorage) ImportArrowToTable(ctx context.Context, objectName, dbFilePath, tableName string) (err error) {
+	op := d.logOperation("import_arrow", "object", objectName, "table", tableName)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	data, err := d.obs.GetBytes(d.objectKey(objectName), nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to retrieve %s from NATS: %w", objectName, err)
+	}
+	reader, err := ipc.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to read Arrow stream: %w", err)
+	}
+	defer reader.Release()
+
+	db, err := openDuckDB(dbFilePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	// The view only lives on this connection and can be scanned once
+	const view = "__arrow_import"
+	var release func()
+	err = conn.Raw(func(driverConn any) error {
+		arrow, err := duckdb.NewArrowFromConn(driverConn.(driver.Conn))
+		if err != nil {
+			return err
+		}
+		release, err = arrow.RegisterView(reader, view)
+		if err != nil {
+			return fmt.Errorf("failed to register Arrow stream: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if _, err := loadIntoTable(ctx, conn, tableName, view); err != nil {
+		return err
+	}
+
+	conn.Close()
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return d.StoreDuckDB(dbFilePath)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestArrowRoundTrip(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx := context.Background()
+	query := "SELECT id, name, created_at, CASE WHEN id = 2 THEN NULL ELSE id * 1.5 END AS score, [id, id + 1] AS ids FROM users"
+	if err := s.ExportQueryToArrow(ctx, query, "users.arrow"); err != nil {
+		t.Fatalf("ExportQueryToArrow: %v", err)
+	}
+	info, err := s.obs.GetInfo("users.arrow")
+	if err != nil {
+		t.Fatalf("GetInfo of the export: %v", err)
+	}
+	if got := info.Headers.Get("Content-Type"); got != arrowContentType {
+		t.Errorf("Content-Type = %q, want %q", got, arrowContentType)
+	}
+
+	imported := filepath.Join(t.TempDir(), "imported.db")
+	target := newTestStorage(t, s.nc, WithDBName("imported.db"))
+	if err := target.ImportArrowToTable(ctx, "users.arrow", imported, "users"); err != nil {
+		t.Fatalf("ImportArrowToTable: %v", err)
+	}
+
+	rowQuery := "SELECT concat_ws('|', id, name, created_at, coalesce(score::VARCHAR, 'null'), ids::VARCHAR, typeof(score), typeof(ids)) FROM (%s) ORDER BY 1"
+	want := queryTestStrings(t, path, fmt.Sprintf(rowQuery, query))
+	got := queryTestStrings(t, imported, fmt.Sprintf(rowQuery, "SELECT * FROM users"))
+	if !slices.Equal(got, want) {
+		t.Errorf("imported rows = %q, want %q", got, want)
+	}
+
+	// Importing again appends, and the stored database holds the table
+	if err := target.ImportArrowToTable(ctx, "users.arrow", imported, "users"); err != nil {
+		t.Fatalf("second ImportArrowToTable: %v", err)
+	}
+	var count int64
+	if err := target.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&count); err != nil {
+		t.Fatalf("QueryRow: %v", err)
+	}
+	if count != 6 {
+		t.Errorf("stored table has %d rows after two imports, want 6", count)
+	}
+}
+
+func TestImportArrowMissing(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	err := s.ImportArrowToTable(context.Background(), "missing.arrow", filepath.Join(t.TempDir(), "t.db"), "t")
+	if err == nil {
+		t.Error("ImportArrowToTable of a missing object succeeded")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+func (d *DuckDBStorage) stagingName() string {
+	return d.dbName + ".__staging"
+}
+
+// StoreAtomic uploads the database to a staging key and verifies what NATS holds before storing
+// it like ForceStore. A database that fails verification is never stored, and a failed store
+// restores the previous object, or keeps the previous chunks of a chunked database.
+func (d *DuckDBStorage) StoreAtomic(dbFilePath string, lock ...Lock) (err error) {
+	op := d.logOperation("store_atomic", "path", dbFilePath)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := d.lifetime(context.Background())
+	defer cancel()
+	defer func() {
+		err = d.closedErr(err)
+		end(err)
+	}()
+
+	if err := d.checkLock(lock); err != nil {
+		return err
+	}
+	if err := d.stage(ctx, dbFilePath); err != nil {
+		return err
+	}
+	return d.storeFile(ctx, dbFilePath, false, d.opts.ChunkSize, lock, nil)
+}
+
+// stage uploads the database file to the staging key, reads it back and compares its hash with
+// the file. The staging object is removed again, so it does not count against the quota of the
+// store that follows.
+func (d *DuckDBStorage) stage(ctx context.Context, dbFilePath string) (err error) {
+	expected, err := hashFile(dbFilePath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := d.putDatabase(ctx, d.stagingName(), dbFilePath, nil); err != nil {
+		return fmt.Errorf("failed to stage database: %w", err)
+	}
+	defer func() {
+		if deleteErr := d.obs.Delete(d.stagingName()); deleteErr != nil && !errors.Is(deleteErr, nats.ErrObjectNotFound) {
+			err = errors.Join(err, fmt.Errorf("failed to delete staging object: %w", deleteErr))
+		}
+	}()
+
+	// Read the staged bytes back so what is verified is what NATS actually holds
+	reader, _, err := d.openObject(ctx, d.stagingName())
+	if err != nil {
+		return err
+	}
+	actual, err := hashReader(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+	if err := verifyChecksum(expected, actual); err != nil {
+		return fmt.Errorf("staged database failed verification: %w", err)
+	}
+	return nil
+}
+
+// LastGoodVersion returns the info of the object currently live under the production key
+func (d *DuckDBStorage) LastGoodVersion() (_ *nats.ObjectInfo, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	return d.obs.GetInfo(d.dbName)
+}
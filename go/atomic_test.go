@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+// corruptingObjectStore flips the first byte read from the staging object
+type corruptingObjectStore struct {
+	nats.ObjectStore
+}
+
+func (o corruptingObjectStore) Get(name string, opts ...nats.GetObjectOpt) (nats.ObjectResult, error) {
+	result, err := o.ObjectStore.Get(name, opts...)
+	if err != nil || !strings.HasSuffix(name, ".__staging") {
+		return result, err
+	}
+	return &corruptObjectResult{ObjectResult: result}, nil
+}
+
+type corruptObjectResult struct {
+	nats.ObjectResult
+	corrupted bool
+}
+
+func (r *corruptObjectResult) Read(p []byte) (int, error) {
+	n, err := r.ObjectResult.Read(p)
+	if n > 0 && !r.corrupted {
+		p[0] ^= 0xff
+		r.corrupted = true
+	}
+	return n, err
+}
+
+// assertNoStaging fails if the staging object of s was left behind
+func assertNoStaging(t *testing.T, s *DuckDBStorage) {
+	t.Helper()
+	if _, err := s.obs.GetInfo(s.stagingName()); !errors.Is(err, nats.ErrObjectNotFound) {
+		t.Errorf("staging object left behind: %v", err)
+	}
+}
+
+func TestStoreAtomic(t *testing.T) {
+	for _, chunkSize := range []int64{0, 16 << 10} {
+		s, path := storeTestDatabase(t, WithChunkSize(chunkSize), WithRevisionHistory(5))
+		execTestDatabase(t, path, "INSERT INTO users VALUES (4, 'Dave', now())")
+
+		if err := s.StoreAtomic(path); err != nil {
+			t.Fatalf("StoreAtomic with chunk size %d: %v", chunkSize, err)
+		}
+		assertNoStaging(t, s)
+
+		out := filepath.Join(t.TempDir(), "out.db")
+		if err := s.RetrieveDuckDB(out); err != nil {
+			t.Fatalf("RetrieveDuckDB: %v", err)
+		}
+		if got := queryTestInt(t, out, "SELECT count(*) FROM users"); got != 4 {
+			t.Errorf("chunk size %d: stored database has %d users, want 4", chunkSize, got)
+		}
+		if chunkSize > 0 {
+			if _, err := s.getManifest(); err != nil {
+				t.Errorf("StoreAtomic ignored the chunk size: %v", err)
+			}
+		} else if revisions, err := s.ListRevisions(); err != nil || len(revisions) != 2 {
+			t.Errorf("ListRevisions() = %d revisions, %v, want 2", len(revisions), err)
+		}
+	}
+}
+
+func TestStoreAtomicVerificationFailure(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	before, err := s.obs.GetInfo(s.dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	execTestDatabase(t, path, "INSERT INTO users VALUES (4, 'Dave', now())")
+
+	obs := s.obs
+	s.obs = corruptingObjectStore{obs}
+	if err := s.StoreAtomic(path); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("StoreAtomic: got %v, want ErrChecksumMismatch", err)
+	}
+	s.obs = obs
+
+	after, err := s.obs.GetInfo(s.dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.NUID != before.NUID {
+		t.Error("production object replaced by a database that failed verification")
+	}
+	assertNoStaging(t, s)
+}
+
+func TestStoreAtomicChecks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	size := testDatabaseSize(t, path)
+
+	s := newTestStorage(t, startTestServer(t), WithLockEnforcement(true))
+	if err := s.StoreAtomic(path); !errors.Is(err, ErrLockRequired) {
+		t.Errorf("StoreAtomic without a lock: got %v, want ErrLockRequired", err)
+	}
+	assertNoStaging(t, s)
+
+	s = newTestStorage(t, startTestServer(t), WithStorageQuota(size/2))
+	if err := s.StoreAtomic(path); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("StoreAtomic over the quota: got %v, want ErrQuotaExceeded", err)
+	}
+	assertNoStaging(t, s)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// auditCallerDepth is the number of stack frames recorded in an audit event
+const auditCallerDepth = 16
+
+// AuditEvent is published to the audit subject after each audited storage operation
+type AuditEvent struct {
+	Operation  string    `json:"operation"`
+	DBName     string    `json:"db_name"`
+	Bucket     string    `json:"bucket"`
+	Timestamp  time.Time `json:"timestamp"`
+	DurationMS int64     `json:"duration_ms"`
+	// SizeBytes is the size of the database file moved by the operation, -1 if unknown
+	SizeBytes int64 `json:"size_bytes"`
+	// Caller lists the calling stack frames, innermost first, as "function file:line"
+	Caller []string `json:"caller"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// AuditEventFromMsg decodes an audit event received from the audit subject
+func AuditEventFromMsg(msg *nats.Msg) (AuditEvent, error) {
+	var event AuditEvent
+	if err := json.Unmarshal(msg.Data, &event); err != nil {
+		return event, fmt.Errorf("failed to decode audit event: %w", err)
+	}
+	return event, nil
+}
+
+// audit publishes the outcome of an operation to the audit subject when one is configured.
+// Publishing is best effort, a failure is logged and does not fail the operation.
+func (d *DuckDBStorage) audit(operation string, start time.Time, size int64, err error) {
+	if d.opts.AuditSubject == "" {
+		return
+	}
+
+	event := AuditEvent{
+		Operation:  operation,
+		DBName:     d.dbName,
+		Bucket:     d.bucket,
+		Timestamp:  time.Now().UTC(),
+		DurationMS: time.Since(start).Milliseconds(),
+		SizeBytes:  size,
+		Caller:     callers(3),
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		d.opts.Logger.Error("failed to encode audit event", "operation", operation, "error", err)
+		return
+	}
+	if _, err := d.js.Publish(d.opts.AuditSubject, data); err != nil {
+		d.opts.Logger.Error("failed to publish audit event", "operation", operation, "subject", d.opts.AuditSubject, "error", err)
+	}
+}
+
+// callers returns the stack of the calling goroutine as "function file:line" entries,
+// skipping the given number of frames
+func callers(skip int) []string {
+	pcs := make([]uintptr, auditCallerDepth)
+	n := runtime.Callers(skip+1, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []string
+	for {
+		frame, more := frames.Next()
+		stack = append(stack, fmt.Sprintf("%s %s:%d", frame.Function, frame.File, frame.Line))
+		if !more {
+			break
+		}
+	}
+	return stack
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestAuditEvents(t *testing.T) {
+	nc := startTestServer(t)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "AUDIT", Subjects: []string{"audit.>"}}); err != nil {
+		t.Fatalf("failed to create audit stream: %v", err)
+	}
+	sub, err := js.SubscribeSync("audit.duckdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestStorage(t, nc, WithAuditSubject("audit.duckdb"))
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); err != nil {
+		t.Fatalf("RetrieveDuckDB: %v", err)
+	}
+	if err := s.DeleteDatabase(); err != nil {
+		t.Fatalf("DeleteDatabase: %v", err)
+	}
+	var n int
+	if err := s.QueryRow(context.Background(), "SELECT count(*) FROM users").Scan(&n); err == nil {
+		t.Fatal("query of a deleted database succeeded")
+	}
+
+	for _, want := range []string{opStore, opRetrieve, opDelete, opQuery} {
+		msg, err := sub.NextMsg(time.Second)
+		if err != nil {
+			t.Fatalf("no %s event: %v", want, err)
+		}
+		event, err := AuditEventFromMsg(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Operation != want {
+			t.Fatalf("event operation = %q, want %q", event.Operation, want)
+		}
+		if event.DBName != defaultDBName || event.Bucket != defaultBucket {
+			t.Errorf("%s event for %s/%s, want %s/%s", want, event.Bucket, event.DBName, defaultBucket, defaultDBName)
+		}
+		if event.Timestamp.IsZero() {
+			t.Errorf("%s event has no timestamp", want)
+		}
+		if !strings.Contains(strings.Join(event.Caller, "\n"), "TestAuditEvents") {
+			t.Errorf("%s event caller does not include the test: %v", want, event.Caller)
+		}
+
+		switch want {
+		case opStore, opRetrieve:
+			if event.SizeBytes <= 0 || event.Error != "" {
+				t.Errorf("%s event size = %d, error = %q, want a size and no error", want, event.SizeBytes, event.Error)
+			}
+		case opQuery:
+			if event.Error == "" {
+				t.Errorf("%s event has no error", want)
+			}
+		}
+	}
+}
+
+func TestAuditEventFromMsgInvalid(t *testing.T) {
+	if _, err := AuditEventFromMsg(&nats.Msg{Data: []byte("not json")}); err == nil {
+		t.Error("AuditEventFromMsg accepted invalid JSON")
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// PAX records holding the object metadata in the archives written by BackupBucket
+const (
+	backupHeadersRecord     = "NATS.headers"
+	backupDescriptionRecord = "NATS.description"
+)
+
+// BackupBucket writes every object of the bucket to a tar.gz archive at archivePath, one entry
+// per object named after it. The object headers and description are kept as PAX records so
+// RestoreBucket can restore them. Links are archived with the content they point to.
+func (d *DuckDBStorage) BackupBucket(ctx context.Context, archivePath string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	count := 0
+	op := d.logOperation("backup_bucket", "path", archivePath)
+	defer func() { op.done(err, "objects", count) }()
+
+	objects, err := d.obs.List(nats.Context(ctx))
+	if err != nil && !errors.Is(err, nats.ErrNoObjectsFound) {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+	slices.SortFunc(objects, func(a, b *nats.ObjectInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	out, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", archivePath, err)
+	}
+	defer func() {
+		out.Close()
+		if err != nil {
+			os.Remove(archivePath)
+		}
+	}()
+
+	gz := gzip.NewWriter(out)
+	w := tar.NewWriter(gz)
+	for _, info := range objects {
+		if err := d.backupObject(ctx, w, info); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", archivePath, err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", archivePath, err)
+	}
+	return out.Close()
+}
+
+// backupObject adds the content and metadata of an object to w
+func (d *DuckDBStorage) backupObject(ctx context.Context, w *tar.Writer, info *nats.ObjectInfo) error {
+	result, err := d.obs.Get(info.Name, nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", info.Name, err)
+	}
+	defer result.Close()
+
+	// The size of a link is that of the object it points to
+	stored, err := result.Info()
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", info.Name, err)
+	}
+	headers, err := json.Marshal(info.Headers)
+	if err != nil {
+		return fmt.Errorf("failed to encode headers of %s: %w", info.Name, err)
+	}
+
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     info.Name,
+		Size:     int64(stored.Size),
+		Mode:     0644,
+		ModTime:  info.ModTime,
+		Format:   tar.FormatPAX,
+		PAXRecords: map[string]string{
+			backupHeadersRecord:     string(headers),
+			backupDescriptionRecord: info.Description,
+		},
+	}
+	if err := w.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", info.Name, err)
+	}
+	if _, err := io.Copy(w, newCtxReader(ctx, result)); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", info.Name, err)
+	}
+	return nil
+}
+
+// RestoreBucket uploads the objects of an archive written by BackupBucket to the bucket with
+// their original headers and description. Objects that already exist are skipped unless
+// overwrite is set. It returns the number of objects uploaded.
+func (d *DuckDBStorage) RestoreBucket(ctx context.Context, archivePath string, overwrite bool) (restored int, err error) {
+	op := d.logOperation("restore_bucket", "path", archivePath, "overwrite", overwrite)
+	defer func() { op.done(err, "objects", restored) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return restored, err
+	}
+	defer func() { end(err) }()
+
+	in, err := os.Open(archivePath)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", archivePath, err)
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", archivePath, err)
+	}
+	defer gz.Close()
+
+	r := tar.NewReader(gz)
+	for {
+		hdr, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return restored, nil
+		}
+		if err != nil {
+			return restored, fmt.Errorf("failed to read %s: %w", archivePath, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		if !overwrite {
+			_, err := d.obs.GetInfo(hdr.Name, nats.Context(ctx))
+			if err == nil {
+				continue
+			}
+			if !errors.Is(err, nats.ErrObjectNotFound) {
+				return restored, fmt.Errorf("failed to get %s: %w", hdr.Name, err)
+			}
+		}
+
+		meta := &nats.ObjectMeta{
+			Name:        hdr.Name,
+			Description: hdr.PAXRecords[backupDescriptionRecord],
+		}
+		if headers := hdr.PAXRecords[backupHeadersRecord]; headers != "" {
+			if err := json.Unmarshal([]byte(headers), &meta.Headers); err != nil {
+				return restored, fmt.Errorf("failed to decode headers of %s: %w", hdr.Name, err)
+			}
+		}
+		if _, err := d.obs.Put(meta, newCtxReader(ctx, r), nats.Context(ctx)); err != nil {
+			return restored, fmt.Errorf("failed to restore %s: %w", hdr.Name, err)
+		}
+		restored++
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestBackupRestoreBucket(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithCompression(CompressionGzip))
+	ctx := context.Background()
+	_, err := s.obs.Put(&nats.ObjectMeta{
+		Name:        "reports/summary.txt",
+		Description: "summary",
+		Headers:     nats.Header{"X-Report": []string{"a", "b"}},
+	}, strings.NewReader("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := filepath.Join(t.TempDir(), "bucket.tar.gz")
+	if err := s.BackupBucket(ctx, archive); err != nil {
+		t.Fatalf("BackupBucket: %v", err)
+	}
+	objects, err := s.obs.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range objects {
+		if err := s.obs.Delete(info.Name); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restored, err := s.RestoreBucket(ctx, archive, false)
+	if err != nil {
+		t.Fatalf("RestoreBucket: %v", err)
+	}
+	if restored != 2 {
+		t.Errorf("restored %d objects, want 2", restored)
+	}
+	var n int
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&n); err != nil || n != 3 {
+		t.Errorf("restored database holds %d users, %v, want 3", n, err)
+	}
+	info, err := s.obs.GetInfo("reports/summary.txt")
+	if err != nil {
+		t.Fatalf("restored object missing: %v", err)
+	}
+	if info.Description != "summary" || !slices.Equal(info.Headers["X-Report"], []string{"a", "b"}) {
+		t.Errorf("restored metadata = %q, %v, want the original", info.Description, info.Headers)
+	}
+	if data, err := s.obs.GetString("reports/summary.txt"); err != nil || data != "hello" {
+		t.Errorf("restored content = %q, %v, want hello", data, err)
+	}
+
+	// Existing objects are only replaced with overwrite
+	if restored, err := s.RestoreBucket(ctx, archive, false); err != nil || restored != 0 {
+		t.Errorf("RestoreBucket into a full bucket = %d, %v, want 0", restored, err)
+	}
+	if restored, err := s.RestoreBucket(ctx, archive, true); err != nil || restored != 2 {
+		t.Errorf("RestoreBucket with overwrite = %d, %v, want 2", restored, err)
+	}
+}
+
+func TestBackupBucketArchive(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	archive := filepath.Join(t.TempDir(), "bucket.tar.gz")
+	if err := s.BackupBucket(context.Background(), archive); err != nil {
+		t.Fatalf("BackupBucket: %v", err)
+	}
+
+	f, err := os.Open(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hdr, err := tar.NewReader(gz).Next()
+	if err != nil {
+		t.Fatalf("failed to read archive: %v", err)
+	}
+	if hdr.Name != defaultDBName || !strings.Contains(hdr.PAXRecords[backupHeadersRecord], checksumHeader) {
+		t.Errorf("archive entry %s with records %v, want %s with its headers", hdr.Name, hdr.PAXRecords, defaultDBName)
+	}
+}
+
+func TestBackupBucketEmpty(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	ctx := context.Background()
+	archive := filepath.Join(t.TempDir(), "empty.tar.gz")
+	if err := s.BackupBucket(ctx, archive); err != nil {
+		t.Fatalf("BackupBucket of an empty bucket: %v", err)
+	}
+	if restored, err := s.RestoreBucket(ctx, archive, false); err != nil || restored != 0 {
+		t.Errorf("RestoreBucket of an empty archive = %d, %v, want 0", restored, err)
+	}
+	if _, err := s.RestoreBucket(ctx, filepath.Join(t.TempDir(), "missing.tar.gz"), false); err == nil {
+		t.Error("RestoreBucket of a missing archive succeeded")
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// defaultBatchParallelism is the default number of concurrent transfers in a batch
+const defaultBatchParallelism = 4
+
+// BatchItem is the outcome of a single transfer in a batch
+type BatchItem struct {
+	Name     string
+	Path     string
+	Size     int64
+	Duration time.Duration
+	Err      error
+}
+
+// BatchResult reports every transfer of a batch. It implements error, listing the failed items,
+// but should be checked with Err since a successful batch is still a non-nil value.
+type BatchResult struct {
+	Items      []BatchItem
+	Succeeded  int
+	Failed     int
+	TotalBytes int64
+	Duration   time.Duration
+}
+
+func (r BatchResult) Error() string {
+	var failures []string
+	for _, item := range r.Items {
+		if item.Err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", item.Name, item.Err))
+		}
+	}
+	return fmt.Sprintf("%d of %d batch items failed: %s", r.Failed, len(r.Items), strings.Join(failures, "; "))
+}
+
+// Err returns the result as an error if any item failed, and nil otherwise
+func (r BatchResult) Err() error {
+	if r.Failed == 0 {
+		return nil
+	}
+	return r
+}
+
+// StoreBatch stores several database files concurrently. files maps object names to file
+// paths. A failing item does not cancel the others.
+func (d *DuckDBStorage) StoreBatch(ctx context.Context, files map[string]string) BatchResult {
+	return d.runBatch(ctx, files, func(ctx context.Context, name, path string) (int64, error) {
+		info, err := d.putDatabase(ctx, name, path, nil)
+		if err != nil {
+			return 0, err
+		}
+		return int64(info.Size), nil
+	})
+}
+
+// RetrieveBatch retrieves several databases concurrently. names maps object names to output
+// paths. A failing item does not cancel the others.
+func (d *DuckDBStorage) RetrieveBatch(ctx context.Context, names map[string]string) BatchResult {
+	return d.runBatch(ctx, names, func(ctx context.Context, name, path string) (int64, error) {
+		if err := d.getDatabase(ctx, name, path); err != nil {
+			return 0, err
+		}
+		return fileSize(path), nil
+	})
+}
+
+// runBatch runs transfer for every name and path with at most BatchParallelism in flight
+func (d *DuckDBStorage) runBatch(ctx context.Context, items map[string]string, transfer func(ctx context.Context, name, path string) (int64, error)) BatchResult {
+	start := time.Now()
+
+	var (
+		mu     sync.Mutex
+		result BatchResult
+	)
+
+	// The group only bounds concurrency, errors are collected per item instead of cancelling
+	var g errgroup.Group
+	g.SetLimit(max(d.opts.BatchParallelism, 1))
+
+	for name, path := range items {
+		g.Go(func() error {
+			item := BatchItem{Name: name, Path: path}
+			itemStart := time.Now()
+			if err := ctx.Err(); err != nil {
+				item.Err = err
+			} else {
+				item.Size, item.Err = transfer(ctx, d.objectKey(name), path)
+			}
+			item.Duration = time.Since(itemStart)
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Items = append(result.Items, item)
+			if item.Err != nil {
+				result.Failed++
+			} else {
+				result.Succeeded++
+				result.TotalBytes += item.Size
+			}
+			return nil
+		})
+	}
+	g.Wait()
+
+	result.Duration = time.Since(start)
+	return result
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreRetrieveBatch(t *testing.T) {
+	s, path := storeTestDatabase(t, WithBatchParallelism(2))
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	files := map[string]string{}
+	outputs := map[string]string{}
+	for i := 0; i < 5; i++ {
+		name := fmt.Sprintf("batch-%d.db", i)
+		files[name] = path
+		outputs[name] = filepath.Join(dir, name)
+	}
+
+	stored := s.StoreBatch(ctx, files)
+	if err := stored.Err(); err != nil {
+		t.Fatalf("StoreBatch: %v", err)
+	}
+	if stored.Succeeded != 5 || len(stored.Items) != 5 || stored.TotalBytes <= 0 {
+		t.Errorf("StoreBatch = %+v", stored)
+	}
+
+	retrieved := s.RetrieveBatch(ctx, outputs)
+	if err := retrieved.Err(); err != nil {
+		t.Fatalf("RetrieveBatch: %v", err)
+	}
+	if retrieved.Succeeded != 5 || retrieved.TotalBytes != 5*int64(len(want)) {
+		t.Errorf("RetrieveBatch = %+v", retrieved)
+	}
+	for name, out := range outputs {
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s does not match the stored database", name)
+		}
+	}
+}
+
+func TestRetrieveBatchPartialFailure(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	dir := t.TempDir()
+	result := s.RetrieveBatch(context.Background(), map[string]string{
+		s.dbName:     filepath.Join(dir, "ok.db"),
+		"missing.db": filepath.Join(dir, "missing.db"),
+	})
+	if result.Succeeded != 1 || result.Failed != 1 {
+		t.Fatalf("RetrieveBatch = %+v, want one success and one failure", result)
+	}
+
+	var batchErr BatchResult
+	if err := result.Err(); !errors.As(err, &batchErr) {
+		t.Fatalf("Err() = %v, want a BatchResult", err)
+	}
+	for _, item := range result.Items {
+		if (item.Name == "missing.db") != (item.Err != nil) {
+			t.Errorf("item %s: error %v", item.Name, item.Err)
+		}
+	}
+}
+
+func TestStoreBatchCancelled(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result := s.StoreBatch(ctx, map[string]string{"a.db": path, "b.db": path})
+	if result.Failed != 2 {
+		t.Fatalf("StoreBatch with a cancelled context = %+v", result)
+	}
+	for _, item := range result.Items {
+		if !errors.Is(item.Err, context.Canceled) {
+			t.Errorf("item %s: got %v, want context.Canceled", item.Name, item.Err)
+		}
+	}
+}
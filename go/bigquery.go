@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// bigQuerySchemaHeader carries the BigQuery schema of a BigQuery JSON export
+const bigQuerySchemaHeader = "X-BQ-Schema"
+
+// BigQuery column types produced by ExportToBigQueryJSON
+const (
+	bigQueryTimestamp = "TIMESTAMP"
+	bigQueryInteger   = "INTEGER"
+	bigQueryFloat     = "FLOAT"
+	bigQueryString    = "STRING"
+	bigQueryBytes     = "BYTES"
+	bigQueryBool      = "BOOL"
+)
+
+// BigQueryField is a column of a BigQuery schema definition
+type BigQueryField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Mode string `json:"mode"`
+}
+
+// ExportToBigQueryJSON runs a query against the stored database and stores the result as
+// newline-delimited JSON that BigQuery can load, in the object objectName of the same bucket.
+// Timestamps and dates are written as RFC 3339 timestamps in UTC, BLOBs as base64 and types
+// BigQuery has no equivalent for as strings. The JSON-encoded schema is stored in the
+// X-BQ-Schema header.
+func (d *DuckDBStorage) ExportToBigQueryJSON(ctx context.Context, query, objectName string) (err error) {
+	op := d.logOperation("export_bigquery", "query", query, "object", objectName)
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	var count int64
+	defer func() { op.done(err, "rows", count) }()
+
+	rows, err := d.QueryRows(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return fmt.Errorf("failed to read column types: %w", err)
+	}
+	schema := bigQuerySchema(columnTypes)
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return fmt.Errorf("failed to encode BigQuery schema: %w", err)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+
+	values := make([]any, len(schema))
+	pointers := make([]any, len(schema))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return fmt.Errorf("failed to scan row %d: %w", count, err)
+		}
+		row := make(map[string]any, len(schema))
+		for i, field := range schema {
+			row[field.Name] = bigQueryValue(field.Type, columnTypes[i].DatabaseTypeName(), values[i])
+		}
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("failed to encode row %d: %w", count, err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows after %d rows: %w", count, err)
+	}
+
+	_, err = d.obs.Put(&nats.ObjectMeta{
+		Name:        d.objectKey(objectName),
+		Description: "BigQuery JSON export of query results",
+		Headers: nats.Header{
+			"Content-Type":       []string{"application/x-ndjson"},
+			bigQuerySchemaHeader: []string{string(schemaJSON)},
+		},
+	}, &buf, nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to store %s in NATS: %w", objectName, err)
+	}
+	return nil
+}
+
+// bigQuerySchema maps the result columns to BigQuery fields
+func bigQuerySchema(columnTypes []*sql.ColumnType) []BigQueryField {
+	schema := make([]BigQueryField, len(columnTypes))
+	for i, column := range columnTypes {
+		schema[i] = BigQueryField{
+			Name: column.Name(),
+			Type: bigQueryType(column.DatabaseTypeName()),
+			Mode: "NULLABLE",
+		}
+	}
+	return schema
+}
+
+// bigQueryType returns the BigQuery type for a DuckDB type name
+func bigQueryType(duckDBType string) string {
+	switch {
+	case strings.HasPrefix(duckDBType, "TIMESTAMP"), duckDBType == "DATE":
+		return bigQueryTimestamp
+	case strings.HasPrefix(duckDBType, "DECIMAL"), duckDBType == "FLOAT", duckDBType == "DOUBLE":
+		return bigQueryFloat
+	case duckDBType == "BOOLEAN":
+		return bigQueryBool
+	case duckDBType == "BLOB":
+		return bigQueryBytes
+	}
+
+	switch strings.TrimPrefix(duckDBType, "U") {
+	case "TINYINT", "SMALLINT", "INTEGER", "BIGINT", "HUGEINT":
+		return bigQueryInteger
+	}
+	return bigQueryString
+}
+
+// bigQueryValue converts a scanned value of a DuckDB column to its JSON form for a column of
+// the BigQuery type
+func bigQueryValue(bigQueryType, duckDBType string, value any) any {
+	if value == nil {
+		return nil
+	}
+
+	switch bigQueryType {
+	case bigQueryTimestamp:
+		if t, ok := value.(time.Time); ok {
+			return t.UTC().Format(time.RFC3339Nano)
+		}
+	case bigQueryBytes:
+		// encoding/json writes byte slices as base64
+		return value
+	case bigQueryString:
+		switch v := value.(type) {
+		case string:
+			return v
+		case []byte:
+			if duckDBType == "UUID" && len(v) == 16 {
+				return fmt.Sprintf("%x-%x-%x-%x-%x", v[0:4], v[4:6], v[6:8], v[8:10], v[10:])
+			}
+			return string(v)
+		case fmt.Stringer:
+			return v.String()
+		}
+		// Lists, structs and maps are written as their JSON text
+		if data, err := json.Marshal(jsonValue(value)); err == nil {
+			return string(data)
+		}
+		return fmt.Sprint(value)
+	}
+	return jsonValue(value)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBigQueryType(t *testing.T) {
+	tests := []struct {
+		duckDBType string
+		want       string
+	}{
+		{"TINYINT", bigQueryInteger},
+		{"UBIGINT", bigQueryInteger},
+		{"HUGEINT", bigQueryInteger},
+		{"DOUBLE", bigQueryFloat},
+		{"DECIMAL(10,2)", bigQueryFloat},
+		{"VARCHAR", bigQueryString},
+		{"BLOB", bigQueryBytes},
+		{"BOOLEAN", bigQueryBool},
+		{"DATE", bigQueryTimestamp},
+		{"TIMESTAMP WITH TIME ZONE", bigQueryTimestamp},
+		{"UUID", bigQueryString},
+		{"INTERVAL", bigQueryString},
+	}
+	for _, tt := range tests {
+		if got := bigQueryType(tt.duckDBType); got != tt.want {
+			t.Errorf("bigQueryType(%s) = %s, want %s", tt.duckDBType, got, tt.want)
+		}
+	}
+}
+
+func TestExportToBigQueryJSON(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "types.db")
+	execTestDatabase(t, path, `CREATE TABLE t AS SELECT
+		1::TINYINT AS tiny, 2::BIGINT AS big, 3::UBIGINT AS ubig, 7::HUGEINT AS huge,
+		1.5::DOUBLE AS dbl, 1.5::FLOAT AS flt, 2.25::DECIMAL(10,2) AS dec,
+		'x' AS str, 'ab'::BLOB AS bin, true AS flag,
+		TIMESTAMP '2024-01-02 03:04:05.123' AS ts, DATE '2024-05-06' AS day,
+		TIMESTAMPTZ '2024-01-02 03:04:05+02' AS tstz,
+		'6ba7b810-9dad-11d1-80b4-00c04fd430c8'::UUID AS id,
+		[1, 2] AS list, {'q': 1} AS struct, NULL::INTEGER AS missing`)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+
+	if err := s.ExportToBigQueryJSON(context.Background(), "SELECT * FROM t", "export.json"); err != nil {
+		t.Fatalf("ExportToBigQueryJSON: %v", err)
+	}
+	info, err := s.obs.GetInfo("export.json")
+	if err != nil {
+		t.Fatalf("failed to get export: %v", err)
+	}
+	var schema []BigQueryField
+	if err := json.Unmarshal([]byte(info.Headers.Get(bigQuerySchemaHeader)), &schema); err != nil {
+		t.Fatalf("failed to decode schema header: %v", err)
+	}
+	want := []BigQueryField{
+		{"tiny", bigQueryInteger, "NULLABLE"},
+		{"big", bigQueryInteger, "NULLABLE"},
+		{"ubig", bigQueryInteger, "NULLABLE"},
+		{"huge", bigQueryInteger, "NULLABLE"},
+		{"dbl", bigQueryFloat, "NULLABLE"},
+		{"flt", bigQueryFloat, "NULLABLE"},
+		{"dec", bigQueryFloat, "NULLABLE"},
+		{"str", bigQueryString, "NULLABLE"},
+		{"bin", bigQueryBytes, "NULLABLE"},
+		{"flag", bigQueryBool, "NULLABLE"},
+		{"ts", bigQueryTimestamp, "NULLABLE"},
+		{"day", bigQueryTimestamp, "NULLABLE"},
+		{"tstz", bigQueryTimestamp, "NULLABLE"},
+		{"id", bigQueryString, "NULLABLE"},
+		{"list", bigQueryString, "NULLABLE"},
+		{"struct", bigQueryString, "NULLABLE"},
+		{"missing", bigQueryInteger, "NULLABLE"},
+	}
+	if !reflect.DeepEqual(schema, want) {
+		t.Errorf("schema = %v, want %v", schema, want)
+	}
+
+	result, err := s.obs.Get("export.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Close()
+	scanner := bufio.NewScanner(result)
+	var rows []map[string]any
+	for scanner.Scan() {
+		var row map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+			t.Fatalf("invalid JSON line %q: %v", scanner.Text(), err)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("exported %d rows, want 1", len(rows))
+	}
+
+	wantValues := map[string]any{
+		"huge":    float64(7),
+		"dec":     2.25,
+		"bin":     "YWI=",
+		"flag":    true,
+		"ts":      "2024-01-02T03:04:05.123Z",
+		"day":     "2024-05-06T00:00:00Z",
+		"tstz":    "2024-01-02T01:04:05Z",
+		"id":      "6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+		"list":    "[1,2]",
+		"struct":  `{"q":1}`,
+		"missing": nil,
+	}
+	for column, want := range wantValues {
+		if got := rows[0][column]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %#v, want %#v", column, got, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	cb := NewCircuitBreaker(3, 50*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := cb.allow(); err != nil {
+			t.Fatalf("allow after %d failures: %v", i, err)
+		}
+		cb.record(nats.ErrTimeout)
+	}
+	if got := cb.State(); got != CircuitClosed {
+		t.Fatalf("state after 2 failures = %v, want closed", got)
+	}
+
+	// A permanent error proves NATS is reachable and resets the count
+	cb.record(os.ErrNotExist)
+	for i := 0; i < 3; i++ {
+		cb.record(nats.ErrTimeout)
+	}
+	if got := cb.State(); got != CircuitOpen {
+		t.Fatalf("state after 3 failures = %v, want open", got)
+	}
+	if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("allow while open: got %v, want ErrCircuitOpen", err)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if got := cb.State(); got != CircuitHalfOpen {
+		t.Fatalf("state after cooldown = %v, want half-open", got)
+	}
+	if err := cb.allow(); err != nil {
+		t.Fatalf("trial call: %v", err)
+	}
+	if err := cb.allow(); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("second call during the trial: got %v, want ErrCircuitOpen", err)
+	}
+	cb.record(nats.ErrTimeout)
+	if got := cb.State(); got != CircuitOpen {
+		t.Fatalf("state after a failed trial = %v, want open", got)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if err := cb.allow(); err != nil {
+		t.Fatalf("trial call: %v", err)
+	}
+	cb.record(nil)
+	if got := cb.State(); got != CircuitClosed {
+		t.Fatalf("state after a successful trial = %v, want closed", got)
+	}
+}
+
+func TestCircuitBreakerNil(t *testing.T) {
+	var cb *CircuitBreaker
+	cb.record(nats.ErrTimeout)
+	if err := cb.allow(); err != nil {
+		t.Errorf("nil breaker rejected a call: %v", err)
+	}
+	if got := cb.State(); got != CircuitClosed {
+		t.Errorf("nil breaker state = %v, want closed", got)
+	}
+}
+
+func TestStorageCircuitBreaker(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithCircuitBreaker(3, 100*time.Millisecond))
+	ctx := context.Background()
+	out := filepath.Join(t.TempDir(), "out.db")
+
+	op := &flakyOperation{failures: 3, err: nats.ErrTimeout}
+	for i := 0; i < 3; i++ {
+		s.withRetry(ctx, op.run)
+	}
+	if got := s.CircuitState(); got != CircuitOpen {
+		t.Fatalf("state after 3 failures = %v, want open", got)
+	}
+	if err := s.RetrieveDuckDB(out); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("RetrieveDuckDB while open: got %v, want ErrCircuitOpen", err)
+	}
+	if op.calls != 3 {
+		t.Errorf("%d calls made, want 3", op.calls)
+	}
+
+	time.Sleep(120 * time.Millisecond)
+	if got := s.CircuitState(); got != CircuitHalfOpen {
+		t.Fatalf("state after cooldown = %v, want half-open", got)
+	}
+	if err := s.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB after cooldown: %v", err)
+	}
+	if got := s.CircuitState(); got != CircuitClosed {
+		t.Errorf("state after a successful request = %v, want closed", got)
+	}
+}
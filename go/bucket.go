@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// openObjectStore binds to the configured bucket, creating it first when AutoCreateBucket is
+// set. Only an existing bucket is treated as success when creating; any other failure, such as
+// missing permissions, is returned.
+func openObjectStore(js nats.JetStreamContext, options StorageOptions) (nats.ObjectStore, error) {
+	if !options.AutoCreateBucket {
+		obs, err := js.ObjectStore(options.Bucket)
+		if errors.Is(err, nats.ErrStreamNotFound) {
+			return nil, fmt.Errorf("%w: %s", nats.ErrBucketNotFound, options.Bucket)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get object store: %w", err)
+		}
+		return obs, nil
+	}
+
+	obs, err := js.CreateObjectStore(&nats.ObjectStoreConfig{
+		Bucket:      options.Bucket,
+		Description: options.Description,
+		TTL:         options.TTL,
+		Storage:     options.StorageType,
+		Replicas:    options.Replicas,
+		MaxBytes:    options.MaxBucketSize,
+	})
+	if errors.Is(err, nats.ErrStreamNameAlreadyInUse) {
+		// The bucket exists with a different configuration, use it as it is
+		obs, err = js.ObjectStore(options.Bucket)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create/get object store: %w", err)
+	}
+	return obs, nil
+}
+
+// BucketStats summarizes every object of the bucket, across all namespaces
+type BucketStats struct {
+	TotalObjects     int
+	TotalBytes       int64
+	OldestObject     time.Time
+	NewestObject     time.Time
+	AverageSizeBytes int64
+}
+
+// BucketStats lists the bucket and aggregates the object sizes and modification times.
+// Internal objects such as chunks and versions are counted as well.
+func (d *DuckDBStorage) BucketStats() (_ *BucketStats, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	objects, err := d.obs.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return &BucketStats{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	stats := &BucketStats{TotalObjects: len(objects)}
+	for _, info := range objects {
+		stats.TotalBytes += int64(info.Size)
+		if stats.OldestObject.IsZero() || info.ModTime.Before(stats.OldestObject) {
+			stats.OldestObject = info.ModTime
+		}
+		if info.ModTime.After(stats.NewestObject) {
+			stats.NewestObject = info.ModTime
+		}
+	}
+	if stats.TotalObjects > 0 {
+		stats.AverageSizeBytes = stats.TotalBytes / int64(stats.TotalObjects)
+	}
+	return stats, nil
+}
+
+// BucketConfig returns the current configuration of the bucket, which may differ from the
+// options if the bucket was created elsewhere
+func (d *DuckDBStorage) BucketConfig() (_ *nats.ObjectStoreConfig, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	status, err := d.obs.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bucket status: %w", err)
+	}
+
+	config := &nats.ObjectStoreConfig{
+		Bucket:      status.Bucket(),
+		Description: status.Description(),
+		TTL:         status.TTL(),
+		Storage:     status.Storage(),
+		Replicas:    status.Replicas(),
+		Metadata:    status.Metadata(),
+		Compression: status.IsCompressed(),
+	}
+	if bucket, ok := status.(*nats.ObjectBucketStatus); ok {
+		config.MaxBytes = bucket.StreamInfo().Config.MaxBytes
+		config.Placement = bucket.StreamInfo().Config.Placement
+	}
+	return config, nil
+}
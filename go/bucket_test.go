@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+func TestOpenObjectStore(t *testing.T) {
+	nc := startTestServer(t)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: "EXISTING", Description: "created elsewhere"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{"exists", []Option{WithBucket("EXISTING"), WithAutoCreateBucket(false)}, nil},
+		{"exists with other config", []Option{WithBucket("EXISTING"), WithDescription("other")}, nil},
+		{"missing with auto-create", []Option{WithBucket("CREATED")}, nil},
+		{"missing without auto-create", []Option{WithBucket("MISSING"), WithAutoCreateBucket(false)}, nats.ErrBucketNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewDuckDBStorage(nc, tt.opts...)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("NewDuckDBStorage: got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewDuckDBStorage: %v", err)
+			}
+			s.Close(context.Background())
+		})
+	}
+
+	if _, err := js.ObjectStore("MISSING"); err == nil {
+		t.Error("bucket created without auto-create")
+	}
+	if _, err := js.ObjectStore("CREATED"); err != nil {
+		t.Errorf("bucket not created with auto-create: %v", err)
+	}
+}
+
+func TestOpenObjectStoreError(t *testing.T) {
+	// Without JetStream creating the bucket fails for a reason other than it existing
+	srv, err := server.NewServer(&server.Options{Port: -1, NoLog: true, NoSigs: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Start()
+	if !srv.ReadyForConnections(5 * time.Second) {
+		t.Fatal("NATS server did not become ready")
+	}
+	t.Cleanup(srv.Shutdown)
+
+	nc := connectTestServer(t, srv)
+	if _, err := NewDuckDBStorage(nc); err == nil || errors.Is(err, nats.ErrBucketNotFound) {
+		t.Fatalf("NewDuckDBStorage without JetStream: got %v, want the JetStream error", err)
+	}
+	if _, err := NewDuckDBStorage(nc, WithBucket("bad.name")); err == nil {
+		t.Error("NewDuckDBStorage accepted an invalid bucket name")
+	}
+}
+
+func TestBucketStats(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	stats, err := s.BucketStats()
+	if err != nil {
+		t.Fatalf("BucketStats of an empty bucket: %v", err)
+	}
+	if *stats != (BucketStats{}) {
+		t.Errorf("BucketStats of an empty bucket = %+v, want zero", stats)
+	}
+
+	for i, size := range []int{100, 200, 600} {
+		if _, err := s.obs.PutBytes(fmt.Sprintf("object-%d", i), bytes.Repeat([]byte{1}, size)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	stats, err = s.BucketStats()
+	if err != nil {
+		t.Fatalf("BucketStats: %v", err)
+	}
+	if stats.TotalObjects != 3 || stats.TotalBytes != 900 || stats.AverageSizeBytes != 300 {
+		t.Errorf("BucketStats = %+v, want 3 objects of 900 bytes", stats)
+	}
+	if !stats.OldestObject.Before(stats.NewestObject) {
+		t.Errorf("oldest object %v not before newest %v", stats.OldestObject, stats.NewestObject)
+	}
+}
+
+func TestBucketConfig(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t),
+		WithTTL(time.Hour), WithReplicas(1), WithStorageType(nats.MemoryStorage), WithMaxBucketSize(1<<20))
+	config, err := s.BucketConfig()
+	if err != nil {
+		t.Fatalf("BucketConfig: %v", err)
+	}
+	if config.Bucket != defaultBucket || config.TTL != time.Hour || config.Replicas != 1 ||
+		config.Storage != nats.MemoryStorage || config.MaxBytes != 1<<20 {
+		t.Errorf("BucketConfig = %+v", config)
+	}
+}
+
+func TestMemoryStorageBucket(t *testing.T) {
+	nc := startTestServer(t)
+	s := newTestStorage(t, nc, WithBucket("MEMORY"), WithStorageType(nats.MemoryStorage))
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB: %v", err)
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("retrieved %d users, want 3", n)
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := js.AccountInfo()
+	if err != nil {
+		t.Fatalf("AccountInfo: %v", err)
+	}
+	if info.Memory == 0 || info.Store != 0 {
+		t.Errorf("account uses %d bytes of memory and %d of file storage, want only memory", info.Memory, info.Store)
+	}
+}
+
+func TestMaxBucketSize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limit := stat.Size() / 2
+	nc := startTestServer(t)
+
+	t.Run("new object", func(t *testing.T) {
+		s := newTestStorage(t, nc, WithBucket("NEW"), WithMaxBucketSize(limit))
+		if err := s.StoreDuckDB(path); err == nil {
+			t.Fatal("StoreDuckDB stored a database larger than the bucket limit")
+		}
+		if _, err := s.GetInfo(); err == nil {
+			t.Error("rejected database left in the bucket")
+		}
+		config, err := s.BucketConfig()
+		if err != nil {
+			t.Fatalf("BucketConfig: %v", err)
+		}
+		if config.MaxBytes != limit || config.Storage != nats.FileStorage {
+			t.Errorf("BucketConfig = %+v, want a file bucket limited to %d bytes", config, limit)
+		}
+	})
+
+	t.Run("replaced object", func(t *testing.T) {
+		s := newTestStorage(t, nc, WithBucket("REPLACED"), WithMaxBucketSize(limit))
+		small := filepath.Join(t.TempDir(), "small.db")
+		if err := os.WriteFile(small, []byte("small database"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.StoreDuckDB(small); err != nil {
+			t.Fatalf("StoreDuckDB within the limit: %v", err)
+		}
+		if err := s.StoreDuckDB(path); err == nil {
+			t.Fatal("StoreDuckDB stored a database larger than the bucket limit")
+		}
+
+		// The rejected store leaves the previous database in place
+		var buf bytes.Buffer
+		if _, err := s.RetrieveToWriter(context.Background(), &buf); err != nil {
+			t.Fatalf("RetrieveToWriter after the rejected store: %v", err)
+		}
+		if got := buf.String(); got != "small database" {
+			t.Errorf("stored database = %q, want the previous one", got)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// MergeReport counts the objects handled by MergeBuckets
+type MergeReport struct {
+	// Copied is the number of objects written to the target, including overwrites and renames
+	Copied int
+	// Skipped is the number of objects the target already held with the same content
+	Skipped int
+	// Conflicted is the number of objects the target held with different content
+	Conflicted int
+}
+
+// MergeBuckets copies every object of sourceBucket into targetBucket, creating the target if
+// needed. Objects the target already holds with the same content hash are skipped. For objects
+// with different content MergeUpsert and MergeOverwrite replace the target object and
+// MergeInsertOnly keeps it and stores the source object as <name>.conflict-<hash>.
+func MergeBuckets(ctx context.Context, sourceBucket, targetBucket string, nc *nats.Conn, strategy MergeStrategy) (report MergeReport, err error) {
+	if sourceBucket == targetBucket {
+		return report, fmt.Errorf("failed to merge buckets: %s is both source and target", sourceBucket)
+	}
+
+	js, err := nc.JetStream()
+	if err != nil {
+		return report, fmt.Errorf("failed to create JetStream context: %w", err)
+	}
+	source, err := js.ObjectStore(sourceBucket)
+	if err != nil {
+		return report, fmt.Errorf("failed to get object store %s: %w", sourceBucket, err)
+	}
+	target, err := js.ObjectStore(targetBucket)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		target, err = js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: targetBucket})
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to create/get object store %s: %w", targetBucket, err)
+	}
+
+	objects, err := source.List(nats.Context(ctx))
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return report, nil
+	}
+	if err != nil {
+		return report, fmt.Errorf("failed to list objects of %s: %w", sourceBucket, err)
+	}
+
+	for _, info := range objects {
+		name := info.Name
+		existing, err := target.GetInfo(name, nats.Context(ctx))
+		switch {
+		case errors.Is(err, nats.ErrObjectNotFound):
+		case err != nil:
+			return report, fmt.Errorf("failed to get %s from %s: %w", name, targetBucket, err)
+		case existing.Digest == info.Digest:
+			report.Skipped++
+			continue
+		default:
+			report.Conflicted++
+			if strategy == MergeInsertOnly {
+				name = conflictName(info)
+				if renamed, err := target.GetInfo(name, nats.Context(ctx)); err == nil && renamed.Digest == info.Digest {
+					continue
+				}
+			}
+		}
+
+		if _, err := copyObjectTo(ctx, source, info.Name, target, name, nats.Header{
+			"X-Copied-From": []string{sourceBucket + "/" + info.Name},
+		}); err != nil {
+			return report, err
+		}
+		report.Copied++
+	}
+	return report, nil
+}
+
+// conflictName is the name MergeBuckets stores a conflicting object under, derived from its
+// content hash so merging again finds the earlier copy
+func conflictName(info *nats.ObjectInfo) string {
+	hash := strings.TrimPrefix(info.Digest, "SHA-256=")
+	if len(hash) > 12 {
+		hash = hash[:12]
+	}
+	return info.Name + ".conflict-" + hash
+}
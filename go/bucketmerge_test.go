@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+// createTestBucket creates an object store holding objects, mapping names to contents
+func createTestBucket(tb testing.TB, js nats.JetStreamContext, bucket string, objects map[string]string) nats.ObjectStore {
+	tb.Helper()
+	obs, err := js.CreateObjectStore(&nats.ObjectStoreConfig{Bucket: bucket})
+	if err != nil {
+		tb.Fatalf("failed to create object store %s: %v", bucket, err)
+	}
+	for name, content := range objects {
+		if _, err := obs.PutString(name, content); err != nil {
+			tb.Fatalf("failed to put %s: %v", name, err)
+		}
+	}
+	return obs
+}
+
+// bucketContents returns the contents of every object of obs by name
+func bucketContents(tb testing.TB, obs nats.ObjectStore) map[string]string {
+	tb.Helper()
+	objects, err := obs.List()
+	if err != nil {
+		tb.Fatalf("failed to list objects: %v", err)
+	}
+	contents := make(map[string]string, len(objects))
+	for _, info := range objects {
+		content, err := obs.GetString(info.Name)
+		if err != nil {
+			tb.Fatalf("failed to get %s: %v", info.Name, err)
+		}
+		contents[info.Name] = content
+	}
+	return contents
+}
+
+func TestMergeBuckets(t *testing.T) {
+	nc := startTestServer(t)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestBucket(t, js, "SOURCE", map[string]string{"same.db": "1", "changed.db": "2", "source.db": "4"})
+	target := createTestBucket(t, js, "TARGET", map[string]string{"same.db": "1", "changed.db": "3", "target.db": "5"})
+	ctx := context.Background()
+
+	report, err := MergeBuckets(ctx, "SOURCE", "TARGET", nc, MergeInsertOnly)
+	if err != nil {
+		t.Fatalf("MergeBuckets: %v", err)
+	}
+	if want := (MergeReport{Copied: 2, Skipped: 1, Conflicted: 1}); report != want {
+		t.Errorf("report = %+v, want %+v", report, want)
+	}
+	contents := bucketContents(t, target)
+	var conflict string
+	for name := range contents {
+		if strings.HasPrefix(name, "changed.db.conflict-") {
+			conflict = name
+		}
+	}
+	if conflict == "" || contents[conflict] != "2" || contents["changed.db"] != "3" {
+		t.Errorf("target = %v, want changed.db kept and the source copy stored as a conflict", contents)
+	}
+	names := make([]string, 0, len(contents))
+	for name := range contents {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if want := []string{"changed.db", conflict, "same.db", "source.db", "target.db"}; !slices.Equal(names, want) {
+		t.Errorf("target holds %v, want %v", names, want)
+	}
+
+	// Merging again finds the earlier copies
+	report, err = MergeBuckets(ctx, "SOURCE", "TARGET", nc, MergeInsertOnly)
+	if err != nil {
+		t.Fatalf("second MergeBuckets: %v", err)
+	}
+	if report.Copied != 0 {
+		t.Errorf("second merge copied %d objects, want 0", report.Copied)
+	}
+
+	report, err = MergeBuckets(ctx, "SOURCE", "TARGET", nc, MergeOverwrite)
+	if err != nil {
+		t.Fatalf("MergeBuckets with MergeOverwrite: %v", err)
+	}
+	if report.Copied != 1 || report.Conflicted != 1 {
+		t.Errorf("report = %+v, want the conflicting object copied", report)
+	}
+	if content, err := target.GetString("changed.db"); err != nil || content != "2" {
+		t.Errorf("changed.db = %q, %v, want the source content", content, err)
+	}
+}
+
+func TestMergeBucketsNewTarget(t *testing.T) {
+	nc := startTestServer(t)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestBucket(t, js, "SOURCE", map[string]string{"a.db": "1", "b.db": "2"})
+
+	report, err := MergeBuckets(context.Background(), "SOURCE", "NEW", nc, MergeUpsert)
+	if err != nil {
+		t.Fatalf("MergeBuckets: %v", err)
+	}
+	if report.Copied != 2 {
+		t.Errorf("copied %d objects, want 2", report.Copied)
+	}
+	target, err := js.ObjectStore("NEW")
+	if err != nil {
+		t.Fatalf("target not created: %v", err)
+	}
+	info, err := target.GetInfo("a.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Headers.Get("X-Copied-From"); got != "SOURCE/a.db" {
+		t.Errorf("X-Copied-From = %q, want SOURCE/a.db", got)
+	}
+
+	if _, err := MergeBuckets(context.Background(), "SOURCE", "SOURCE", nc, MergeUpsert); err == nil {
+		t.Error("MergeBuckets accepted the same source and target")
+	}
+	if _, err := MergeBuckets(context.Background(), "MISSING", "NEW", nc, MergeUpsert); err == nil {
+		t.Error("MergeBuckets accepted a missing source")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// InsertStats reports the outcome of BulkInsertFromStream
+type InsertStats struct {
+	MessagesConsumed int64
+	RowsInserted     int64
+	FlushCount       int64
+	Duration         time.Duration
+}
+
+// BulkInsertFromStream inserts the JSON messages of a JetStream stream into tableName, creating
+// the table from the first batch if needed. Messages are delivered through the durable push
+// consumer consumerName, which is created if it does not exist and kept afterwards, so a later
+// call continues where this one stopped. Rows are inserted and the database is stored once
+// batchSize messages are pending or flushInterval passed since the first of them, and messages
+// are acknowledged only after the store. Messages that are not valid JSON are terminated. The
+// method returns after the last message in the stream was stored or once ctx is done.
+func (d *DuckDBStorage) BulkInsertFromStream(ctx context.Context, streamName, consumerName, tableName string, batchSize int, flushInterval time.Duration) (stats InsertStats, err error) {
+	op := d.logOperation("bulk_insert", "stream", streamName, "consumer", consumerName, "table", tableName)
+	start := time.Now()
+	defer func() {
+		stats.Duration = time.Since(start)
+		op.done(err, "messages", stats.MessagesConsumed, "rows", stats.RowsInserted, "flushes", stats.FlushCount)
+	}()
+	ctx, cancel := d.lifetime(ctx)
+	defer cancel()
+	end, err := d.beginOperation()
+	if err != nil {
+		return stats, err
+	}
+	defer func() { end(err) }()
+
+	if batchSize <= 0 {
+		return stats, fmt.Errorf("invalid batch size: %d", batchSize)
+	}
+	if flushInterval <= 0 {
+		return stats, fmt.Errorf("invalid flush interval: %v", flushInterval)
+	}
+
+	sub, err := d.bindPushConsumer(streamName, consumerName, flushInterval)
+	if err != nil {
+		return stats, err
+	}
+	defer sub.Unsubscribe()
+
+	consumer, err := sub.ConsumerInfo()
+	if err != nil {
+		return stats, fmt.Errorf("failed to get consumer info: %w", err)
+	}
+	if consumer.NumPending == 0 && consumer.NumAckPending == 0 {
+		return stats, nil
+	}
+
+	// Continue from the stored database if there is one
+	dbPath, err := tempPath("duckdb-nats-bulk-*.db")
+	if err != nil {
+		return stats, err
+	}
+	defer removeTempDatabase(dbPath)
+
+	if err := d.retrieve(ctx, dbPath); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+		return stats, err
+	}
+
+	db, err := openDuckDB(dbPath)
+	if err != nil {
+		return stats, err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return stats, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	var pending []*nats.Msg
+	var flushAt time.Time
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		// The batch is stored even when ctx is done, so it is not redelivered
+		_, inserted, err := loadMessages(context.Background(), conn, tableName, pending)
+		if err != nil {
+			return err
+		}
+		if _, err := conn.ExecContext(context.Background(), "CHECKPOINT"); err != nil {
+			return fmt.Errorf("failed to checkpoint database: %w", err)
+		}
+		if err := d.StoreDuckDB(dbPath); err != nil {
+			return err
+		}
+		for _, msg := range pending {
+			msg.Ack()
+		}
+		stats.RowsInserted += inserted
+		stats.FlushCount++
+		pending = nil
+		return nil
+	}
+
+	for {
+		wait := flushInterval
+		if len(pending) > 0 {
+			wait = time.Until(flushAt)
+		}
+		waitCtx, cancel := context.WithTimeout(ctx, wait)
+		msg, err := sub.NextMsgWithContext(waitCtx)
+		cancel()
+
+		if ctx.Err() != nil {
+			return stats, flush()
+		}
+		if errors.Is(err, context.DeadlineExceeded) {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+			continue
+		}
+		if err != nil {
+			return stats, fmt.Errorf("failed to receive message: %w", err)
+		}
+
+		if len(pending) == 0 {
+			flushAt = time.Now().Add(flushInterval)
+		}
+		pending = append(pending, msg)
+		stats.MessagesConsumed++
+
+		// The last message in the stream reports nothing pending behind it
+		exhausted := false
+		if meta, err := msg.Metadata(); err == nil && meta.NumPending == 0 {
+			exhausted = true
+		}
+		if exhausted || len(pending) >= batchSize {
+			if err := flush(); err != nil {
+				return stats, err
+			}
+		}
+		if exhausted {
+			return stats, nil
+		}
+	}
+}
+
+// bindPushConsumer subscribes to the durable push consumer of a stream, creating the consumer
+// first if needed. Binding to a consumer the library did not create keeps it on unsubscribe.
+func (d *DuckDBStorage) bindPushConsumer(streamName, consumerName string, flushInterval time.Duration) (*nats.Subscription, error) {
+	_, err := d.js.ConsumerInfo(streamName, consumerName)
+	if errors.Is(err, nats.ErrConsumerNotFound) {
+		_, err = d.js.AddConsumer(streamName, &nats.ConsumerConfig{
+			Durable:        consumerName,
+			DeliverSubject: nats.NewInbox(),
+			DeliverPolicy:  nats.DeliverAllPolicy,
+			AckPolicy:      nats.AckExplicitPolicy,
+			AckWait:        2 * flushInterval,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create push consumer: %w", err)
+	}
+
+	sub, err := d.js.SubscribeSync("", nats.Bind(streamName, consumerName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to consumer %s: %w", consumerName, err)
+	}
+	return sub, nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// publishTestEvents publishes n JSON events numbered from first to subject
+func publishTestEvents(tb testing.TB, js nats.JetStreamContext, subject string, first, n int) {
+	tb.Helper()
+	for i := first; i < first+n; i++ {
+		if _, err := js.Publish(subject, []byte(fmt.Sprintf(`{"id": %d, "name": "event %d"}`, i, i))); err != nil {
+			tb.Fatalf("failed to publish event %d: %v", i, err)
+		}
+	}
+}
+
+func TestBulkInsertFromStream(t *testing.T) {
+	nc := startTestServer(t)
+	js, err := nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := js.AddStream(&nats.StreamConfig{Name: "EVENTS", Subjects: []string{"events.>"}}); err != nil {
+		t.Fatalf("failed to create stream: %v", err)
+	}
+	publishTestEvents(t, js, "events.created", 0, 500)
+	if _, err := js.Publish("events.created", []byte("not json")); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestStorage(t, nc)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+	stats, err := s.BulkInsertFromStream(ctx, "EVENTS", "bulk", "events", 128, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("BulkInsertFromStream: %v", err)
+	}
+	if stats.MessagesConsumed != 501 || stats.RowsInserted != 500 || stats.FlushCount != 4 {
+		t.Errorf("stats = %+v, want 501 messages, 500 rows and 4 flushes", stats)
+	}
+	var n, distinct int
+	if err := s.QueryRow(ctx, "SELECT count(*), count(DISTINCT id) FROM events").Scan(&n, &distinct); err != nil {
+		t.Fatalf("failed to query events: %v", err)
+	}
+	if n != 500 || distinct != 500 {
+		t.Errorf("stored %d events with %d ids, want 500", n, distinct)
+	}
+
+	// The durable consumer continues where the previous call stopped
+	stats, err = s.BulkInsertFromStream(ctx, "EVENTS", "bulk", "events", 128, 200*time.Millisecond)
+	if err != nil || stats.MessagesConsumed != 0 {
+		t.Errorf("call without pending messages = %+v, %v, want nothing consumed", stats, err)
+	}
+	publishTestEvents(t, js, "events.created", 500, 10)
+	stats, err = s.BulkInsertFromStream(ctx, "EVENTS", "bulk", "events", 128, 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("BulkInsertFromStream: %v", err)
+	}
+	if stats.RowsInserted != 10 || stats.FlushCount != 1 {
+		t.Errorf("stats = %+v, want 10 rows in 1 flush", stats)
+	}
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM events").Scan(&n); err != nil || n != 510 {
+		t.Errorf("stored %d events, %v, want 510", n, err)
+	}
+}
+
+func TestBulkInsertFromStreamInvalid(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	ctx := context.Background()
+	if _, err := s.BulkInsertFromStream(ctx, "EVENTS", "bulk", "events", 0, time.Second); err == nil {
+		t.Error("BulkInsertFromStream accepted a batch size of 0")
+	}
+	if _, err := s.BulkInsertFromStream(ctx, "EVENTS", "bulk", "events", 10, 0); err == nil {
+		t.Error("BulkInsertFromStream accepted a flush interval of 0")
+	}
+	if _, err := s.BulkInsertFromStream(ctx, "MISSING", "bulk", "events", 10, time.Second); err == nil {
+		t.Error("BulkInsertFromStream accepted a missing stream")
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// cdcWatermarkColumn is the column CDC uses to find modified rows
+const cdcWatermarkColumn = "_updated_at"
+
+// cdcState is persisted in KV so a restarted publisher continues where it left off. The
+// version each row was last published at is kept under a key of its own, see rowStateKey.
+type cdcState struct {
+	Watermark time.Time `json:"watermark"`
+	Sequence  uint64    `json:"sequence"`
+}
+
+func (d *DuckDBStorage) cdcBucket() string {
+	return d.bucket + "-cdc"
+}
+
+// StartCDCPublisher polls tableName of the local database every pollInterval and publishes rows
+// whose _updated_at column moved past the last watermark to subject. Rows sharing the watermark
+// timestamp are published once each. subject must be bound to a JetStream stream, a row is only
+// recorded as published once the stream acknowledged it. Each message carries X-Table,
+// X-Operation (INSERT or UPDATE) and X-Sequence headers and a Nats-Msg-Id naming the row
+// version, so the stream drops a change published again after a restart. The method blocks
+// until ctx is cancelled or the storage is closed.
+func (d *DuckDBStorage) StartCDCPublisher(ctx context.Context, dbFilePath, tableName, subject string, pollInterval time.Duration) (err error) {
+	op := d.logOperation("cdc", "table", tableName, "subject", subject)
+	defer func() { op.done(err) }()
+	ctx, cancel := d.lifetime(ctx)
+	defer cancel()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if pollInterval <= 0 {
+		return fmt.Errorf("invalid poll interval: %v", pollInterval)
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	return d.runCDC(ctx, ticker.C, dbFilePath, tableName, subject)
+}
+
+// runCDC publishes changes on every tick until ctx is cancelled
+func (d *DuckDBStorage) runCDC(ctx context.Context, ticks <-chan time.Time, dbFilePath, tableName, subject string) error {
+	kv, err := d.keyValue(d.cdcBucket())
+	if err != nil {
+		return err
+	}
+	key := d.dbName + "." + tableName
+
+	state, err := loadCDCState(kv, key)
+	if err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticks:
+			published, err := d.publishChanges(ctx, kv, key, dbFilePath, tableName, subject, state)
+			// Keep the progress of a poll cut short so its rows are not published again
+			if published > 0 {
+				if err := saveCDCState(kv, key, state); err != nil {
+					return err
+				}
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+	}
+}
+
+// publishChanges publishes rows modified since the watermark and advances state
+func (d *DuckDBStorage) publishChanges(ctx context.Context, kv nats.KeyValue, stateKey, dbFilePath, tableName, subject string, state *cdcState) (int, error) {
+	db, err := openDuckDB(dbFilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	keyColumns, err := primaryKeyColumns(ctx, db, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	// Rows written after the last poll may share the watermark timestamp, so it is included
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT * FROM %s WHERE %s >= ? ORDER BY %s",
+		quoteIdent(tableName), quoteIdent(cdcWatermarkColumn), quoteIdent(cdcWatermarkColumn)),
+		state.Watermark)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query changes: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	published := 0
+	for rows.Next() {
+		row, err := scanRowMap(rows, columns)
+		if err != nil {
+			return published, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		pk, err := rowKey(row, keyColumns)
+		if err != nil {
+			return published, err
+		}
+
+		// Rows sharing the watermark are returned again, skip the versions already published
+		updatedAt, _ := row[cdcWatermarkColumn].(time.Time)
+		version := updatedAt.UTC().Format(time.RFC3339Nano)
+		rowState := rowStateKey(stateKey, pk)
+		last, found, err := publishedVersion(kv, rowState)
+		if err != nil {
+			return published, err
+		}
+		if found && last == version {
+			continue
+		}
+		operation := "INSERT"
+		if found {
+			operation = "UPDATE"
+		}
+
+		data, err := json.Marshal(row)
+		if err != nil {
+			return published, fmt.Errorf("failed to encode row: %w", err)
+		}
+
+		msg := nats.NewMsg(subject)
+		msg.Data = data
+		msg.Header.Set("X-Table", tableName)
+		msg.Header.Set("X-Operation", operation)
+		msg.Header.Set("X-Sequence", strconv.FormatUint(state.Sequence+1, 10))
+		msg.Header.Set(nats.MsgIdHdr, rowState+"@"+version)
+		if _, err := d.js.PublishMsg(msg, nats.Context(ctx)); err != nil {
+			return published, fmt.Errorf("failed to publish change: %w", err)
+		}
+		if _, err := kv.Put(rowState, []byte(version)); err != nil {
+			return published, fmt.Errorf("failed to record published row: %w", err)
+		}
+
+		state.Sequence++
+		if updatedAt.After(state.Watermark) {
+			state.Watermark = updatedAt
+		}
+		published++
+	}
+	if err := rows.Err(); err != nil {
+		return published, fmt.Errorf("failed to read changes: %w", err)
+	}
+
+	return published, nil
+}
+
+// primaryKeyColumns returns the primary key columns of tableName
+func primaryKeyColumns(ctx context.Context, db *sql.DB, tableName string) ([]string, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT UNNEST(constraint_column_names) FROM duckdb_constraints() WHERE table_name = ? AND constraint_type = 'PRIMARY KEY'",
+		tableName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up primary key of %s: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to look up primary key of %s: %w", tableName, err)
+		}
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to look up primary key of %s: %w", tableName, err)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table %s has no primary key", tableName)
+	}
+	return columns, nil
+}
+
+// rowKey encodes the primary key values of a row as a single string
+func rowKey(row map[string]any, keyColumns []string) (string, error) {
+	values := make([]any, len(keyColumns))
+	for i, column := range keyColumns {
+		values[i] = row[column]
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode primary key: %w", err)
+	}
+	return string(data), nil
+}
+
+// rowStateKey returns the key recording the published version of the row with key pk. Published
+// rows are recorded under keys of their own next to stateKey, so the state value stays small
+// however many rows the table has.
+func rowStateKey(stateKey, pk string) string {
+	sum := sha256.Sum256([]byte(pk))
+	return stateKey + ".rows." + hex.EncodeToString(sum[:16])
+}
+
+// publishedVersion returns the _updated_at the row under key was last published at, reporting
+// whether it was published at all
+func publishedVersion(kv nats.KeyValue, key string) (string, bool, error) {
+	entry, err := kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to look up published row: %w", err)
+	}
+	return string(entry.Value()), true, nil
+}
+
+func loadCDCState(kv nats.KeyValue, key string) (*cdcState, error) {
+	state := &cdcState{}
+
+	entry, err := kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CDC state: %w", err)
+	}
+
+	if err := json.Unmarshal(entry.Value(), state); err != nil {
+		return nil, fmt.Errorf("failed to decode CDC state: %w", err)
+	}
+	return state, nil
+}
+
+func saveCDCState(kv nats.KeyValue, key string, state *cdcState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to encode CDC state: %w", err)
+	}
+	if _, err := kv.Put(key, data); err != nil {
+		return fmt.Errorf("failed to save CDC state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// pollCDC runs runCDC for a single poll driven by a fake tick channel. The second tick is only
+// received once the first poll finished.
+func pollCDC(t *testing.T, s *DuckDBStorage, path string) {
+	t.Helper()
+	ticks := make(chan time.Time)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.runCDC(ctx, ticks, path, "items", "cdc.items") }()
+	for i := 0; i < 2; i++ {
+		select {
+		case ticks <- time.Now():
+		case err := <-done:
+			cancel()
+			t.Fatalf("runCDC: %v", err)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("runCDC: %v", err)
+	}
+}
+
+// addCDCStream creates the stream that acknowledges the changes published by pollCDC
+func addCDCStream(t *testing.T, s *DuckDBStorage) {
+	t.Helper()
+	if _, err := s.js.AddStream(&nats.StreamConfig{Name: "CDC", Subjects: []string{"cdc.>"}}); err != nil {
+		t.Fatalf("failed to create CDC stream: %v", err)
+	}
+}
+
+// cdcStateSize returns the size of the persisted CDC state of the items table
+func cdcStateSize(t *testing.T, s *DuckDBStorage) int {
+	t.Helper()
+	kv, err := s.keyValue(s.cdcBucket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := kv.Get(s.dbName + ".items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(entry.Value())
+}
+
+// receiveChanges returns the operations of the changes published on sub so far, keyed by id
+func receiveChanges(t *testing.T, sub *nats.Subscription) map[int64]string {
+	t.Helper()
+	changes := map[int64]string{}
+	for {
+		msg, err := sub.NextMsg(200 * time.Millisecond)
+		if err != nil {
+			return changes
+		}
+		if got := msg.Header.Get("X-Table"); got != "items" {
+			t.Errorf("X-Table = %q", got)
+		}
+		var row struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(msg.Data, &row); err != nil {
+			t.Fatalf("change %s is not JSON: %v", msg.Data, err)
+		}
+		changes[row.ID] = msg.Header.Get("X-Operation")
+	}
+}
+
+func TestCDC(t *testing.T) {
+	nc := startTestServer(t)
+	s := newTestStorage(t, nc)
+	addCDCStream(t, s)
+	path := filepath.Join(t.TempDir(), "cdc.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, v VARCHAR, _updated_at TIMESTAMP)",
+		"INSERT INTO items SELECT i, 'a', TIMESTAMP '2024-01-01 00:00:00' FROM range(100) r(i)",
+	)
+	sub, err := nc.SubscribeSync("cdc.items")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pollCDC(t, s, path)
+	changes := receiveChanges(t, sub)
+	if len(changes) != 100 {
+		t.Fatalf("%d changes published, want 100", len(changes))
+	}
+	for id, operation := range changes {
+		if operation != "INSERT" {
+			t.Fatalf("row %d published as %s", id, operation)
+		}
+	}
+	// The persisted state does not grow with the rows sharing the watermark
+	if size := cdcStateSize(t, s); size > 200 {
+		t.Errorf("CDC state is %d bytes", size)
+	}
+
+	// A new row sharing the watermark timestamp and an update are each published once
+	execTestDatabase(t, path,
+		"INSERT INTO items VALUES (500, 'b', TIMESTAMP '2024-01-01 00:00:00')",
+		"UPDATE items SET v = 'z', _updated_at = TIMESTAMP '2024-01-02 00:00:00' WHERE id = 1",
+	)
+	pollCDC(t, s, path)
+	changes = receiveChanges(t, sub)
+	if len(changes) != 2 || changes[500] != "INSERT" || changes[1] != "UPDATE" {
+		t.Errorf("changes = %v, want 500 inserted and 1 updated", changes)
+	}
+
+	pollCDC(t, s, path)
+	if changes := receiveChanges(t, sub); len(changes) != 0 {
+		t.Errorf("unchanged table published %v", changes)
+	}
+}
+
+func TestCDCUnacknowledged(t *testing.T) {
+	nc := startTestServer(t)
+	s := newTestStorage(t, nc)
+	path := filepath.Join(t.TempDir(), "cdc.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, _updated_at TIMESTAMP)",
+		"INSERT INTO items SELECT i, TIMESTAMP '2024-01-01 00:00:00' FROM range(10) r(i)",
+	)
+
+	// Without a stream no change is acknowledged, so none is recorded as published
+	ticks := make(chan time.Time, 1)
+	ticks <- time.Now()
+	if err := s.runCDC(context.Background(), ticks, path, "items", "cdc.items"); err == nil {
+		t.Fatal("runCDC succeeded without a stream acknowledging the changes")
+	}
+
+	addCDCStream(t, s)
+	sub, err := nc.SubscribeSync("cdc.items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pollCDC(t, s, path)
+	changes := receiveChanges(t, sub)
+	if len(changes) != 10 {
+		t.Fatalf("%d changes published once acknowledged, want 10", len(changes))
+	}
+	for id, operation := range changes {
+		if operation != "INSERT" {
+			t.Errorf("row %d published as %s", id, operation)
+		}
+	}
+}
+
+func TestCDCRequiresPrimaryKey(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "cdc.db")
+	execTestDatabase(t, path, "CREATE TABLE items (id INTEGER, _updated_at TIMESTAMP)")
+
+	ticks := make(chan time.Time, 1)
+	ticks <- time.Now()
+	if err := s.runCDC(context.Background(), ticks, path, "items", "cdc.items"); err == nil {
+		t.Fatal("runCDC accepted a table without a primary key")
+	}
+}
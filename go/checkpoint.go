@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+const (
+	// defaultCheckpointTimeout is how long StoreDuckDBWithCheckpoint waits for the WAL by default
+	defaultCheckpointTimeout = 5 * time.Second
+	// checkpointPollInterval is how often StoreDuckDBWithCheckpoint checks for the WAL file
+	checkpointPollInterval = 10 * time.Millisecond
+)
+
+// ErrCheckpointTimeout is returned by StoreDuckDBWithCheckpoint when the WAL file outlives the
+// timeout set by WithCheckpointTimeout
+var ErrCheckpointTimeout = errors.New("checkpoint timed out")
+
+// StoreDuckDBWithCheckpoint merges the WAL of the open database db into dbFilePath, closes db
+// and stores the file once DuckDB removed the WAL. A checkpoint is refused while write
+// transactions are open on db, so it is retried until they finished. ErrCheckpointTimeout is
+// returned if that or the removal of the WAL takes longer than the timeout set by
+// WithCheckpointTimeout. db is closed in every case.
+func (d *DuckDBStorage) StoreDuckDBWithCheckpoint(ctx context.Context, db *sql.DB, dbFilePath string) (err error) {
+	op := d.logOperation("store_checkpoint", "path", dbFilePath)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	deadline := time.Now().Add(d.opts.CheckpointTimeout)
+	err = pollUntil(ctx, deadline, func() (bool, error) {
+		_, err := db.ExecContext(ctx, "CHECKPOINT")
+		var duckErr *duckdb.Error
+		if errors.As(err, &duckErr) && duckErr.Type == duckdb.ErrorTypeTransaction {
+			// Another connection has an open write transaction
+			return false, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to checkpoint database: %w", err)
+		}
+		return true, nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	walPath := dbFilePath + ".wal"
+	err = pollUntil(ctx, deadline, func() (bool, error) {
+		_, err := os.Stat(walPath)
+		if errors.Is(err, os.ErrNotExist) {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to stat WAL file: %w", err)
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return d.StoreDuckDBContext(ctx, dbFilePath)
+}
+
+// pollUntil calls check every checkpointPollInterval until it reports done, returns an error or
+// deadline passes, in which case ErrCheckpointTimeout is returned
+func pollUntil(ctx context.Context, deadline time.Time, check func() (bool, error)) error {
+	for {
+		done, err := check()
+		if done || err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return ErrCheckpointTimeout
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(checkpointPollInterval):
+		}
+	}
+}
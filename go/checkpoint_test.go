@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStoreDuckDBWithCheckpoint(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithCheckpointTimeout(5*time.Second))
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := openDuckDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		"CREATE TABLE events (id INTEGER)",
+		"INSERT INTO events SELECT i FROM range(1000) r(i)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The checkpoint waits for the open transaction to commit
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO events VALUES (-1)"); err != nil {
+		t.Fatal(err)
+	}
+	committed := make(chan error, 1)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		committed <- tx.Commit()
+	}()
+
+	if err := s.StoreDuckDBWithCheckpoint(context.Background(), db, path); err != nil {
+		t.Fatalf("StoreDuckDBWithCheckpoint: %v", err)
+	}
+	if err := <-committed; err != nil {
+		t.Fatalf("failed to commit: %v", err)
+	}
+	if _, err := os.Stat(path + ".wal"); !os.IsNotExist(err) {
+		t.Error("WAL file left after the checkpoint")
+	}
+	var n int
+	if err := s.QueryRow(context.Background(), "SELECT count(*) FROM events").Scan(&n); err != nil || n != 1001 {
+		t.Errorf("stored database holds %d events, %v, want 1001", n, err)
+	}
+}
+
+func TestStoreDuckDBWithCheckpointTimeout(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithCheckpointTimeout(200*time.Millisecond))
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := openDuckDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE events (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("INSERT INTO events VALUES (1)"); err != nil {
+		t.Fatal(err)
+	}
+
+	stored := make(chan error, 1)
+	go func() { stored <- s.StoreDuckDBWithCheckpoint(context.Background(), db, path) }()
+	time.Sleep(400 * time.Millisecond)
+	tx.Rollback()
+	if err := <-stored; !errors.Is(err, ErrCheckpointTimeout) {
+		t.Errorf("StoreDuckDBWithCheckpoint with an open transaction: got %v, want ErrCheckpointTimeout", err)
+	}
+
+	if rev, err := s.CurrentRevision(); err != nil || rev != 0 {
+		t.Errorf("revision = %d, %v, want nothing stored after the timeout", rev, err)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nats-io/nats.go"
+)
+
+// checksumHeader records the SHA-256 of the uncompressed database bytes
+const checksumHeader = "X-Content-SHA256"
+
+// ErrChecksumMismatch is returned when retrieved bytes do not match the stored hash
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrUnchanged is returned by StoreDuckDB when deduplication skipped storing an identical file
+var ErrUnchanged = errors.New("database unchanged")
+
+// hashReader returns the hex-encoded SHA-256 of everything read from r
+func hashReader(r io.Reader) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", fmt.Errorf("failed to hash data: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// verifyChecksum compares an expected hex digest with the actual one
+func verifyChecksum(expected, actual string) error {
+	if expected != actual {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expected, actual)
+	}
+	return nil
+}
+
+// hashFile returns the hex-encoded SHA-256 of the file at path
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	return hashReader(file)
+}
+
+// checkUnchanged returns ErrUnchanged if the file has the same hash as the stored database
+func (d *DuckDBStorage) checkUnchanged(dbFilePath string) error {
+	info, err := d.obs.GetInfo(d.dbName)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", d.dbName, err)
+	}
+	stored := info.Headers.Get(checksumHeader)
+	if stored == "" {
+		return nil
+	}
+
+	checksum, err := hashFile(dbFilePath)
+	if err != nil {
+		return err
+	}
+	if checksum == stored {
+		return fmt.Errorf("%w: %s", ErrUnchanged, checksum)
+	}
+	return nil
+}
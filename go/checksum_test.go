@@ -0,0 +1,136 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestVerifyChecksum(t *testing.T) {
+	sum, err := hashReader(bytes.NewReader([]byte("duckdb")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyChecksum(sum, sum); err != nil {
+		t.Errorf("matching checksums: %v", err)
+	}
+	if err := verifyChecksum(sum, "0000"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("differing checksums: got %v, want ErrChecksumMismatch", err)
+	}
+}
+
+func TestRetrieveCorrupted(t *testing.T) {
+	for _, algorithm := range []CompressionAlgorithm{CompressionNone, CompressionGzip} {
+		t.Run(algorithmName(algorithm), func(t *testing.T) {
+			s, _ := storeTestDatabase(t, WithCompression(algorithm))
+			info, err := s.GetInfo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := s.obs.GetBytes(s.dbName)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Flip a byte of the stored payload while keeping the headers recording the original hash
+			data[len(data)/2] ^= 0xff
+			meta := info.ObjectMeta
+			if _, err := s.obs.Put(&meta, bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+
+			out := filepath.Join(t.TempDir(), "out.db")
+			if err := s.RetrieveDuckDB(out); err == nil {
+				t.Fatal("RetrieveDuckDB accepted corrupted bytes")
+			} else if algorithm == CompressionNone && !errors.Is(err, ErrChecksumMismatch) {
+				t.Fatalf("RetrieveDuckDB: got %v, want ErrChecksumMismatch", err)
+			}
+			if _, err := os.Stat(out); !os.IsNotExist(err) {
+				t.Errorf("corrupted database left at %s", out)
+			}
+		})
+	}
+}
+
+func TestRetrieveChecksumHeaderMismatch(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithCompression(CompressionGzip))
+	info, err := s.GetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := info.ObjectMeta
+	meta.Headers = nats.Header{}
+	for k, v := range info.Headers {
+		meta.Headers[k] = v
+	}
+	meta.Headers.Set(checksumHeader, "bad")
+	if err := s.obs.UpdateMeta(s.dbName, &meta); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDB(out); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("RetrieveDuckDB: got %v, want ErrChecksumMismatch", err)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("corrupted database left at %s", out)
+	}
+}
+
+func TestStoreDeduplication(t *testing.T) {
+	s, path := storeTestDatabase(t, WithDeduplication(true))
+	stored, err := s.CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.StoreDuckDB(path); !errors.Is(err, ErrUnchanged) {
+		t.Fatalf("StoreDuckDB of an unchanged file: got %v, want ErrUnchanged", err)
+	}
+	if rev, err := s.CurrentRevision(); err != nil || rev != stored {
+		t.Fatalf("revision after the skipped store = %d, %v, want %d", rev, err, stored)
+	}
+
+	if err := s.ForceStore(path); err != nil {
+		t.Fatalf("ForceStore: %v", err)
+	}
+	forced, err := s.CurrentRevision()
+	if err != nil || forced == stored {
+		t.Fatalf("revision after ForceStore = %d, %v, want a new revision", forced, err)
+	}
+
+	execTestDatabase(t, path, "INSERT INTO users VALUES (10, 'Dave', now())")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB of a changed file: %v", err)
+	}
+}
+
+func TestStoreWithoutDeduplication(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"disabled", nil},
+		// Chunked databases carry no whole-file hash to compare
+		{"chunked", []Option{WithDeduplication(true), WithChunkSize(64 * 1024)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, path := storeTestDatabase(t, tt.opts...)
+			stored, err := s.CurrentRevision()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := s.StoreDuckDB(path); err != nil {
+				t.Fatalf("StoreDuckDB of an unchanged file: %v", err)
+			}
+			if rev, err := s.CurrentRevision(); err != nil || rev == stored {
+				t.Errorf("revision after storing again = %d, %v, want a new revision", rev, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+// ChunkManifest describes a database stored as a sequence of chunk objects
+type ChunkManifest struct {
+	Name       string      `json:"name"`
+	ChunkSize  int64       `json:"chunk_size"`
+	ChunkCount int         `json:"chunk_count"`
+	TotalSize  int64       `json:"total_size"`
+	Chunks     []ChunkInfo `json:"chunks"`
+}
+
+// ChunkInfo describes a single chunk object referenced by a manifest
+type ChunkInfo struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+func (d *DuckDBStorage) manifestName() string {
+	return manifestNameOf(d.dbName)
+}
+
+// manifestSuffix is appended to a database object name to name its chunk manifest
+const manifestSuffix = ".manifest"
+
+// manifestNameOf returns the name of the chunk manifest of the database object name
+func manifestNameOf(name string) string {
+	return name + manifestSuffix
+}
+
+// manifestDatabase returns the name of the database whose chunk manifest info is, if it is one
+func manifestDatabase(info *nats.ObjectInfo) (string, bool) {
+	if info.Headers.Get("Content-Type") != "application/json" {
+		return "", false
+	}
+	return strings.CutSuffix(info.Name, manifestSuffix)
+}
+
+// chunkName returns the object name of a chunk of the upload generation
+func (d *DuckDBStorage) chunkName(generation string, index int) string {
+	return chunkNameOf(d.dbName, generation, index)
+}
+
+// chunkNameOf returns the object name of a chunk of the upload generation of the database
+// object name
+func chunkNameOf(name, generation string, index int) string {
+	return fmt.Sprintf("%s.part.%s.%04d", name, generation, index)
+}
+
+// StoreDuckDBChunked stores a DuckDB database file as fixed-size chunk objects plus a JSON
+// manifest, with the checks of StoreDuckDB
+func (d *DuckDBStorage) StoreDuckDBChunked(dbFilePath string, chunkSize int64, lock ...Lock) error {
+	if chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size: %d", chunkSize)
+	}
+	return d.store(context.Background(), dbFilePath, d.opts.Deduplicate, chunkSize, lock)
+}
+
+// storeChunked stores the database file in chunks, aborting between and within chunks once
+// ctx is done. extra headers are stored with the manifest. Every upload writes a new generation
+// of chunks and switches the manifest last, so a failed upload leaves the stored database intact.
+func (d *DuckDBStorage) storeChunked(ctx context.Context, dbFilePath string, chunkSize int64, extra nats.Header) (err error) {
+	start := time.Now()
+	defer func() { d.metrics.observe(opStore, start, err) }()
+
+	if chunkSize <= 0 {
+		return fmt.Errorf("invalid chunk size: %d", chunkSize)
+	}
+
+	file, err := os.Open(dbFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open database file: %w", err)
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat database file: %w", err)
+	}
+
+	// Remember the previous manifest so its chunks can be removed afterwards
+	previous, err := d.getManifest()
+	if err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+		return err
+	}
+
+	manifest := ChunkManifest{
+		Name:      d.dbName,
+		ChunkSize: chunkSize,
+		TotalSize: stat.Size(),
+	}
+	generation := nuid.Next()
+	defer func() {
+		if err != nil {
+			d.deleteChunks(d.obs, manifest.Chunks)
+		}
+	}()
+
+	for index, remaining := 0, stat.Size(); remaining > 0; index++ {
+		size := min(chunkSize, remaining)
+		hash := sha256.New()
+
+		headers := nats.Header{
+			"Content-Type": []string{"application/octet-stream"},
+		}
+		d.setExpiry(headers)
+		// Chunks are compressed and encrypted like whole databases, the manifest hash covers
+		// the plain bytes
+		reader, err := d.encodeStream(newCtxReader(ctx, io.TeeReader(io.LimitReader(file, size), hash)), headers)
+		if err != nil {
+			return err
+		}
+		name := d.chunkName(generation, index)
+		_, err = d.obs.Put(&nats.ObjectMeta{
+			Name:        name,
+			Description: "DuckDB database chunk",
+			Headers:     headers,
+		}, reader, nats.Context(ctx))
+		reader.Close()
+		if err != nil {
+			// A rejected Put may still have stored the object
+			d.deleteChunks(d.obs, []ChunkInfo{{Index: index, Name: name}})
+			return fmt.Errorf("failed to store chunk %d in NATS: %w", index, err)
+		}
+
+		manifest.Chunks = append(manifest.Chunks, ChunkInfo{
+			Index:  index,
+			Name:   name,
+			Size:   size,
+			SHA256: hex.EncodeToString(hash.Sum(nil)),
+		})
+		remaining -= size
+		if d.opts.ProgressCallback != nil {
+			d.opts.ProgressCallback(stat.Size()-remaining, stat.Size())
+		}
+	}
+	manifest.ChunkCount = len(manifest.Chunks)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+
+	info, err := d.obs.GetInfo(d.manifestName())
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		info = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to get info for %s: %w", d.manifestName(), err)
+	}
+
+	headers := cloneHeader(extra)
+	headers.Set("Content-Type", "application/json")
+	d.setExpiry(headers)
+	_, err = d.obs.Put(&nats.ObjectMeta{
+		Name:        d.manifestName(),
+		Description: "DuckDB chunk manifest",
+		Headers:     headers,
+	}, bytes.NewReader(data), nats.Context(ctx))
+	if err != nil {
+		// Only drop the new chunks once the previous manifest is back in place
+		err = fmt.Errorf("failed to store manifest in NATS: %w", err)
+		if restoreErr := d.restoreObject(d.js, d.obs, d.manifestName(), info); restoreErr != nil {
+			manifest.Chunks = nil
+			return errors.Join(err, restoreErr)
+		}
+		return err
+	}
+
+	// The new manifest is in place, drop the previous generation
+	if previous != nil {
+		d.deleteChunks(d.obs, previous.Chunks)
+	}
+	d.metrics.observeSize(opStore, manifest.TotalSize)
+
+	return nil
+}
+
+// deleteChunks deletes chunk objects of obs that no manifest references. Failures are logged,
+// they only leave unreferenced chunks behind.
+func (d *DuckDBStorage) deleteChunks(obs nats.ObjectStore, chunks []ChunkInfo) {
+	for _, chunk := range chunks {
+		if err := obs.Delete(chunk.Name); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			d.opts.Logger.Error("failed to delete chunk", "db", d.dbName, "chunk", chunk.Name, "error", err)
+		}
+	}
+}
+
+// RetrieveDuckDBChunked reassembles a chunked DuckDB database, falling back to the single-object
+// path, with the checks of RetrieveDuckDB
+func (d *DuckDBStorage) RetrieveDuckDBChunked(outputPath string, lock ...Lock) error {
+	return d.retrieveFile(context.Background(), outputPath, true, lock)
+}
+
+func (d *DuckDBStorage) retrieveChunked(ctx context.Context, outputPath string) (err error) {
+	start := time.Now()
+	manifest, err := d.getManifest()
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return d.retrieveObject(ctx, outputPath)
+	}
+	defer func() { d.metrics.observe(opRetrieve, start, err) }()
+
+	if err != nil {
+		return err
+	}
+
+	err = writeOutput(outputPath, func(file *os.File) error {
+		for _, chunk := range manifest.Chunks {
+			if err := d.retrieveChunk(ctx, chunk, file); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	d.metrics.observeSize(opRetrieve, manifest.TotalSize)
+
+	return nil
+}
+
+// retrieveChunk copies the decoded bytes of a single chunk to w and verifies their hash
+// against the manifest
+func (d *DuckDBStorage) retrieveChunk(ctx context.Context, chunk ChunkInfo, w io.Writer) error {
+	obj, _, err := d.openObjectFrom(ctx, d.obs, chunk.Name)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve chunk %d from NATS: %w", chunk.Index, err)
+	}
+	defer obj.Close()
+
+	hash := sha256.New()
+	_, err = io.Copy(io.MultiWriter(w, hash), obj)
+	if err != nil {
+		return fmt.Errorf("failed to write chunk %d: %w", chunk.Index, err)
+	}
+
+	actual := hex.EncodeToString(hash.Sum(nil))
+	if actual != chunk.SHA256 {
+		return fmt.Errorf("chunk %d hash mismatch: expected %s, got %s", chunk.Index, chunk.SHA256, actual)
+	}
+
+	return nil
+}
+
+// getManifest fetches and decodes the chunk manifest for the database
+func (d *DuckDBStorage) getManifest() (*ChunkManifest, error) {
+	return d.getManifestOf(d.dbName)
+}
+
+// getManifestOf fetches and decodes the chunk manifest of the database object name
+func (d *DuckDBStorage) getManifestOf(name string) (*ChunkManifest, error) {
+	return getManifestFrom(d.obs, name)
+}
+
+// getManifestFrom fetches and decodes the chunk manifest of the database object name in obs
+func getManifestFrom(obs nats.ObjectStore, name string) (*ChunkManifest, error) {
+	data, err := obs.GetBytes(manifestNameOf(name))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve manifest from NATS: %w", err)
+	}
+
+	var manifest ChunkManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest: %w", err)
+	}
+
+	return &manifest, nil
+}
+
+// deleteChunked deletes the chunks and the manifest of the database object name, if it was
+// stored in chunks. Chunks go first, so a failed delete can be repeated using the manifest.
+func (d *DuckDBStorage) deleteChunked(name string) (bool, error) {
+	return deleteChunkedFrom(d.obs, name)
+}
+
+// deleteChunkedFrom deletes the chunks and the manifest of the database object name in obs,
+// like deleteChunked
+func deleteChunkedFrom(obs nats.ObjectStore, name string) (bool, error) {
+	manifest, err := getManifestFrom(obs, name)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, chunk := range manifest.Chunks {
+		if err := obs.Delete(chunk.Name); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return false, fmt.Errorf("failed to delete chunk %d: %w", chunk.Index, err)
+		}
+	}
+	if err := obs.Delete(manifestNameOf(name)); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+		return false, fmt.Errorf("failed to delete manifest: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+// failingChunkObjectStore fails the upload of the chunk with the failing index, counted from 1
+type failingChunkObjectStore struct {
+	nats.ObjectStore
+	puts    *atomic.Int32
+	failing int32
+}
+
+func (o failingChunkObjectStore) Put(meta *nats.ObjectMeta, r io.Reader, opts ...nats.ObjectOpt) (*nats.ObjectInfo, error) {
+	if strings.Contains(meta.Name, ".part.") && o.puts.Add(1) == o.failing {
+		return nil, nats.ErrTimeout
+	}
+	return o.ObjectStore.Put(meta, r, opts...)
+}
+
+// storedChunks returns the names of the chunk objects in the bucket of s
+func storedChunks(t *testing.T, s *DuckDBStorage) []string {
+	t.Helper()
+	objects, err := s.obs.List()
+	if err != nil && !errors.Is(err, nats.ErrNoObjectsFound) {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range objects {
+		if strings.Contains(info.Name, ".part.") {
+			names = append(names, info.Name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// manifestChunks returns the names of the chunks referenced by the manifest of s
+func manifestChunks(t *testing.T, s *DuckDBStorage) []string {
+	t.Helper()
+	manifest, err := s.getManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, chunk := range manifest.Chunks {
+		names = append(names, chunk.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// retrieveTestChunks retrieves the chunked database of s and fails unless it holds want
+func retrieveTestChunks(t *testing.T, s *DuckDBStorage, want []byte) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDBChunked(out); err != nil {
+		t.Fatalf("RetrieveDuckDBChunked: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("retrieved database does not match the stored one")
+	}
+}
+
+func TestStoreDuckDBChunked(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	data := storeTestChunks(t, s, 4)
+	retrieveTestChunks(t, s, data)
+	if chunks := manifestChunks(t, s); len(chunks) != 5 {
+		t.Fatalf("manifest references %d chunks, want 5", len(chunks))
+	}
+
+	// A smaller database replaces every chunk of the previous generation
+	data = storeTestChunks(t, s, 1)
+	retrieveTestChunks(t, s, data)
+	if got, want := storedChunks(t, s), manifestChunks(t, s); !slices.Equal(got, want) {
+		t.Errorf("bucket holds chunks %v, want %v", got, want)
+	}
+}
+
+func TestStoreDuckDBChunkedPartialFailure(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	data := storeTestChunks(t, s, 4)
+	stored := manifestChunks(t, s)
+
+	path := filepath.Join(t.TempDir(), "new.db")
+	if err := os.WriteFile(path, bytes.Repeat([]byte{1}, 4*testChunkSize), 0600); err != nil {
+		t.Fatal(err)
+	}
+	obs := s.obs
+	s.obs = failingChunkObjectStore{ObjectStore: obs, puts: new(atomic.Int32), failing: 3}
+	if err := s.StoreDuckDBChunked(path, testChunkSize); !errors.Is(err, nats.ErrTimeout) {
+		t.Fatalf("StoreDuckDBChunked: got %v, want nats.ErrTimeout", err)
+	}
+	s.obs = obs
+
+	// The previous generation is still complete and nothing of the failed one is left
+	retrieveTestChunks(t, s, data)
+	if got := storedChunks(t, s); !slices.Equal(got, stored) {
+		t.Errorf("bucket holds chunks %v, want %v", got, stored)
+	}
+}
+
+func TestStoreDuckDBChunkedChecks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	size := testDatabaseSize(t, path)
+
+	s := newTestStorage(t, startTestServer(t), WithLockEnforcement(true))
+	if err := s.StoreDuckDBChunked(path, size/3); !errors.Is(err, ErrLockRequired) {
+		t.Errorf("StoreDuckDBChunked without a lock: got %v, want ErrLockRequired", err)
+	}
+	if err := s.RetrieveDuckDBChunked(filepath.Join(t.TempDir(), "out.db")); !errors.Is(err, ErrLockRequired) {
+		t.Errorf("RetrieveDuckDBChunked without a lock: got %v, want ErrLockRequired", err)
+	}
+
+	s = newTestStorage(t, startTestServer(t), WithStorageQuota(size/2))
+	if err := s.StoreDuckDBChunked(path, size/3); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("StoreDuckDBChunked over the quota: got %v, want ErrQuotaExceeded", err)
+	}
+	if err := s.StoreDuckDBChunked(path, 0); err == nil {
+		t.Error("StoreDuckDBChunked accepted a chunk size of 0")
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// defaultDrainTimeout bounds how long Close waits for the NATS connection to drain
+const defaultDrainTimeout = 30 * time.Second
+
+// ErrStorageClosed is returned by operations started or interrupted after Close was called
+var ErrStorageClosed = errors.New("storage closed")
+
+// MultiError collects the errors reported while closing the storage handler
+type MultiError struct {
+	Errors []error
+}
+
+func (e *MultiError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Unwrap lets errors.Is and errors.As match any of the collected errors
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// Close stops the background goroutines started by the handler, cancels in-flight stores and
+// retrieves and waits for them until ctx is done, closes open in-memory databases, appenders and
+// mirror connections and drains the NATS connection if the handler created it or
+// WithConnectionDrain is set. Operations started afterwards fail with ErrStorageClosed. Errors of
+// operations that finished while draining and ctx.Err() if they did not finish in time are
+// returned as a *MultiError.
+func (d *DuckDBStorage) Close(ctx context.Context) error {
+	d.mu.Lock()
+	if d.closing {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closing = true
+	d.mu.Unlock()
+
+	d.cancel()
+
+	var errs []error
+	drained := make(chan struct{})
+	go func() {
+		d.inflight.Wait()
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		errs = append(errs, fmt.Errorf("failed waiting for in-flight operations: %w", ctx.Err()))
+	}
+
+	d.mu.Lock()
+	errs = append(errs, d.drainErrs...)
+	resources := make([]io.Closer, 0, len(d.resources))
+	for resource := range d.resources {
+		resources = append(resources, resource)
+	}
+	d.mu.Unlock()
+
+	for _, resource := range resources {
+		if err := resource.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	d.closeMirrors()
+	if err := d.drainConnection(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
+
+// drainConnection drains and closes the NATS connection if the handler is responsible for it
+func (d *DuckDBStorage) drainConnection() error {
+	if !(d.ownsConn || d.opts.DrainConnection) || d.nc.IsClosed() {
+		return nil
+	}
+
+	if err := d.nc.Drain(); err != nil {
+		return fmt.Errorf("failed to drain NATS connection: %w", err)
+	}
+	// Drain returns immediately and closes the connection once it is done
+	deadline := time.Now().Add(d.opts.DrainTimeout)
+	for !d.nc.IsClosed() {
+		if time.Now().After(deadline) {
+			d.nc.Close()
+			return errors.New("timed out draining NATS connection")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return nil
+}
+
+// beginOperation registers an in-flight operation that Close waits for, or returns
+// ErrStorageClosed once Close has been called. The returned function ends it, recording its
+// error if Close is already waiting unless Close itself interrupted it.
+func (d *DuckDBStorage) beginOperation() (func(err error), error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closing {
+		return nil, ErrStorageClosed
+	}
+
+	d.inflight.Add(1)
+	return func(err error) {
+		if err != nil && !errors.Is(err, ErrStorageClosed) {
+			d.mu.Lock()
+			if d.closing {
+				d.drainErrs = append(d.drainErrs, err)
+			}
+			d.mu.Unlock()
+		}
+		d.inflight.Done()
+	}, nil
+}
+
+// closedErr reports a cancellation caused by Close as ErrStorageClosed
+func (d *DuckDBStorage) closedErr(err error) error {
+	if d.ctx.Err() != nil && errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w: %w", ErrStorageClosed, err)
+	}
+	return err
+}
+
+// lifetime derives a context from ctx that is also cancelled when Close is called
+func (d *DuckDBStorage) lifetime(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := context.AfterFunc(d.ctx, cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+	}
+}
+
+// goBackground runs fn in a goroutine that Close waits for, or returns ErrStorageClosed once
+// Close has been called. The context passed to fn is cancelled when ctx is or when Close is
+// called.
+func (d *DuckDBStorage) goBackground(ctx context.Context, fn func(ctx context.Context)) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.goBackgroundLocked(ctx, fn)
+}
+
+// goBackgroundLocked is goBackground for callers holding d.mu
+func (d *DuckDBStorage) goBackgroundLocked(ctx context.Context, fn func(ctx context.Context)) error {
+	if d.closing {
+		return ErrStorageClosed
+	}
+
+	ctx, cancel := d.lifetime(ctx)
+	d.inflight.Add(1)
+	go func() {
+		defer d.inflight.Done()
+		defer cancel()
+		fn(ctx)
+	}()
+	return nil
+}
+
+// trackResource registers a resource that Close closes if the caller has not
+func (d *DuckDBStorage) trackResource(resource io.Closer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.resources[resource] = struct{}{}
+}
+
+// untrackResource forgets a resource closed by the caller
+func (d *DuckDBStorage) untrackResource(resource io.Closer) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.resources, resource)
+}
@@ -0,0 +1,282 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestMultiError(t *testing.T) {
+	err := &MultiError{Errors: []error{ErrStorageClosed, context.DeadlineExceeded}}
+	if got, want := err.Error(), "2 errors: storage closed; context deadline exceeded"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+	if !errors.Is(err, ErrStorageClosed) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("errors.Is does not match the collected errors")
+	}
+}
+
+func TestCloseStopsGoroutines(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx := context.Background()
+	before := runtime.NumGoroutine()
+
+	if err := s.StartSnapshotScheduler(ctx, path, 20*time.Millisecond); err != nil {
+		t.Fatalf("StartSnapshotScheduler: %v", err)
+	}
+	if err := s.Watch(ctx, func(*nats.ObjectInfo) error { return nil }); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if err := s.StartQueryService(ctx, "test.close.query"); err != nil {
+		t.Fatalf("StartQueryService: %v", err)
+	}
+	mem, err := s.OpenInMemory(ctx)
+	if err != nil {
+		t.Fatalf("OpenInMemory: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if s.SchedulerErrors() != nil {
+		t.Error("snapshot scheduler still running after Close")
+	}
+	if err := mem.DB().Ping(); err == nil {
+		t.Error("in-memory database still open after Close")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines running after Close, want at most %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := s.Close(ctx); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestCloseRejectsOperations(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := s.StoreDuckDB(path); !errors.Is(err, ErrStorageClosed) {
+		t.Errorf("StoreDuckDB after Close: got %v, want ErrStorageClosed", err)
+	}
+	if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); !errors.Is(err, ErrStorageClosed) {
+		t.Errorf("RetrieveDuckDB after Close: got %v, want ErrStorageClosed", err)
+	}
+	if err := s.Watch(context.Background(), func(*nats.ObjectInfo) error { return nil }); !errors.Is(err, ErrStorageClosed) {
+		t.Errorf("Watch after Close: got %v, want ErrStorageClosed", err)
+	}
+	if s.nc.IsClosed() {
+		t.Error("Close closed a caller-supplied connection")
+	}
+}
+
+func TestCloseDrainsConnection(t *testing.T) {
+	nc := startTestServer(t)
+	s := newTestStorage(t, nc, WithConnectionDrain(true), WithDrainTimeout(5*time.Second))
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !nc.IsClosed() {
+		t.Error("connection still open after Close with WithConnectionDrain")
+	}
+}
+
+// slowObjectStore delays every download
+type slowObjectStore struct {
+	nats.ObjectStore
+	delay time.Duration
+}
+
+func (o slowObjectStore) Get(name string, opts ...nats.GetObjectOpt) (nats.ObjectResult, error) {
+	time.Sleep(o.delay)
+	return o.ObjectStore.Get(name, opts...)
+}
+
+func TestCloseTimeout(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	s.obs = slowObjectStore{s.obs, 150 * time.Millisecond}
+
+	retrieved := make(chan error, 1)
+	go func() { retrieved <- s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")) }()
+	time.Sleep(20 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Close during a slow retrieve: got %v, want context.DeadlineExceeded", err)
+	}
+	select {
+	case err := <-retrieved:
+		if !errors.Is(err, ErrStorageClosed) {
+			t.Errorf("interrupted RetrieveDuckDB: got %v, want ErrStorageClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RetrieveDuckDB did not return after Close")
+	}
+}
+
+func TestClosedStorageMethods(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	ctx := context.Background()
+	out := filepath.Join(t.TempDir(), "out.db")
+	databases := map[string]string{"a": defaultDBName}
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"StoreDuckDB", func() error { return s.StoreDuckDB(path) }},
+		{"StoreDuckDBContext", func() error { return s.StoreDuckDBContext(ctx, path) }},
+		{"ForceStore", func() error { return s.ForceStore(path) }},
+		{"RetrieveDuckDB", func() error { return s.RetrieveDuckDB(out) }},
+		{"RetrieveDuckDBContext", func() error { return s.RetrieveDuckDBContext(ctx, out) }},
+		{"GetInfo", func() error { _, err := s.GetInfo(); return err }},
+		{"DeleteDatabase", func() error { return s.DeleteDatabase() }},
+		{"StoreDuckDBChunked", func() error { return s.StoreDuckDBChunked(path, 1024) }},
+		{"RetrieveDuckDBChunked", func() error { return s.RetrieveDuckDBChunked(out) }},
+		{"RetrieveDuckDBParallel", func() error { return s.RetrieveDuckDBParallel(out, 2) }},
+		{"StoreAtomic", func() error { return s.StoreAtomic(path) }},
+		{"LastGoodVersion", func() error { _, err := s.LastGoodVersion(); return err }},
+		{"StoreIfRevision", func() error { return s.StoreIfRevision(path, 1) }},
+		{"CurrentRevision", func() error { _, err := s.CurrentRevision(); return err }},
+		{"StoreDuckDBWithCheckpoint", func() error { return s.StoreDuckDBWithCheckpoint(ctx, nil, path) }},
+		{"StoreFromReader", func() error { return s.StoreFromReader(ctx, strings.NewReader("db"), 2, nil) }},
+		{"StoreReader", func() error { return s.StoreReader(strings.NewReader("db"), 2) }},
+		{"ReadFrom", func() error { _, err := s.ReadFrom(strings.NewReader("db")); return err }},
+		{"RetrieveToWriter", func() error { _, err := s.RetrieveToWriter(ctx, io.Discard); return err }},
+		{"WriteTo", func() error { _, err := s.WriteTo(io.Discard); return err }},
+		{"StoreVersion", func() error { return s.StoreVersion(path, "v1") }},
+		{"RetrieveVersion", func() error { return s.RetrieveVersion("v1", out) }},
+		{"ListVersions", func() error { _, err := s.ListVersions(); return err }},
+		{"PromoteVersion", func() error { return s.PromoteVersion("v1") }},
+		{"StoreWithTag", func() error { return s.StoreWithTag(path, "prod") }},
+		{"GetByTag", func() error { return s.GetByTag("prod", out) }},
+		{"DeleteTag", func() error { return s.DeleteTag("prod") }},
+		{"ListTags", func() error { _, err := s.ListTags(); return err }},
+		{"ListRevisions", func() error { _, err := s.ListRevisions(); return err }},
+		{"RetrieveRevision", func() error { return s.RetrieveRevision(out, 1) }},
+		{"RecoverToRevision", func() error { return s.RecoverToRevision(out, 1) }},
+		{"CopyDatabase", func() error { return s.CopyDatabase(defaultDBName, "copy.db", true) }},
+		{"CopyDatabaseToBucket", func() error { return s.CopyDatabaseToBucket(defaultDBName, "OTHER", "copy.db", true) }},
+		{"RenameDatabase", func() error { return s.RenameDatabase(defaultDBName, "renamed.db") }},
+		{"SoftDelete", func() error { return s.SoftDelete(defaultDBName, time.Hour) }},
+		{"RestoreDeleted", func() error { return s.RestoreDeleted(defaultDBName) }},
+		{"ListDeleted", func() error { _, err := s.ListDeleted(); return err }},
+		{"PurgeExpired", func() error { _, err := s.PurgeExpired(); return err }},
+		{"PurgeBucket", func() error { _, _, err := s.PurgeBucket(ctx); return err }},
+		{"Pin", func() error { return s.Pin(defaultDBName) }},
+		{"Unpin", func() error { return s.Unpin(defaultDBName) }},
+		{"ListPinned", func() error { _, err := s.ListPinned(); return err }},
+		{"ListDatabases", func() error { _, err := s.ListDatabases(); return err }},
+		{"ListAllNamespaces", func() error { _, err := s.ListAllNamespaces(); return err }},
+		{"Stat", func() error { _, err := s.Stat(); return err }},
+		{"GetObjectSize", func() error { _, err := s.GetObjectSize(defaultDBName); return err }},
+		{"EstimateRetrieveDuration", func() error { _, err := s.EstimateRetrieveDuration(defaultDBName, 10); return err }},
+		{"StorageUsage", func() error { _, err := s.StorageUsage(); return err }},
+		{"BucketStats", func() error { _, err := s.BucketStats(); return err }},
+		{"BucketConfig", func() error { _, err := s.BucketConfig(); return err }},
+		{"HealthCheck", func() error { _, err := s.HealthCheck(ctx); return err }},
+		{"ReplicationStatus", func() error { _, err := s.ReplicationStatus(); return err }},
+		{"BackupBucket", func() error { return s.BackupBucket(ctx, filepath.Join(t.TempDir(), "backup.tar")) }},
+		{"RestoreBucket", func() error { _, err := s.RestoreBucket(ctx, "backup.tar", true); return err }},
+		{"ReplicateToS3", func() error { return s.ReplicateToS3(ctx, "bucket", "key", nil) }},
+		{"AcquireLock", func() error { _, err := s.AcquireLock(ctx, time.Second); return err }},
+		{"QueryRows", func() error { _, err := s.QueryRows(ctx, "SELECT 1"); return err }},
+		{"QueryRow", func() error { var n int; return s.QueryRow(ctx, "SELECT 1").Scan(&n) }},
+		{"FetchAndQuery", func() error { _, err := s.FetchAndQuery(ctx, "SELECT 1"); return err }},
+		{"RunQueryOnLatest", func() error { _, err := s.RunQueryOnLatest(ctx, "SELECT 1"); return err }},
+		{"QueryPlan", func() error { _, err := s.QueryPlan(ctx, "SELECT 1"); return err }},
+		{"AggregateQuery", func() error { _, err := s.AggregateQuery(ctx, "SELECT 1", []string{defaultDBName}); return err }},
+		{"AggregateQueryAll", func() error { _, err := s.AggregateQueryAll(ctx, "SELECT 1"); return err }},
+		{"CrossQuery", func() error { _, err := s.CrossQuery(ctx, databases, "SELECT 1"); return err }},
+		{"QueryJoinNATSKV", func() error { _, err := s.QueryJoinNATSKV(ctx, "SELECT 1", "KV", "kv"); return err }},
+		{"QueryToKV", func() error { return s.QueryToKV(ctx, "SELECT 1 AS k", "KV", "k") }},
+		{"QueryFromKV", func() error { return s.QueryFromKV(ctx, "KV", []string{"k"}, "t") }},
+		{"QueryRemote", func() error { _, err := s.QueryRemote(ctx, "test.query", "SELECT 1"); return err }},
+		{"DescribeTable", func() error { _, err := s.DescribeTable(ctx, "users"); return err }},
+		{"TableExists", func() error { _, err := s.TableExists(ctx, "users"); return err }},
+		{"ListTables", func() error { _, err := s.ListTables(ctx); return err }},
+		{"DatabaseStats", func() error { _, err := s.DatabaseStats(ctx); return err }},
+		{"DiffDatabases", func() error { _, err := s.DiffDatabases(ctx, defaultDBName, "other.db"); return err }},
+		{"RetrieveAndDiff", func() error { _, err := s.RetrieveAndDiff(ctx, path); return err }},
+		{"SyncFromNATSIfNewer", func() error { _, err := s.SyncFromNATSIfNewer(ctx, out); return err }},
+		{"VerifyStoredDatabase", func() error { return s.VerifyStoredDatabase(nil) }},
+		{"ExecuteDDL", func() error { return s.ExecuteDDL(ctx, "CREATE TABLE t (x INTEGER)") }},
+		{"TruncateTable", func() error { return s.TruncateTable(ctx, "users") }},
+		{"TruncateAllTables", func() error { _, err := s.TruncateAllTables(ctx); return err }},
+		{"CompactDatabase", func() error { _, err := s.CompactDatabase(ctx); return err }},
+		{"StoreQueryResult", func() error { return s.StoreQueryResult(ctx, "SELECT 1", "derived.db", "t") }},
+		{"MergeDatabase", func() error {
+			_, err := s.MergeDatabase(ctx, path, defaultDBName, "users", "id", MergeInsertOnly)
+			return err
+		}},
+		{"MigrateFromSQLite", func() error { _, err := s.MigrateFromSQLite(ctx, "source.sqlite", "migrated.db"); return err }},
+		{"StoreMigration", func() error { return s.StoreMigration("001", "SELECT 1") }},
+		{"MigrateSchema", func() error { _, err := s.MigrateSchema(ctx, path); return err }},
+		{"StoreSchema", func() error { return s.StoreSchema(path) }},
+		{"RetrieveSchema", func() error { _, err := s.RetrieveSchema(); return err }},
+		{"CompareSchemas", func() error { _, err := s.CompareSchemas("v1", "v2"); return err }},
+		{"CreateSnapshot", func() error { return s.CreateSnapshot(ctx, "snap") }},
+		{"RestoreFromSnapshot", func() error { return s.RestoreFromSnapshot(ctx, "snap", out) }},
+		{"SignDatabase", func() error { return s.SignDatabase(nil) }},
+		{"VerifySignature", func() error { return s.VerifySignature(nil) }},
+		{"RotateEncryptionKey", func() error { return s.RotateEncryptionKey(nil, nil) }},
+		{"StoreExtension", func() error { return s.StoreExtension("ext", path) }},
+		{"LoadExtension", func() error { return s.LoadExtension(ctx, nil, "ext") }},
+		{"ListExtensions", func() error { _, err := s.ListExtensions(); return err }},
+		{"StoreWAL", func() error { return s.StoreWAL(path, 1) }},
+		{"ReplayWAL", func() error { return s.ReplayWAL(path, out, 1, 2) }},
+		{"PurgeWALsBefore", func() error { _, err := s.PurgeWALsBefore(1); return err }},
+		{"ExportTableToParquet", func() error { return s.ExportTableToParquet(ctx, path, "users", "users.parquet") }},
+		{"ImportParquetToTable", func() error { return s.ImportParquetToTable(ctx, "users.parquet", out, "users") }},
+		{"ExportParquetPartitioned", func() error { _, err := s.ExportParquetPartitioned(ctx, "users", "name"); return err }},
+		{"ListPartitions", func() error { _, err := s.ListPartitions("users", "name"); return err }},
+		{"ExportQueryToArrow", func() error { return s.ExportQueryToArrow(ctx, "SELECT 1", "q.arrow") }},
+		{"ImportArrowToTable", func() error { return s.ImportArrowToTable(ctx, "q.arrow", out, "t") }},
+		{"ExportQueryToCSV", func() error { _, err := s.ExportQueryToCSV(ctx, "SELECT 1", "q.csv", CSVExportOptions{}); return err }},
+		{"ImportCSVFromNATS", func() error { _, err := s.ImportCSVFromNATS(ctx, "q.csv", out, "t", CSVImportOptions{}); return err }},
+		{"ExportQueryToJSONLines", func() error { _, err := s.ExportQueryToJSONLines(ctx, "SELECT 1", "q.jsonl"); return err }},
+		{"ImportJSONLinesFromNATS", func() error { _, err := s.ImportJSONLinesFromNATS(ctx, "q.jsonl", out, "t"); return err }},
+		{"ExportToBigQueryJSON", func() error { return s.ExportToBigQueryJSON(ctx, "SELECT 1", "q.json") }},
+		{"StreamQueryResults", func() error { return s.StreamQueryResults(ctx, "SELECT 1", "test.results") }},
+		{"BulkInsertFromStream", func() error { _, err := s.BulkInsertFromStream(ctx, "S", "c", "t", 10, time.Second); return err }},
+		{"ReplayDeadLetter", func() error { return s.ReplayDeadLetter(nil) }},
+		{"OpenInMemory", func() error { _, err := s.OpenInMemory(ctx); return err }},
+		{"OpenInMemoryReadOnly", func() error { _, err := s.OpenInMemoryReadOnly(ctx); return err }},
+		{"OpenAppender", func() error { _, err := s.OpenAppender(ctx, "users"); return err }},
+		{"Watch", func() error { return s.Watch(ctx, func(*nats.ObjectInfo) error { return nil }) }},
+		{"WatchAll", func() error { return s.WatchAll(ctx, "", func(*nats.ObjectInfo) error { return nil }) }},
+		{"StartSnapshotScheduler", func() error { return s.StartSnapshotScheduler(ctx, path, time.Second) }},
+		{"StartFileWatcher", func() error { return s.StartFileWatcher(ctx, path) }},
+		{"StartQueryService", func() error { return s.StartQueryService(ctx, "test.query") }},
+		{"StartQueryWorker", func() error { return s.StartQueryWorker(ctx, "test.worker", 1) }},
+		{"SetWorkerCount", func() error { return s.SetWorkerCount(2) }},
+		{"StartMessageIngester", func() error { return s.StartMessageIngester(ctx, "test.ingest", "S", "c", "t", time.Second) }},
+		{"StartCDCPublisher", func() error { return s.StartCDCPublisher(ctx, path, "users", "test.cdc", time.Second) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, ErrStorageClosed) {
+				t.Errorf("%s after Close: got %v, want ErrStorageClosed", tt.name, err)
+			}
+		})
+	}
+}
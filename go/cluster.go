@@ -0,0 +1,175 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// clusterHealthTimeout bounds the health check of a single cluster in ClusterHealth
+const clusterHealthTimeout = 2 * time.Second
+
+// ErrClusterUnavailable is returned by DuckDBStorageCluster when no cluster could serve a read
+var ErrClusterUnavailable = errors.New("no cluster available")
+
+// ClusterConfig describes one NATS cluster of a DuckDBStorageCluster
+type ClusterConfig struct {
+	URL string
+	// Credentials is the path of a NATS credentials file, empty connects without one
+	Credentials string
+	// Bucket is the object store bucket on this cluster, empty uses the default bucket
+	Bucket string
+	// Priority orders the clusters for reads, lower values are tried first
+	Priority int
+}
+
+// clusterMember is a cluster of a DuckDBStorageCluster with its storage handler
+type clusterMember struct {
+	url     string
+	storage *DuckDBStorage
+}
+
+// DuckDBStorageCluster stores a database on several independent NATS clusters. Writes go to
+// every cluster, reads are served by the first cluster in priority order that succeeds.
+type DuckDBStorageCluster struct {
+	members []clusterMember
+}
+
+// NewDuckDBStorageCluster connects to every cluster and creates a storage handler for dbName
+// on each. opts apply to all of them. It fails if any cluster cannot be reached.
+func NewDuckDBStorageCluster(clusters []ClusterConfig, dbName string, opts ...Option) (*DuckDBStorageCluster, error) {
+	if len(clusters) == 0 {
+		return nil, errors.New("at least one cluster is required")
+	}
+	clusters = slices.Clone(clusters)
+	slices.SortStableFunc(clusters, func(a, b ClusterConfig) int {
+		return cmp.Compare(a.Priority, b.Priority)
+	})
+
+	options := defaultStorageOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	c := &DuckDBStorageCluster{}
+	for _, cluster := range clusters {
+		dialOpts := append([]nats.Option(nil), options.NATSOptions...)
+		if cluster.Credentials != "" {
+			dialOpts = append(dialOpts, nats.UserCredentials(cluster.Credentials))
+		}
+		nc, err := nats.Connect(cluster.URL, dialOpts...)
+		if err != nil {
+			c.Close(context.Background())
+			return nil, fmt.Errorf("failed to connect to cluster %s: %w", cluster.URL, err)
+		}
+
+		clusterOpts := append(slices.Clone(opts), WithDBName(dbName))
+		if cluster.Bucket != "" {
+			clusterOpts = append(clusterOpts, WithBucket(cluster.Bucket))
+		}
+		storage, err := NewDuckDBStorage(nc, clusterOpts...)
+		if err != nil {
+			nc.Close()
+			c.Close(context.Background())
+			return nil, fmt.Errorf("cluster %s: %w", cluster.URL, err)
+		}
+		storage.ownsConn = true
+		c.members = append(c.members, clusterMember{url: cluster.URL, storage: storage})
+	}
+	return c, nil
+}
+
+// StoreDuckDB stores the database file on every cluster concurrently. Failed clusters are
+// reported in a *MultiError while the store succeeds on the others. ErrUnchanged is only
+// returned if deduplication skipped the store on every cluster.
+func (c *DuckDBStorageCluster) StoreDuckDB(dbFilePath string) error {
+	results := make([]error, len(c.members))
+	var wg sync.WaitGroup
+	for i, member := range c.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = member.storage.StoreDuckDB(dbFilePath)
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	unchanged := 0
+	for i, err := range results {
+		switch {
+		case errors.Is(err, ErrUnchanged):
+			unchanged++
+		case err != nil:
+			errs = append(errs, fmt.Errorf("cluster %s: %w", c.members[i].url, err))
+		}
+	}
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	if unchanged == len(c.members) {
+		return results[0]
+	}
+	return nil
+}
+
+// RetrieveDuckDB retrieves the database to outputPath from the first cluster in priority order
+// that serves it. Disconnected clusters are skipped without waiting for a request to time out.
+// If every cluster fails the errors are returned with ErrClusterUnavailable.
+func (c *DuckDBStorageCluster) RetrieveDuckDB(outputPath string) error {
+	var errs []error
+	for _, member := range c.members {
+		if !member.storage.nc.IsConnected() {
+			errs = append(errs, fmt.Errorf("cluster %s: not connected", member.url))
+			continue
+		}
+		err := member.storage.RetrieveDuckDB(outputPath)
+		if err == nil {
+			return nil
+		}
+		errs = append(errs, fmt.Errorf("cluster %s: %w", member.url, err))
+	}
+	return fmt.Errorf("%w: %w", ErrClusterUnavailable, &MultiError{Errors: errs})
+}
+
+// ClusterHealth runs HealthCheck on every cluster and reports which passed, keyed by URL
+func (c *DuckDBStorageCluster) ClusterHealth() map[string]bool {
+	health := make(map[string]bool, len(c.members))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, member := range c.members {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), clusterHealthTimeout)
+			defer cancel()
+			_, err := member.storage.HealthCheck(ctx)
+
+			mu.Lock()
+			health[member.url] = err == nil
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return health
+}
+
+// Close closes the storage handler and connection of every cluster
+func (c *DuckDBStorageCluster) Close(ctx context.Context) error {
+	var errs []error
+	for _, member := range c.members {
+		if err := member.storage.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("cluster %s: %w", member.url, err))
+		}
+	}
+	if len(errs) > 0 {
+		return &MultiError{Errors: errs}
+	}
+	return nil
+}
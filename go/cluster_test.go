@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestDuckDBStorageCluster(t *testing.T) {
+	primary, secondary := runTestServer(t, nil), runTestServer(t, nil)
+	c, err := NewDuckDBStorageCluster([]ClusterConfig{
+		{URL: secondary.ClientURL(), Priority: 2},
+		{URL: primary.ClientURL(), Priority: 1, Bucket: "PRIMARY"},
+	}, "cluster.db", WithNATSOptions(nats.MaxReconnects(-1)))
+	if err != nil {
+		t.Fatalf("NewDuckDBStorageCluster: %v", err)
+	}
+	defer c.Close(context.Background())
+	if c.members[0].url != primary.ClientURL() {
+		t.Errorf("first cluster = %s, want the primary by priority", c.members[0].url)
+	}
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	if err := c.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	// Writes go to every cluster, each with its own bucket
+	for bucket, srv := range map[string]string{"PRIMARY": primary.ClientURL(), defaultBucket: secondary.ClientURL()} {
+		nc, err := nats.Connect(srv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := newTestStorage(t, nc, WithBucket(bucket), WithDBName("cluster.db"))
+		if rev, err := s.CurrentRevision(); err != nil || rev == 0 {
+			t.Errorf("database not stored in %s on %s: %v", bucket, srv, err)
+		}
+		nc.Close()
+	}
+	if health := c.ClusterHealth(); !health[primary.ClientURL()] || !health[secondary.ClientURL()] {
+		t.Errorf("health = %v, want both clusters healthy", health)
+	}
+
+	primary.Shutdown()
+	deadline := time.Now().Add(3 * time.Second)
+	for c.members[0].storage.nc.IsConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("still connected to the primary after its shutdown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if health := c.ClusterHealth(); health[primary.ClientURL()] || !health[secondary.ClientURL()] {
+		t.Errorf("health = %v, want only the secondary healthy", health)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := c.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB with the primary down: %v", err)
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("retrieved database holds %d users, want 3", n)
+	}
+	var multi *MultiError
+	if err := c.StoreDuckDB(path); !errors.As(err, &multi) || len(multi.Errors) != 1 {
+		t.Errorf("StoreDuckDB with the primary down: got %v, want one failed cluster", err)
+	}
+
+	secondary.Shutdown()
+	deadline = time.Now().Add(3 * time.Second)
+	for c.members[1].storage.nc.IsConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("still connected to the secondary after its shutdown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := c.RetrieveDuckDB(out); !errors.Is(err, ErrClusterUnavailable) {
+		t.Errorf("RetrieveDuckDB with every cluster down: got %v, want ErrClusterUnavailable", err)
+	}
+}
+
+func TestNewDuckDBStorageClusterInvalid(t *testing.T) {
+	if _, err := NewDuckDBStorageCluster(nil, "cluster.db"); err == nil {
+		t.Error("NewDuckDBStorageCluster accepted no clusters")
+	}
+	srv := runTestServer(t, nil)
+	_, err := NewDuckDBStorageCluster([]ClusterConfig{
+		{URL: srv.ClientURL()},
+		{URL: "nats://127.0.0.1:1"},
+	}, "cluster.db")
+	if err == nil {
+		t.Error("NewDuckDBStorageCluster accepted an unreachable cluster")
+	}
+}
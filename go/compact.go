@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// defaultCompactionThreshold is the size reduction in percent below which a compacted
+// database is not stored
+const defaultCompactionThreshold = 1.0
+
+// CompactionResult reports the outcome of CompactDatabase
+type CompactionResult struct {
+	OriginalSize  int64
+	CompactedSize int64
+	// SpaceSaved is the size reduction of the stored database, zero if the compacted copy was
+	// not stored
+	SpaceSaved int64
+}
+
+// CompactDatabase rewrites the stored database without the space left behind by deleted and
+// updated rows. The copy is vacuumed and, since DuckDB does not return freed blocks to the file
+// system, copied into a fresh file with COPY FROM DATABASE. The result is stored only if it is
+// smaller by more than the compaction threshold.
+func (d *DuckDBStorage) CompactDatabase(ctx context.Context) (result CompactionResult, err error) {
+	op := d.logOperation("compact")
+	defer func() {
+		op.done(err, "original_size", result.OriginalSize, "compacted_size", result.CompactedSize, "saved", result.SpaceSaved)
+	}()
+	end, err := d.beginOperation()
+	if err != nil {
+		return result, err
+	}
+	defer func() { end(err) }()
+
+	locks, release, err := d.rewriteLock(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer release()
+
+	original, err := tempPath("duckdb-nats-compact-*.db")
+	if err != nil {
+		return result, err
+	}
+	defer removeTempDatabase(original)
+
+	if err := d.RetrieveDuckDBContext(ctx, original, locks...); err != nil {
+		return result, err
+	}
+	result.OriginalSize = fileSize(original)
+
+	compacted, err := tempPath("duckdb-nats-compacted-*.db")
+	if err != nil {
+		return result, err
+	}
+	defer removeTempDatabase(compacted)
+
+	if err := compactFile(ctx, original, compacted); err != nil {
+		return result, err
+	}
+	result.CompactedSize = fileSize(compacted)
+
+	saved := result.OriginalSize - result.CompactedSize
+	if float64(saved) <= float64(result.OriginalSize)*d.opts.CompactionThreshold/100 {
+		return result, nil
+	}
+	if err := d.StoreDuckDBContext(ctx, compacted, locks...); err != nil {
+		return result, err
+	}
+	result.SpaceSaved = saved
+	return result, nil
+}
+
+// compactFile vacuums the database at src and copies it into a new database at dst
+func compactFile(ctx context.Context, src, dst string) error {
+	db, err := openDuckDB("")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("ATTACH %s AS original", quoteLiteral(src))); err != nil {
+		return fmt.Errorf("failed to attach database: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "USE original; VACUUM; CHECKPOINT original; USE memory"); err != nil {
+		return fmt.Errorf("failed to vacuum database: %w", err)
+	}
+	if err := copyDatabase(ctx, db, dst, "compacted", "original", "compacted", ""); err != nil {
+		return fmt.Errorf("failed to compact database: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// createFragmentedDatabase creates a database at path whose events table had most of its rows
+// deleted after they were written to disk
+func createFragmentedDatabase(tb testing.TB, path string) {
+	tb.Helper()
+	execTestDatabase(tb, path,
+		"CREATE TABLE events AS SELECT i AS id, repeat('x', 100) || i::VARCHAR AS payload FROM range(300000) r(i)",
+		"CREATE TABLE kept AS SELECT 1 AS id",
+		"CREATE VIEW kept_view AS SELECT * FROM kept",
+		"CHECKPOINT",
+		"DELETE FROM events WHERE id >= 1000",
+		"CHECKPOINT")
+}
+
+func TestCompactDatabase(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "test.db")
+	createFragmentedDatabase(t, path)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	stored, err := s.CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.CompactDatabase(ctx)
+	if err != nil {
+		t.Fatalf("CompactDatabase: %v", err)
+	}
+	if result.OriginalSize != fileSize(path) {
+		t.Errorf("original size = %d, want %d", result.OriginalSize, fileSize(path))
+	}
+	if result.CompactedSize >= result.OriginalSize || result.SpaceSaved != result.OriginalSize-result.CompactedSize {
+		t.Fatalf("result = %+v, want a smaller compacted database", result)
+	}
+	if rev, err := s.CurrentRevision(); err != nil || rev == stored {
+		t.Errorf("revision after compaction = %d, %v, want a new revision", rev, err)
+	}
+
+	var events, kept int
+	if err := s.QueryRow(ctx, "SELECT (SELECT count(*) FROM events), (SELECT count(*) FROM kept_view)").Scan(&events, &kept); err != nil {
+		t.Fatalf("failed to query the compacted database: %v", err)
+	}
+	if events != 1000 || kept != 1 {
+		t.Errorf("compacted database holds %d events and %d kept rows, want 1000 and 1", events, kept)
+	}
+
+	// Compacting again saves too little to be stored
+	compacted, err := s.CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = s.CompactDatabase(ctx)
+	if err != nil {
+		t.Fatalf("second CompactDatabase: %v", err)
+	}
+	if result.SpaceSaved != 0 {
+		t.Errorf("second compaction saved %d bytes, want 0", result.SpaceSaved)
+	}
+	if rev, err := s.CurrentRevision(); err != nil || rev != compacted {
+		t.Errorf("revision after the second compaction = %d, %v, want %d", rev, err, compacted)
+	}
+}
+
+func TestCompactionThreshold(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithCompactionThreshold(100))
+	path := filepath.Join(t.TempDir(), "test.db")
+	createFragmentedDatabase(t, path)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	stored, err := s.CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.CompactDatabase(context.Background())
+	if err != nil {
+		t.Fatalf("CompactDatabase: %v", err)
+	}
+	if result.CompactedSize >= result.OriginalSize || result.SpaceSaved != 0 {
+		t.Errorf("result = %+v, want a smaller copy that is not stored", result)
+	}
+	if rev, err := s.CurrentRevision(); err != nil || rev != stored {
+		t.Errorf("revision = %d, %v, want %d", rev, err, stored)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionAlgorithm identifies how a stored database object is compressed
+type CompressionAlgorithm string
+
+const (
+	CompressionNone CompressionAlgorithm = ""
+	CompressionGzip CompressionAlgorithm = "gzip"
+	CompressionZstd CompressionAlgorithm = "zstd"
+)
+
+// compressionHeader records the algorithm used for an object
+const compressionHeader = "X-Compression"
+
+// compressReader returns a reader yielding the compressed contents of r
+func compressReader(r io.Reader, algorithm CompressionAlgorithm) (io.ReadCloser, error) {
+	pr, pw := io.Pipe()
+
+	var w io.WriteCloser
+	switch algorithm {
+	case CompressionGzip:
+		w = gzip.NewWriter(pw)
+	case CompressionZstd:
+		zw, err := zstd.NewWriter(pw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		w = zw
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm: %q", algorithm)
+	}
+
+	go func() {
+		_, err := io.Copy(w, r)
+		if closeErr := w.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr, nil
+}
+
+// decompressReader returns a reader yielding the decompressed contents of r
+func decompressReader(r io.Reader, algorithm CompressionAlgorithm) (io.ReadCloser, error) {
+	switch algorithm {
+	case CompressionGzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create gzip reader: %w", err)
+		}
+		return zr, nil
+	case CompressionZstd:
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create zstd reader: %w", err)
+		}
+		return zr.IOReadCloser(), nil
+	default:
+		return nil, fmt.Errorf("unsupported compression algorithm: %q", algorithm)
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// algorithmName names algorithm in subtests
+func algorithmName(algorithm CompressionAlgorithm) string {
+	if algorithm == CompressionNone {
+		return "none"
+	}
+	return string(algorithm)
+}
+
+func TestCompressReaderRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("hello duckdb "), 10000)
+	for _, algorithm := range []CompressionAlgorithm{CompressionGzip, CompressionZstd} {
+		t.Run(string(algorithm), func(t *testing.T) {
+			compressed, err := compressReader(bytes.NewReader(data), algorithm)
+			if err != nil {
+				t.Fatalf("compressReader: %v", err)
+			}
+			packed, err := io.ReadAll(compressed)
+			if err != nil {
+				t.Fatalf("failed to compress: %v", err)
+			}
+			if len(packed) >= len(data) {
+				t.Errorf("compressed size %d not smaller than %d", len(packed), len(data))
+			}
+
+			decompressed, err := decompressReader(bytes.NewReader(packed), algorithm)
+			if err != nil {
+				t.Fatalf("decompressReader: %v", err)
+			}
+			defer decompressed.Close()
+			got, err := io.ReadAll(decompressed)
+			if err != nil {
+				t.Fatalf("failed to decompress: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("decompressed data does not match the original")
+			}
+		})
+	}
+}
+
+func TestCompressReaderUnsupported(t *testing.T) {
+	if _, err := compressReader(bytes.NewReader(nil), "lz4"); err == nil {
+		t.Error("compressReader accepted an unsupported algorithm")
+	}
+	if _, err := decompressReader(bytes.NewReader(nil), "lz4"); err == nil {
+		t.Error("decompressReader accepted an unsupported algorithm")
+	}
+}
+
+func TestStoreRetrieveCompressed(t *testing.T) {
+	nc := startTestServer(t)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src.db")
+	createTestDatabase(t, src)
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, algorithm := range []CompressionAlgorithm{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(algorithmName(algorithm), func(t *testing.T) {
+			s := newTestStorage(t, nc, WithDBName("compressed-"+algorithmName(algorithm)+".db"), WithCompression(algorithm))
+			if err := s.StoreDuckDB(src); err != nil {
+				t.Fatalf("StoreDuckDB: %v", err)
+			}
+
+			info, err := s.GetInfo()
+			if err != nil {
+				t.Fatalf("GetInfo: %v", err)
+			}
+			if got := CompressionAlgorithm(info.Headers.Get(compressionHeader)); got != algorithm {
+				t.Errorf("compression header = %q, want %q", got, algorithm)
+			}
+			if algorithm != CompressionNone && info.Size >= uint64(len(data)) {
+				t.Errorf("stored size %d not smaller than %d", info.Size, len(data))
+			}
+
+			out := filepath.Join(t.TempDir(), "out.db")
+			if err := s.RetrieveDuckDB(out); err != nil {
+				t.Fatalf("RetrieveDuckDB: %v", err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatal("retrieved database does not match the stored one")
+			}
+		})
+	}
+}
+
+// BenchmarkCompression stores and retrieves a database of about 100 MB under each algorithm,
+// reporting the bytes sent to NATS per operation
+func BenchmarkCompression(b *testing.B) {
+	nc := startTestServer(b)
+	dir := b.TempDir()
+	src := filepath.Join(dir, "src.db")
+	execTestDatabase(b, src,
+		"CREATE TABLE t AS SELECT i AS id, md5(i::VARCHAR) AS hash, random() AS value FROM range(4000000) r(i)")
+	fi, err := os.Stat(src)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, algorithm := range []CompressionAlgorithm{CompressionNone, CompressionGzip, CompressionZstd} {
+		b.Run(algorithmName(algorithm), func(b *testing.B) {
+			s := newTestStorage(b, nc, WithDBName("bench-"+algorithmName(algorithm)+".db"), WithCompression(algorithm))
+			out := filepath.Join(b.TempDir(), "out.db")
+			b.SetBytes(fi.Size())
+			b.ResetTimer()
+
+			var transferred uint64
+			for i := 0; i < b.N; i++ {
+				if err := s.StoreDuckDB(src); err != nil {
+					b.Fatalf("StoreDuckDB: %v", err)
+				}
+				if err := s.RetrieveDuckDB(out); err != nil {
+					b.Fatalf("RetrieveDuckDB: %v", err)
+				}
+
+				b.StopTimer()
+				info, err := s.GetInfo()
+				if err != nil {
+					b.Fatalf("GetInfo: %v", err)
+				}
+				transferred = info.Size
+				os.Remove(out)
+				b.StartTimer()
+			}
+			b.ReportMetric(float64(transferred), "stored-bytes/op")
+		})
+	}
+}
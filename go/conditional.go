@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// conditionalLockTTL is the lease of the lock taken by StoreIfRevision, kept alive while storing
+	conditionalLockTTL = 30 * time.Second
+	// conditionalLockPoll is how often StoreIfRevision retries a lock held by a concurrent writer
+	conditionalLockPoll = 50 * time.Millisecond
+)
+
+// ErrRevisionConflict is returned by StoreIfRevision when the database changed since the
+// caller read it
+type ErrRevisionConflict struct {
+	Current  uint64
+	Expected uint64
+}
+
+func (e ErrRevisionConflict) Error() string {
+	return fmt.Sprintf("revision conflict: expected %d, current %d", e.Expected, e.Current)
+}
+
+// CurrentRevision returns the revision of the stored database, or 0 if it has not been stored
+func (d *DuckDBStorage) CurrentRevision() (_ uint64, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { end(err) }()
+
+	name := d.dbName
+	if d.opts.ChunkSize > 0 {
+		name = d.manifestName()
+	}
+
+	revision, err := d.metaRevision(name)
+	if errors.Is(err, ErrObjectNotFound) {
+		return 0, nil
+	}
+	return revision, err
+}
+
+// StoreIfRevision stores the database only if the stored revision still equals
+// expectedRevision, as returned by CurrentRevision, and returns ErrRevisionConflict otherwise.
+// The check and the store run under the database lock, so of several writers expecting the
+// same revision exactly one succeeds.
+func (d *DuckDBStorage) StoreIfRevision(dbFilePath string, expectedRevision uint64) (err error) {
+	op := d.logOperation("store_if_revision", "path", dbFilePath, "expected", expectedRevision)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	lock, err := d.waitForLock(context.Background(), conditionalLockTTL)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	current, err := d.CurrentRevision()
+	if err != nil {
+		return err
+	}
+	if current != expectedRevision {
+		return ErrRevisionConflict{Current: current, Expected: expectedRevision}
+	}
+
+	return d.StoreDuckDB(dbFilePath, lock)
+}
+
+// waitForLock acquires the database lock, waiting while another writer holds it. A writer
+// holding the lock for longer than ttl makes it give up with ErrLockHeld.
+func (d *DuckDBStorage) waitForLock(ctx context.Context, ttl time.Duration) (Lock, error) {
+	ctx, cancel := context.WithTimeout(ctx, ttl)
+	defer cancel()
+
+	for {
+		lock, err := d.AcquireLock(ctx, ttl)
+		if !errors.Is(err, ErrLockHeld) {
+			return lock, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, err
+		case <-time.After(conditionalLockPoll):
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStoreIfRevisionEmpty(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	if rev, err := s.CurrentRevision(); err != nil || rev != 0 {
+		t.Fatalf("CurrentRevision before storing = %d, %v, want 0", rev, err)
+	}
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	if err := s.StoreIfRevision(path, 0); err != nil {
+		t.Fatalf("StoreIfRevision(0) of a new database: %v", err)
+	}
+	var conflict ErrRevisionConflict
+	if err := s.StoreIfRevision(path, 0); !errors.As(err, &conflict) {
+		t.Fatalf("StoreIfRevision(0) of a stored database: got %v, want ErrRevisionConflict", err)
+	}
+	if conflict.Expected != 0 || conflict.Current == 0 {
+		t.Errorf("conflict = %+v, want expected 0 and the stored revision", conflict)
+	}
+}
+
+func TestStoreIfRevisionConcurrent(t *testing.T) {
+	for _, chunkSize := range []int64{0, 4096} {
+		t.Run(fmt.Sprintf("chunk=%d", chunkSize), func(t *testing.T) {
+			server := runTestServer(t, nil)
+			path := filepath.Join(t.TempDir(), "test.db")
+			createTestDatabase(t, path)
+
+			// Each writer uses its own connection, like separate processes
+			var writers []*DuckDBStorage
+			for len(writers) < 4 {
+				writers = append(writers, newTestStorage(t, connectTestServer(t, server), WithChunkSize(chunkSize)))
+			}
+			s := writers[0]
+			if err := s.StoreDuckDB(path); err != nil {
+				t.Fatal(err)
+			}
+
+			for round := 1; round <= 3; round++ {
+				rev, err := s.CurrentRevision()
+				if err != nil || rev == 0 {
+					t.Fatalf("round %d: CurrentRevision = %d, %v", round, rev, err)
+				}
+
+				errs := make([]error, len(writers))
+				var wg sync.WaitGroup
+				for i, w := range writers {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						errs[i] = w.StoreIfRevision(path, rev)
+					}()
+				}
+				wg.Wait()
+
+				succeeded, conflicts := 0, 0
+				for _, err := range errs {
+					var conflict ErrRevisionConflict
+					switch {
+					case err == nil:
+						succeeded++
+					case errors.As(err, &conflict):
+						conflicts++
+						if conflict.Expected != rev || conflict.Current == rev {
+							t.Errorf("round %d: conflict = %+v, want expected %d", round, conflict, rev)
+						}
+					default:
+						t.Fatalf("round %d: StoreIfRevision: %v", round, err)
+					}
+				}
+				if succeeded != 1 || conflicts != len(writers)-1 {
+					t.Fatalf("round %d: %d stores succeeded and %d conflicted, want 1 and %d", round, succeeded, conflicts, len(writers)-1)
+				}
+			}
+		})
+	}
+}
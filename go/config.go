@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"gopkg.in/yaml.v3"
+)
+
+// configRetryBaseDelay is the first retry delay used for max_retries from a config file
+const configRetryBaseDelay = 100 * time.Millisecond
+
+// defaultConfig is written by WriteDefaultConfig
+const defaultConfig = `# DuckDB storage configuration. JSON with the same keys is accepted as well.
+
+# URL of the NATS server, several servers may be given separated by commas
+nats_url: nats://127.0.0.1:4222
+
+# Object store bucket, created if it does not exist
+bucket: DUCKDB
+
+# Object name of the database in the bucket
+db_name: duckdb.db
+
+# Compression of stored databases: none, gzip or zstd
+compression: none
+
+# Retries of transient NATS failures, 0 disables retries
+max_retries: 0
+
+# Store databases as chunks of this many bytes, 0 stores a single object
+chunk_size: 0
+
+# Environment variable holding the 32-byte encryption key, hex or base64 encoded.
+# Leave empty to store databases unencrypted.
+encryption_key_env: ""
+`
+
+// StorageConfig is the file format read by NewDuckDBStorageFromConfigFile
+type StorageConfig struct {
+	NATSURL          string `yaml:"nats_url"`
+	Bucket           string `yaml:"bucket"`
+	DBName           string `yaml:"db_name"`
+	Compression      string `yaml:"compression"`
+	MaxRetries       int    `yaml:"max_retries"`
+	ChunkSize        int64  `yaml:"chunk_size"`
+	EncryptionKeyEnv string `yaml:"encryption_key_env"`
+}
+
+// LoadConfig reads a YAML or JSON storage configuration file
+func LoadConfig(path string) (StorageConfig, error) {
+	var config StorageConfig
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return config, fmt.Errorf("failed to read config file: %w", err)
+	}
+	// JSON documents are valid YAML
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Options converts the configuration into storage options. Empty fields keep the defaults.
+func (c StorageConfig) Options() ([]Option, error) {
+	var opts []Option
+	if c.Bucket != "" {
+		opts = append(opts, WithBucket(c.Bucket))
+	}
+	if c.DBName != "" {
+		opts = append(opts, WithDBName(c.DBName))
+	}
+
+	switch algorithm := CompressionAlgorithm(c.Compression); algorithm {
+	case "none", CompressionNone:
+	case CompressionGzip, CompressionZstd:
+		opts = append(opts, WithCompression(algorithm))
+	default:
+		return nil, fmt.Errorf("invalid compression: %q", c.Compression)
+	}
+
+	if c.MaxRetries < 0 {
+		return nil, fmt.Errorf("invalid max_retries: %d", c.MaxRetries)
+	}
+	if c.MaxRetries > 0 {
+		opts = append(opts, WithRetry(c.MaxRetries+1, configRetryBaseDelay))
+	}
+	if c.ChunkSize < 0 {
+		return nil, fmt.Errorf("invalid chunk_size: %d", c.ChunkSize)
+	}
+	if c.ChunkSize > 0 {
+		opts = append(opts, WithChunkSize(c.ChunkSize))
+	}
+
+	if c.EncryptionKeyEnv != "" {
+		key, err := encryptionKeyFromEnv(c.EncryptionKeyEnv)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithEncryptionKey(key))
+	}
+	return opts, nil
+}
+
+// encryptionKeyFromEnv decodes the hex or base64 encoded key held by the environment variable
+func encryptionKeyFromEnv(name string) ([]byte, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, fmt.Errorf("encryption key variable %s is not set", name)
+	}
+	if key, err := hex.DecodeString(value); err == nil {
+		return key, nil
+	}
+	key, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("encryption key variable %s is neither hex nor base64", name)
+	}
+	return key, nil
+}
+
+// NewDuckDBStorageFromConfigFile connects to NATS and creates a storage handler as described by
+// the configuration file at path. opts are applied after the file and take precedence. The
+// handler owns the connection, Close drains and closes it.
+func NewDuckDBStorageFromConfigFile(path string, opts ...Option) (*DuckDBStorage, error) {
+	config, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	configOpts, err := config.Options()
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	opts = append(configOpts, opts...)
+
+	options := defaultStorageOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	url := config.NATSURL
+	if url == "" {
+		url = nats.DefaultURL
+	}
+	nc, err := nats.Connect(url, options.NATSOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	d, err := NewDuckDBStorage(nc, opts...)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	d.ownsConn = true
+	return d, nil
+}
+
+// WriteDefaultConfig writes a commented example configuration to path, failing if the file
+// already exists
+func WriteDefaultConfig(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+	if _, err := file.WriteString(defaultConfig); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
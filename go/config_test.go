@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteDefaultConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "duckdb.yaml")
+	if err := WriteDefaultConfig(path); err != nil {
+		t.Fatalf("WriteDefaultConfig: %v", err)
+	}
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	want := StorageConfig{
+		NATSURL:     "nats://127.0.0.1:4222",
+		Bucket:      defaultBucket,
+		DBName:      defaultDBName,
+		Compression: "none",
+	}
+	if config != want {
+		t.Errorf("default config = %+v, want %+v", config, want)
+	}
+	if _, err := config.Options(); err != nil {
+		t.Errorf("Options of the default config: %v", err)
+	}
+	if err := WriteDefaultConfig(path); err == nil {
+		t.Error("WriteDefaultConfig overwrote an existing file")
+	}
+}
+
+func TestEncryptionKeyFromEnv(t *testing.T) {
+	key := bytes.Repeat([]byte{0xab}, 32)
+	tests := []struct {
+		name  string
+		value string
+		valid bool
+	}{
+		{"hex", strings.Repeat("ab", 32), true},
+		{"base64", base64.StdEncoding.EncodeToString(key), true},
+		{"unset", "", false},
+		{"invalid", "not a key!", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_DUCKDB_KEY", tt.value)
+			got, err := encryptionKeyFromEnv("TEST_DUCKDB_KEY")
+			if !tt.valid {
+				if err == nil {
+					t.Errorf("encryptionKeyFromEnv accepted %q", tt.value)
+				}
+				return
+			}
+			if err != nil || !bytes.Equal(got, key) {
+				t.Errorf("encryptionKeyFromEnv = %x, %v, want %x", got, err, key)
+			}
+		})
+	}
+}
+
+func TestStorageConfigOptionsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config StorageConfig
+	}{
+		{"compression", StorageConfig{Compression: "lz4"}},
+		{"max_retries", StorageConfig{MaxRetries: -1}},
+		{"chunk_size", StorageConfig{ChunkSize: -1}},
+		{"encryption_key_env", StorageConfig{EncryptionKeyEnv: "TEST_DUCKDB_UNSET_KEY"}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.config.Options(); err == nil {
+			t.Errorf("Options accepted an invalid %s", tt.name)
+		}
+	}
+}
+
+func TestNewDuckDBStorageFromConfigFile(t *testing.T) {
+	nc := startTestServer(t)
+	t.Setenv("TEST_DUCKDB_KEY", strings.Repeat("ab", 32))
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"config.json": `{"nats_url": "` + nc.ConnectedUrl() + `", "bucket": "CONFIG", "db_name": "config.db", "compression": "zstd", "max_retries": 2, "chunk_size": 4096, "encryption_key_env": "TEST_DUCKDB_KEY"}`,
+		"config.yaml": "nats_url: " + nc.ConnectedUrl() + "\nbucket: CONFIG\ndb_name: config.db\ncompression: zstd\nmax_retries: 2\nchunk_size: 4096\nencryption_key_env: TEST_DUCKDB_KEY\n",
+	}
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			s, err := NewDuckDBStorageFromConfigFile(path)
+			if err != nil {
+				t.Fatalf("NewDuckDBStorageFromConfigFile: %v", err)
+			}
+			defer s.Close(context.Background())
+
+			o := s.opts
+			if o.Bucket != "CONFIG" || o.DBName != "config.db" || o.Compression != CompressionZstd ||
+				o.RetryAttempts != 3 || o.ChunkSize != 4096 || len(o.EncryptionKey) != 32 {
+				t.Errorf("options = %+v, want the values of %s", o, name)
+			}
+			if !s.ownsConn {
+				t.Error("handler does not own the connection it created")
+			}
+		})
+	}
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	if err := os.WriteFile(invalid, []byte("nats_url: "+nc.ConnectedUrl()+"\ncompression: lz4\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDuckDBStorageFromConfigFile(invalid); err == nil {
+		t.Error("NewDuckDBStorageFromConfigFile accepted an invalid compression")
+	}
+	if _, err := NewDuckDBStorageFromConfigFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("NewDuckDBStorageFromConfigFile of a missing file succeeded")
+	}
+}
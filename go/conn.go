@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NewDuckDBStorageWithTLS connects to serverURL with a client certificate and a CA bundle and
+// returns a storage handler that owns the connection. Extra dial options can be passed with
+// WithNATSOptions. Close drains and closes the connection.
+func NewDuckDBStorageWithTLS(serverURL string, certFile, keyFile, caFile string, opts ...Option) (*DuckDBStorage, error) {
+	options := defaultStorageOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	dialOpts := []nats.Option{
+		nats.ClientCert(certFile, keyFile),
+		nats.RootCAs(caFile),
+	}
+	dialOpts = append(dialOpts, options.NATSOptions...)
+
+	nc, err := nats.Connect(serverURL, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	d, err := NewDuckDBStorage(nc, opts...)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	d.ownsConn = true
+	return d, nil
+}
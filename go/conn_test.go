@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+)
+
+// testCertificates holds the PEM files of a test CA and the certificates it issued
+type testCertificates struct {
+	caFile, serverCert, serverKey, clientCert, clientKey string
+}
+
+// writeTestCertificates writes a CA, a server certificate for 127.0.0.1 and a client
+// certificate to dir
+func writeTestCertificates(t *testing.T, dir string) testCertificates {
+	t.Helper()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs := testCertificates{caFile: filepath.Join(dir, "ca.pem")}
+	writePEM(t, certs.caFile, "CERTIFICATE", caDER)
+
+	issue := func(name string, serial int64, usage x509.ExtKeyUsage, ip net.IP) (string, string) {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		}
+		if ip != nil {
+			template.IPAddresses = []net.IP{ip}
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		certFile, keyFile := filepath.Join(dir, name+".pem"), filepath.Join(dir, name+".key")
+		writePEM(t, certFile, "CERTIFICATE", der)
+		writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+		return certFile, keyFile
+	}
+	certs.serverCert, certs.serverKey = issue("server", 2, x509.ExtKeyUsageServerAuth, net.IPv4(127, 0, 0, 1))
+	certs.clientCert, certs.clientKey = issue("client", 3, x509.ExtKeyUsageClientAuth, nil)
+	return certs
+}
+
+// writePEM writes der to path as a PEM block of the given type
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runTLSTestServer starts an embedded server requiring client certificates issued by the test CA
+func runTLSTestServer(t *testing.T, certs testCertificates) *server.Server {
+	t.Helper()
+	tlsConfig, err := server.GenTLSConfig(&server.TLSConfigOpts{
+		CertFile: certs.serverCert,
+		KeyFile:  certs.serverKey,
+		CaFile:   certs.caFile,
+		Verify:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runTestServer(t, &server.Options{
+		Host:      "127.0.0.1",
+		TLS:       true,
+		TLSConfig: tlsConfig,
+		TLSVerify: true,
+	})
+}
+
+func TestNewDuckDBStorageWithTLS(t *testing.T) {
+	dir := t.TempDir()
+	certs := writeTestCertificates(t, dir)
+	srv := runTLSTestServer(t, certs)
+
+	s, err := NewDuckDBStorageWithTLS(srv.ClientURL(), certs.clientCert, certs.clientKey, certs.caFile)
+	if err != nil {
+		t.Fatalf("NewDuckDBStorageWithTLS: %v", err)
+	}
+	if !s.nc.TLSRequired() {
+		t.Error("connection does not use TLS")
+	}
+
+	path := filepath.Join(dir, "test.db")
+	createTestDatabase(t, path)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB over TLS: %v", err)
+	}
+	if err := s.RetrieveDuckDB(filepath.Join(dir, "out.db")); err != nil {
+		t.Fatalf("RetrieveDuckDB over TLS: %v", err)
+	}
+
+	// The handler owns the connection
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if !s.nc.IsClosed() {
+		t.Error("Close left the connection open")
+	}
+}
+
+func TestNewDuckDBStorageWithTLSRejected(t *testing.T) {
+	dir := t.TempDir()
+	certs := writeTestCertificates(t, dir)
+	srv := runTLSTestServer(t, certs)
+
+	// A client certificate from another CA is refused
+	other := writeTestCertificates(t, t.TempDir())
+	if _, err := NewDuckDBStorageWithTLS(srv.ClientURL(), other.clientCert, other.clientKey, certs.caFile); err == nil {
+		t.Error("connected with a certificate from an unknown CA")
+	}
+	if _, err := NewDuckDBStorageWithTLS(srv.ClientURL(), certs.clientCert, filepath.Join(dir, "missing.key"), certs.caFile); err == nil {
+		t.Error("connected without the client key")
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+// ErrAlreadyExists is returned when a destination object exists and overwriting was not requested
+var ErrAlreadyExists = errors.New("object already exists")
+
+// CopyDatabase duplicates a stored database under a new name in the same bucket. The bytes are
+// streamed from NATS to NATS without touching disk. A chunked database is copied chunk by chunk
+// with a manifest of its own.
+func (d *DuckDBStorage) CopyDatabase(sourceName, destName string, overwrite bool) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	return d.copyDatabase(d.obs, d.bucket, sourceName, destName, overwrite)
+}
+
+// CopyDatabaseToBucket duplicates a stored database into another bucket, creating it if needed
+func (d *DuckDBStorage) CopyDatabaseToBucket(sourceName, destBucket, destName string, overwrite bool) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	dest, err := d.objectStore(destBucket)
+	if err != nil {
+		return err
+	}
+	return d.copyDatabase(dest, destBucket, sourceName, destName, overwrite)
+}
+
+func (d *DuckDBStorage) copyDatabase(dest nats.ObjectStore, destBucket, sourceName, destName string, overwrite bool) (err error) {
+	op := d.logOperation(opCopy, "source", sourceName, "dest_bucket", destBucket, "dest", destName)
+	defer func() {
+		d.metrics.countError(opCopy, err)
+		op.done(err)
+	}()
+	sourceName, destName = d.objectKey(sourceName), d.objectKey(destName)
+
+	if !overwrite {
+		for _, name := range []string{destName, manifestNameOf(destName)} {
+			_, err := dest.GetInfo(name)
+			if err == nil {
+				return fmt.Errorf("%w: %s/%s", ErrAlreadyExists, destBucket, destName)
+			}
+			if !errors.Is(err, nats.ErrObjectNotFound) {
+				return fmt.Errorf("failed to check destination: %w", err)
+			}
+		}
+	}
+
+	ctx := context.Background()
+	extra := nats.Header{
+		"X-Copied-From": []string{d.bucket + "/" + sourceName},
+		"X-Copied-At":   []string{time.Now().UTC().Format(time.RFC3339)},
+	}
+	manifest, err := d.getManifestOf(sourceName)
+	switch {
+	case err == nil:
+		if err := d.copyChunked(ctx, sourceName, manifest, dest, destName, extra); err != nil {
+			return err
+		}
+		// A database overwritten in the other format would otherwise shadow the copy
+		if err := dest.Delete(destName); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return fmt.Errorf("failed to delete %s: %w", destName, err)
+		}
+		return nil
+	case errors.Is(err, nats.ErrObjectNotFound):
+		if _, err := copyObjectTo(ctx, d.obs, sourceName, dest, destName, extra); err != nil {
+			return err
+		}
+		_, err = deleteChunkedFrom(dest, destName)
+		return err
+	default:
+		return err
+	}
+}
+
+// copyChunked copies the chunks of the chunked database sourceName into dest under a new
+// generation of destName and switches the destination manifest last, like storeChunked. extra
+// headers are stored with the manifest.
+func (d *DuckDBStorage) copyChunked(ctx context.Context, sourceName string, manifest *ChunkManifest, dest nats.ObjectStore, destName string, extra nats.Header) (err error) {
+	info, err := d.obs.GetInfo(manifestNameOf(sourceName))
+	if err != nil {
+		return fmt.Errorf("failed to get info for %s: %w", manifestNameOf(sourceName), err)
+	}
+	previous, err := getManifestFrom(dest, destName)
+	if err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+		return err
+	}
+
+	copied := *manifest
+	copied.Name = destName
+	copied.Chunks = nil
+	generation := nuid.Next()
+	defer func() {
+		if err != nil {
+			d.deleteChunks(dest, copied.Chunks)
+		}
+	}()
+	for _, chunk := range manifest.Chunks {
+		name := chunkNameOf(destName, generation, chunk.Index)
+		if _, err := copyObjectTo(ctx, d.obs, chunk.Name, dest, name, nil); err != nil {
+			d.deleteChunks(dest, []ChunkInfo{{Index: chunk.Index, Name: name}})
+			return fmt.Errorf("failed to copy chunk %d: %w", chunk.Index, err)
+		}
+		chunk.Name = name
+		copied.Chunks = append(copied.Chunks, chunk)
+	}
+
+	data, err := json.MarshalIndent(copied, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	headers := cloneHeader(info.Headers)
+	maps.Copy(headers, extra)
+	_, err = dest.Put(&nats.ObjectMeta{
+		Name:        manifestNameOf(destName),
+		Description: info.Description,
+		Headers:     headers,
+	}, bytes.NewReader(data), nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %w", manifestNameOf(destName), err)
+	}
+
+	if previous != nil {
+		d.deleteChunks(dest, previous.Chunks)
+	}
+	return nil
+}
+
+// copyObject streams the stored bytes of src to dst, keeping its description and headers
+func (d *DuckDBStorage) copyObject(ctx context.Context, src, dst string) (*nats.ObjectInfo, error) {
+	return copyObjectTo(ctx, d.obs, src, d.obs, dst, nil)
+}
+
+// copyObjectTo streams the stored bytes of src into dst, keeping the source description and
+// headers and adding extra headers on top
+func copyObjectTo(ctx context.Context, srcStore nats.ObjectStore, src string, dstStore nats.ObjectStore, dst string, extra nats.Header) (*nats.ObjectInfo, error) {
+	obj, err := srcStore.Get(src, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	defer obj.Close()
+
+	info, err := obj.Info()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object info: %w", err)
+	}
+
+	headers := cloneHeader(info.Headers)
+	// A version copied under another name, such as when it is promoted, is no longer a version
+	if dst != src {
+		headers.Del(versionHeader)
+	}
+	// Pins are recorded per object, so a copy starts unpinned
+	if dst != src || dstStore != srcStore {
+		headers.Del(pinnedHeader)
+	}
+	maps.Copy(headers, extra)
+
+	copied, err := dstStore.Put(&nats.ObjectMeta{
+		Name:        dst,
+		Description: info.Description,
+		Headers:     headers,
+	}, obj, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+
+	return copied, nil
+}
+
+// objectStore binds to another bucket on the same JetStream context, creating it if needed
+func (d *DuckDBStorage) objectStore(bucket string) (nats.ObjectStore, error) {
+	obs, err := d.js.ObjectStore(bucket)
+	if errors.Is(err, nats.ErrStreamNotFound) {
+		obs, err = d.js.CreateObjectStore(&nats.ObjectStoreConfig{
+			Bucket:      bucket,
+			Description: d.opts.Description,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create/get object store %s: %w", bucket, err)
+	}
+	return obs, nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// retrieveTestCopy retrieves the database named name through a storage with opts and returns
+// the number of users it holds
+func retrieveTestCopy(t *testing.T, s *DuckDBStorage, name string, opts ...Option) int64 {
+	t.Helper()
+	copied := newTestStorage(t, s.nc, append(opts, WithDBName(name))...)
+	out := filepath.Join(t.TempDir(), "copy.db")
+	if err := copied.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("failed to retrieve %s: %v", name, err)
+	}
+	return queryTestInt(t, out, "SELECT count(*) FROM users")
+}
+
+func TestCopyDatabase(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	if err := s.CopyDatabase(defaultDBName, "copy.db", false); err != nil {
+		t.Fatalf("CopyDatabase: %v", err)
+	}
+	if got := retrieveTestCopy(t, s, "copy.db"); got != 3 {
+		t.Errorf("copy has %d users, want 3", got)
+	}
+
+	execTestDatabase(t, path, "INSERT INTO users VALUES (4, 'Dave', now())")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CopyDatabase(defaultDBName, "copy.db", false); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("CopyDatabase onto an existing database: got %v, want ErrAlreadyExists", err)
+	}
+	if got := retrieveTestCopy(t, s, "copy.db"); got != 3 {
+		t.Errorf("refused copy changed the destination to %d users", got)
+	}
+	if err := s.CopyDatabase(defaultDBName, "copy.db", true); err != nil {
+		t.Fatalf("CopyDatabase with overwrite: %v", err)
+	}
+	if got := retrieveTestCopy(t, s, "copy.db"); got != 4 {
+		t.Errorf("overwritten copy has %d users, want 4", got)
+	}
+
+	if err := s.CopyDatabaseToBucket(defaultDBName, "COPIES", "copy.db", false); err != nil {
+		t.Fatalf("CopyDatabaseToBucket: %v", err)
+	}
+	if got := retrieveTestCopy(t, s, "copy.db", WithBucket("COPIES")); got != 4 {
+		t.Errorf("copy in another bucket has %d users, want 4", got)
+	}
+}
+
+func TestCopyDatabaseChunked(t *testing.T) {
+	s, path := storeTestDatabase(t, WithChunkSize(16<<10))
+	if err := s.CopyDatabase(defaultDBName, "copy.db", false); err != nil {
+		t.Fatalf("CopyDatabase: %v", err)
+	}
+	if err := s.CopyDatabaseToBucket(defaultDBName, "COPIES", "copy.db", false); err != nil {
+		t.Fatalf("CopyDatabaseToBucket: %v", err)
+	}
+	if err := s.CopyDatabase(defaultDBName, "copy.db", false); !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("CopyDatabase onto a chunked database: got %v, want ErrAlreadyExists", err)
+	}
+
+	// The copies own their chunks and outlive the source
+	execTestDatabase(t, path, "INSERT INTO users VALUES (4, 'Dave', now())")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := retrieveTestCopy(t, s, "copy.db", WithChunkSize(16<<10)); got != 3 {
+		t.Errorf("copy has %d users, want 3", got)
+	}
+	if got := retrieveTestCopy(t, s, "copy.db", WithChunkSize(16<<10), WithBucket("COPIES")); got != 3 {
+		t.Errorf("copy in another bucket has %d users, want 3", got)
+	}
+
+	if err := s.CopyDatabase(defaultDBName, "copy.db", true); err != nil {
+		t.Fatalf("CopyDatabase with overwrite: %v", err)
+	}
+	if got := retrieveTestCopy(t, s, "copy.db", WithChunkSize(16<<10)); got != 4 {
+		t.Errorf("overwritten copy has %d users, want 4", got)
+	}
+	// Only the chunks of the latest copy are left
+	var chunks []string
+	for _, name := range storedChunks(t, s) {
+		if strings.HasPrefix(name, "copy.db.part.") {
+			chunks = append(chunks, name)
+		}
+	}
+	want := manifestChunks(t, newTestStorage(t, s.nc, WithDBName("copy.db")))
+	if !slices.Equal(chunks, want) {
+		t.Errorf("bucket holds copied chunks %v, want %v", chunks, want)
+	}
+}
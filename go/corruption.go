@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+// ErrRetrieveCorruption is returned by RetrieveDuckDB when every download attempt produced a
+// file that DuckDB cannot open
+type ErrRetrieveCorruption struct {
+	Attempts  int
+	LastError error
+}
+
+func (e ErrRetrieveCorruption) Error() string {
+	return fmt.Sprintf("retrieved database is corrupt after %d attempts: %v", e.Attempts, e.LastError)
+}
+
+func (e ErrRetrieveCorruption) Unwrap() error {
+	return e.LastError
+}
+
+// retrieveVerified retrieves the database to outputPath and checks that DuckDB can open it,
+// downloading it again up to AutoRetryOnCorruption times
+func (d *DuckDBStorage) retrieveVerified(ctx context.Context, outputPath string, chunked bool) error {
+	var lastErr error
+	for attempt := 1; attempt <= d.opts.AutoRetryOnCorruption; attempt++ {
+		if err := d.retrieveAs(ctx, outputPath, chunked); err != nil {
+			return err
+		}
+		if lastErr = checkOpenable(outputPath); lastErr == nil {
+			return nil
+		}
+		os.Remove(outputPath)
+		os.Remove(outputPath + ".wal")
+	}
+	return ErrRetrieveCorruption{Attempts: d.opts.AutoRetryOnCorruption, LastError: lastErr}
+}
+
+// checkOpenable opens the DuckDB database at path and closes it again
+func checkOpenable(path string) error {
+	connector, err := duckdb.NewConnector(path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+	return connector.Close()
+}
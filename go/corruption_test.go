@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckOpenable(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.db")
+	createTestDatabase(t, valid)
+	if err := checkOpenable(valid); err != nil {
+		t.Errorf("checkOpenable of a database: %v", err)
+	}
+
+	invalid := filepath.Join(dir, "invalid.db")
+	if err := os.WriteFile(invalid, []byte(strings.Repeat("not a database ", 100)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkOpenable(invalid); err == nil {
+		t.Error("checkOpenable accepted a file that is not a database")
+	}
+}
+
+func TestRetrieveCorruptionRetries(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithAutoRetryOnCorruption(2))
+	path := filepath.Join(t.TempDir(), "bad.db")
+	if err := os.WriteFile(path, []byte("this is not a duckdb database file at all"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.db")
+	err := s.RetrieveDuckDB(out)
+	var corruption ErrRetrieveCorruption
+	if !errors.As(err, &corruption) {
+		t.Fatalf("RetrieveDuckDB of a corrupt database: got %v, want ErrRetrieveCorruption", err)
+	}
+	if corruption.Attempts != 2 || corruption.LastError == nil {
+		t.Errorf("corruption = %+v, want 2 attempts and the open error", corruption)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("corrupt database left at %s", out)
+	}
+}
+
+func TestRetrieveCorruptionValid(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithAutoRetryOnCorruption(2))
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB: %v", err)
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("retrieved %d users, want 3", n)
+	}
+}
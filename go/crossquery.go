@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// defaultMaxCrossQueryDatabases is the default limit on databases attached by CrossQuery
+const defaultMaxCrossQueryDatabases = 8
+
+// ErrTooManyDatabases is returned when CrossQuery is asked to attach more databases than allowed
+var ErrTooManyDatabases = errors.New("too many databases")
+
+// CrossQuery runs a query across several databases stored in the bucket. databases maps the
+// alias used in the query to the object name, so "SELECT ... FROM foo.users JOIN bar.orders"
+// works with {"foo": "foo.db", "bar": "bar.db"}. Every database is attached read-only.
+func (d *DuckDBStorage) CrossQuery(ctx context.Context, databases map[string]string, query string) (_ *Rows, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	if len(databases) > d.opts.MaxCrossQueryDatabases {
+		return nil, fmt.Errorf("%w: %d requested, limit is %d", ErrTooManyDatabases, len(databases), d.opts.MaxCrossQueryDatabases)
+	}
+
+	op := d.logOperation("cross_query", "databases", len(databases), "query", query)
+	defer func() { op.done(err) }()
+
+	var paths []string
+	removeAll := func() {
+		for _, path := range paths {
+			removeTempDatabase(path)
+		}
+	}
+
+	aliases := slices.Sorted(maps.Keys(databases))
+	for _, alias := range aliases {
+		path, err := tempPath("duckdb-nats-*.db")
+		if err != nil {
+			removeAll()
+			return nil, err
+		}
+		paths = append(paths, path)
+
+		if err := d.getDatabase(ctx, d.objectKey(databases[alias]), path); err != nil {
+			removeAll()
+			return nil, fmt.Errorf("failed to retrieve %s: %w", databases[alias], err)
+		}
+		if err := d.filterDatabase(ctx, path, query); err != nil {
+			removeAll()
+			return nil, err
+		}
+	}
+
+	db, err := openDuckDB("")
+	if err != nil {
+		removeAll()
+		return nil, err
+	}
+	// Attached databases are visible to every connection of the in-memory database
+	for i, alias := range aliases {
+		attach := fmt.Sprintf("ATTACH %s AS %s (READ_ONLY)", quoteLiteral(paths[i]), quoteIdent(alias))
+		if _, err := db.ExecContext(ctx, attach); err != nil {
+			db.Close()
+			removeAll()
+			return nil, fmt.Errorf("failed to attach %s: %w", alias, err)
+		}
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		db.Close()
+		removeAll()
+		return nil, fmt.Errorf("failed to query databases: %w", err)
+	}
+
+	return &Rows{Rows: rows, db: db, paths: paths}, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCrossQuery(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	orders := filepath.Join(t.TempDir(), "orders.db")
+	execTestDatabase(t, orders,
+		"CREATE TABLE orders (id INTEGER, amount DOUBLE)",
+		"INSERT INTO orders VALUES (2, 9.5), (3, 12.25), (7, 1)",
+	)
+	if err := newTestStorage(t, s.nc, WithDBName("orders.db")).StoreDuckDB(orders); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := s.CrossQuery(context.Background(),
+		map[string]string{"people": s.dbName, "sales": "orders.db"},
+		"SELECT u.name, o.amount FROM people.users u JOIN sales.orders o USING (id) ORDER BY id")
+	if err != nil {
+		t.Fatalf("CrossQuery: %v", err)
+	}
+	defer rows.Close()
+
+	type joined struct {
+		name   string
+		amount float64
+	}
+	var got []joined
+	for rows.Next() {
+		var row joined
+		if err := rows.Scan(&row.name, &row.amount); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, row)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []joined{{"Bob", 9.5}, {"Charlie", 12.25}}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("joined rows = %v, want %v", got, want)
+	}
+}
+
+func TestCrossQueryErrors(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithMaxCrossQueryDatabases(1))
+	ctx := context.Background()
+
+	_, err := s.CrossQuery(ctx, map[string]string{"a": "a.db", "b": "b.db"}, "SELECT 1")
+	if !errors.Is(err, ErrTooManyDatabases) {
+		t.Errorf("CrossQuery over the limit: got %v, want ErrTooManyDatabases", err)
+	}
+	if _, err := s.CrossQuery(ctx, map[string]string{"a": "missing.db"}, "SELECT 1"); err == nil {
+		t.Error("CrossQuery of a missing database succeeded")
+	}
+}
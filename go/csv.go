@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CSVImportOptions controls how a CSV object is parsed by ImportCSVFromNATS
+type CSVImportOptions struct {
+	// Delimiter separates fields, auto-detected when empty
+	Delimiter string
+	// HasHeader reports whether the first line holds column names
+	HasHeader bool
+	// NullString is the field value that is read as NULL
+	NullString string
+	// DateFormat is a strptime format for date columns, auto-detected when empty
+	DateFormat string
+	// IgnoreErrors skips malformed rows instead of failing; they are counted in SkippedRows
+	IgnoreErrors bool
+}
+
+// ImportResult reports the outcome of loading a file into a table
+type ImportResult struct {
+	RowsInserted int64
+	SkippedRows  int64
+}
+
+// csvSource builds the read_csv_auto call for the given file and options
+func (o CSVImportOptions) csvSource(path string) string {
+	args := []string{quoteLiteral(path), fmt.Sprintf("header=%t", o.HasHeader)}
+	if o.Delimiter != "" {
+		args = append(args, "delim="+quoteLiteral(o.Delimiter))
+	}
+	if o.NullString != "" {
+		args = append(args, "nullstr="+quoteLiteral(o.NullString))
+	}
+	if o.DateFormat != "" {
+		args = append(args, "dateformat="+quoteLiteral(o.DateFormat))
+	}
+	if o.IgnoreErrors {
+		args = append(args, "store_rejects=true")
+	}
+	return "read_csv_auto(" + strings.Join(args, ", ") + ")"
+}
+
+// ImportCSVFromNATS loads a stored CSV object into a table of the local database and stores the
+// updated database. Rows are appended if the table already exists.
+func (d *DuckDBStorage) ImportCSVFromNATS(ctx context.Context, csvObjectName, targetDBFilePath, targetTable string, opts CSVImportOptions) (result ImportResult, err error) {
+	op := d.logOperation("import_csv", "object", csvObjectName, "table", targetTable)
+	defer func() { op.done(err, "rows", result.RowsInserted, "skipped", result.SkippedRows) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return result, err
+	}
+	defer func() { end(err) }()
+
+	csvPath, err := tempPath("duckdb-nats-*.csv")
+	if err != nil {
+		return result, err
+	}
+	defer removeTemp(csvPath)
+
+	if err := d.getFile(ctx, d.objectKey(csvObjectName), csvPath); err != nil {
+		return result, err
+	}
+
+	db, err := openDuckDB(targetDBFilePath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	// Rejected rows are recorded in a temp table, so everything runs on one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	result.RowsInserted, err = loadIntoTable(ctx, conn, targetTable, opts.csvSource(csvPath))
+	if err != nil {
+		return result, err
+	}
+
+	if opts.IgnoreErrors {
+		err = conn.QueryRowContext(ctx,
+			"SELECT COUNT(DISTINCT (scan_id, line)) FROM reject_errors",
+		).Scan(&result.SkippedRows)
+		if err != nil {
+			return result, fmt.Errorf("failed to count rejected rows: %w", err)
+		}
+	}
+
+	conn.Close()
+	if err := db.Close(); err != nil {
+		return result, fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return result, d.StoreDuckDB(targetDBFilePath)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestCSVSource(t *testing.T) {
+	tests := []struct {
+		opts CSVImportOptions
+		want string
+	}{
+		{CSVImportOptions{}, "read_csv_auto('in.csv', header=false)"},
+		{CSVImportOptions{HasHeader: true, Delimiter: ";"}, "read_csv_auto('in.csv', header=true, delim=';')"},
+		{
+			CSVImportOptions{NullString: "NA", DateFormat: "%d/%m/%Y", IgnoreErrors: true},
+			"read_csv_auto('in.csv', header=false, nullstr='NA', dateformat='%d/%m/%Y', store_rejects=true)",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.csvSource("in.csv"); got != tt.want {
+			t.Errorf("csvSource(%+v) = %s, want %s", tt.opts, got, tt.want)
+		}
+	}
+}
+
+func TestImportCSVFromNATS(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+	csv := "id;name;score;joined\n" +
+		"1;Alice;9.5;02/01/2024\n" +
+		"2;NA;7.25;03/02/2024\n" +
+		"3;Charlie;NA;NA\n"
+	if _, err := s.obs.PutString("people.csv", csv); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(t.TempDir(), "target.db")
+	createTestDatabase(t, target)
+	opts := CSVImportOptions{Delimiter: ";", HasHeader: true, NullString: "NA", DateFormat: "%d/%m/%Y"}
+	result, err := s.ImportCSVFromNATS(ctx, "people.csv", target, "people", opts)
+	if err != nil {
+		t.Fatalf("ImportCSVFromNATS: %v", err)
+	}
+	if result.RowsInserted != 3 || result.SkippedRows != 0 {
+		t.Errorf("result = %+v, want 3 rows inserted", result)
+	}
+
+	var rows, nullNames, nullScores, nullDates int
+	err = s.QueryRow(ctx, `SELECT count(*),
+		count(*) FILTER (WHERE name IS NULL),
+		count(*) FILTER (WHERE score IS NULL),
+		count(*) FILTER (WHERE joined IS NULL)
+		FROM people`).Scan(&rows, &nullNames, &nullScores, &nullDates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows != 3 || nullNames != 1 || nullScores != 1 || nullDates != 1 {
+		t.Errorf("rows %d, NULL names %d, scores %d, dates %d", rows, nullNames, nullScores, nullDates)
+	}
+
+	var dataType, joined string
+	err = s.QueryRow(ctx, "SELECT data_type FROM information_schema.columns WHERE table_name = 'people' AND column_name = 'joined'").Scan(&dataType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dataType != "DATE" {
+		t.Errorf("joined column type = %s, want DATE", dataType)
+	}
+	if err := s.QueryRow(ctx, "SELECT strftime(joined, '%Y-%m-%d') FROM people WHERE id = 2").Scan(&joined); err != nil {
+		t.Fatal(err)
+	}
+	if joined != "2024-02-03" {
+		t.Errorf("joined = %s, want 2024-02-03", joined)
+	}
+}
+
+func TestImportCSVFromNATSIgnoreErrors(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+	if _, err := s.obs.PutString("bad.csv", "id,name\n1,a\n2,b,extra,fields\n3,c\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(t.TempDir(), "target.db")
+	createTestDatabase(t, target)
+	result, err := s.ImportCSVFromNATS(ctx, "bad.csv", target, "items", CSVImportOptions{Delimiter: ",", HasHeader: true, IgnoreErrors: true})
+	if err != nil {
+		t.Fatalf("ImportCSVFromNATS: %v", err)
+	}
+	if result.RowsInserted != 2 || result.SkippedRows != 1 {
+		t.Errorf("result = %+v, want 2 rows inserted and 1 skipped", result)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// CSVExportOptions controls how query results are written by ExportQueryToCSV
+type CSVExportOptions struct {
+	// Delimiter separates fields, a comma when zero
+	Delimiter rune
+	// IncludeHeader writes the column names as the first line
+	IncludeHeader bool
+	// NullString is written for NULL values
+	NullString string
+	// DateFormat is the Go time layout for date and timestamp values, RFC 3339 when empty
+	DateFormat string
+}
+
+// ExportStats reports the size of an export
+type ExportStats struct {
+	RowCount     int64
+	BytesWritten int64
+}
+
+// ExportQueryToCSV runs a query against the stored database and stores the result as a CSV
+// object named outputObjectName in the same bucket
+func (d *DuckDBStorage) ExportQueryToCSV(ctx context.Context, query, outputObjectName string, opts CSVExportOptions) (stats ExportStats, err error) {
+	op := d.logOperation("export_csv", "query", query, "object", outputObjectName)
+	defer func() { op.done(err, "rows", stats.RowCount, "bytes", stats.BytesWritten) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return stats, err
+	}
+	defer func() { end(err) }()
+
+	rows, err := d.QueryRows(ctx, query)
+	if err != nil {
+		return stats, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return stats, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if opts.Delimiter != 0 {
+		w.Comma = opts.Delimiter
+	}
+	if opts.IncludeHeader {
+		if err := w.Write(columns); err != nil {
+			return stats, fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return stats, fmt.Errorf("failed to scan row %d: %w", stats.RowCount, err)
+		}
+		for i, value := range values {
+			record[i] = opts.format(value)
+		}
+		if err := w.Write(record); err != nil {
+			return stats, fmt.Errorf("failed to write row %d: %w", stats.RowCount, err)
+		}
+		stats.RowCount++
+	}
+	if err := rows.Err(); err != nil {
+		return stats, fmt.Errorf("failed to read rows after %d rows: %w", stats.RowCount, err)
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return stats, fmt.Errorf("failed to write CSV: %w", err)
+	}
+
+	stats.BytesWritten = int64(buf.Len())
+	_, err = d.obs.Put(&nats.ObjectMeta{
+		Name:        d.objectKey(outputObjectName),
+		Description: "CSV export of query results",
+		Headers: nats.Header{
+			"Content-Type": []string{"text/csv"},
+		},
+	}, &buf, nats.Context(ctx))
+	if err != nil {
+		return stats, fmt.Errorf("failed to store %s in NATS: %w", outputObjectName, err)
+	}
+	return stats, nil
+}
+
+// format renders a scanned value as a CSV field
+func (o CSVExportOptions) format(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return o.NullString
+	case time.Time:
+		if o.DateFormat != "" {
+			return v.Format(o.DateFormat)
+		}
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVExportFormat(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		opts  CSVExportOptions
+		value any
+		want  string
+	}{
+		{"null", CSVExportOptions{NullString: `\N`}, nil, `\N`},
+		{"time", CSVExportOptions{}, ts, "2024-03-01T12:30:00Z"},
+		{"time with format", CSVExportOptions{DateFormat: "2006-01-02"}, ts, "2024-03-01"},
+		{"bytes", CSVExportOptions{}, []byte("blob"), "blob"},
+		{"float", CSVExportOptions{}, 0.1, "0.1"},
+		{"float32", CSVExportOptions{}, float32(0.1), "0.1"},
+		{"integer", CSVExportOptions{}, int64(42), "42"},
+	}
+	for _, tt := range tests {
+		if got := tt.opts.format(tt.value); got != tt.want {
+			t.Errorf("%s: format(%v) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExportQueryToCSV(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx := context.Background()
+	query := "SELECT id, name, created_at, CASE WHEN id = 2 THEN NULL ELSE id * 10 END AS score FROM users ORDER BY id"
+	stats, err := s.ExportQueryToCSV(ctx, query, "users.csv", CSVExportOptions{
+		Delimiter:     ';',
+		IncludeHeader: true,
+		NullString:    "NULL",
+	})
+	if err != nil {
+		t.Fatalf("ExportQueryToCSV: %v", err)
+	}
+	if stats.RowCount != 3 {
+		t.Errorf("RowCount = %d, want 3", stats.RowCount)
+	}
+
+	info, err := s.obs.GetInfo("users.csv")
+	if err != nil {
+		t.Fatalf("GetInfo of the export: %v", err)
+	}
+	if got := info.Headers.Get("Content-Type"); got != "text/csv" {
+		t.Errorf("Content-Type = %q, want text/csv", got)
+	}
+	if info.Size != uint64(stats.BytesWritten) {
+		t.Errorf("stored %d bytes, BytesWritten = %d", info.Size, stats.BytesWritten)
+	}
+	data, err := s.obs.GetString("users.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header, _, _ := strings.Cut(data, "\n"); header != "id;name;created_at;score" {
+		t.Errorf("header = %q", header)
+	}
+
+	// Importing the export gives back the rows that were queried
+	imported := filepath.Join(t.TempDir(), "imported.db")
+	_, err = s.ImportCSVFromNATS(ctx, "users.csv", imported, "users", CSVImportOptions{
+		Delimiter:  ";",
+		HasHeader:  true,
+		NullString: "NULL",
+	})
+	if err != nil {
+		t.Fatalf("ImportCSVFromNATS: %v", err)
+	}
+	rowQuery := "SELECT concat_ws('|', id, name, epoch(created_at), coalesce(score::VARCHAR, 'null')) FROM (%s) ORDER BY 1"
+	want := queryTestStrings(t, path, fmt.Sprintf(rowQuery, query))
+	got := queryTestStrings(t, imported, fmt.Sprintf(rowQuery, "SELECT * FROM users"))
+	if !slices.Equal(got, want) {
+		t.Errorf("imported rows = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"io"
+)
+
+// ctxCheckInterval is how many bytes pass between context checks of a ctxReader
+const ctxCheckInterval = 64 << 10
+
+// ctxReader fails with the context error once ctx is done, checking every ctxCheckInterval bytes
+type ctxReader struct {
+	ctx       context.Context
+	r         io.Reader
+	unchecked int64
+}
+
+// newCtxReader wraps r to stop reading once ctx is done, returning r unchanged when ctx can
+// never be done
+func newCtxReader(ctx context.Context, r io.Reader) io.Reader {
+	if ctx.Done() == nil {
+		return r
+	}
+	return &ctxReader{ctx: ctx, r: r}
+}
+
+func (c *ctxReader) Read(b []byte) (int, error) {
+	if c.unchecked >= ctxCheckInterval {
+		c.unchecked = 0
+		if err := c.ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := c.r.Read(b)
+	c.unchecked += int64(n)
+	return n, err
+}
+
+// interruptReader reads r through a pipe so that a Read blocked in r, such as on a network
+// connection, fails with ctx.Err() once ctx is done. The goroutine reading r exits with the
+// first Read of r that returns afterwards. stop must be called once reading is finished.
+func interruptReader(ctx context.Context, r io.Reader) (io.Reader, func()) {
+	if ctx.Done() == nil {
+		return r, func() {}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := io.Copy(pw, r)
+		pw.CloseWithError(err)
+	}()
+	stopAfter := context.AfterFunc(ctx, func() { pw.CloseWithError(ctx.Err()) })
+	return pr, func() {
+		stopAfter()
+		pr.Close()
+	}
+}
+
+// interruptWriter writes to w through a pipe so that a Write blocked in w fails with ctx.Err()
+// once ctx is done. wait must be called after the last Write, it returns once w received
+// everything and reports the error of w.
+func interruptWriter(ctx context.Context, w io.Writer) (io.Writer, func() error) {
+	if ctx.Done() == nil {
+		return w, func() error { return nil }
+	}
+
+	pr, pw := io.Pipe()
+	copied := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(w, pr)
+		// Fail further writes with the error of w
+		pr.CloseWithError(err)
+		copied <- err
+	}()
+	stopAfter := context.AfterFunc(ctx, func() { pr.CloseWithError(ctx.Err()) })
+	return pw, func() error {
+		defer stopAfter()
+		pw.Close()
+		select {
+		case err := <-copied:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
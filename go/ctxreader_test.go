@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCtxReader(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := newCtxReader(ctx, bytes.NewReader(make([]byte, 4*ctxCheckInterval)))
+	buf := make([]byte, ctxCheckInterval)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		t.Fatalf("read before cancelling: %v", err)
+	}
+	cancel()
+	if _, err := io.ReadFull(r, buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("read after cancelling: got %v, want context.Canceled", err)
+	}
+
+	plain := bytes.NewReader(nil)
+	if newCtxReader(context.Background(), plain) != io.Reader(plain) {
+		t.Error("newCtxReader wrapped a reader for a context that is never done")
+	}
+}
+
+func TestInterruptReader(t *testing.T) {
+	// The pipe is never written, so a Read blocks until the context is done
+	blocked, unblock := io.Pipe()
+	defer unblock.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	r, stop := interruptReader(ctx, blocked)
+	defer stop()
+	if _, err := r.Read(make([]byte, 1)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("blocked read: got %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestStoreContextCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	nc := startTestServer(t)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"single", nil},
+		{"chunked", []Option{WithChunkSize(200000)}},
+		{"compressed", []Option{WithCompression(CompressionZstd)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			// Cancel once the first KB was uploaded
+			opts := append(tt.opts, WithDBName(fmt.Sprintf("cancel-%s.db", tt.name)), WithProgressInterval(1024),
+				WithProgressCallback(func(done, total int64) {
+					if done >= 1024 {
+						cancel()
+					}
+				}))
+			s := newTestStorage(t, nc, opts...)
+
+			if err := s.StoreDuckDBContext(ctx, path); !errors.Is(err, context.Canceled) {
+				t.Fatalf("StoreDuckDBContext: got %v, want context.Canceled", err)
+			}
+			if rev, err := s.CurrentRevision(); err != nil || rev != 0 {
+				t.Errorf("CurrentRevision after the cancelled store = %d, %v, want nothing stored", rev, err)
+			}
+
+			if err := s.StoreDuckDB(path); err != nil {
+				t.Fatal(err)
+			}
+			out := filepath.Join(t.TempDir(), "out.db")
+			if err := s.RetrieveDuckDBContext(ctx, out); !errors.Is(err, context.Canceled) {
+				t.Errorf("RetrieveDuckDBContext with a cancelled context: got %v, want context.Canceled", err)
+			}
+			if err := s.RetrieveDuckDBContext(context.Background(), out); err != nil {
+				t.Errorf("RetrieveDuckDBContext: %v", err)
+			}
+		})
+	}
+}
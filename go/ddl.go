@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DDLError reports a DDL statement DuckDB rejected
+type DDLError struct {
+	// Statement is the failing statement of the DDL passed to ExecuteDDL
+	Statement string
+	Message   string
+	// Line is the 1-based line of the DDL on which the failing statement starts
+	Line int
+}
+
+func (e *DDLError) Error() string {
+	return fmt.Sprintf("failed to execute DDL at line %d: %s", e.Line, e.Message)
+}
+
+// ddlStatement is a statement of a DDL script and the line it starts on
+type ddlStatement struct {
+	text string
+	line int
+}
+
+// ExecuteDDL applies ddl, which may hold several statements separated by semicolons, to the
+// stored database in a single transaction and stores the result. If a statement fails the
+// transaction is rolled back, the stored database is left unchanged and a *DDLError is returned.
+func (d *DuckDBStorage) ExecuteDDL(ctx context.Context, ddl string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	statements := splitStatements(ddl)
+	op := d.logOperation("execute_ddl", "statements", len(statements))
+	defer func() { op.done(err) }()
+
+	if len(statements) == 0 {
+		return fmt.Errorf("no DDL statements given")
+	}
+
+	return d.rewrite(ctx, func(db *sql.DB) error {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin transaction: %w", err)
+		}
+		defer tx.Rollback()
+
+		// Statements run one at a time so a failure can be traced to its line
+		for _, statement := range statements {
+			if _, err := tx.ExecContext(ctx, statement.text); err != nil {
+				return &DDLError{Statement: statement.text, Message: err.Error(), Line: statement.line}
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			last := statements[len(statements)-1]
+			return &DDLError{Statement: last.text, Message: err.Error(), Line: last.line}
+		}
+		return nil
+	})
+}
+
+// quoteEnd returns the index of the quote that closes the string literal or quoted identifier
+// opened at script[i], or the last index of an unterminated one. Quotes are escaped by doubling
+// them, which this treats as two adjacent literals.
+func quoteEnd(script string, i int) int {
+	end := strings.IndexByte(script[i+1:], script[i])
+	if end < 0 {
+		return len(script) - 1
+	}
+	return i + 1 + end
+}
+
+// splitStatements splits a SQL script on the semicolons outside of quotes and comments,
+// dropping statements that hold nothing but comments
+func splitStatements(script string) []ddlStatement {
+	var statements []ddlStatement
+	start, line, startLine := 0, 1, 0
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		if startLine == 0 && !strings.ContainsRune(" \t\r\n;", rune(c)) &&
+			!strings.HasPrefix(script[i:], "--") && !strings.HasPrefix(script[i:], "/*") {
+			startLine = line
+		}
+
+		switch {
+		case c == '\n':
+			line++
+		case c == '\'' || c == '"':
+			end := quoteEnd(script, i)
+			line += strings.Count(script[i:end], "\n")
+			i = end
+		case strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				end = len(script) - i
+			}
+			i += end - 1
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				end = len(script) - i - 2
+			}
+			line += strings.Count(script[i:i+2+end], "\n")
+			i += end + 3
+		case c == ';':
+			if startLine > 0 {
+				statements = append(statements, ddlStatement{text: strings.TrimSpace(script[start:i]), line: startLine})
+			}
+			start, startLine = i+1, 0
+		}
+	}
+	if startLine > 0 {
+		statements = append(statements, ddlStatement{text: strings.TrimSpace(script[start:]), line: startLine})
+	}
+	return statements
+}
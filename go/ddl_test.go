@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []ddlStatement
+	}{
+		{"empty", " \n;; ", nil},
+		{"single without semicolon", "CREATE TABLE a (x INT)", []ddlStatement{{"CREATE TABLE a (x INT)", 1}}},
+		{"lines", "CREATE TABLE a (x INT);\n\nALTER TABLE a ADD COLUMN y INT;\n", []ddlStatement{
+			{"CREATE TABLE a (x INT)", 1},
+			{"ALTER TABLE a ADD COLUMN y INT", 3},
+		}},
+		{"quoted semicolon", "CREATE TABLE a (x VARCHAR DEFAULT ';\n');\nCREATE TABLE \"b;\" (y INT)", []ddlStatement{
+			{"CREATE TABLE a (x VARCHAR DEFAULT ';\n')", 1},
+			{"CREATE TABLE \"b;\" (y INT)", 3},
+		}},
+		{"comments", "-- setup; not a statement\n/* a;\n b */\nCREATE TABLE a (x INT); -- trailing", []ddlStatement{
+			{"-- setup; not a statement\n/* a;\n b */\nCREATE TABLE a (x INT)", 4},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.script); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", tt.script, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExecuteDDL(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+	if err := s.ExecuteDDL(ctx, "ALTER TABLE users ADD COLUMN email VARCHAR;\nCREATE INDEX idx_name ON users (name)"); err != nil {
+		t.Fatalf("ExecuteDDL: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB: %v", err)
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM information_schema.columns WHERE table_name = 'users' AND column_name = 'email'"); n != 1 {
+		t.Error("email column missing after ExecuteDDL")
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM duckdb_indexes() WHERE index_name = 'idx_name'"); n != 1 {
+		t.Error("index missing after ExecuteDDL")
+	}
+}
+
+func TestExecuteDDLFailure(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+	stored, err := s.CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		ddl       string
+		statement string
+		line      int
+	}{
+		{"syntax error", "CREATE TABLE created (x INT);\n\nCREAT TABLE other (x INT)", "CREAT TABLE other (x INT)", 3},
+		{"unknown type", "CREATE TABLE created (x INT);\nCREATE TABLE other (x NOPE)", "CREATE TABLE other (x NOPE)", 2},
+		{"first statement", "ALTER TABLE missing ADD COLUMN x INT;\nCREATE TABLE created (x INT)", "ALTER TABLE missing ADD COLUMN x INT", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.ExecuteDDL(ctx, tt.ddl)
+			var ddlErr *DDLError
+			if !errors.As(err, &ddlErr) {
+				t.Fatalf("ExecuteDDL: got %v, want a *DDLError", err)
+			}
+			if ddlErr.Statement != tt.statement || ddlErr.Line != tt.line || ddlErr.Message == "" {
+				t.Errorf("DDLError = %+v, want %q at line %d", ddlErr, tt.statement, tt.line)
+			}
+		})
+	}
+
+	// Failed scripts store nothing, not even their successful statements
+	if rev, err := s.CurrentRevision(); err != nil || rev != stored {
+		t.Errorf("revision after failed DDL = %d, %v, want %d", rev, err, stored)
+	}
+	var n int
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM information_schema.tables WHERE table_name = 'created'").Scan(&n); err != nil || n != 0 {
+		t.Errorf("table of a failed script exists: %d, %v", n, err)
+	}
+
+	if err := s.ExecuteDDL(ctx, "-- nothing to do"); err == nil {
+		t.Error("ExecuteDDL accepted a script without statements")
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// deadLetterPayloadSize is how many leading bytes of the file a dead letter carries
+const deadLetterPayloadSize = 4 << 10
+
+// DeadLetter is published to the dead letter subject when a store fails after all retries
+type DeadLetter struct {
+	DBName    string    `json:"db_name"`
+	FilePath  string    `json:"file_path"`
+	Error     string    `json:"error"`
+	Timestamp time.Time `json:"timestamp"`
+	// Payload holds the first 4 KB of the file, to identify it if the file is gone
+	Payload    []byte `json:"payload"`
+	RetryCount int    `json:"retry_count"`
+}
+
+// isDeadLetter reports whether a store error means the upload was given up on, as opposed to
+// being rejected up front
+func isDeadLetter(err error) bool {
+	return IsRetryable(err) || errors.Is(err, ErrCircuitOpen)
+}
+
+// deadLetter publishes a dead letter for a failed store when a subject is configured.
+// Publishing is best effort, a failure is logged.
+func (d *DuckDBStorage) deadLetter(dbFilePath string, attempts int, storeErr error) {
+	if d.opts.DeadLetterSubject == "" {
+		return
+	}
+
+	letter := DeadLetter{
+		DBName:     d.dbName,
+		FilePath:   dbFilePath,
+		Error:      storeErr.Error(),
+		Timestamp:  time.Now().UTC(),
+		RetryCount: attempts,
+	}
+	if file, err := os.Open(dbFilePath); err == nil {
+		letter.Payload, _ = io.ReadAll(io.LimitReader(file, deadLetterPayloadSize))
+		file.Close()
+	}
+
+	data, err := json.Marshal(letter)
+	if err != nil {
+		d.opts.Logger.Error("failed to encode dead letter", "db", d.dbName, "error", err)
+		return
+	}
+	if _, err := d.js.Publish(d.opts.DeadLetterSubject, data); err != nil {
+		d.opts.Logger.Error("failed to publish dead letter", "db", d.dbName, "subject", d.opts.DeadLetterSubject, "error", err)
+	}
+}
+
+// ReplayDeadLetter stores the file named by a dead letter again. The letter must be for the
+// database of this storage and the file must still exist.
+func (d *DuckDBStorage) ReplayDeadLetter(msg *nats.Msg) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	var letter DeadLetter
+	if err := json.Unmarshal(msg.Data, &letter); err != nil {
+		return fmt.Errorf("failed to decode dead letter: %w", err)
+	}
+	if letter.DBName != d.dbName {
+		return fmt.Errorf("dead letter is for %s, not %s", letter.DBName, d.dbName)
+	}
+	if _, err := os.Stat(letter.FilePath); err != nil {
+		return fmt.Errorf("failed to replay dead letter: %w", err)
+	}
+	return d.ForceStore(letter.FilePath)
+}
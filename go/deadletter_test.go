@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// failingObjectStore fails every upload with a transient error
+type failingObjectStore struct {
+	nats.ObjectStore
+}
+
+func (failingObjectStore) Put(*nats.ObjectMeta, io.Reader, ...nats.ObjectOpt) (*nats.ObjectInfo, error) {
+	return nil, nats.ErrTimeout
+}
+
+func TestDeadLetter(t *testing.T) {
+	s, path := storeTestDatabase(t, WithDeadLetterSubject("dlq.store"), WithRetry(2, time.Millisecond))
+	if _, err := s.js.AddStream(&nats.StreamConfig{Name: "DLQ", Subjects: []string{"dlq.>"}}); err != nil {
+		t.Fatalf("failed to create dead letter stream: %v", err)
+	}
+	sub, err := s.js.SubscribeSync("dlq.store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obs := s.obs
+	s.obs = failingObjectStore{obs}
+	if err := s.ForceStore(path); !errors.Is(err, nats.ErrTimeout) {
+		t.Fatalf("ForceStore: got %v, want nats.ErrTimeout", err)
+	}
+
+	msg, err := sub.NextMsg(2 * time.Second)
+	if err != nil {
+		t.Fatalf("no dead letter published: %v", err)
+	}
+	var letter DeadLetter
+	if err := json.Unmarshal(msg.Data, &letter); err != nil {
+		t.Fatalf("failed to decode dead letter: %v", err)
+	}
+	if letter.DBName != defaultDBName || letter.FilePath != path || letter.Error == "" {
+		t.Errorf("dead letter = %s %s %q, want %s %s and an error", letter.DBName, letter.FilePath, letter.Error, defaultDBName, path)
+	}
+	if letter.RetryCount != 2 {
+		t.Errorf("retry count = %d, want 2", letter.RetryCount)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(letter.Payload) != string(data[:deadLetterPayloadSize]) {
+		t.Errorf("payload holds %d bytes, want the first %d of the file", len(letter.Payload), deadLetterPayloadSize)
+	}
+
+	s.obs = obs
+	if err := s.ReplayDeadLetter(msg); err != nil {
+		t.Fatalf("ReplayDeadLetter: %v", err)
+	}
+	if rev, err := s.CurrentRevision(); err != nil || rev == stored {
+		t.Errorf("revision after the replay = %d, %v, want a new revision", rev, err)
+	}
+}
+
+func TestDeadLetterNotPublished(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithDeadLetterSubject("dlq.store"))
+	if _, err := s.js.AddStream(&nats.StreamConfig{Name: "DLQ", Subjects: []string{"dlq.>"}}); err != nil {
+		t.Fatalf("failed to create dead letter stream: %v", err)
+	}
+	sub, err := s.js.SubscribeSync("dlq.store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A missing file is rejected up front rather than given up on
+	if err := s.StoreDuckDB(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Fatal("StoreDuckDB of a missing file succeeded")
+	}
+	if _, err := sub.NextMsg(200 * time.Millisecond); err == nil {
+		t.Error("dead letter published for a rejected store")
+	}
+}
+
+func TestReplayDeadLetterRejected(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	letter := func(dl DeadLetter) *nats.Msg {
+		data, err := json.Marshal(dl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &nats.Msg{Data: data}
+	}
+
+	tests := []struct {
+		name string
+		msg  *nats.Msg
+	}{
+		{"invalid", &nats.Msg{Data: []byte("not json")}},
+		{"other database", letter(DeadLetter{DBName: "other.db", FilePath: path})},
+		{"missing file", letter(DeadLetter{DBName: defaultDBName, FilePath: filepath.Join(t.TempDir(), "missing.db")})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.ReplayDeadLetter(tt.msg); err == nil {
+				t.Error("ReplayDeadLetter succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// defaultResultTable is the table StoreQueryResult creates when no table name is given
+const defaultResultTable = "result"
+
+// StoreQueryResult runs a query against the stored database and stores its result as a new
+// database named outputDBName holding a single table, named tableName or "result" when empty.
+// The query runs next to the data, so large sources can be reduced without a client round trip.
+func (d *DuckDBStorage) StoreQueryResult(ctx context.Context, query, outputDBName, tableName string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if tableName == "" {
+		tableName = defaultResultTable
+	}
+	rows := int64(-1)
+	op := d.logOperation("store_query_result", "query", query, "output", outputDBName, "table", tableName)
+	defer func() { op.done(err, "rows", rows) }()
+
+	sourcePath, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(sourcePath)
+	if err := d.filterDatabase(ctx, sourcePath, query); err != nil {
+		return err
+	}
+
+	outputPath, err := tempPath("duckdb-nats-result-*.db")
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(outputPath)
+
+	db, err := openDuckDB(sourcePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// The query runs against the source as the default catalog, so its table names resolve
+	// without qualification. Attached databases are per connection.
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH "+quoteLiteral(outputPath)+" AS query_result"); err != nil {
+		return fmt.Errorf("failed to create result database: %w", err)
+	}
+	result, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE query_result.main.%s AS %s", quoteIdent(tableName), query))
+	if err != nil {
+		return fmt.Errorf("failed to store query result: %w", err)
+	}
+	if rows, err = result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to read result row count: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "DETACH query_result"); err != nil {
+		return fmt.Errorf("failed to detach result database: %w", err)
+	}
+	conn.Close()
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	if err := d.checkQuota(outputPath, d.objectKey(outputDBName)); err != nil {
+		return err
+	}
+	_, err = d.putDatabase(ctx, d.objectKey(outputDBName), outputPath, nil)
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStoreQueryResult(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "items.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE items AS SELECT range AS id FROM range(1, 11)",
+		"CREATE TABLE other (i INTEGER)",
+	)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		query     string
+		table     string
+		wantTable string
+		wantCount int64
+	}{
+		{"derived.db", "SELECT * FROM items WHERE id > 5", "", "result", 5},
+		{"summary.db", "SELECT count(*) AS n FROM items", "summary", "summary", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.StoreQueryResult(ctx, tt.query, tt.name, tt.table); err != nil {
+				t.Fatalf("StoreQueryResult: %v", err)
+			}
+
+			out := filepath.Join(t.TempDir(), tt.name)
+			if err := newTestStorage(t, s.nc, WithDBName(tt.name)).RetrieveDuckDB(out); err != nil {
+				t.Fatalf("RetrieveDuckDB: %v", err)
+			}
+			// Only the result table is stored
+			if tables := queryTestStrings(t, out, "SELECT table_name FROM duckdb_tables()"); !slices.Equal(tables, []string{tt.wantTable}) {
+				t.Errorf("tables = %v, want [%s]", tables, tt.wantTable)
+			}
+			if n := queryTestInt(t, out, "SELECT count(*) FROM "+tt.wantTable); n != tt.wantCount {
+				t.Errorf("result has %d rows, want %d", n, tt.wantCount)
+			}
+		})
+	}
+
+	// The stored source database is left as it was
+	var n int64
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM items").Scan(&n); err != nil || n != 10 {
+		t.Errorf("source has %d items, %v, want 10", n, err)
+	}
+	if err := s.StoreQueryResult(ctx, "SELECT * FROM missing", "broken.db", ""); err == nil {
+		t.Error("StoreQueryResult of an invalid query succeeded")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ColumnInfo describes a column of a stored table
+type ColumnInfo struct {
+	Name       string
+	Type       string
+	IsNullable bool
+	// DefaultValue is the SQL expression of the column default, empty without a default
+	DefaultValue string
+}
+
+// DescribeTable returns the columns of a table of the stored database in column order. A
+// missing table yields no columns.
+func (d *DuckDBStorage) DescribeTable(ctx context.Context, tableName string) (_ []ColumnInfo, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	rows, err := d.QueryRows(ctx, `
+		SELECT column_name, data_type, is_nullable = 'YES', column_default
+		FROM information_schema.columns
+		WHERE table_name = ?
+		ORDER BY ordinal_position`, tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []ColumnInfo
+	for rows.Next() {
+		var column ColumnInfo
+		var defaultValue sql.NullString
+		if err := rows.Scan(&column.Name, &column.Type, &column.IsNullable, &defaultValue); err != nil {
+			return nil, fmt.Errorf("failed to describe table %s: %w", tableName, err)
+		}
+		column.DefaultValue = defaultValue.String
+		columns = append(columns, column)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to describe table %s: %w", tableName, err)
+	}
+	return columns, nil
+}
+
+// TableExists reports whether the stored database has a table with the given name
+func (d *DuckDBStorage) TableExists(ctx context.Context, tableName string) (_ bool, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return false, err
+	}
+	defer func() { end(err) }()
+
+	var count int
+	err = d.QueryRow(ctx, "SELECT count(*) FROM information_schema.tables WHERE table_name = ?", tableName).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %w", tableName, err)
+	}
+	return count > 0, nil
+}
+
+// ListTables returns the user tables of the stored database, qualified with their schema
+// outside the main schema
+func (d *DuckDBStorage) ListTables(ctx context.Context) (_ []string, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	rows, err := d.QueryRows(ctx, `
+		SELECT schema_name, table_name FROM duckdb_tables()
+		WHERE NOT internal AND NOT temporary
+		ORDER BY schema_name, table_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table tableRef
+		if err := rows.Scan(&table.schema, &table.name); err != nil {
+			return nil, fmt.Errorf("failed to list tables: %w", err)
+		}
+		tables = append(tables, table.String())
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
+	}
+	return tables, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// storeDescribeDatabase stores a database with users, orders and archive.events tables
+func storeDescribeDatabase(t *testing.T) *DuckDBStorage {
+	t.Helper()
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "describe.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE users (id INTEGER NOT NULL, name VARCHAR DEFAULT 'anon')",
+		"CREATE TABLE orders (id BIGINT)",
+		"CREATE SCHEMA archive",
+		"CREATE TABLE archive.events (at TIMESTAMP)",
+	)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestListTables(t *testing.T) {
+	s := storeDescribeDatabase(t)
+	tables, err := s.ListTables(context.Background())
+	if err != nil {
+		t.Fatalf("ListTables: %v", err)
+	}
+	if want := []string{"archive.events", "orders", "users"}; !slices.Equal(tables, want) {
+		t.Errorf("ListTables = %v, want %v", tables, want)
+	}
+}
+
+func TestDescribeTable(t *testing.T) {
+	s := storeDescribeDatabase(t)
+	ctx := context.Background()
+	columns, err := s.DescribeTable(ctx, "users")
+	if err != nil {
+		t.Fatalf("DescribeTable: %v", err)
+	}
+	want := []ColumnInfo{
+		{Name: "id", Type: "INTEGER"},
+		{Name: "name", Type: "VARCHAR", IsNullable: true, DefaultValue: "'anon'"},
+	}
+	if !slices.Equal(columns, want) {
+		t.Errorf("DescribeTable = %+v, want %+v", columns, want)
+	}
+
+	columns, err = s.DescribeTable(ctx, "missing")
+	if err != nil || len(columns) != 0 {
+		t.Errorf("DescribeTable of a missing table = %+v, %v, want no columns", columns, err)
+	}
+}
+
+func TestTableExists(t *testing.T) {
+	s := storeDescribeDatabase(t)
+	for table, want := range map[string]bool{"users": true, "events": true, "missing": false} {
+		exists, err := s.TableExists(context.Background(), table)
+		if err != nil {
+			t.Fatalf("TableExists(%s): %v", table, err)
+		}
+		if exists != want {
+			t.Errorf("TableExists(%s) = %v, want %v", table, exists, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+)
+
+// DatabaseDiff is the table-level data difference between two stored databases
+type DatabaseDiff struct {
+	TablesOnlyInA []string
+	TablesOnlyInB []string
+	// TableDiffs holds an entry for every table present in both databases
+	TableDiffs map[string]TableDiff
+}
+
+// TableDiff counts the distinct rows of a table found in only one of the databases
+type TableDiff struct {
+	RowsOnlyInA int64
+	RowsOnlyInB int64
+}
+
+// Identical reports whether both tables hold the same rows
+func (t TableDiff) Identical() bool {
+	return t.RowsOnlyInA == 0 && t.RowsOnlyInB == 0
+}
+
+// DiffDatabases retrieves two stored databases and compares the rows of every table they have
+// in common. Every table is scanned in full, so this is expensive for large databases.
+func (d *DuckDBStorage) DiffDatabases(ctx context.Context, nameA, nameB string) (diff DatabaseDiff, err error) {
+	op := d.logOperation("diff", "a", nameA, "b", nameB)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return diff, err
+	}
+	defer func() { end(err) }()
+
+	var paths []string
+	defer func() {
+		for _, path := range paths {
+			removeTempDatabase(path)
+		}
+	}()
+	for _, name := range []string{nameA, nameB} {
+		path, err := tempPath("duckdb-nats-*.db")
+		if err != nil {
+			return diff, err
+		}
+		paths = append(paths, path)
+
+		if err := d.getDatabase(ctx, d.objectKey(name), path); err != nil {
+			return diff, fmt.Errorf("failed to retrieve %s: %w", name, err)
+		}
+	}
+
+	return diffFiles(ctx, paths[0], paths[1])
+}
+
+// diffFiles compares the rows of every table two database files have in common. Both are
+// attached read-only, so neither file is modified.
+func diffFiles(ctx context.Context, pathA, pathB string) (diff DatabaseDiff, err error) {
+	paths := []string{pathA, pathB}
+	db, err := openDuckDB("")
+	if err != nil {
+		return diff, err
+	}
+	defer db.Close()
+
+	for i, alias := range []string{"a", "b"} {
+		attach := fmt.Sprintf("ATTACH %s AS %s (READ_ONLY)", quoteLiteral(paths[i]), alias)
+		if _, err := db.ExecContext(ctx, attach); err != nil {
+			return diff, fmt.Errorf("failed to attach %s: %w", alias, err)
+		}
+	}
+
+	tablesA, err := attachedTables(ctx, db, "a")
+	if err != nil {
+		return diff, err
+	}
+	tablesB, err := attachedTables(ctx, db, "b")
+	if err != nil {
+		return diff, err
+	}
+
+	diff.TableDiffs = make(map[string]TableDiff)
+	for _, table := range tablesA {
+		if !slices.Contains(tablesB, table) {
+			diff.TablesOnlyInA = append(diff.TablesOnlyInA, table.String())
+			continue
+		}
+
+		var tableDiff TableDiff
+		if tableDiff.RowsOnlyInA, err = countExcept(ctx, db, "a", "b", table); err != nil {
+			return diff, err
+		}
+		if tableDiff.RowsOnlyInB, err = countExcept(ctx, db, "b", "a", table); err != nil {
+			return diff, err
+		}
+		diff.TableDiffs[table.String()] = tableDiff
+	}
+	for _, table := range tablesB {
+		if !slices.Contains(tablesA, table) {
+			diff.TablesOnlyInB = append(diff.TablesOnlyInB, table.String())
+		}
+	}
+
+	return diff, nil
+}
+
+// tableRef names a table of an attached database
+type tableRef struct {
+	schema string
+	name   string
+}
+
+// String returns the table name, qualified with its schema outside the main schema
+func (t tableRef) String() string {
+	if t.schema == "main" {
+		return t.name
+	}
+	return t.schema + "." + t.name
+}
+
+// attachedTables returns the user tables of an attached database in a stable order
+func attachedTables(ctx context.Context, db *sql.DB, alias string) ([]tableRef, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT schema_name, table_name FROM duckdb_tables()
+		WHERE database_name = ? AND NOT internal AND NOT temporary
+		ORDER BY schema_name, table_name`, alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables of %s: %w", alias, err)
+	}
+	defer rows.Close()
+
+	var tables []tableRef
+	for rows.Next() {
+		var table tableRef
+		if err := rows.Scan(&table.schema, &table.name); err != nil {
+			return nil, fmt.Errorf("failed to list tables of %s: %w", alias, err)
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list tables of %s: %w", alias, err)
+	}
+	return tables, nil
+}
+
+// countExcept counts the distinct rows of table in database from that are missing in database other
+func countExcept(ctx context.Context, db *sql.DB, from, other string, table tableRef) (int64, error) {
+	qualified := quoteIdent(table.schema) + "." + quoteIdent(table.name)
+
+	var count int64
+	err := db.QueryRowContext(ctx, fmt.Sprintf(
+		"SELECT count(*) FROM (SELECT * FROM %s.%s EXCEPT SELECT * FROM %s.%s)",
+		from, qualified, other, qualified,
+	)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compare table %s: %w", table, err)
+	}
+	return count, nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestDiffFiles(t *testing.T) {
+	dir := t.TempDir()
+	pathA, pathB := filepath.Join(dir, "a.db"), filepath.Join(dir, "b.db")
+	createTestDatabase(t, pathA)
+	execTestDatabase(t, pathA,
+		"CREATE SCHEMA archive",
+		"CREATE TABLE archive.events (id INTEGER)",
+		"CREATE TABLE same (id INTEGER)",
+		"INSERT INTO same VALUES (1), (2)",
+	)
+	// Copy A so the users rows share their timestamps
+	data, err := os.ReadFile(pathA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pathB, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	execTestDatabase(t, pathB,
+		"DROP TABLE archive.events",
+		"DROP SCHEMA archive",
+		"DELETE FROM users WHERE id = 1",
+		"INSERT INTO users VALUES (10, 'Dave', now()), (11, 'Eve', now())",
+		"DELETE FROM same",
+		"INSERT INTO same VALUES (2), (1)",
+		"CREATE TABLE extra (id INTEGER)",
+	)
+
+	diff, err := diffFiles(context.Background(), pathA, pathB)
+	if err != nil {
+		t.Fatalf("diffFiles: %v", err)
+	}
+	if want := []string{"archive.events"}; !slices.Equal(diff.TablesOnlyInA, want) {
+		t.Errorf("TablesOnlyInA = %v, want %v", diff.TablesOnlyInA, want)
+	}
+	if want := []string{"extra"}; !slices.Equal(diff.TablesOnlyInB, want) {
+		t.Errorf("TablesOnlyInB = %v, want %v", diff.TablesOnlyInB, want)
+	}
+	if got, want := diff.TableDiffs["users"], (TableDiff{RowsOnlyInA: 1, RowsOnlyInB: 2}); got != want {
+		t.Errorf("users diff = %+v, want %+v", got, want)
+	}
+	if same, ok := diff.TableDiffs["same"]; !ok || !same.Identical() {
+		t.Errorf("same diff = %+v, %v, want identical", same, ok)
+	}
+	if len(diff.TableDiffs) != 2 {
+		t.Errorf("TableDiffs = %v, want users and same", diff.TableDiffs)
+	}
+}
+
+func TestDiffDatabases(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	execTestDatabase(t, path,
+		"INSERT INTO users VALUES (10, 'Dave', now())",
+		"CREATE TABLE extra (id INTEGER)",
+	)
+	other := newTestStorage(t, s.nc, WithDBName("changed.db"))
+	if err := other.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := s.DiffDatabases(context.Background(), defaultDBName, "changed.db")
+	if err != nil {
+		t.Fatalf("DiffDatabases: %v", err)
+	}
+	if got, want := diff.TableDiffs["users"], (TableDiff{RowsOnlyInB: 1}); got != want {
+		t.Errorf("users diff = %+v, want %+v", got, want)
+	}
+	if len(diff.TablesOnlyInA) != 0 || !slices.Equal(diff.TablesOnlyInB, []string{"extra"}) {
+		t.Errorf("tables only in A = %v and only in B = %v, want none and extra", diff.TablesOnlyInA, diff.TablesOnlyInB)
+	}
+
+	if _, err := s.DiffDatabases(context.Background(), defaultDBName, "missing.db"); err == nil {
+		t.Error("DiffDatabases with a missing database succeeded")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.DiffDatabases(ctx, defaultDBName, "changed.db"); err == nil {
+		t.Error("DiffDatabases with a cancelled context succeeded")
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	encryptionHeader      = "X-Encryption"
+	encryptionNonceHeader = "X-Encryption-Nonce"
+	encryptionAlgorithm   = "AES-256-GCM"
+
+	// encryptionSegmentSize is the plaintext size sealed as one GCM message
+	encryptionSegmentSize = 64 * 1024
+)
+
+// ErrDecryptionFailed is returned when an encrypted object cannot be decrypted with the configured key
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// newGCM returns an AES-256-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("encryption key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// newNonce returns a random base nonce for an object
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, 12)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return nonce, nil
+}
+
+// segmentNonce derives the nonce of a segment from the base nonce and the segment counter
+func segmentNonce(base []byte, counter uint64) []byte {
+	nonce := make([]byte, len(base))
+	copy(nonce, base)
+	for i := 0; i < 8; i++ {
+		nonce[len(nonce)-1-i] ^= byte(counter >> (8 * i))
+	}
+	return nonce
+}
+
+// encryptReader returns a reader yielding r encrypted as a sequence of GCM segments. Each
+// segment is framed as a final flag byte, a 4-byte ciphertext length and the ciphertext; the
+// flag is authenticated so truncated objects fail to decrypt.
+func encryptReader(r io.Reader, key, nonce []byte) (io.ReadCloser, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(encryptSegments(pw, bufio.NewReaderSize(r, encryptionSegmentSize), aead, nonce))
+	}()
+	return pr, nil
+}
+
+func encryptSegments(w io.Writer, r *bufio.Reader, aead cipher.AEAD, nonce []byte) error {
+	plaintext := make([]byte, encryptionSegmentSize)
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(r, plaintext)
+		final := false
+		switch {
+		case err == io.EOF || err == io.ErrUnexpectedEOF:
+			final = true
+		case err != nil:
+			return err
+		default:
+			_, peekErr := r.Peek(1)
+			final = peekErr == io.EOF
+		}
+
+		frame := []byte{0, 0, 0, 0, 0}
+		if final {
+			frame[0] = 1
+		}
+		sealed := aead.Seal(nil, segmentNonce(nonce, counter), plaintext[:n], frame[:1])
+		binary.BigEndian.PutUint32(frame[1:], uint32(len(sealed)))
+
+		if _, err := w.Write(frame); err != nil {
+			return err
+		}
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
+
+// decryptReader reverses encryptReader
+type decryptReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	nonce   []byte
+	counter uint64
+	buf     []byte
+	done    bool
+}
+
+func newDecryptReader(r io.Reader, key, nonce []byte) (*decryptReader, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("%w: invalid nonce", ErrDecryptionFailed)
+	}
+	return &decryptReader{r: r, aead: aead, nonce: nonce}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// next reads and opens the following segment
+func (d *decryptReader) next() error {
+	frame := make([]byte, 5)
+	if _, err := io.ReadFull(d.r, frame); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: truncated ciphertext", ErrDecryptionFailed)
+		}
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(frame[1:])
+	if size > encryptionSegmentSize+uint32(d.aead.Overhead()) {
+		return fmt.Errorf("%w: invalid segment size", ErrDecryptionFailed)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("%w: truncated ciphertext", ErrDecryptionFailed)
+		}
+		return err
+	}
+
+	plaintext, err := d.aead.Open(nil, segmentNonce(d.nonce, d.counter), sealed, frame[:1])
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+
+	d.buf = plaintext
+	d.counter++
+	d.done = frame[0] == 1
+	return nil
+}
+
+// decryptObject wraps r with decryption if the object headers say it is encrypted
+func decryptObject(r io.Reader, headers nats.Header, key []byte) (io.Reader, error) {
+	if headers.Get(encryptionHeader) == "" {
+		return r, nil
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: no encryption key configured", ErrDecryptionFailed)
+	}
+
+	nonce, err := base64.StdEncoding.DecodeString(headers.Get(encryptionNonceHeader))
+	if err != nil || len(nonce) == 0 {
+		return nil, fmt.Errorf("%w: missing or invalid nonce", ErrDecryptionFailed)
+	}
+	return newDecryptReader(r, key, nonce)
+}
+
+// encryptObject wraps r with encryption under a fresh nonce and records it in headers
+func encryptObject(r io.Reader, headers nats.Header, key []byte) (io.ReadCloser, error) {
+	nonce, err := newNonce()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptReader(r, key, nonce)
+	if err != nil {
+		return nil, err
+	}
+	headers.Set(encryptionHeader, encryptionAlgorithm)
+	headers.Set(encryptionNonceHeader, base64.StdEncoding.EncodeToString(nonce))
+	return encrypted, nil
+}
+
+// ErrKeyRotation is returned when RotateEncryptionKey fails part way. The objects rotated
+// before the failure are encrypted with the old key again, except for the ones in Rotated,
+// whose rollback failed as well.
+type ErrKeyRotation struct {
+	Object  string
+	Rotated []string
+	Err     error
+}
+
+func (e ErrKeyRotation) Error() string {
+	msg := fmt.Sprintf("failed to rotate key for %s: %v", e.Object, e.Err)
+	if len(e.Rotated) > 0 {
+		msg += fmt.Sprintf(", still encrypted with the new key: %s", strings.Join(e.Rotated, ", "))
+	}
+	return msg
+}
+
+func (e ErrKeyRotation) Unwrap() error {
+	return e.Err
+}
+
+// encryptionKey returns the key objects are currently encrypted with
+func (d *DuckDBStorage) encryptionKey() []byte {
+	d.keyMu.RLock()
+	defer d.keyMu.RUnlock()
+	return d.opts.EncryptionKey
+}
+
+// RotateEncryptionKey re-encrypts every encrypted object in the bucket from oldKey to newKey
+// and switches the storage handler to newKey. Stores and retrievals of the handler wait for the
+// rotation to finish. If an object cannot be rotated, the ones rotated before it are rolled
+// back to oldKey and an ErrKeyRotation is returned.
+func (d *DuckDBStorage) RotateEncryptionKey(oldKey, newKey []byte) (err error) {
+	op := d.logOperation("rotate_key")
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if _, err := newGCM(newKey); err != nil {
+		return err
+	}
+
+	d.keyMu.Lock()
+	defer d.keyMu.Unlock()
+
+	objects, err := d.obs.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		objects = nil
+	} else if err != nil {
+		return fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var rotated []string
+	for _, info := range objects {
+		if info.Headers.Get(encryptionHeader) == "" {
+			continue
+		}
+		if err := d.reencryptObject(info.Name, oldKey, newKey); err != nil {
+			rotationErr := ErrKeyRotation{Object: info.Name, Err: err}
+			for _, name := range rotated {
+				if rollbackErr := d.reencryptObject(name, newKey, oldKey); rollbackErr != nil {
+					d.opts.Logger.Error("failed to roll back key rotation", "object", name, "error", rollbackErr)
+					rotationErr.Rotated = append(rotationErr.Rotated, name)
+				}
+			}
+			return rotationErr
+		}
+		rotated = append(rotated, info.Name)
+	}
+
+	d.opts.EncryptionKey = newKey
+	return nil
+}
+
+func (d *DuckDBStorage) reencryptObject(name string, oldKey, newKey []byte) error {
+	obj, err := d.obs.Get(name)
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+
+	info, err := obj.Info()
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := decryptObject(obj, info.Headers, oldKey)
+	if err != nil {
+		return err
+	}
+
+	headers := cloneHeader(info.Headers)
+	encrypted, err := encryptObject(plaintext, headers, newKey)
+	if err != nil {
+		return err
+	}
+	defer encrypted.Close()
+
+	_, err = d.obs.Put(&nats.ObjectMeta{
+		Name:        name,
+		Description: info.Description,
+		Headers:     headers,
+	}, encrypted)
+	return err
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+// duckDBMagic is the magic number DuckDB writes at the start of every database file
+var duckDBMagic = []byte("DUCK")
+
+// testKey returns a random AES-256 key
+func testKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestEncryptObjectRoundTrip(t *testing.T) {
+	key := testKey(t)
+	for _, size := range []int{0, 1, encryptionSegmentSize, encryptionSegmentSize + 5, 3 * encryptionSegmentSize} {
+		data := make([]byte, size)
+		rand.Read(data)
+
+		headers := nats.Header{}
+		encrypted, err := encryptObject(bytes.NewReader(data), headers, key)
+		if err != nil {
+			t.Fatalf("encryptObject(%d bytes): %v", size, err)
+		}
+		ciphertext, err := io.ReadAll(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		decrypted, err := decryptObject(bytes.NewReader(ciphertext), headers, key)
+		if err != nil {
+			t.Fatalf("decryptObject(%d bytes): %v", size, err)
+		}
+		got, err := io.ReadAll(decrypted)
+		if err != nil {
+			t.Fatalf("failed to decrypt %d bytes: %v", size, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("%d bytes did not round-trip", size)
+		}
+
+		// A truncated ciphertext must not decrypt to a silently shorter plaintext
+		if len(ciphertext) > 3 {
+			decrypted, err := decryptObject(bytes.NewReader(ciphertext[:len(ciphertext)-3]), headers, key)
+			if err == nil {
+				_, err = io.ReadAll(decrypted)
+			}
+			if !errors.Is(err, ErrDecryptionFailed) {
+				t.Errorf("truncated %d bytes: got %v, want ErrDecryptionFailed", size, err)
+			}
+		}
+	}
+}
+
+func TestNewGCMKeySize(t *testing.T) {
+	if _, err := newGCM(make([]byte, 16)); err == nil {
+		t.Error("newGCM accepted a 128-bit key")
+	}
+	if _, err := NewDuckDBStorage(startTestServer(t), WithEncryptionKey(make([]byte, 8))); err == nil {
+		t.Error("NewDuckDBStorage accepted an invalid key")
+	}
+}
+
+func TestEncryptedStore(t *testing.T) {
+	for _, chunkSize := range []int64{0, 64 << 10} {
+		t.Run(fmt.Sprintf("chunk=%d", chunkSize), func(t *testing.T) {
+			key := testKey(t)
+			s, path := storeTestDatabase(t, WithEncryptionKey(key), WithChunkSize(chunkSize))
+			plain, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			name := s.dbName
+			if chunkSize > 0 {
+				manifest, err := s.getManifest()
+				if err != nil {
+					t.Fatal(err)
+				}
+				name = manifest.Chunks[0].Name
+			}
+			raw, err := s.obs.GetBytes(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if bytes.Contains(raw, duckDBMagic) {
+				t.Error("stored object contains the DuckDB magic bytes")
+			}
+			if bytes.Contains(raw, plain[:4096]) {
+				t.Error("stored object contains plaintext")
+			}
+
+			out := filepath.Join(t.TempDir(), "out.db")
+			if err := s.RetrieveDuckDB(out); err != nil {
+				t.Fatalf("RetrieveDuckDB: %v", err)
+			}
+			got, err := os.ReadFile(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatal("retrieved database does not match the stored one")
+			}
+		})
+	}
+}
+
+func TestRotateEncryptionKey(t *testing.T) {
+	oldKey, newKey := testKey(t), testKey(t)
+	s, path := storeTestDatabase(t, WithEncryptionKey(oldKey), WithCompression(CompressionZstd))
+	if err := s.RotateEncryptionKey(oldKey, newKey); err != nil {
+		t.Fatalf("RotateEncryptionKey: %v", err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB with the new key: %v", err)
+	}
+	want, _ := os.ReadFile(path)
+	if got, _ := os.ReadFile(out); !bytes.Equal(got, want) {
+		t.Fatal("retrieved database does not match the stored one")
+	}
+
+	stale := newTestStorage(t, s.nc, WithEncryptionKey(oldKey), WithCompression(CompressionZstd))
+	if err := stale.RetrieveDuckDB(filepath.Join(t.TempDir(), "stale.db")); !errors.Is(err, ErrDecryptionFailed) {
+		t.Errorf("RetrieveDuckDB with the old key: got %v, want ErrDecryptionFailed", err)
+	}
+}
+
+// failingPutObjectStore fails the uploads whose number, counted from 1, is in failing
+type failingPutObjectStore struct {
+	nats.ObjectStore
+	puts    *atomic.Int32
+	failing []int32
+}
+
+func (o failingPutObjectStore) Put(meta *nats.ObjectMeta, r io.Reader, opts ...nats.ObjectOpt) (*nats.ObjectInfo, error) {
+	if slices.Contains(o.failing, o.puts.Add(1)) {
+		return nil, nats.ErrTimeout
+	}
+	return o.ObjectStore.Put(meta, r, opts...)
+}
+
+func TestRotateEncryptionKeyPartialFailure(t *testing.T) {
+	oldKey, newKey := testKey(t), testKey(t)
+	s, path := storeTestDatabase(t, WithEncryptionKey(oldKey))
+	if err := s.StoreVersion(path, "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first object is rotated, the second fails and the first is rolled back
+	obs := s.obs
+	s.obs = failingPutObjectStore{ObjectStore: obs, puts: new(atomic.Int32), failing: []int32{2}}
+	err := s.RotateEncryptionKey(oldKey, newKey)
+	var rotationErr ErrKeyRotation
+	if !errors.As(err, &rotationErr) || !errors.Is(err, nats.ErrTimeout) {
+		t.Fatalf("RotateEncryptionKey: got %v, want ErrKeyRotation", err)
+	}
+	if rotationErr.Object == "" || len(rotationErr.Rotated) != 0 {
+		t.Errorf("ErrKeyRotation = %+v, want the failed object and nothing left rotated", rotationErr)
+	}
+	s.obs = obs
+
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDB(out); err != nil {
+		t.Errorf("RetrieveDuckDB with the old key after a failed rotation: %v", err)
+	}
+	if err := s.RetrieveVersion("v1", out); err != nil {
+		t.Errorf("RetrieveVersion with the old key after a failed rotation: %v", err)
+	}
+
+	// A failed rollback is reported with the objects left on the new key
+	s.obs = failingPutObjectStore{ObjectStore: obs, puts: new(atomic.Int32), failing: []int32{2, 3}}
+	err = s.RotateEncryptionKey(oldKey, newKey)
+	if !errors.As(err, &rotationErr) || len(rotationErr.Rotated) != 1 || rotationErr.Rotated[0] == rotationErr.Object {
+		t.Fatalf("RotateEncryptionKey with a failed rollback: got %v, want one object left rotated", err)
+	}
+	s.obs = obs
+	rotated := newTestStorage(t, s.nc, WithEncryptionKey(newKey))
+	name := rotationErr.Rotated[0]
+	reader, _, err := rotated.openObjectFrom(context.Background(), rotated.obs, name)
+	if err == nil {
+		_, err = io.ReadAll(reader)
+		reader.Close()
+	}
+	if err != nil {
+		t.Errorf("%s is not readable with the new key: %v", name, err)
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Environment variables read by NewDuckDBStorageFromEnv
+const (
+	envNATSURL       = "DUCKDB_NATS_URL"
+	envBucket        = "DUCKDB_NATS_BUCKET"
+	envDBName        = "DUCKDB_NATS_DB_NAME"
+	envCompression   = "DUCKDB_NATS_COMPRESSION"
+	envEncryptionKey = "DUCKDB_NATS_ENCRYPTION_KEY"
+	envCredsFile     = "DUCKDB_NATS_CREDS_FILE"
+	envTLSCA         = "DUCKDB_NATS_TLS_CA"
+)
+
+// ErrMissingEnvVar is returned by NewDuckDBStorageFromEnv when a required variable is not set
+type ErrMissingEnvVar struct {
+	VarName string
+}
+
+func (e ErrMissingEnvVar) Error() string {
+	return fmt.Sprintf("environment variable %s is not set", e.VarName)
+}
+
+// NewDuckDBStorageFromEnv connects to NATS and creates a storage handler configured by the
+// environment. DUCKDB_NATS_URL is required. DUCKDB_NATS_BUCKET, DUCKDB_NATS_DB_NAME and
+// DUCKDB_NATS_COMPRESSION default like the config file keys, DUCKDB_NATS_ENCRYPTION_KEY holds a
+// hex or base64 encoded key, DUCKDB_NATS_CREDS_FILE a NATS credentials file and
+// DUCKDB_NATS_TLS_CA a CA bundle to verify the server with. opts are applied after the
+// environment and take precedence. The handler owns the connection, Close drains and closes it.
+func NewDuckDBStorageFromEnv(opts ...Option) (*DuckDBStorage, error) {
+	url, envOpts, err := envOptions()
+	if err != nil {
+		return nil, err
+	}
+	opts = append(envOpts, opts...)
+
+	options := defaultStorageOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	nc, err := nats.Connect(url, options.NATSOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	d, err := NewDuckDBStorage(nc, opts...)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	d.ownsConn = true
+	return d, nil
+}
+
+// envOptions returns the NATS URL and the storage options described by the environment
+func envOptions() (string, []Option, error) {
+	config := StorageConfig{
+		NATSURL:     os.Getenv(envNATSURL),
+		Bucket:      os.Getenv(envBucket),
+		DBName:      os.Getenv(envDBName),
+		Compression: os.Getenv(envCompression),
+	}
+	if config.NATSURL == "" {
+		return "", nil, ErrMissingEnvVar{VarName: envNATSURL}
+	}
+	if os.Getenv(envEncryptionKey) != "" {
+		config.EncryptionKeyEnv = envEncryptionKey
+	}
+
+	opts, err := config.Options()
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid environment: %w", err)
+	}
+	if creds := os.Getenv(envCredsFile); creds != "" {
+		opts = append(opts, WithNATSOptions(nats.UserCredentials(creds)))
+	}
+	if ca := os.Getenv(envTLSCA); ca != "" {
+		opts = append(opts, WithNATSOptions(nats.RootCAs(ca)))
+	}
+	return config.NATSURL, opts, nil
+}
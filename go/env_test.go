@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+// setTestEnv sets the storage environment variables for a connection to url
+func setTestEnv(t *testing.T, url string) {
+	t.Setenv(envNATSURL, url)
+	t.Setenv(envBucket, "ENVBUCKET")
+	t.Setenv(envDBName, "env.db")
+	t.Setenv(envCompression, "gzip")
+	t.Setenv(envEncryptionKey, "0707070707070707070707070707070707070707070707070707070707070707")
+	t.Setenv(envCredsFile, "")
+	t.Setenv(envTLSCA, "")
+}
+
+func TestEnvOptions(t *testing.T) {
+	setTestEnv(t, "nats://example.com:4222")
+	t.Setenv(envCredsFile, filepath.Join(t.TempDir(), "user.creds"))
+	t.Setenv(envTLSCA, filepath.Join(t.TempDir(), "ca.pem"))
+
+	url, opts, err := envOptions()
+	if err != nil {
+		t.Fatalf("envOptions: %v", err)
+	}
+	options := defaultStorageOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if url != "nats://example.com:4222" {
+		t.Errorf("url = %s, want nats://example.com:4222", url)
+	}
+	if options.Bucket != "ENVBUCKET" || options.DBName != "env.db" || options.Compression != CompressionGzip {
+		t.Errorf("options = %s, %s, %s, want ENVBUCKET, env.db, gzip", options.Bucket, options.DBName, options.Compression)
+	}
+	if !bytes.Equal(options.EncryptionKey, bytes.Repeat([]byte{7}, 32)) {
+		t.Errorf("encryption key = %x", options.EncryptionKey)
+	}
+	// The credentials and CA become NATS connection options
+	if len(options.NATSOptions) != 2 {
+		t.Errorf("%d NATS options, want 2", len(options.NATSOptions))
+	}
+}
+
+func TestNewDuckDBStorageFromEnv(t *testing.T) {
+	nc := startTestServer(t)
+	setTestEnv(t, nc.ConnectedUrl())
+
+	s, err := NewDuckDBStorageFromEnv()
+	if err != nil {
+		t.Fatalf("NewDuckDBStorageFromEnv: %v", err)
+	}
+	defer s.Close(context.Background())
+	if s.bucket != "ENVBUCKET" || s.dbName != "env.db" || s.opts.Compression != CompressionGzip {
+		t.Errorf("storage = %s, %s, %s, want ENVBUCKET, env.db, gzip", s.bucket, s.dbName, s.opts.Compression)
+	}
+	if !bytes.Equal(s.opts.EncryptionKey, bytes.Repeat([]byte{7}, 32)) {
+		t.Errorf("encryption key = %x", s.opts.EncryptionKey)
+	}
+	if !s.ownsConn {
+		t.Error("storage does not own its connection")
+	}
+
+	// Options passed in take precedence over the environment
+	override, err := NewDuckDBStorageFromEnv(WithDBName("override.db"))
+	if err != nil {
+		t.Fatalf("NewDuckDBStorageFromEnv: %v", err)
+	}
+	defer override.Close(context.Background())
+	if override.dbName != "override.db" {
+		t.Errorf("db name = %s, want override.db", override.dbName)
+	}
+}
+
+func TestNewDuckDBStorageFromEnvInvalid(t *testing.T) {
+	setTestEnv(t, "")
+	var missing ErrMissingEnvVar
+	if _, err := NewDuckDBStorageFromEnv(); !errors.As(err, &missing) || missing.VarName != envNATSURL {
+		t.Errorf("NewDuckDBStorageFromEnv without a URL: got %v, want ErrMissingEnvVar for %s", err, envNATSURL)
+	}
+
+	setTestEnv(t, "nats://127.0.0.1:1")
+	t.Setenv(envCompression, "lz4")
+	if _, err := NewDuckDBStorageFromEnv(); err == nil {
+		t.Error("NewDuckDBStorageFromEnv accepted an unsupported compression")
+	}
+	t.Setenv(envCompression, "")
+	t.Setenv(envEncryptionKey, "short")
+	if _, err := NewDuckDBStorageFromEnv(); err == nil {
+		t.Error("NewDuckDBStorageFromEnv accepted an invalid encryption key")
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// expiresAtHeader records when a stored database becomes eligible for PurgeExpired
+const expiresAtHeader = "X-Expires-At"
+
+// setExpiry adds the expiry header to headers when an object TTL is configured
+func (d *DuckDBStorage) setExpiry(headers nats.Header) {
+	if d.opts.ObjectTTL > 0 {
+		headers.Set(expiresAtHeader, time.Now().Add(d.opts.ObjectTTL).UTC().Format(time.RFC3339Nano))
+	}
+}
+
+// purgeExpiredObjects deletes every object of the bucket whose expiry header has passed
+func (d *DuckDBStorage) purgeExpiredObjects() (int, error) {
+	objects, err := d.obs.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	now := time.Now()
+	purged := 0
+	for _, info := range objects {
+		value := info.Headers.Get(expiresAtHeader)
+		if value == "" {
+			continue
+		}
+		expiresAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil || now.Before(expiresAt) {
+			continue
+		}
+		if err := d.obs.Delete(info.Name); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return purged, fmt.Errorf("failed to purge %s: %w", info.Name, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
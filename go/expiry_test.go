@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestBucketTTL(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithBucket("EXPIRING"), WithBucketTTL(200*time.Millisecond))
+	config, err := s.BucketConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.TTL != 200*time.Millisecond {
+		t.Errorf("bucket TTL = %v, want 200ms", config.TTL)
+	}
+
+	// The server removes expired messages on its own schedule, so allow it some slack
+	time.Sleep(300 * time.Millisecond)
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_, err := s.GetInfo()
+		if errors.Is(err, nats.ErrObjectNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("GetInfo after the bucket TTL: got %v, want ErrObjectNotFound", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestObjectTTL(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithObjectTTL(100*time.Millisecond))
+	info, err := s.GetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt, err := time.Parse(time.RFC3339Nano, info.Headers.Get(expiresAtHeader))
+	if err != nil {
+		t.Fatalf("invalid %s header: %v", expiresAtHeader, err)
+	}
+	if until := time.Until(expiresAt); until <= 0 || until > 100*time.Millisecond {
+		t.Errorf("database expires in %v, want within 100ms", until)
+	}
+
+	if purged, err := s.PurgeExpired(); err != nil || purged != 0 {
+		t.Fatalf("PurgeExpired before the expiry = %d, %v, want 0", purged, err)
+	}
+	time.Sleep(150 * time.Millisecond)
+	if purged, err := s.PurgeExpired(); err != nil || purged != 1 {
+		t.Fatalf("PurgeExpired after the expiry = %d, %v, want 1", purged, err)
+	}
+	if _, err := s.GetInfo(); !errors.Is(err, nats.ErrObjectNotFound) {
+		t.Errorf("GetInfo after PurgeExpired: got %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestObjectTTLChunked(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithObjectTTL(time.Millisecond), WithChunkSize(100000))
+	time.Sleep(5 * time.Millisecond)
+	// The manifest and every chunk carry the expiry
+	purged, err := s.PurgeExpired()
+	if err != nil {
+		t.Fatalf("PurgeExpired: %v", err)
+	}
+	if purged < 2 {
+		t.Errorf("PurgeExpired purged %d objects, want the manifest and its chunks", purged)
+	}
+	if objects, err := s.obs.List(); !errors.Is(err, nats.ErrNoObjectsFound) {
+		t.Errorf("objects left after PurgeExpired: %d, %v", len(objects), err)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// extensionPrefix is the object name prefix of stored DuckDB extensions
+	extensionPrefix = "extensions/"
+	// extensionVersionHeader records the version given to StoreExtension
+	extensionVersionHeader = "X-Extension-Version"
+	// extensionSuffix is the file name suffix DuckDB expects for extension files
+	extensionSuffix = ".duckdb_extension"
+)
+
+// ExtensionInfo describes a stored DuckDB extension
+type ExtensionInfo struct {
+	Name    string
+	Version string
+	Size    int64
+}
+
+// extensionOptions controls a single StoreExtension call
+type extensionOptions struct {
+	version string
+}
+
+// ExtensionOption configures StoreExtension
+type ExtensionOption func(*extensionOptions)
+
+// WithExtensionVersion records the version of the stored extension, reported by ListExtensions
+func WithExtensionVersion(version string) ExtensionOption {
+	return func(o *extensionOptions) {
+		o.version = version
+	}
+}
+
+// StoreExtension uploads a DuckDB extension file so other instances can load it with
+// LoadExtension. name is the extension name, such as "spatial".
+func (d *DuckDBStorage) StoreExtension(name, localPath string, opts ...ExtensionOption) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	name = strings.TrimSuffix(strings.TrimPrefix(name, extensionPrefix), extensionSuffix)
+	op := d.logOperation("store_extension", "extension", name, "path", localPath)
+	defer func() { op.done(err) }()
+
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid extension name: %q", name)
+	}
+
+	var options extensionOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	headers := nats.Header{
+		"Content-Type": []string{"application/octet-stream"},
+	}
+	if options.version != "" {
+		headers.Set(extensionVersionHeader, options.version)
+	}
+	_, err = d.putFile(context.Background(), d.objectKey(extensionPrefix+name), localPath, "DuckDB extension "+name, headers)
+	return err
+}
+
+// LoadExtension downloads a stored extension into the extension directory of db and loads it.
+// Extensions that are not signed by DuckDB only load if db was opened with
+// allow_unsigned_extensions enabled.
+func (d *DuckDBStorage) LoadExtension(ctx context.Context, db *sql.DB, name string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	name = strings.TrimSuffix(name, extensionSuffix)
+	op := d.logOperation("load_extension", "extension", name)
+	defer func() { op.done(err) }()
+
+	path, err := extensionPath(ctx, db, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create extension directory: %w", err)
+	}
+	if err := d.getFile(ctx, d.objectKey(extensionPrefix+name), path); err != nil {
+		return err
+	}
+
+	if _, err := db.ExecContext(ctx, "LOAD "+quoteLiteral(path)); err != nil {
+		return fmt.Errorf("failed to load extension %s: %w", name, err)
+	}
+	return nil
+}
+
+// ListExtensions returns the stored extensions of the namespace sorted by name
+func (d *DuckDBStorage) ListExtensions() (_ []ExtensionInfo, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	objects, err := d.obs.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var extensions []ExtensionInfo
+	for _, info := range objects {
+		key, ok := d.logicalName(info.Name)
+		if !ok {
+			continue
+		}
+		if name, ok := strings.CutPrefix(key, extensionPrefix); ok && !strings.Contains(name, "/") {
+			extensions = append(extensions, ExtensionInfo{
+				Name:    name,
+				Version: info.Headers.Get(extensionVersionHeader),
+				Size:    int64(info.Size),
+			})
+		}
+	}
+	slices.SortFunc(extensions, func(a, b ExtensionInfo) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return extensions, nil
+}
+
+// extensionPath returns where INSTALL would place the named extension for db, so a downloaded
+// extension can also be loaded by name afterwards
+func extensionPath(ctx context.Context, db *sql.DB, name string) (string, error) {
+	var dir, version, platform string
+	err := db.QueryRowContext(ctx,
+		"SELECT current_setting('extension_directory'), version(), (SELECT platform FROM pragma_platform())",
+	).Scan(&dir, &version, &platform)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up extension directory: %w", err)
+	}
+
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to look up extension directory: %w", err)
+		}
+		dir = filepath.Join(home, ".duckdb", "extensions")
+	}
+	return filepath.Join(dir, version, platform, name+extensionSuffix), nil
+}
+
+// isExtensionObject reports whether name is a stored extension, in any namespace
+func isExtensionObject(name string) bool {
+	return strings.HasPrefix(name, extensionPrefix) || strings.Contains(name, "/"+extensionPrefix)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreExtension(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "dummy"+extensionSuffix)
+	content := bytes.Repeat([]byte("ext"), 1000)
+	if err := os.WriteFile(path, content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreExtension("dummy", path, WithExtensionVersion("v1.2")); err != nil {
+		t.Fatalf("StoreExtension: %v", err)
+	}
+
+	extensions, err := s.ListExtensions()
+	if err != nil {
+		t.Fatalf("ListExtensions: %v", err)
+	}
+	want := ExtensionInfo{Name: "dummy", Version: "v1.2", Size: int64(len(content))}
+	if len(extensions) != 1 || extensions[0] != want {
+		t.Errorf("ListExtensions = %+v, want [%+v]", extensions, want)
+	}
+	// Extensions are not listed as databases
+	entries, err := s.ListDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("ListDatabases = %v, want only the database", entries)
+	}
+
+	db, err := openDuckDB("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec("SET extension_directory = " + quoteLiteral(t.TempDir())); err != nil {
+		t.Fatal(err)
+	}
+
+	// The dummy file is downloaded but cannot be loaded as an extension
+	if err := s.LoadExtension(ctx, db, "dummy"); err == nil {
+		t.Error("LoadExtension loaded a file that is not an extension")
+	}
+	installed, err := extensionPath(ctx, db, "dummy")
+	if err != nil {
+		t.Fatalf("extensionPath: %v", err)
+	}
+	got, err := os.ReadFile(installed)
+	if err != nil {
+		t.Fatalf("extension not downloaded: %v", err)
+	}
+	if !bytes.Equal(got, content) {
+		t.Error("downloaded extension does not match the stored file")
+	}
+}
+
+func TestStoreExtensionRejected(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	for _, name := range []string{"", "nested/name", extensionSuffix} {
+		if err := s.StoreExtension(name, "unused"); err == nil {
+			t.Errorf("StoreExtension accepted the name %q", name)
+		}
+	}
+	if err := s.StoreExtension("missing", filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("StoreExtension of a missing file succeeded")
+	}
+	if extensions, err := s.ListExtensions(); err != nil || len(extensions) != 0 {
+		t.Errorf("ListExtensions = %v, %v, want none", extensions, err)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// FetchAndQuery runs a query against the stored database and returns every result row as a map
+// from column name to value. Integers are returned as int64 and floats as float64; strings,
+// booleans, times and blobs keep their driver types. The whole result is held in memory, so
+// use QueryRows for large results.
+func (d *DuckDBStorage) FetchAndQuery(ctx context.Context, query string, args ...any) (_ []map[string]any, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	rows, err := d.QueryRows(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return collectRows(rows.Rows)
+}
+
+// collectRows reads all remaining rows into maps of normalized values
+func collectRows(rows *sql.Rows) ([]map[string]any, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	var results []map[string]any
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, fmt.Errorf("failed to scan row %d: %w", len(results), err)
+		}
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			row[column] = normalizeValue(values[i])
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows after %d rows: %w", len(results), err)
+	}
+	return results, nil
+}
+
+// FetchAndQueryTyped runs a query against the database stored by d and converts every result
+// row with scanFn, which must scan the current row only
+func FetchAndQueryTyped[T any](ctx context.Context, d *DuckDBStorage, query string, scanFn func(*sql.Rows) (T, error)) ([]T, error) {
+	rows, err := d.QueryRows(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []T
+	for rows.Next() {
+		result, err := scanFn(rows.Rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row %d: %w", len(results), err)
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read rows after %d rows: %w", len(results), err)
+	}
+	return results, nil
+}
+
+// normalizeValue widens the sized integer and float types returned by the driver
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	default:
+		return value
+	}
+}
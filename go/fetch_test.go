@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		value, want any
+	}{
+		{int8(-1), int64(-1)},
+		{int16(2), int64(2)},
+		{int32(3), int64(3)},
+		{int(4), int64(4)},
+		{uint8(5), int64(5)},
+		{uint16(6), int64(6)},
+		{uint32(7), int64(7)},
+		{float32(0.5), float64(0.5)},
+		{uint64(8), uint64(8)},
+		{"text", "text"},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		if got := normalizeValue(tt.value); got != tt.want {
+			t.Errorf("normalizeValue(%T %v) = %T %v, want %T %v", tt.value, tt.value, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestFetchAndQuery(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+	results, err := s.FetchAndQuery(ctx,
+		"SELECT 1::INTEGER AS i, 2.5::FLOAT AS f, 'x' AS s, created_at AS ts, true AS b, 'ab'::BLOB AS bl, NULL AS n, ? AS p FROM users WHERE id = 1", 7)
+	if err != nil {
+		t.Fatalf("FetchAndQuery: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("FetchAndQuery returned %d rows, want 1", len(results))
+	}
+
+	row := results[0]
+	wantTypes := map[string]reflect.Type{
+		"i":  reflect.TypeOf(int64(0)),
+		"f":  reflect.TypeOf(float64(0)),
+		"s":  reflect.TypeOf(""),
+		"ts": reflect.TypeOf(time.Time{}),
+		"b":  reflect.TypeOf(true),
+		"bl": reflect.TypeOf([]byte(nil)),
+		"p":  reflect.TypeOf(int64(0)),
+	}
+	for column, want := range wantTypes {
+		if got := reflect.TypeOf(row[column]); got != want {
+			t.Errorf("column %s is %v, want %v", column, got, want)
+		}
+	}
+	if value, ok := row["n"]; !ok || value != nil {
+		t.Errorf("column n = %v, %v, want a nil entry", value, ok)
+	}
+	if row["i"] != int64(1) || row["f"] != 2.5 || string(row["bl"].([]byte)) != "ab" || row["p"] != int64(7) {
+		t.Errorf("row = %v", row)
+	}
+}
+
+func TestFetchAndQueryTyped(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	type user struct {
+		id   int
+		name string
+	}
+	users, err := FetchAndQueryTyped(context.Background(), s, "SELECT id, name FROM users ORDER BY id", func(rows *sql.Rows) (user, error) {
+		var u user
+		err := rows.Scan(&u.id, &u.name)
+		return u, err
+	})
+	if err != nil {
+		t.Fatalf("FetchAndQueryTyped: %v", err)
+	}
+	want := []user{{1, "Alice"}, {2, "Bob"}, {3, "Charlie"}}
+	if !reflect.DeepEqual(users, want) {
+		t.Errorf("users = %v, want %v", users, want)
+	}
+
+	if _, err := FetchAndQueryTyped(context.Background(), s, "SELECT * FROM missing", func(rows *sql.Rows) (user, error) {
+		return user{}, nil
+	}); err == nil {
+		t.Error("FetchAndQueryTyped of an invalid query succeeded")
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// HealthStatus is the result of HealthCheck
+type HealthStatus struct {
+	NATSConnected         bool
+	JetStreamAvailable    bool
+	ObjectStoreAccessible bool
+	Latency               time.Duration
+}
+
+// HealthCheck verifies the NATS connection, JetStream and the object store bucket in turn.
+// On failure the status reports the checks that passed and the error names the one that failed.
+func (d *DuckDBStorage) HealthCheck(ctx context.Context) (_ HealthStatus, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return HealthStatus{}, err
+	}
+	defer func() { end(err) }()
+
+	var status HealthStatus
+	start := time.Now()
+	result := func(err error) (HealthStatus, error) {
+		status.Latency = time.Since(start)
+		return status, err
+	}
+
+	if s := d.nc.Status(); s != nats.CONNECTED {
+		return result(fmt.Errorf("nats connection check failed: status %s", s))
+	}
+	status.NATSConnected = true
+
+	if _, err := d.js.AccountInfo(nats.Context(ctx)); err != nil {
+		return result(fmt.Errorf("jetstream check failed: %w", err))
+	}
+	status.JetStreamAvailable = true
+
+	if _, err := d.obs.List(nats.Context(ctx)); err != nil && !errors.Is(err, nats.ErrNoObjectsFound) {
+		return result(fmt.Errorf("object store check failed: %w", err))
+	}
+	status.ObjectStoreAccessible = true
+
+	return result(nil)
+}
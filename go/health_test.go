@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestHealthCheck(t *testing.T) {
+	srv := runTestServer(t, nil)
+	nc := connectTestServer(t, srv, nats.ReconnectWait(time.Second))
+	s := newTestStorage(t, nc)
+
+	status, err := s.HealthCheck(context.Background())
+	if err != nil {
+		t.Fatalf("HealthCheck: %v", err)
+	}
+	if !status.NATSConnected || !status.JetStreamAvailable || !status.ObjectStoreAccessible || status.Latency <= 0 {
+		t.Errorf("status = %+v, want every check passed", status)
+	}
+
+	disconnected := make(chan struct{})
+	nc.SetDisconnectErrHandler(func(*nats.Conn, error) { close(disconnected) })
+	srv.Shutdown()
+	select {
+	case <-disconnected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection not disconnected")
+	}
+
+	status, err = s.HealthCheck(context.Background())
+	if err == nil {
+		t.Fatal("HealthCheck passed without a server")
+	}
+	if status.NATSConnected || status.JetStreamAvailable || status.ObjectStoreAccessible {
+		t.Errorf("status = %+v, want every check failed", status)
+	}
+}
+
+func TestHealthCheckClosed(t *testing.T) {
+	nc := startTestServer(t)
+	s := newTestStorage(t, nc)
+	nc.Close()
+
+	status, err := s.HealthCheck(context.Background())
+	if err == nil || status.NATSConnected {
+		t.Fatalf("HealthCheck on a closed connection = %+v, %v", status, err)
+	}
+}
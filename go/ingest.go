@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	defaultIngestBufferSize = 1000
+	ingestFetchBatch        = 100
+	ingestFetchWait         = time.Second
+)
+
+// IngestStats reports the progress of StartMessageIngester
+type IngestStats struct {
+	BytesConsumed int64
+	RowsInserted  int64
+	FlushCount    int64
+}
+
+type ingestCounters struct {
+	bytes   atomic.Int64
+	rows    atomic.Int64
+	flushes atomic.Int64
+}
+
+// IngestStats returns the counters of the message ingester
+func (d *DuckDBStorage) IngestStats() IngestStats {
+	return IngestStats{
+		BytesConsumed: d.ingest.bytes.Load(),
+		RowsInserted:  d.ingest.rows.Load(),
+		FlushCount:    d.ingest.flushes.Load(),
+	}
+}
+
+// StartMessageIngester consumes JSON messages from a JetStream pull consumer and inserts them
+// into tableName. The database is stored every flushInterval or once IngestBufferSize rows are
+// pending, and messages are acknowledged only after the flush that contains them. The method
+// blocks until ctx is cancelled or the storage is closed, then performs a final flush.
+func (d *DuckDBStorage) StartMessageIngester(ctx context.Context, subject, streamName, consumerName, tableName string, flushInterval time.Duration) (err error) {
+	op := d.logOperation("ingest", "subject", subject, "stream", streamName, "table", tableName)
+	defer func() { op.done(err) }()
+	ctx, cancel := d.lifetime(ctx)
+	defer cancel()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if flushInterval <= 0 {
+		return fmt.Errorf("invalid flush interval: %v", flushInterval)
+	}
+
+	sub, err := d.js.PullSubscribe(subject, consumerName,
+		nats.BindStream(streamName),
+		nats.AckWait(2*flushInterval),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create pull consumer: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	// Continue from the stored database if there is one
+	dbPath, err := tempPath("duckdb-nats-ingest-*.db")
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(dbPath)
+
+	if err := d.retrieve(ctx, dbPath); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+		return err
+	}
+
+	db, err := openDuckDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	var pending []*nats.Msg
+	var pendingRows int
+
+	flush := func() error {
+		if len(pending) == 0 {
+			return nil
+		}
+		// Merge the WAL so the file on disk holds every inserted row
+		if _, err := conn.ExecContext(context.Background(), "CHECKPOINT"); err != nil {
+			return fmt.Errorf("failed to checkpoint database: %w", err)
+		}
+		// Stored directly, StoreDuckDB refuses to start once the final flush runs during Close
+		err := d.storeFileLocked(context.Background(), dbPath, d.opts.Deduplicate)
+		if err != nil && !errors.Is(err, ErrUnchanged) {
+			return err
+		}
+		for _, msg := range pending {
+			msg.Ack()
+		}
+		pending, pendingRows = nil, 0
+		d.ingest.flushes.Add(1)
+		return nil
+	}
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return flush()
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return err
+			}
+			continue
+		default:
+		}
+
+		fetchCtx, cancel := context.WithTimeout(ctx, min(ingestFetchWait, flushInterval))
+		msgs, err := sub.Fetch(ingestFetchBatch, nats.Context(fetchCtx))
+		cancel()
+		if err != nil && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, nats.ErrTimeout) {
+			if ctx.Err() != nil {
+				return flush()
+			}
+			return fmt.Errorf("failed to fetch messages: %w", err)
+		}
+		if len(msgs) == 0 {
+			continue
+		}
+
+		rows, err := d.insertMessages(ctx, conn, tableName, msgs)
+		if err != nil {
+			return err
+		}
+		pending = append(pending, msgs...)
+		pendingRows += rows
+
+		if pendingRows >= d.opts.IngestBufferSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// insertMessages loads the JSON payloads of msgs into tableName, terminating messages that are
+// not valid JSON so they are not redelivered
+func (d *DuckDBStorage) insertMessages(ctx context.Context, conn *sql.Conn, tableName string, msgs []*nats.Msg) (int, error) {
+	for _, msg := range msgs {
+		d.ingest.bytes.Add(int64(len(msg.Data)))
+	}
+	rows, inserted, err := loadMessages(ctx, conn, tableName, msgs)
+	if err != nil {
+		return 0, err
+	}
+	d.ingest.rows.Add(inserted)
+
+	return rows, nil
+}
+
+// loadMessages loads the JSON payloads of msgs into tableName, terminating messages that are
+// not valid JSON so they are not redelivered. It returns the number of valid messages and of
+// rows inserted.
+func loadMessages(ctx context.Context, conn *sql.Conn, tableName string, msgs []*nats.Msg) (int, int64, error) {
+	var batch bytes.Buffer
+	rows := 0
+	for _, msg := range msgs {
+		if err := json.Compact(&batch, msg.Data); err != nil {
+			msg.Term()
+			continue
+		}
+		batch.WriteByte('\n')
+		rows++
+	}
+	if rows == 0 {
+		return 0, 0, nil
+	}
+
+	batchPath, err := tempPath("duckdb-nats-ingest-*.ndjson")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer removeTemp(batchPath)
+
+	if err := os.WriteFile(batchPath, batch.Bytes(), 0600); err != nil {
+		return 0, 0, fmt.Errorf("failed to write message batch: %w", err)
+	}
+
+	inserted, err := loadIntoTable(ctx, conn, tableName,
+		"read_json_auto("+quoteLiteral(batchPath)+", format='newline_delimited')")
+	if err != nil {
+		return 0, 0, err
+	}
+	return rows, inserted, nil
+}
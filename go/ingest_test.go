@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// waitIngested waits until the ingester of s reports rows inserted rows and flushes flushes
+func waitIngested(t *testing.T, s *DuckDBStorage, rows, flushes int64) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		stats := s.IngestStats()
+		if stats.RowsInserted == rows && stats.FlushCount == flushes {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("IngestStats() = %+v, want %d rows and %d flushes", stats, rows, flushes)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// storedTestEvents returns the number of events in the database stored in the bucket of nc
+func storedTestEvents(t *testing.T, nc *nats.Conn) int64 {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "events.db")
+	if err := newTestStorage(t, nc).RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB: %v", err)
+	}
+	return queryTestInt(t, out, "SELECT count(*) FROM events")
+}
+
+func TestMessageIngester(t *testing.T) {
+	nc := startTestServer(t)
+	s := newTestStorage(t, nc, WithIngestBufferSize(10))
+	if _, err := s.js.AddStream(&nats.StreamConfig{Name: "EVENTS", Subjects: []string{"events.>"}}); err != nil {
+		t.Fatalf("failed to create stream: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.StartMessageIngester(context.Background(), "events.>", "EVENTS", "ingester", "events", time.Minute)
+	}()
+
+	// A full buffer is flushed right away
+	publishTestEvents(t, s.js, "events.created", 0, 10)
+	waitIngested(t, s, 10, 1)
+	if n := storedTestEvents(t, nc); n != 10 {
+		t.Fatalf("stored database has %d events after the buffer flush, want 10", n)
+	}
+
+	// Rows below the buffer size wait for the next flush
+	publishTestEvents(t, s.js, "events.created", 10, 5)
+	waitIngested(t, s, 15, 1)
+	if n := storedTestEvents(t, nc); n != 10 {
+		t.Errorf("stored database has %d events before the next flush, want 10", n)
+	}
+
+	// Close flushes the remaining rows
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("StartMessageIngester: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("StartMessageIngester did not return after Close")
+	}
+	if stats := s.IngestStats(); stats.FlushCount != 2 {
+		t.Errorf("FlushCount = %d after Close, want 2", stats.FlushCount)
+	}
+	if n := storedTestEvents(t, nc); n != 15 {
+		t.Errorf("stored database has %d events after Close, want 15", n)
+	}
+}
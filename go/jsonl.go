@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/nats-io/nats.go"
+)
+
+// maxJSONLineSize is the longest line ImportJSONLinesFromNATS accepts
+const maxJSONLineSize = 64 << 20
+
+// ImportJSONLinesFromNATS loads a stored newline-delimited JSON object into a table of the
+// local database and stores the updated database. Rows are appended if the table already
+// exists. Lines that are not valid JSON are skipped and counted in SkippedRows.
+func (d *DuckDBStorage) ImportJSONLinesFromNATS(ctx context.Context, jsonlObjectName, targetDBFilePath, tableName string) (result ImportResult, err error) {
+	op := d.logOperation("import_jsonl", "object", jsonlObjectName, "table", tableName)
+	defer func() { op.done(err, "rows", result.RowsInserted, "skipped", result.SkippedRows) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return result, err
+	}
+	defer func() { end(err) }()
+
+	downloadPath, err := tempPath("duckdb-nats-*.ndjson")
+	if err != nil {
+		return result, err
+	}
+	defer removeTemp(downloadPath)
+
+	if err := d.getFile(ctx, d.objectKey(jsonlObjectName), downloadPath); err != nil {
+		return result, err
+	}
+
+	// DuckDB reads malformed lines as rows of NULLs, so they are dropped before loading
+	jsonlPath, err := tempPath("duckdb-nats-*.ndjson")
+	if err != nil {
+		return result, err
+	}
+	defer removeTemp(jsonlPath)
+
+	result.SkippedRows, err = filterJSONLines(downloadPath, jsonlPath)
+	if err != nil {
+		return result, err
+	}
+
+	db, err := openDuckDB(targetDBFilePath)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	result.RowsInserted, err = loadIntoTable(ctx, conn, tableName, "read_ndjson_auto("+quoteLiteral(jsonlPath)+")")
+	if err != nil {
+		return result, err
+	}
+
+	conn.Close()
+	if err := db.Close(); err != nil {
+		return result, fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return result, d.StoreDuckDB(targetDBFilePath)
+}
+
+// ExportQueryToJSONLines runs a query against the stored database and stores the result as a
+// newline-delimited JSON object named outputObjectName in the same bucket. Structs, maps and
+// lists are written as nested JSON values.
+func (d *DuckDBStorage) ExportQueryToJSONLines(ctx context.Context, query, outputObjectName string) (stats ExportStats, err error) {
+	op := d.logOperation("export_jsonl", "query", query, "object", outputObjectName)
+	defer func() { op.done(err, "rows", stats.RowCount, "bytes", stats.BytesWritten) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return stats, err
+	}
+	defer func() { end(err) }()
+
+	dbPath, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return stats, err
+	}
+	defer removeTempDatabase(dbPath)
+
+	if err := d.filterDatabase(ctx, dbPath, query); err != nil {
+		return stats, err
+	}
+	db, err := openDuckDB(dbPath)
+	if err != nil {
+		return stats, err
+	}
+	defer db.Close()
+
+	jsonlPath, err := tempPath("duckdb-nats-*.ndjson")
+	if err != nil {
+		return stats, err
+	}
+	defer removeTemp(jsonlPath)
+
+	result, err := db.ExecContext(ctx, fmt.Sprintf("COPY (%s) TO %s (FORMAT JSON)", query, quoteLiteral(jsonlPath)))
+	if err != nil {
+		return stats, fmt.Errorf("failed to export query: %w", err)
+	}
+	if stats.RowCount, err = result.RowsAffected(); err != nil {
+		return stats, fmt.Errorf("failed to read exported row count: %w", err)
+	}
+
+	info, err := d.putFile(ctx, d.objectKey(outputObjectName), jsonlPath, "JSON lines export of query results", nats.Header{
+		"Content-Type": []string{"application/x-ndjson"},
+	})
+	if err != nil {
+		return stats, err
+	}
+	stats.BytesWritten = int64(info.Size)
+	return stats, nil
+}
+
+// filterJSONLines copies the lines of src that are valid JSON to dst, omitting blank lines, and
+// returns the number of invalid lines
+func filterJSONLines(src, dst string) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	defer out.Close()
+
+	var invalid int64
+	w := bufio.NewWriter(out)
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(nil, maxJSONLineSize)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if !json.Valid(line) {
+			invalid++
+			continue
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		return invalid, fmt.Errorf("failed to read %s: %w", src, err)
+	}
+	if err := w.Flush(); err != nil {
+		return invalid, fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	if err := out.Close(); err != nil {
+		return invalid, fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return invalid, nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFilterJSONLines(t *testing.T) {
+	dir := t.TempDir()
+	src, dst := filepath.Join(dir, "src.ndjson"), filepath.Join(dir, "dst.ndjson")
+	input := "{\"id\": 1}\n\n  {\"id\": 2}  \n{not json\n[1, 2]\n"
+	if err := os.WriteFile(src, []byte(input), 0600); err != nil {
+		t.Fatal(err)
+	}
+	invalid, err := filterJSONLines(src, dst)
+	if err != nil {
+		t.Fatalf("filterJSONLines: %v", err)
+	}
+	if invalid != 1 {
+		t.Errorf("filterJSONLines counted %d invalid lines, want 1", invalid)
+	}
+	got, err := os.ReadFile(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\"id\": 1}\n{\"id\": 2}\n[1, 2]\n"; string(got) != want {
+		t.Errorf("filtered lines = %q, want %q", got, want)
+	}
+}
+
+func TestJSONLinesRoundTrip(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "nested.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE a (id INTEGER, info STRUCT(name VARCHAR, tags VARCHAR[]), nums INTEGER[])",
+		"INSERT INTO a VALUES (1, {'name': 'x', 'tags': ['a', 'b']}, [1, 2]), (2, NULL, []), (3, {'name': 'y', 'tags': []}, NULL)",
+	)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.ExportQueryToJSONLines(ctx, "SELECT * FROM a ORDER BY id", "a.jsonl")
+	if err != nil {
+		t.Fatalf("ExportQueryToJSONLines: %v", err)
+	}
+	if stats.RowCount != 3 || stats.BytesWritten == 0 {
+		t.Errorf("stats = %+v, want 3 rows", stats)
+	}
+
+	target := newTestStorage(t, s.nc, WithDBName("imported.db"))
+	imported := filepath.Join(t.TempDir(), "imported.db")
+	result, err := target.ImportJSONLinesFromNATS(ctx, "a.jsonl", imported, "a")
+	if err != nil {
+		t.Fatalf("ImportJSONLinesFromNATS: %v", err)
+	}
+	if result.RowsInserted != 3 || result.SkippedRows != 0 {
+		t.Errorf("result = %+v, want 3 rows inserted", result)
+	}
+
+	rowQuery := "SELECT to_json(a)::VARCHAR FROM a ORDER BY id"
+	want := queryTestStrings(t, path, rowQuery)
+	if got := queryTestStrings(t, imported, rowQuery); !slices.Equal(got, want) {
+		t.Errorf("imported rows = %q, want %q", got, want)
+	}
+	var tag string
+	if err := target.QueryRow(ctx, "SELECT info.tags[2] FROM a WHERE id = 1").Scan(&tag); err != nil || tag != "b" {
+		t.Errorf("nested tag of the stored import = %q, %v, want b", tag, err)
+	}
+
+	// Appending skips the malformed line
+	data, err := s.obs.GetBytes("a.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.obs.PutBytes("bad.jsonl", append(bytes.Clone(data), "{not json\n"...)); err != nil {
+		t.Fatal(err)
+	}
+	result, err = target.ImportJSONLinesFromNATS(ctx, "bad.jsonl", imported, "a")
+	if err != nil {
+		t.Fatalf("ImportJSONLinesFromNATS with a malformed line: %v", err)
+	}
+	if result.RowsInserted != 3 || result.SkippedRows != 1 {
+		t.Errorf("result = %+v, want 3 rows inserted and 1 skipped", result)
+	}
+	if n := queryTestInt(t, imported, "SELECT count(*) FROM a"); n != 6 {
+		t.Errorf("table holds %d rows after appending, want 6", n)
+	}
+}
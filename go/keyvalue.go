@@ -0,0 +1,25 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// keyValue binds to the named KV bucket, creating it if it does not exist yet
+func (d *DuckDBStorage) keyValue(bucket string) (nats.KeyValue, error) {
+	return keyValueBucket(d.js, bucket)
+}
+
+// keyValueBucket binds to the named KV bucket of js, creating it if it does not exist yet
+func keyValueBucket(js nats.JetStreamContext, bucket string) (nats.KeyValue, error) {
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{Bucket: bucket})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create/get key-value bucket %s: %w", bucket, err)
+	}
+	return kv, nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// QueryJoinNATSKV runs a query against the stored database with the current entries of a KV
+// bucket available as the table virtualTableAlias(key VARCHAR, value JSON), so results can be
+// joined with live lookup data. Values that are not JSON are stored as JSON strings. The KV
+// table is a temporary in-memory table and the stored database is attached read-only.
+func (d *DuckDBStorage) QueryJoinNATSKV(ctx context.Context, query, kvBucket, virtualTableAlias string, args ...any) (results []map[string]any, err error) {
+	op := d.logOperation("query_join_kv", "query", query, "kv_bucket", kvBucket, "alias", virtualTableAlias)
+	defer func() { op.done(err, "rows", len(results)) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return results, err
+	}
+	defer func() { end(err) }()
+
+	kv, err := d.keyValue(kvBucket)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := kv.Keys(nats.Context(ctx))
+	if err != nil && !errors.Is(err, nats.ErrNoKeysFound) {
+		return nil, fmt.Errorf("failed to list keys of %s: %w", kvBucket, err)
+	}
+
+	dbPath, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer removeTempDatabase(dbPath)
+	if err := d.filterDatabase(ctx, dbPath, query); err != nil {
+		return nil, err
+	}
+
+	db, err := openDuckDB("")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// Temporary tables are per connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("ATTACH %s AS stored (READ_ONLY); USE stored", quoteLiteral(dbPath))); err != nil {
+		return nil, fmt.Errorf("failed to attach database: %w", err)
+	}
+	table := quoteIdent(virtualTableAlias)
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TEMP TABLE %s (key VARCHAR, value JSON)", table)); err != nil {
+		return nil, fmt.Errorf("failed to create table %s: %w", virtualTableAlias, err)
+	}
+
+	insert, err := conn.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s VALUES (?, ?)", table))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare insert: %w", err)
+	}
+	defer insert.Close()
+	for _, key := range keys {
+		entry, err := kv.Get(key)
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			// Deleted since the keys were listed
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get key %s: %w", key, err)
+		}
+
+		value := entry.Value()
+		if !json.Valid(value) {
+			value, _ = json.Marshal(string(value))
+		}
+		if _, err := insert.ExecContext(ctx, key, string(value)); err != nil {
+			return nil, fmt.Errorf("failed to insert key %s: %w", key, err)
+		}
+	}
+
+	rows, err := conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	defer rows.Close()
+
+	return collectRows(rows)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestQueryJoinNATSKV(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+	kv, err := s.keyValue("emails")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, value := range map[string]string{
+		"1": `{"email": "alice@example.com"}`,
+		// Plain values are stored as JSON strings
+		"2": "bob@example.com",
+		"9": `{"email": "nobody@example.com"}`,
+	} {
+		if _, err := kv.PutString(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rows, err := s.QueryJoinNATSKV(ctx, `
+		SELECT u.name, coalesce(e.value->>'email', e.value->>'$') AS email
+		FROM users u JOIN emails e ON e.key = u.id::VARCHAR
+		WHERE u.id > ?
+		ORDER BY u.id`, "emails", "emails", 0)
+	if err != nil {
+		t.Fatalf("QueryJoinNATSKV: %v", err)
+	}
+	want := []map[string]any{
+		{"name": "Alice", "email": "alice@example.com"},
+		{"name": "Bob", "email": "bob@example.com"},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("rows = %v, want %v", rows, want)
+	}
+	for i := range want {
+		if rows[i]["name"] != want[i]["name"] || rows[i]["email"] != want[i]["email"] {
+			t.Errorf("row %d = %v, want %v", i, rows[i], want[i])
+		}
+	}
+
+	// The stored database is attached read-only
+	if _, err := s.QueryJoinNATSKV(ctx, "DELETE FROM users", "emails", "emails"); err == nil {
+		t.Error("QueryJoinNATSKV modified the stored database")
+	}
+}
+
+func TestQueryJoinNATSKVEmpty(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	rows, err := s.QueryJoinNATSKV(context.Background(), "SELECT count(*) AS n FROM lookup", "empty", "lookup")
+	if err != nil {
+		t.Fatalf("QueryJoinNATSKV: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["n"] != int64(0) {
+		t.Errorf("rows = %v, want a count of 0", rows)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// latestCache is the local copy of the database kept by RunQueryOnLatest
+type latestCache struct {
+	mu       sync.Mutex
+	revision uint64
+	path     string
+}
+
+// RunQueryOnLatest runs a query against the latest stored revision of the database and returns
+// the rows like FetchAndQuery. The database is downloaded only when its revision changed since
+// the previous call, otherwise the cached copy is queried. Queries against the cache run one
+// at a time.
+func (d *DuckDBStorage) RunQueryOnLatest(ctx context.Context, query string, args ...any) (results []map[string]any, err error) {
+	op := d.logOperation("query_latest", "query", query)
+	defer func() { op.done(err, "rows", len(results)) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	revision, err := d.CurrentRevision()
+	if err != nil {
+		return nil, err
+	}
+	if revision == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrObjectNotFound, d.dbName)
+	}
+
+	cache := &d.latest
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	if cache.path == "" || cache.revision != revision {
+		path, err := d.retrieveTemp(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if cache.path == "" {
+			d.trackResource(cache)
+		}
+		cache.release()
+		cache.path, cache.revision = path, revision
+	}
+
+	db, release, err := d.openFiltered(ctx, cache.path, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+	defer rows.Close()
+
+	return collectRows(rows)
+}
+
+// InvalidateCache removes the copy cached by RunQueryOnLatest, so the next call downloads the
+// database again
+func (d *DuckDBStorage) InvalidateCache() error {
+	d.latest.mu.Lock()
+	defer d.latest.mu.Unlock()
+
+	d.untrackResource(&d.latest)
+	d.latest.release()
+	return nil
+}
+
+// CachedRevision returns the revision cached by RunQueryOnLatest, or 0 if nothing is cached
+func (d *DuckDBStorage) CachedRevision() uint64 {
+	d.latest.mu.Lock()
+	defer d.latest.mu.Unlock()
+	return d.latest.revision
+}
+
+// Close removes the cached copy, it is called by DuckDBStorage.Close
+func (c *latestCache) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.release()
+	return nil
+}
+
+// release removes the cached copy, the caller must hold the lock
+func (c *latestCache) release() {
+	if c.path == "" {
+		return
+	}
+	removeTempDatabase(c.path)
+	c.path, c.revision = "", 0
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+// countingObjectStore counts the downloads made through it
+type countingObjectStore struct {
+	nats.ObjectStore
+	gets *atomic.Int32
+}
+
+func (o countingObjectStore) Get(name string, opts ...nats.GetObjectOpt) (nats.ObjectResult, error) {
+	o.gets.Add(1)
+	return o.ObjectStore.Get(name, opts...)
+}
+
+func TestRunQueryOnLatest(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	var gets atomic.Int32
+	s.obs = countingObjectStore{s.obs, &gets}
+	ctx := context.Background()
+
+	count := func() any {
+		t.Helper()
+		rows, err := s.RunQueryOnLatest(ctx, "SELECT count(*) AS n FROM users")
+		if err != nil {
+			t.Fatalf("RunQueryOnLatest: %v", err)
+		}
+		return rows[0]["n"]
+	}
+	if n := count(); n != int64(3) {
+		t.Fatalf("count = %v, want 3", n)
+	}
+	first := s.CachedRevision()
+	if current, err := s.CurrentRevision(); err != nil || first != current {
+		t.Fatalf("cached revision = %d, want %d", first, current)
+	}
+	count()
+
+	execTestDatabase(t, path, "INSERT INTO users VALUES (4, 'Dave', now())")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	if n := count(); n != int64(4) {
+		t.Fatalf("count after storing a new revision = %v, want 4", n)
+	}
+	count()
+	if n := gets.Load(); n != 2 {
+		t.Errorf("%d downloads for two revisions, want 2", n)
+	}
+	if s.CachedRevision() == first {
+		t.Error("cached revision not updated")
+	}
+
+	if err := s.InvalidateCache(); err != nil {
+		t.Fatalf("InvalidateCache: %v", err)
+	}
+	if rev := s.CachedRevision(); rev != 0 {
+		t.Errorf("cached revision after InvalidateCache = %d, want 0", rev)
+	}
+	count()
+	if n := gets.Load(); n != 3 {
+		t.Errorf("%d downloads after InvalidateCache, want 3", n)
+	}
+
+	cached := s.latest.path
+	if err := s.Close(ctx); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := os.Stat(cached); !os.IsNotExist(err) {
+		t.Errorf("cached copy left at %s after Close", cached)
+	}
+}
+
+func TestRunQueryOnLatestMissing(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	if _, err := s.RunQueryOnLatest(context.Background(), "SELECT 1"); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("RunQueryOnLatest without a database: got %v, want ErrObjectNotFound", err)
+	}
+}
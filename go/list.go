@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// timestampHeader records when a database object was stored
+const timestampHeader = "X-Timestamp"
+
+// databaseContentType is the Content-Type of the database objects this package stores
+const databaseContentType = "application/x-duckdb"
+
+// internalObjectPattern matches the names of the chunk, revision, WAL, version index and staging
+// objects stored next to a database
+var internalObjectPattern = regexp.MustCompile(`.\.(part\.([0-9A-Za-z]+\.)?[0-9]{4,}|revision\.[0-9]+|wal\.[0-9]+|versions|__staging)$`)
+
+// DatabaseEntry describes a logical database stored in the bucket
+type DatabaseEntry struct {
+	Name      string
+	Size      int64
+	ModTime   time.Time
+	Timestamp string
+}
+
+// ListOptions filters the results of ListDatabases
+type ListOptions struct {
+	// Prefix restricts results to names starting with the given string
+	Prefix string
+	// MinSize restricts results to objects of at least this many bytes
+	MinSize int64
+}
+
+// ListDatabases enumerates the databases stored in the bucket, skipping internal and soft-deleted
+// objects. A chunked database is listed under its name with the size recorded in its manifest.
+func (d *DuckDBStorage) ListDatabases(opts ...ListOptions) (entries []DatabaseEntry, err error) {
+	op := d.logOperation(opList)
+	defer func() {
+		d.metrics.countError(opList, err)
+		op.done(err, "count", len(entries))
+	}()
+	end, err := d.beginOperation()
+	if err != nil {
+		return entries, err
+	}
+	defer func() { end(err) }()
+
+	var filter ListOptions
+	if len(opts) > 0 {
+		filter = opts[0]
+	}
+
+	var objects []*nats.ObjectInfo
+	_, err = d.withRetry(context.Background(), func() error {
+		objects, err = d.obs.List()
+		return err
+	})
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	// A database stored both whole and in chunks is listed once, as its latest copy
+	listed := make(map[string]int)
+	for _, info := range objects {
+		if info.Headers.Get(deletedAtHeader) != "" {
+			continue
+		}
+		objectName, size := info.Name, int64(info.Size)
+		if database, ok := manifestDatabase(info); ok {
+			manifest, err := d.getManifestOf(database)
+			if err != nil {
+				return nil, err
+			}
+			objectName, size = database, manifest.TotalSize
+		} else if !isDatabaseObject(info) {
+			continue
+		}
+		name, ok := d.logicalName(objectName)
+		if !ok || !strings.HasPrefix(name, filter.Prefix) || size < filter.MinSize {
+			continue
+		}
+
+		entry := DatabaseEntry{
+			Name:      name,
+			Size:      size,
+			ModTime:   info.ModTime,
+			Timestamp: objectTimestamp(info),
+		}
+		if i, ok := listed[name]; ok {
+			if entry.ModTime.After(entries[i].ModTime) {
+				entries[i] = entry
+			}
+			continue
+		}
+		listed[name] = len(entries)
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// isDatabaseObject reports whether info is a logical database rather than an object stored
+// next to one, such as a chunk, manifest, schema, version, WAL, migration, extension, snapshot
+// or export. Objects without a Content-Type are treated as databases stored by other tools
+// unless their name is one this package generates.
+func isDatabaseObject(info *nats.ObjectInfo) bool {
+	if contentType := info.Headers.Get("Content-Type"); contentType != "" && contentType != databaseContentType {
+		return false
+	}
+	return info.Headers.Get(versionHeader) == "" && !isInternalObject(info.Name)
+}
+
+// isInternalObject reports whether name is one of the names this package generates for chunk,
+// staging, revision, WAL, version index, migration, extension or snapshot objects
+func isInternalObject(name string) bool {
+	return isMigrationObject(name) ||
+		isExtensionObject(name) ||
+		isLogicalSnapshotObject(name) ||
+		internalObjectPattern.MatchString(name)
+}
+
+// objectTimestamp returns the store timestamp header, accepting the legacy header name
+func objectTimestamp(info *nats.ObjectInfo) string {
+	if ts := info.Headers.Get(timestampHeader); ts != "" {
+		return ts
+	}
+	return info.Headers.Get("Timestamp")
+}
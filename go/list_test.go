@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestIsInternalObject(t *testing.T) {
+	tests := []struct {
+		name     string
+		internal bool
+	}{
+		{"duckdb.db", false},
+		{"sales.db", false},
+		{"user@example.com", false},
+		{"x.schema", false},
+		{"part.0001", false},
+		{"sales.db.part.0001", true},
+		{"sales.db.part.12345", true},
+		{"sales.db.part.S6lEsScnWGWbVxLeHwvBtc.0001", true},
+		{"sales.db.part.001", false},
+		{"sales.db.revision.3", true},
+		{"sales.db.wal.42", true},
+		{"sales.db.versions", true},
+		{"sales.db.__staging", true},
+		{"migrations/001.sql", true},
+		{"tenant/migrations/001.sql", true},
+		{"extensions/json.duckdb_extension", true},
+		{"snapshots/nightly.zip", true},
+		{"tenant/snapshots/nightly.zip", true},
+		{"tenant/sales.db", false},
+	}
+	for _, tt := range tests {
+		if got := isInternalObject(tt.name); got != tt.internal {
+			t.Errorf("isInternalObject(%q) = %v, want %v", tt.name, got, tt.internal)
+		}
+	}
+}
+
+func TestListDatabases(t *testing.T) {
+	nc := startTestServer(t)
+	src := filepath.Join(t.TempDir(), "src.db")
+	if err := os.WriteFile(src, make([]byte, 1024), 0600); err != nil {
+		t.Fatal(err)
+	}
+	large := filepath.Join(t.TempDir(), "large.db")
+	if err := os.WriteFile(large, make([]byte, 4096), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, path := range map[string]string{"alpha.db": src, "beta.db": large, "gamma.db": src} {
+		s := newTestStorage(t, nc, WithBucket("LIST"), WithDBName(name))
+		if err := s.StoreDuckDB(path); err != nil {
+			t.Fatalf("StoreDuckDB(%s): %v", name, err)
+		}
+	}
+	// A chunked database leaves a manifest and parts that must not be listed separately
+	s := newTestStorage(t, nc, WithBucket("LIST"), WithDBName("alpha.db"))
+	if err := s.StoreDuckDBChunked(src, 256); err != nil {
+		t.Fatalf("StoreDuckDBChunked: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts []ListOptions
+		want []string
+	}{
+		{"all", nil, []string{"alpha.db", "beta.db", "gamma.db"}},
+		{"prefix", []ListOptions{{Prefix: "b"}}, []string{"beta.db"}},
+		{"min size", []ListOptions{{MinSize: 2048}}, []string{"beta.db"}},
+		{"no match", []ListOptions{{Prefix: "delta"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := s.ListDatabases(tt.opts...)
+			if err != nil {
+				t.Fatalf("ListDatabases: %v", err)
+			}
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.Name)
+			}
+			slices.Sort(names)
+			if !slices.Equal(names, tt.want) {
+				t.Errorf("ListDatabases = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestListDatabasesChunked(t *testing.T) {
+	nc := startTestServer(t)
+	path := filepath.Join(t.TempDir(), "test.db")
+	if err := os.WriteFile(path, make([]byte, 4096), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStorage(t, nc, WithBucket("CHUNKED"), WithDBName("chunked.db"), WithChunkSize(1000))
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	whole := newTestStorage(t, nc, WithBucket("CHUNKED"), WithDBName("whole.db"))
+	if err := whole.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+
+	entries, err := s.ListDatabases()
+	if err != nil {
+		t.Fatalf("ListDatabases: %v", err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+		if entry.Size != 4096 {
+			t.Errorf("%s listed with %d bytes, want 4096", entry.Name, entry.Size)
+		}
+	}
+	slices.Sort(names)
+	if want := []string{"chunked.db", "whole.db"}; !slices.Equal(names, want) {
+		t.Errorf("ListDatabases = %v, want %v", names, want)
+	}
+
+	entries, err = s.ListDatabases(ListOptions{MinSize: 5000})
+	if err != nil {
+		t.Fatalf("ListDatabases: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("ListDatabases with a minimum size above the manifest total = %v", entries)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// RetrieveAndDiff compares the stored database with the database file at localPath, the stored
+// one being A in the returned diff. The stored database is retrieved to a temp file and
+// neither file is modified.
+func (d *DuckDBStorage) RetrieveAndDiff(ctx context.Context, localPath string) (diff DatabaseDiff, err error) {
+	op := d.logOperation("retrieve_diff", "path", localPath)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return diff, err
+	}
+	defer func() { end(err) }()
+
+	if _, err := os.Stat(localPath); err != nil {
+		return diff, fmt.Errorf("failed to stat local database: %w", err)
+	}
+
+	path, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return diff, err
+	}
+	defer removeTempDatabase(path)
+
+	return diffFiles(ctx, path, localPath)
+}
+
+// SyncFromNATSIfNewer retrieves the stored database to localPath if it was stored after the
+// local file was last modified or the file does not exist, and reports whether it did. A local
+// file changed after the last store is left alone. The retrieved file gets the modification
+// time of the stored database.
+func (d *DuckDBStorage) SyncFromNATSIfNewer(ctx context.Context, localPath string) (synced bool, err error) {
+	op := d.logOperation("sync_if_newer", "path", localPath)
+	defer func() { op.done(err, "synced", synced) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return synced, err
+	}
+	defer func() { end(err) }()
+
+	name := d.dbName
+	if d.opts.ChunkSize > 0 {
+		name = d.manifestName()
+	}
+	info, err := d.obs.GetInfo(name, nats.Context(ctx))
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return false, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get %s: %w", name, err)
+	}
+
+	local, err := os.Stat(localPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("failed to stat local database: %w", err)
+	}
+	if err == nil && !info.ModTime.After(local.ModTime()) {
+		return false, nil
+	}
+
+	if err := d.RetrieveDuckDBContext(ctx, localPath); err != nil {
+		return false, err
+	}
+	// Stamp the copy with the store time, as file times come from a coarse clock that can make a
+	// file written right after the store look older than it
+	if err := os.Chtimes(localPath, time.Now(), info.ModTime); err != nil {
+		return true, fmt.Errorf("failed to set modification time: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRetrieveAndDiff(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx := context.Background()
+
+	t.Run("same content", func(t *testing.T) {
+		diff, err := s.RetrieveAndDiff(ctx, path)
+		if err != nil {
+			t.Fatalf("RetrieveAndDiff: %v", err)
+		}
+		if len(diff.TablesOnlyInA) != 0 || len(diff.TablesOnlyInB) != 0 {
+			t.Errorf("tables only in one database: %v, %v", diff.TablesOnlyInA, diff.TablesOnlyInB)
+		}
+		if td, ok := diff.TableDiffs["users"]; !ok || !td.Identical() {
+			t.Errorf("users diff = %+v, %v, want identical", td, ok)
+		}
+	})
+
+	t.Run("different content", func(t *testing.T) {
+		execTestDatabase(t, path,
+			"INSERT INTO users VALUES (10, 'Dave', now())",
+			"CREATE TABLE extra (x INTEGER)",
+		)
+		before, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff, err := s.RetrieveAndDiff(ctx, path)
+		if err != nil {
+			t.Fatalf("RetrieveAndDiff: %v", err)
+		}
+		if want := (TableDiff{RowsOnlyInB: 1}); diff.TableDiffs["users"] != want {
+			t.Errorf("users diff = %+v, want %+v", diff.TableDiffs["users"], want)
+		}
+		if !slices.Equal(diff.TablesOnlyInB, []string{"extra"}) || len(diff.TablesOnlyInA) != 0 {
+			t.Errorf("tables only in one database: %v, %v, want only extra locally", diff.TablesOnlyInA, diff.TablesOnlyInB)
+		}
+		if after, err := os.ReadFile(path); err != nil || !bytes.Equal(after, before) {
+			t.Errorf("RetrieveAndDiff modified the local database: %v", err)
+		}
+	})
+
+	if _, err := s.RetrieveAndDiff(ctx, filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("RetrieveAndDiff accepted a missing local database")
+	}
+}
+
+func TestSyncFromNATSIfNewer(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "local.db")
+	if _, err := s.SyncFromNATSIfNewer(ctx, path); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("SyncFromNATSIfNewer without a stored database: got %v, want ErrObjectNotFound", err)
+	}
+
+	source := filepath.Join(t.TempDir(), "source.db")
+	createTestDatabase(t, source)
+	if err := s.StoreDuckDB(source); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+
+	sync := func(want bool) {
+		t.Helper()
+		synced, err := s.SyncFromNATSIfNewer(ctx, path)
+		if err != nil {
+			t.Fatalf("SyncFromNATSIfNewer: %v", err)
+		}
+		if synced != want {
+			t.Errorf("SyncFromNATSIfNewer = %v, want %v", synced, want)
+		}
+	}
+
+	// A missing local file is always synced, after which it is up to date
+	sync(true)
+	if n := queryTestInt(t, path, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("synced database holds %d users, want 3", n)
+	}
+	info, err := s.GetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stat.ModTime().Equal(info.ModTime) {
+		t.Errorf("synced file modified at %v, want the store time %v", stat.ModTime(), info.ModTime)
+	}
+	sync(false)
+
+	// Local changes made after the last store are kept
+	execTestDatabase(t, path, "INSERT INTO users VALUES (10, 'Dave', now())")
+	sync(false)
+	if n := queryTestInt(t, path, "SELECT count(*) FROM users"); n != 4 {
+		t.Errorf("local database holds %d users after the skipped sync, want 4", n)
+	}
+
+	// A database stored after the local file was last modified replaces it
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+	sync(true)
+	if n := queryTestInt(t, path, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("synced database holds %d users, want 3", n)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nuid"
+)
+
+var (
+	// ErrLockHeld is returned when another holder owns the database lock
+	ErrLockHeld = errors.New("lock is held by another writer")
+	// ErrLockRequired is returned when lock enforcement is enabled and no valid lock was supplied
+	ErrLockRequired = errors.New("a held lock is required for this operation")
+)
+
+const (
+	// minLockTTL keeps the heartbeat interval, ttl/3, long enough for a KV round trip
+	minLockTTL = 100 * time.Millisecond
+	// storeLockTTL is the ttl of the lock taken for stores made on behalf of a tracked
+	// resource, the heartbeat keeps it for as long as the store takes
+	storeLockTTL = 30 * time.Second
+)
+
+// Lock is an advisory lock on a stored database
+type Lock interface {
+	// Release gives up the lock so other writers can acquire it
+	Release() error
+	// Held reports whether the lock is still owned by this holder
+	Held() bool
+}
+
+// lockRecord is the value stored under the lock key
+type lockRecord struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type natsLock struct {
+	kv    nats.KeyValue
+	key   string
+	token string
+	ttl   time.Duration
+
+	mu       sync.Mutex
+	revision uint64
+	held     bool
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func (d *DuckDBStorage) lockBucket() string {
+	return d.bucket + "-locks"
+}
+
+func (d *DuckDBStorage) lockKey() string {
+	return d.dbName + ".lock"
+}
+
+// AcquireLock takes the advisory lock for the database. The lock is kept alive by a heartbeat
+// every ttl/3 and expires after ttl if its holder goes away without releasing it. ttl must be
+// at least 100ms.
+func (d *DuckDBStorage) AcquireLock(ctx context.Context, ttl time.Duration) (_ Lock, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	return d.acquireLock(ctx, ttl)
+}
+
+// acquireLock is AcquireLock for operations already registered with Close
+func (d *DuckDBStorage) acquireLock(ctx context.Context, ttl time.Duration) (Lock, error) {
+	if ttl < minLockTTL {
+		return nil, fmt.Errorf("invalid lock ttl: %v, must be at least %v", ttl, minLockTTL)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	kv, err := d.keyValue(d.lockBucket())
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &natsLock{
+		kv:    kv,
+		key:   d.lockKey(),
+		token: nuid.Next(),
+		ttl:   ttl,
+	}
+
+	record, err := lock.record()
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := lock.create(ctx, record)
+	if err != nil {
+		return nil, err
+	}
+
+	lock.revision = revision
+	lock.held = true
+	lock.stop = make(chan struct{})
+	lock.done = make(chan struct{})
+	go lock.heartbeat(lock.stop)
+
+	return lock, nil
+}
+
+// create creates the lock key or takes over an expired lock. A lock released between the
+// attempt to create the key and reading it is free again, so creating the key is retried.
+func (l *natsLock) create(ctx context.Context, record []byte) (uint64, error) {
+	for {
+		revision, err := l.kv.Create(l.key, record)
+		if !errors.Is(err, nats.ErrKeyExists) {
+			return revision, err
+		}
+		revision, err = l.takeOverExpired(record)
+		if !errors.Is(err, nats.ErrKeyNotFound) {
+			return revision, err
+		}
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// takeOverExpired replaces an existing lock whose holder stopped refreshing it. It returns
+// nats.ErrKeyNotFound if the lock was released in the meantime.
+func (l *natsLock) takeOverExpired(record []byte) (uint64, error) {
+	entry, err := l.kv.Get(l.key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read existing lock: %w", err)
+	}
+
+	var existing lockRecord
+	if err := json.Unmarshal(entry.Value(), &existing); err == nil && time.Now().Before(existing.ExpiresAt) {
+		return 0, ErrLockHeld
+	}
+
+	revision, err := l.kv.Update(l.key, record, entry.Revision())
+	if err != nil {
+		// Someone else won the race for the expired lock
+		return 0, ErrLockHeld
+	}
+	return revision, nil
+}
+
+func (l *natsLock) record() ([]byte, error) {
+	data, err := json.Marshal(lockRecord{Token: l.token, ExpiresAt: time.Now().Add(l.ttl)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode lock: %w", err)
+	}
+	return data, nil
+}
+
+// heartbeat refreshes the lock expiry until the lock is released or lost
+func (l *natsLock) heartbeat(stop <-chan struct{}) {
+	defer close(l.done)
+
+	ticker := time.NewTicker(l.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !l.refresh() {
+				return
+			}
+		}
+	}
+}
+
+func (l *natsLock) refresh() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	record, err := l.record()
+	if err == nil {
+		var revision uint64
+		revision, err = l.kv.Update(l.key, record, l.revision)
+		l.revision = revision
+	}
+	if err != nil {
+		// The key changed underneath us, so the lock can no longer be trusted
+		l.held = false
+		return false
+	}
+	return true
+}
+
+// Release stops the heartbeat and deletes the lock key if it is still ours
+func (l *natsLock) Release() error {
+	l.mu.Lock()
+	if l.stop == nil {
+		l.mu.Unlock()
+		return nil
+	}
+	close(l.stop)
+	l.stop = nil
+	l.mu.Unlock()
+
+	<-l.done
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.held {
+		return nil
+	}
+	l.held = false
+
+	if err := l.kv.Delete(l.key, nats.LastRevision(l.revision)); err != nil {
+		return fmt.Errorf("failed to release lock: %w", err)
+	}
+	return nil
+}
+
+func (l *natsLock) Held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.held
+}
+
+// live reports whether the lock key in KV is still the unexpired revision this holder wrote
+// last. Held only reflects the last heartbeat, the key may have been taken over since.
+func (l *natsLock) live() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.held {
+		return false, nil
+	}
+
+	entry, err := l.kv.Get(l.key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read lock: %w", err)
+	}
+	var record lockRecord
+	if err := json.Unmarshal(entry.Value(), &record); err != nil {
+		return false, nil
+	}
+	return entry.Revision() == l.revision && record.Token == l.token && time.Now().Before(record.ExpiresAt), nil
+}
+
+// storeFileLocked stores a database file on behalf of a resource that has no Lock of its own.
+// With lock enforcement enabled it holds the lock for the duration of the store and fails with
+// ErrLockHeld while another writer owns it.
+func (d *DuckDBStorage) storeFileLocked(ctx context.Context, dbFilePath string, deduplicate bool) error {
+	if !d.opts.EnforceLock {
+		return d.storeFile(ctx, dbFilePath, deduplicate, d.opts.ChunkSize, nil, nil)
+	}
+
+	lock, err := d.acquireLock(ctx, storeLockTTL)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+	return d.storeFile(ctx, dbFilePath, deduplicate, d.opts.ChunkSize, []Lock{lock}, nil)
+}
+
+// checkLock enforces WithLockEnforcement for operations that take an optional lock. A lock only
+// counts if it was taken on this database in this bucket and its key is still live in KV.
+func (d *DuckDBStorage) checkLock(locks []Lock) error {
+	if !d.opts.EnforceLock {
+		return nil
+	}
+	for _, lock := range locks {
+		held, ok := lock.(*natsLock)
+		if !ok || held.key != d.lockKey() || held.kv.Bucket() != d.lockBucket() {
+			continue
+		}
+		live, err := held.live()
+		if err != nil {
+			return err
+		}
+		if live {
+			return nil
+		}
+	}
+	return ErrLockRequired
+}
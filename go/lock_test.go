@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// readTestLock returns the record stored under the lock key of s
+func readTestLock(t *testing.T, s *DuckDBStorage) (lockRecord, uint64) {
+	t.Helper()
+	kv, err := s.keyValue(s.lockBucket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := kv.Get(s.lockKey())
+	if err != nil {
+		t.Fatalf("failed to read lock: %v", err)
+	}
+	var record lockRecord
+	if err := json.Unmarshal(entry.Value(), &record); err != nil {
+		t.Fatal(err)
+	}
+	return record, entry.Revision()
+}
+
+// putTestLock overwrites the lock key of s with a lock of another holder expiring at expiresAt
+func putTestLock(t *testing.T, s *DuckDBStorage, expiresAt time.Time) {
+	t.Helper()
+	kv, err := s.keyValue(s.lockBucket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(lockRecord{Token: "other", ExpiresAt: expiresAt})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kv.Put(s.lockKey(), data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAcquireLockContention(t *testing.T) {
+	nc := startTestServer(t)
+	s, other := newTestStorage(t, nc), newTestStorage(t, nc)
+	ctx := context.Background()
+
+	lock, err := s.AcquireLock(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	if _, err := other.AcquireLock(ctx, time.Minute); !errors.Is(err, ErrLockHeld) {
+		t.Fatalf("AcquireLock of a held lock: got %v, want ErrLockHeld", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if lock.Held() {
+		t.Error("released lock is still held")
+	}
+	if err := lock.Release(); err != nil {
+		t.Errorf("second Release: %v", err)
+	}
+	next, err := other.AcquireLock(ctx, time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock after Release: %v", err)
+	}
+	next.Release()
+
+	if _, err := s.AcquireLock(ctx, time.Millisecond); err == nil {
+		t.Error("AcquireLock accepted a ttl below the minimum")
+	}
+}
+
+func TestAcquireLockTakeOverExpired(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	putTestLock(t, s, time.Now().Add(-time.Second))
+
+	lock, err := s.AcquireLock(context.Background(), time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireLock of an expired lock: %v", err)
+	}
+	defer lock.Release()
+	if record, _ := readTestLock(t, s); record.Token == "other" {
+		t.Error("expired lock was not taken over")
+	}
+}
+
+func TestLockHeartbeat(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	ttl := 300 * time.Millisecond
+	lock, err := s.AcquireLock(context.Background(), ttl)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	defer lock.Release()
+	acquired, _ := readTestLock(t, s)
+
+	time.Sleep(2 * ttl)
+	if !lock.Held() {
+		t.Fatal("lock lost while its heartbeat runs")
+	}
+	refreshed, _ := readTestLock(t, s)
+	if !refreshed.ExpiresAt.After(acquired.ExpiresAt) {
+		t.Errorf("heartbeat did not extend the lock: expiry %v, was %v", refreshed.ExpiresAt, acquired.ExpiresAt)
+	}
+	if _, err := s.AcquireLock(context.Background(), ttl); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("AcquireLock of a refreshed lock: got %v, want ErrLockHeld", err)
+	}
+}
+
+func TestStoreEnforceLock(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	s.opts.EnforceLock = true
+	ctx := context.Background()
+
+	if err := s.StoreDuckDB(path); !errors.Is(err, ErrLockRequired) {
+		t.Fatalf("StoreDuckDB without a lock: got %v, want ErrLockRequired", err)
+	}
+	lock, err := s.AcquireLock(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreDuckDB(path, lock); err != nil {
+		t.Fatalf("StoreDuckDB with the lock: %v", err)
+	}
+
+	// A lock on the same database name in another bucket does not count
+	elsewhere := newTestStorage(t, s.nc, WithBucket("OTHER"))
+	foreign, err := elsewhere.AcquireLock(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer foreign.Release()
+	if err := s.StoreDuckDB(path, foreign); !errors.Is(err, ErrLockRequired) {
+		t.Errorf("StoreDuckDB with a lock of another bucket: got %v, want ErrLockRequired", err)
+	}
+
+	// Nor does a lock whose key was taken over before its heartbeat noticed
+	putTestLock(t, s, time.Now().Add(time.Minute))
+	if !lock.Held() {
+		t.Fatal("lock lost before the next heartbeat")
+	}
+	if err := s.StoreDuckDB(path, lock); !errors.Is(err, ErrLockRequired) {
+		t.Errorf("StoreDuckDB with a taken over lock: got %v, want ErrLockRequired", err)
+	}
+	lock.Release()
+
+	kv, err := s.keyValue(s.lockBucket())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := kv.Delete(s.lockKey()); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		t.Fatal(err)
+	}
+	released, err := s.AcquireLock(ctx, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	released.Release()
+	if err := s.StoreDuckDB(path, released); !errors.Is(err, ErrLockRequired) {
+		t.Errorf("StoreDuckDB with a released lock: got %v, want ErrLockRequired", err)
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// Logger receives structured log messages as alternating key/value pairs, like slog.Logger
+type Logger interface {
+	Info(msg string, fields ...any)
+	Error(msg string, fields ...any)
+	Debug(msg string, fields ...any)
+}
+
+// slogLogger adapts a *slog.Logger to Logger
+type slogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger returns a Logger writing to l
+func NewSlogLogger(l *slog.Logger) Logger {
+	return &slogLogger{l: l}
+}
+
+func (s *slogLogger) Info(msg string, fields ...any)  { s.l.Info(msg, fields...) }
+func (s *slogLogger) Error(msg string, fields ...any) { s.l.Error(msg, fields...) }
+func (s *slogLogger) Debug(msg string, fields ...any) { s.l.Debug(msg, fields...) }
+
+// noopLogger discards everything
+type noopLogger struct{}
+
+// NewNoopLogger returns a Logger that discards all messages
+func NewNoopLogger() Logger {
+	return noopLogger{}
+}
+
+func (noopLogger) Info(string, ...any)  {}
+func (noopLogger) Error(string, ...any) {}
+func (noopLogger) Debug(string, ...any) {}
+
+// operationLog logs the start and outcome of a single storage operation
+type operationLog struct {
+	logger Logger
+	fields []any
+	start  time.Time
+}
+
+// logOperation logs the start of operation and returns a handle to log its outcome
+func (d *DuckDBStorage) logOperation(operation string, fields ...any) *operationLog {
+	fields = append([]any{"operation", operation, "db", d.dbName, "bucket", d.bucket}, fields...)
+	d.opts.Logger.Debug("operation started", fields...)
+	return &operationLog{logger: d.opts.Logger, fields: fields, start: time.Now()}
+}
+
+// done logs the outcome of the operation with its duration and any extra fields
+func (o *operationLog) done(err error, fields ...any) {
+	fields = append(append(o.fields, fields...), "duration", time.Since(o.start))
+	if err != nil {
+		o.logger.Error("operation failed", append(fields, "error", err)...)
+		return
+	}
+	o.logger.Info("operation completed", fields...)
+}
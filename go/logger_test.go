@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// logEntry is a message recorded by captureLogger
+type logEntry struct {
+	level  string
+	msg    string
+	fields map[string]any
+}
+
+// captureLogger is a Logger recording every message
+type captureLogger struct {
+	mu      sync.Mutex
+	entries []logEntry
+}
+
+func (l *captureLogger) log(level, msg string, fields []any) {
+	entry := logEntry{level: level, msg: msg, fields: map[string]any{}}
+	for i := 0; i+1 < len(fields); i += 2 {
+		if key, ok := fields[i].(string); ok {
+			entry.fields[key] = fields[i+1]
+		}
+	}
+	l.mu.Lock()
+	l.entries = append(l.entries, entry)
+	l.mu.Unlock()
+}
+
+func (l *captureLogger) Info(msg string, fields ...any)  { l.log("info", msg, fields) }
+func (l *captureLogger) Error(msg string, fields ...any) { l.log("error", msg, fields) }
+func (l *captureLogger) Debug(msg string, fields ...any) { l.log("debug", msg, fields) }
+
+// find returns the entries with the given level and message for operation
+func (l *captureLogger) find(level, msg, operation string) []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var found []logEntry
+	for _, entry := range l.entries {
+		if entry.level == level && entry.msg == msg && entry.fields["operation"] == operation {
+			found = append(found, entry)
+		}
+	}
+	return found
+}
+
+func TestLoggerRoundTrip(t *testing.T) {
+	logger := &captureLogger{}
+	s, _ := storeTestDatabase(t, WithLogger(logger))
+	if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, operation := range []string{opStore, opRetrieve} {
+		if len(logger.find("debug", "operation started", operation)) != 1 {
+			t.Errorf("no start logged for %s", operation)
+		}
+		completed := logger.find("info", "operation completed", operation)
+		if len(completed) != 1 {
+			t.Fatalf("no completion logged for %s", operation)
+		}
+		if completed[0].fields["db"] != s.dbName || completed[0].fields["bucket"] != s.bucket {
+			t.Errorf("%s completion fields = %v", operation, completed[0].fields)
+		}
+		if _, ok := completed[0].fields["duration"]; !ok {
+			t.Errorf("%s completion has no duration", operation)
+		}
+	}
+}
+
+func TestLoggerFailure(t *testing.T) {
+	logger := &captureLogger{}
+	s, _ := storeTestDatabase(t, WithLogger(logger))
+	if err := s.RetrieveVersion("missing", filepath.Join(t.TempDir(), "out.db")); err == nil {
+		t.Fatal("RetrieveVersion of a missing version succeeded")
+	}
+
+	failed := logger.find("error", "operation failed", "retrieve_version")
+	if len(failed) != 1 {
+		t.Fatal("no failure logged for retrieve_version")
+	}
+	if failed[0].fields["error"] == nil || failed[0].fields["version"] != "missing" {
+		t.Errorf("failure fields = %v", failed[0].fields)
+	}
+}
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	logger.Info("stored", "db", "x.db")
+	logger.Debug("started")
+	logger.Error("failed", "error", "boom")
+
+	out := buf.String()
+	for _, want := range []string{"level=INFO msg=stored db=x.db", "level=DEBUG msg=started", "level=ERROR msg=failed error=boom"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output does not contain %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// logicalSnapshotPrefix is the object name prefix of the snapshots stored by CreateSnapshot
+const logicalSnapshotPrefix = "snapshots/"
+
+func (d *DuckDBStorage) logicalSnapshotName(snapshotName string) string {
+	return d.objectKey(logicalSnapshotPrefix + snapshotName + ".zip")
+}
+
+// isLogicalSnapshotObject reports whether name is a snapshot stored by CreateSnapshot
+func isLogicalSnapshotObject(name string) bool {
+	return strings.HasPrefix(name, logicalSnapshotPrefix) || strings.Contains(name, "/"+logicalSnapshotPrefix)
+}
+
+// CreateSnapshot stores a logical snapshot of the stored database as snapshots/<snapshotName>.zip.
+// The snapshot is the output of DuckDB's EXPORT DATABASE, the schema.sql, load.sql and a CSV
+// file per table, and can be restored by DuckDB versions that cannot read the database file.
+func (d *DuckDBStorage) CreateSnapshot(ctx context.Context, snapshotName string) (err error) {
+	op := d.logOperation("create_snapshot", "snapshot", snapshotName)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	dbPath, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(dbPath)
+
+	exportDir, err := tempPath("duckdb-nats-export-*")
+	if err != nil {
+		return err
+	}
+	defer removeTempDir(exportDir)
+
+	db, err := openDuckDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "EXPORT DATABASE "+quoteLiteral(exportDir)); err != nil {
+		return fmt.Errorf("failed to export database: %w", err)
+	}
+	db.Close()
+
+	zipPath, err := tempPath("duckdb-nats-snapshot-*.zip")
+	if err != nil {
+		return err
+	}
+	defer removeTemp(zipPath)
+
+	if err := zipDir(exportDir, zipPath); err != nil {
+		return err
+	}
+
+	_, err = d.putFile(ctx, d.logicalSnapshotName(snapshotName), zipPath, "Logical snapshot of "+d.dbName, nats.Header{
+		"Content-Type": []string{"application/zip"},
+	})
+	return err
+}
+
+// RestoreFromSnapshot rebuilds the database saved by CreateSnapshot as a new database at
+// outputDBPath, which must not exist yet
+func (d *DuckDBStorage) RestoreFromSnapshot(ctx context.Context, snapshotName, outputDBPath string) (err error) {
+	op := d.logOperation("restore_snapshot", "snapshot", snapshotName, "path", outputDBPath)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if _, err := os.Stat(outputDBPath); err == nil {
+		return fmt.Errorf("failed to restore snapshot: %s already exists", outputDBPath)
+	}
+
+	zipPath, err := tempPath("duckdb-nats-snapshot-*.zip")
+	if err != nil {
+		return err
+	}
+	defer removeTemp(zipPath)
+
+	if err := d.getFile(ctx, d.logicalSnapshotName(snapshotName), zipPath); err != nil {
+		return err
+	}
+
+	importDir, err := tempPath("duckdb-nats-import-*")
+	if err != nil {
+		return err
+	}
+	defer removeTempDir(importDir)
+
+	if err := unzipDir(zipPath, importDir); err != nil {
+		return err
+	}
+
+	db, err := openDuckDB(outputDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "IMPORT DATABASE "+quoteLiteral(importDir)); err != nil {
+		return fmt.Errorf("failed to import database: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
+
+// zipDir writes the regular files of dir to a new zip archive at zipPath
+func zipDir(dir, zipPath string) error {
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", zipPath, err)
+	}
+	defer out.Close()
+
+	w := zip.NewWriter(out)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if err := zipFile(w, filepath.Join(dir, entry.Name()), entry.Name()); err != nil {
+			return err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", zipPath, err)
+	}
+	return out.Close()
+}
+
+// zipFile adds the file at path to w as name
+func zipFile(w *zip.Writer, path, name string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer in.Close()
+
+	entry, err := w.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	if _, err := io.Copy(entry, in); err != nil {
+		return fmt.Errorf("failed to add %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+// unzipDir extracts the files of the zip archive at zipPath into a new directory dir. Only
+// plain file names are accepted so an archive cannot write outside dir.
+func unzipDir(zipPath, dir string) error {
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to open snapshot archive: %w", err)
+	}
+	defer archive.Close()
+
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return fmt.Errorf("failed to create %s: %w", dir, err)
+	}
+	for _, file := range archive.File {
+		if file.Name != filepath.Base(file.Name) || file.Name == ".." || file.FileInfo().IsDir() {
+			return fmt.Errorf("failed to extract snapshot archive: invalid entry %q", file.Name)
+		}
+		if err := unzipFile(file, filepath.Join(dir, file.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unzipFile extracts a single archive entry to path
+func unzipFile(file *zip.File, path string) error {
+	in, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %w", file.Name, err)
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to extract %s: %w", file.Name, err)
+	}
+	return out.Close()
+}
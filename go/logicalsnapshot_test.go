@@ -0,0 +1,80 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLogicalSnapshotRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "test.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE a AS SELECT i AS id FROM range(10) r(i)",
+		`CREATE TABLE b AS SELECT i AS id, 'x,"y' || chr(10) || 'z' AS text FROM range(20) r(i)`,
+		"CREATE SCHEMA archive",
+		"CREATE TABLE archive.c AS SELECT TIMESTAMP '2024-01-02 03:04:05' + INTERVAL (i) HOUR AS ts FROM range(30) r(i)")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+
+	if err := s.CreateSnapshot(ctx, "nightly"); err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	if _, err := s.obs.GetInfo("snapshots/nightly.zip"); err != nil {
+		t.Fatalf("snapshot not stored: %v", err)
+	}
+	if entries, err := s.ListDatabases(); err != nil || len(entries) != 1 || entries[0].Name != defaultDBName {
+		t.Errorf("ListDatabases = %v, %v, want only the database", entries, err)
+	}
+
+	out := filepath.Join(t.TempDir(), "restored.db")
+	if err := s.RestoreFromSnapshot(ctx, "nightly", out); err != nil {
+		t.Fatalf("RestoreFromSnapshot: %v", err)
+	}
+	for table, want := range map[string]int64{"a": 10, "b": 20, "archive.c": 30} {
+		if n := queryTestInt(t, out, "SELECT count(*) FROM "+table); n != want {
+			t.Errorf("restored %s holds %d rows, want %d", table, n, want)
+		}
+	}
+	want := queryTestStrings(t, path, "SELECT text || ts FROM b, archive.c ORDER BY b.id, ts")
+	if got := queryTestStrings(t, out, "SELECT text || ts FROM b, archive.c ORDER BY b.id, ts"); !slices.Equal(got, want) {
+		t.Error("restored values differ from the stored database")
+	}
+
+	if err := s.RestoreFromSnapshot(ctx, "nightly", out); err == nil {
+		t.Error("RestoreFromSnapshot overwrote an existing database")
+	}
+	if err := s.RestoreFromSnapshot(ctx, "missing", filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Error("RestoreFromSnapshot of a missing snapshot succeeded")
+	}
+}
+
+func TestUnzipDirRejectsPaths(t *testing.T) {
+	for _, name := range []string{"../escape.sql", "sub/file.csv", "/abs.csv"} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			zipPath := filepath.Join(dir, "snapshot.zip")
+			f, err := os.Create(zipPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w := zip.NewWriter(f)
+			if _, err := w.Create(name); err != nil {
+				t.Fatal(err)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			if err := unzipDir(zipPath, filepath.Join(dir, "out")); err == nil {
+				t.Errorf("unzipDir accepted entry %q", name)
+			}
+		})
+	}
+}
@@ -1,178 +1,641 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/marcboeker/go-duckdb"
 	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus/push"
 )
 
 type DuckDBStorage struct {
-	js     nats.JetStreamContext
-	obs    nats.ObjectStore
-	bucket string
-	dbName string
+	nc       *nats.Conn
+	ownsConn bool
+	js       nats.JetStreamContext
+	obs      nats.ObjectStore
+	bucket   string
+	dbName   string
+	opts     StorageOptions
+
+	mu            sync.Mutex
+	schedulerErrs chan error
+	// keyMu guards opts.EncryptionKey, which RotateEncryptionKey replaces
+	keyMu sync.RWMutex
+
+	watcherEvents chan WatchEvent
+
+	// ctx is cancelled by Close to stop background goroutines
+	ctx       context.Context
+	cancel    context.CancelFunc
+	inflight  sync.WaitGroup
+	closing   bool
+	drainErrs []error
+	resources map[io.Closer]struct{}
+
+	mirrors []mirrorStore
+
+	ingest  ingestCounters
+	metrics *Metrics
+	pusher  *push.Pusher
+	breaker *CircuitBreaker
+	workers *queryWorkerPool
+	latest  latestCache
+	plans   planCache
 }
 
 // NewDuckDBStorage creates a new storage handler for DuckDB files
-func NewDuckDBStorage(nc *nats.Conn, bucket, dbName string) (*DuckDBStorage, error) {
+func NewDuckDBStorage(nc *nats.Conn, opts ...Option) (*DuckDBStorage, error) {
+	options := defaultStorageOptions()
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Bucket == "" || options.DBName == "" {
+		return nil, fmt.Errorf("bucket and database name are required")
+	}
+	if options.Logger == nil {
+		options.Logger = NewNoopLogger()
+	}
+	options.Namespace = strings.Trim(options.Namespace, "/")
+
+	// Best effort, a failure here must not prevent using the storage
+	if _, err := CleanupOrphans(); err != nil {
+		options.Logger.Error("failed to clean up orphaned temp files", "error", err)
+	}
+	if options.EncryptionKey != nil {
+		if _, err := newGCM(options.EncryptionKey); err != nil {
+			return nil, err
+		}
+	}
+
 	js, err := nc.JetStream()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get JetStream context: %w", err)
 	}
 
-	obs, err := js.CreateObjectStore(&nats.ObjectStoreConfig{
-		Bucket:      bucket,
-		Description: "DuckDB database storage",
-	})
+	obs, err := openObjectStore(js, options)
 	if err != nil {
-		obs, err = js.ObjectStore(bucket)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create/get object store: %w", err)
-		}
+		return nil, err
 	}
 
-	return &DuckDBStorage{
-		js:     js,
-		obs:    obs,
-		bucket: bucket,
-		dbName: dbName,
-	}, nil
-}
+	var metrics *Metrics
+	if options.MetricsRegisterer != nil || options.PushgatewayURL != "" {
+		metrics = NewMetrics()
+	}
+	if options.MetricsRegisterer != nil {
+		if err := options.MetricsRegisterer.Register(metrics); err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
 
-// createSampleDatabase creates a sample DuckDB database with some data
-func createSampleDatabase(dbPath string) error {
-	// Ensure the directory exists
-	err := os.MkdirAll(filepath.Dir(dbPath), 0755)
+	mirrors, err := connectMirrors(options)
 	if err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+		if options.MetricsRegisterer != nil {
+			options.MetricsRegisterer.Unregister(metrics)
+		}
+		return nil, err
 	}
 
-	// Connect to DuckDB
-	connector, err := duckdb.NewConnector(dbPath, nil)
-	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+	d := &DuckDBStorage{
+		nc:        nc,
+		js:        js,
+		obs:       obs,
+		bucket:    options.Bucket,
+		opts:      options,
+		metrics:   metrics,
+		resources: make(map[io.Closer]struct{}),
+		mirrors:   mirrors,
 	}
-	db := sql.OpenDB(connector)
-	defer db.Close()
+	d.dbName = d.objectKey(options.DBName)
+	d.ctx, d.cancel = context.WithCancel(context.Background())
+	if options.CircuitFailureThreshold > 0 {
+		d.breaker = NewCircuitBreaker(options.CircuitFailureThreshold, options.CircuitCooldown)
+	}
+	if options.PushgatewayURL != "" {
+		d.pusher = newPusher(options, metrics)
+		if options.PushInterval > 0 {
+			if err := d.startPushing(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+// StoreDuckDB stores a DuckDB database file in NATS object store. When lock enforcement is
+// enabled a held Lock must be passed. With deduplication enabled it returns ErrUnchanged
+// instead of uploading a file identical to the stored database.
+func (d *DuckDBStorage) StoreDuckDB(dbFilePath string, lock ...Lock) error {
+	return d.store(context.Background(), dbFilePath, d.opts.Deduplicate, d.opts.ChunkSize, lock)
+}
+
+// StoreDuckDBContext stores the database file like StoreDuckDB and aborts the upload with
+// ctx.Err() once ctx is done
+func (d *DuckDBStorage) StoreDuckDBContext(ctx context.Context, dbFilePath string, lock ...Lock) error {
+	return d.store(ctx, dbFilePath, d.opts.Deduplicate, d.opts.ChunkSize, lock)
+}
+
+// ForceStore stores the database file like StoreDuckDB, even if it is unchanged
+func (d *DuckDBStorage) ForceStore(dbFilePath string, lock ...Lock) error {
+	return d.store(context.Background(), dbFilePath, false, d.opts.ChunkSize, lock)
+}
 
-	// Create a sample table and insert data
-	_, err = db.Exec(`
-		CREATE TABLE users (
-			id INTEGER PRIMARY KEY,
-			name VARCHAR,
-			created_at TIMESTAMP
-		)
-	`)
+// store stores the database file as a single object, or in chunks of chunkSize bytes if it is
+// positive
+func (d *DuckDBStorage) store(ctx context.Context, dbFilePath string, deduplicate bool, chunkSize int64, lock []Lock) (err error) {
+	end, err := d.beginOperation()
 	if err != nil {
-		return fmt.Errorf("failed to create table: %w", err)
+		return err
 	}
+	ctx, cancel := d.lifetime(ctx)
+	defer cancel()
+	defer func() {
+		err = d.closedErr(err)
+		if errors.Is(err, ErrUnchanged) {
+			end(nil)
+		} else {
+			end(err)
+		}
+	}()
+
+	return d.storeFile(ctx, dbFilePath, deduplicate, chunkSize, lock, nil)
+}
 
-	// Insert sample data
-	_, err = db.Exec(`
-		INSERT INTO users (id, name, created_at) VALUES 
-		(1, 'Alice', CURRENT_TIMESTAMP),
-		(2, 'Bob', CURRENT_TIMESTAMP),
-		(3, 'Charlie', CURRENT_TIMESTAMP)
-	`)
+// storeFile stores a database file without registering an operation with Close, which lets
+// tracked resources persist their final state while Close releases them. extra headers are
+// stored with the database object, or with the manifest when chunkSize is positive.
+func (d *DuckDBStorage) storeFile(ctx context.Context, dbFilePath string, deduplicate bool, chunkSize int64, lock []Lock, extra nats.Header) (err error) {
+	size := fileSize(dbFilePath)
+	op := d.logOperation(opStore, "path", dbFilePath, "size", size)
+	ctx, span := d.startSpan(ctx, spanStore)
+	defer func() {
+		// Skipping an unchanged upload is not a failure
+		reported := err
+		if errors.Is(err, ErrUnchanged) {
+			reported = nil
+		}
+		endSpan(span, reported)
+		op.done(reported, "unchanged", err != nil && reported == nil)
+		d.audit(opStore, op.start, size, reported)
+	}()
+	setSize(span, size)
+
+	if err := d.checkLock(lock); err != nil {
+		return err
+	}
+	if err := d.validateSchema(dbFilePath); err != nil {
+		return err
+	}
+	// Chunked databases carry no whole-file hash and are always stored
+	if deduplicate && chunkSize <= 0 {
+		if err := d.checkUnchanged(dbFilePath); err != nil {
+			return err
+		}
+	}
+	if err := d.checkQuota(dbFilePath, d.dbName); err != nil {
+		return err
+	}
+	stats, err := d.withRetry(ctx, func() error {
+		if chunkSize > 0 {
+			return d.storeChunked(ctx, dbFilePath, chunkSize, extra)
+		}
+		return d.storeObject(ctx, dbFilePath, extra)
+	})
+	if isDeadLetter(err) {
+		d.deadLetter(dbFilePath, stats.Attempts, err)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to insert data: %w", err)
+		return err
 	}
+	return d.replicate(ctx, dbFilePath)
+}
 
+// storeObject stores a DuckDB database file as a single object
+func (d *DuckDBStorage) storeObject(ctx context.Context, dbFilePath string, extra nats.Header) error {
+	if _, err := d.putDatabaseMirrored(ctx, d.dbName, dbFilePath, extra); err != nil {
+		return err
+	}
+	if d.opts.RevisionHistory > 0 {
+		return d.recordRevision(ctx)
+	}
 	return nil
 }
 
-// StoreDuckDB stores a DuckDB database file in NATS object store
-func (d *DuckDBStorage) StoreDuckDB(dbFilePath string) error {
+// putDatabase uploads a DuckDB database file to the named object, storing extra headers with it
+func (d *DuckDBStorage) putDatabase(ctx context.Context, name, dbFilePath string, extra nats.Header) (_ *nats.ObjectInfo, err error) {
+	start := time.Now()
+	defer func() { d.metrics.observe(opStore, start, err) }()
+
+	info, err := d.putDatabaseTo(ctx, d.js, d.obs, name, dbFilePath, extra)
+	if err != nil {
+		return nil, err
+	}
+	d.metrics.observeSize(opStore, int64(info.Size))
+	return info, nil
+}
+
+// putDatabaseTo uploads a DuckDB database file to the named object of obs, the bucket of js,
+// storing extra headers with it
+func (d *DuckDBStorage) putDatabaseTo(ctx context.Context, js nats.JetStreamContext, obs nats.ObjectStore, name, dbFilePath string, extra nats.Header) (*nats.ObjectInfo, error) {
 	file, err := os.Open(dbFilePath)
 	if err != nil {
-		return fmt.Errorf("failed to open database file: %w", err)
+		return nil, fmt.Errorf("failed to open database file: %w", err)
 	}
 	defer file.Close()
 
-	_, err = d.obs.Put(&nats.ObjectMeta{
-		Name:        d.dbName,
+	// Hash the file up front so the digest can travel in the object headers
+	checksum, err := hashReader(file)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind database file: %w", err)
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat database file: %w", err)
+	}
+
+	return d.putStream(ctx, js, obs, name, file, stat.Size(), checksum, extra)
+}
+
+// putStream uploads the database bytes read from r to the named object of obs, the bucket of
+// js, applying the configured compression and encryption. A failed upload leaves the stored
+// object as it was. size is only used for progress reports and may be -1.
+// Without a known checksum the bytes are hashed while streaming and the checksum header is
+// added once the upload is complete. Reading stops with ctx.Err() once ctx is done. extra
+// headers are stored as well unless they collide with the ones set here.
+func (d *DuckDBStorage) putStream(ctx context.Context, js nats.JetStreamContext, obs nats.ObjectStore, name string, r io.Reader, size int64, checksum string, extra nats.Header) (*nats.ObjectInfo, error) {
+	headers := cloneHeader(extra)
+	headers.Set("Content-Type", databaseContentType)
+	headers.Set(timestampHeader, time.Now().UTC().Format(time.RFC3339))
+	d.setExpiry(headers)
+
+	hash := sha256.New()
+	if checksum != "" {
+		headers.Set(checksumHeader, checksum)
+	} else {
+		r = io.TeeReader(r, hash)
+	}
+
+	reader, err := d.encodeStream(newProgressReader(newCtxReader(ctx, r), size, d.opts.ProgressInterval, d.opts.ProgressCallback), headers)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	previous, err := obs.GetInfo(name)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		previous = nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to get info for %s: %w", name, err)
+	}
+
+	info, err := obs.Put(&nats.ObjectMeta{
+		Name:        name,
 		Description: "DuckDB database file",
-		Headers: nats.Header{
-			"Content-Type": []string{"application/x-duckdb"},
-			"Timestamp":    []string{time.Now().UTC().Format(time.RFC3339)},
-		},
-	}, file)
+		Headers:     headers,
+	}, reader, nats.Context(ctx))
+
+	if err != nil {
+		err = fmt.Errorf("failed to store database in NATS: %w", err)
+		if restoreErr := d.restoreObject(js, obs, name, previous); restoreErr != nil {
+			return nil, errors.Join(err, restoreErr)
+		}
+		return nil, err
+	}
+
+	if checksum == "" {
+		meta := info.ObjectMeta
+		meta.Headers = cloneHeader(info.Headers)
+		meta.Headers.Set(checksumHeader, hex.EncodeToString(hash.Sum(nil)))
+		if err := obs.UpdateMeta(name, &meta); err != nil {
+			return nil, fmt.Errorf("failed to record checksum of %s: %w", name, err)
+		}
+		info.ObjectMeta = meta
+	}
 
+	return info, nil
+}
+
+// restoreObject undoes what a failed Put left of the named object of obs, the bucket of js.
+// Put reports chunks the server rejected, for example beyond the bucket's MaxBytes, only after
+// publishing the metadata of the new object, whose chunks it then purges. That metadata is
+// replaced by the one of previous, whose chunks Put keeps until it succeeds, or the object is
+// deleted if previous is nil.
+func (d *DuckDBStorage) restoreObject(js nats.JetStreamContext, obs nats.ObjectStore, name string, previous *nats.ObjectInfo) error {
+	current, err := obs.GetInfo(name)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return nil
+	}
 	if err != nil {
-		return fmt.Errorf("failed to store database in NATS: %w", err)
+		return fmt.Errorf("failed to get info for %s: %w", name, err)
+	}
+	if previous != nil && current.NUID == previous.NUID {
+		return nil
 	}
 
+	if previous == nil {
+		if err := obs.Delete(name); err != nil {
+			return fmt.Errorf("failed to delete partial %s: %w", name, err)
+		}
+		return nil
+	}
+	data, err := json.Marshal(previous)
+	if err != nil {
+		return fmt.Errorf("failed to encode info for %s: %w", name, err)
+	}
+	msg := nats.NewMsg(d.metaSubject(name))
+	msg.Header.Set(nats.MsgRollup, nats.MsgRollupSubject)
+	msg.Data = data
+	if _, err := js.PublishMsg(msg); err != nil {
+		return fmt.Errorf("failed to restore %s: %w", name, err)
+	}
 	return nil
 }
 
-// RetrieveDuckDB retrieves a DuckDB database file from NATS object store
-func (d *DuckDBStorage) RetrieveDuckDB(outputPath string) error {
-	obj, err := d.obs.Get(d.dbName)
+// encodeStream wraps r with the configured compression and encryption and records both in
+// headers. Closing the returned reader stops the encoders.
+func (d *DuckDBStorage) encodeStream(r io.Reader, headers nats.Header) (io.ReadCloser, error) {
+	encoded := &layeredReader{Reader: r}
+	if d.opts.Compression != CompressionNone {
+		compressed, err := compressReader(encoded.Reader, d.opts.Compression)
+		if err != nil {
+			return nil, err
+		}
+		encoded.Reader = compressed
+		encoded.closers = append(encoded.closers, compressed)
+		headers.Set(compressionHeader, string(d.opts.Compression))
+	}
+
+	// Encrypt after compressing, ciphertext does not compress
+	if key := d.encryptionKey(); key != nil {
+		encrypted, err := encryptObject(encoded.Reader, headers, key)
+		if err != nil {
+			encoded.Close()
+			return nil, err
+		}
+		encoded.Reader = encrypted
+		encoded.closers = append([]io.Closer{encrypted}, encoded.closers...)
+	}
+	return encoded, nil
+}
+
+// RetrieveDuckDB retrieves a DuckDB database file from NATS object store. When lock
+// enforcement is enabled a held Lock must be passed.
+func (d *DuckDBStorage) RetrieveDuckDB(outputPath string, lock ...Lock) error {
+	return d.RetrieveDuckDBContext(context.Background(), outputPath, lock...)
+}
+
+// RetrieveDuckDBContext retrieves the database like RetrieveDuckDB and aborts the download
+// with ctx.Err() once ctx is done
+func (d *DuckDBStorage) RetrieveDuckDBContext(ctx context.Context, outputPath string, lock ...Lock) error {
+	return d.retrieveFile(ctx, outputPath, d.opts.ChunkSize > 0, lock)
+}
+
+// retrieveFile retrieves the database to outputPath, reassembling it from chunks if chunked
+func (d *DuckDBStorage) retrieveFile(ctx context.Context, outputPath string, chunked bool, lock []Lock) (err error) {
+	op := d.logOperation(opRetrieve, "path", outputPath)
+	ctx, span := d.startSpan(ctx, spanRetrieve)
+	size := int64(-1)
+	defer func() {
+		endSpan(span, err)
+		op.done(err, "size", size)
+		d.audit(opRetrieve, op.start, size, err)
+	}()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := d.lifetime(ctx)
+	defer cancel()
+	defer func() {
+		err = d.closedErr(err)
+		end(err)
+	}()
+
+	if err := d.checkLock(lock); err != nil {
+		return err
+	}
+	if d.opts.AutoRetryOnCorruption > 0 {
+		err = d.retrieveVerified(ctx, outputPath, chunked)
+	} else {
+		err = d.retrieveAs(ctx, outputPath, chunked)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to retrieve database from NATS: %w", err)
+		return err
 	}
-	defer obj.Close()
+	size = fileSize(outputPath)
+	setSize(span, size)
+	return nil
+}
 
-	// Ensure the directory exists
-	err = os.MkdirAll(filepath.Dir(outputPath), 0755)
+// retrieve writes the database to outputPath using the configured storage format
+func (d *DuckDBStorage) retrieve(ctx context.Context, outputPath string) error {
+	return d.retrieveAs(ctx, outputPath, d.opts.ChunkSize > 0)
+}
+
+// retrieveAs writes the database to outputPath, reassembling it from chunks if chunked
+func (d *DuckDBStorage) retrieveAs(ctx context.Context, outputPath string, chunked bool) error {
+	_, err := d.withRetry(ctx, func() error {
+		if chunked {
+			return d.retrieveChunked(ctx, outputPath)
+		}
+		return d.retrieveObject(ctx, outputPath)
+	})
+	return err
+}
+
+// retrieveObject retrieves a DuckDB database file stored as a single object
+func (d *DuckDBStorage) retrieveObject(ctx context.Context, outputPath string) error {
+	return d.getDatabase(ctx, d.dbName, outputPath)
+}
+
+// openObjectFrom returns a reader over the decoded contents of the named database object in obs
+func (d *DuckDBStorage) openObjectFrom(ctx context.Context, obs nats.ObjectStore, name string) (io.ReadCloser, *nats.ObjectInfo, error) {
+	obj, err := obs.Get(name, nats.Context(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to retrieve database from NATS: %w", err)
+	}
+
+	info, err := obj.Info()
+	if err != nil {
+		obj.Close()
+		return nil, nil, fmt.Errorf("failed to read object info: %w", err)
+	}
+
+	plaintext, err := decryptObject(obj, info.Headers, d.encryptionKey())
+	if err != nil {
+		obj.Close()
+		return nil, nil, err
+	}
+
+	// Objects without a compression header are stored as raw bytes
+	algorithm := CompressionAlgorithm(info.Headers.Get(compressionHeader))
+	if algorithm == CompressionNone {
+		return &layeredReader{Reader: plaintext, closers: []io.Closer{obj}}, info, nil
+	}
+
+	decompressed, err := decompressReader(plaintext, algorithm)
+	if err != nil {
+		obj.Close()
+		return nil, nil, err
+	}
+
+	return &layeredReader{Reader: decompressed, closers: []io.Closer{decompressed, obj}}, info, nil
+}
+
+// getDatabase downloads the named database object to outputPath, verifying its checksum
+func (d *DuckDBStorage) getDatabase(ctx context.Context, name, outputPath string) (err error) {
+	start := time.Now()
+	defer func() { d.metrics.observe(opRetrieve, start, err) }()
+
+	var info *nats.ObjectInfo
+	err = writeOutput(outputPath, func(file *os.File) (err error) {
+		_, info, err = d.writeObject(ctx, name, file)
+		return err
+	})
 	if err != nil {
+		return err
+	}
+	d.metrics.observeSize(opRetrieve, int64(info.Size))
+
+	return nil
+}
+
+// writeOutput creates outputPath from the bytes write puts into a temp file next to it. The
+// temp file is only renamed once write succeeded, so a failed or interrupted download never
+// truncates or replaces an existing file at outputPath.
+func writeOutput(outputPath string, write func(*os.File) error) error {
+	// Ensure the directory exists
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	file, err := os.Create(outputPath)
+	file, err := os.CreateTemp(filepath.Dir(outputPath), filepath.Base(outputPath)+".tmp-*")
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
+	defer removeTemp(file.Name())
 	defer file.Close()
+	if err := RegisterTemp(file.Name()); err != nil {
+		return err
+	}
 
-	_, err = io.Copy(file, obj)
-	if err != nil {
+	if err := write(file); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
 		return fmt.Errorf("failed to write database to file: %w", err)
 	}
 
+	if err := os.Rename(file.Name(), outputPath); err != nil {
+		return fmt.Errorf("failed to move database into place: %w", err)
+	}
 	return nil
 }
 
-// GetInfo retrieves information about the stored database
-func (d *DuckDBStorage) GetInfo() (*nats.ObjectInfo, error) {
-	return d.obs.GetInfo(d.dbName)
+// writeObject streams the decoded contents of the named database object to w and verifies
+// the checksum once everything is written
+func (d *DuckDBStorage) writeObject(ctx context.Context, name string, w io.Writer) (int64, *nats.ObjectInfo, error) {
+	reader, info, err := d.openObject(ctx, name)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, hash), newCtxReader(ctx, reader))
+	if err != nil {
+		return n, nil, fmt.Errorf("failed to write database: %w", err)
+	}
+
+	// Objects stored before checksums were introduced have no header to verify against
+	if expected := info.Headers.Get(checksumHeader); expected != "" {
+		if err := verifyChecksum(expected, hex.EncodeToString(hash.Sum(nil))); err != nil {
+			return n, nil, err
+		}
+	}
+	return n, info, nil
+}
+
+// layeredReader reads from the outermost of a stack of readers and closes all of them
+type layeredReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r *layeredReader) Close() error {
+	var err error
+	for _, c := range r.closers {
+		if closeErr := c.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
 }
 
-// DeleteDatabase removes the database from the object store
-func (d *DuckDBStorage) DeleteDatabase() error {
-	return d.obs.Delete(d.dbName)
+// cloneHeader returns a copy of h that can be modified without affecting the original
+func cloneHeader(h nats.Header) nats.Header {
+	clone := make(nats.Header, len(h))
+	for key, values := range h {
+		clone[key] = append([]string(nil), values...)
+	}
+	return clone
 }
 
-// VerifyDatabase checks if the retrieved database is accessible and contains the expected data
-func VerifyDatabase(dbPath string) error {
-	connector, err := duckdb.NewConnector(dbPath, nil)
+// GetInfo retrieves information about the stored database
+func (d *DuckDBStorage) GetInfo() (info *nats.ObjectInfo, err error) {
+	end, err := d.beginOperation()
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return info, err
 	}
-	db := sql.OpenDB(connector)
-	defer db.Close()
+	defer func() { end(err) }()
+
+	_, err = d.withRetry(context.Background(), func() error {
+		info, err = d.obs.GetInfo(d.dbName)
+		return err
+	})
+	return info, err
+}
 
-	var count int
-	err = db.QueryRow("SELECT COUNT(*) FROM users").Scan(&count)
+// DeleteDatabase immediately removes the database from the object store. Pinned databases are
+// only deleted when WithForce is passed.
+func (d *DuckDBStorage) DeleteDatabase(opts ...DeleteOption) (err error) {
+	op := d.logOperation(opDelete)
+	_, span := d.startSpan(context.Background(), spanDelete)
+	defer func() {
+		endSpan(span, err)
+		d.metrics.countError(opDelete, err)
+		op.done(err)
+		d.audit(opDelete, op.start, -1, err)
+	}()
+	end, err := d.beginOperation()
 	if err != nil {
-		return fmt.Errorf("failed to query database: %w", err)
+		return err
 	}
+	defer func() { end(err) }()
 
-	fmt.Printf("Database verified: found %d users\n", count)
-	return nil
+	return d.softDelete(d.dbName, 0, opts)
 }
 
 func main() {
+	logger := NewSlogLogger(slog.New(slog.NewTextHandler(os.Stdout, nil)))
+
 	// Connect to NATS server
 	nc, err := nats.Connect(nats.DefaultURL)
 	if err != nil {
-		fmt.Printf("Failed to connect to NATS: %v\n", err)
+		logger.Error("failed to connect to NATS", "url", nats.DefaultURL, "error", err)
 		return
 	}
 	defer nc.Close()
@@ -182,51 +645,58 @@ func main() {
 	retrievedDbPath := "./tmp/mydb_retrieved.db"
 
 	// Create a sample database
-	fmt.Println("Creating sample database...")
-	err = createSampleDatabase(dbPath)
+	logger.Info("creating sample database", "path", dbPath)
+	err = GenerateSampleDatabase(dbPath, SampleDBConfig{TableCount: 2, RowsPerTable: 1000})
 	if err != nil {
-		fmt.Printf("Failed to create sample database: %v\n", err)
+		logger.Error("failed to create sample database", "error", err)
 		return
 	}
 
 	// Create storage handler
-	storage, err := NewDuckDBStorage(nc, "DUCKDB", "mydb.db")
+	storage, err := NewDuckDBStorage(nc,
+		WithBucket("DUCKDB"),
+		WithDBName("mydb.db"),
+		WithCompression(CompressionZstd),
+		WithLogger(logger),
+	)
 	if err != nil {
-		fmt.Printf("Failed to create storage handler: %v\n", err)
+		logger.Error("failed to create storage handler", "error", err)
 		return
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := storage.Close(ctx); err != nil {
+			logger.Error("failed to close storage handler", "error", err)
+		}
+	}()
 
 	// Store database
-	fmt.Println("Storing database in NATS...")
 	err = storage.StoreDuckDB(dbPath)
 	if err != nil {
-		fmt.Printf("Failed to store database: %v\n", err)
 		return
 	}
 
 	// Get info about stored database
 	info, err := storage.GetInfo()
 	if err != nil {
-		fmt.Printf("Failed to get info: %v\n", err)
+		logger.Error("failed to get info", "error", err)
 		return
 	}
-	fmt.Printf("Database stored: Size=%d bytes, ModTime=%v\n", info.Size, info.ModTime)
+	logger.Info("database stored", "size", info.Size, "mod_time", info.ModTime)
 
 	// Retrieve database
-	fmt.Println("Retrieving database from NATS...")
 	err = storage.RetrieveDuckDB(retrievedDbPath)
 	if err != nil {
-		fmt.Printf("Failed to retrieve database: %v\n", err)
 		return
 	}
 
-	// Verify the retrieved database
-	fmt.Println("Verifying retrieved database...")
-	err = VerifyDatabase(retrievedDbPath)
+	// Verify the stored database
+	err = storage.VerifyStoredDatabase([]TableExpectation{
+		{TableName: "table_1", MinRows: 1000, RequiredColumns: []string{"id"}},
+		{TableName: "table_2", MinRows: 1000, RequiredColumns: []string{"id"}},
+	})
 	if err != nil {
-		fmt.Printf("Failed to verify database: %v\n", err)
 		return
 	}
-
-	fmt.Println("Operation completed successfully!")
 }
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats.go"
+)
+
+// runTestServer starts an embedded NATS server with JetStream enabled that is shut down when
+// the test ends. opts may be nil.
+func runTestServer(tb testing.TB, opts *server.Options) *server.Server {
+	tb.Helper()
+	if opts == nil {
+		opts = &server.Options{}
+	}
+	if opts.Port == 0 {
+		opts.Port = -1
+	}
+	opts.JetStream = true
+	if opts.StoreDir == "" {
+		opts.StoreDir = tb.TempDir()
+	}
+	opts.NoLog = true
+	opts.NoSigs = true
+
+	s, err := server.NewServer(opts)
+	if err != nil {
+		tb.Fatalf("failed to create NATS server: %v", err)
+	}
+	go s.Start()
+	if !s.ReadyForConnections(5 * time.Second) {
+		s.Shutdown()
+		tb.Fatal("NATS server did not become ready")
+	}
+	tb.Cleanup(s.Shutdown)
+	return s
+}
+
+// connectTestServer connects to s, closing the connection when the test ends
+func connectTestServer(tb testing.TB, s *server.Server, opts ...nats.Option) *nats.Conn {
+	tb.Helper()
+	nc, err := nats.Connect(s.ClientURL(), opts...)
+	if err != nil {
+		tb.Fatalf("failed to connect to NATS: %v", err)
+	}
+	tb.Cleanup(nc.Close)
+	return nc
+}
+
+// startTestServer starts an embedded NATS server and returns a connection to it
+func startTestServer(tb testing.TB) *nats.Conn {
+	tb.Helper()
+	return connectTestServer(tb, runTestServer(tb, nil))
+}
+
+// newTestStorage creates a storage handler on nc that is closed when the test ends
+func newTestStorage(tb testing.TB, nc *nats.Conn, opts ...Option) *DuckDBStorage {
+	tb.Helper()
+	s, err := NewDuckDBStorage(nc, opts...)
+	if err != nil {
+		tb.Fatalf("failed to create storage: %v", err)
+	}
+	tb.Cleanup(func() { s.Close(context.Background()) })
+	return s
+}
+
+// createTestDatabase creates a DuckDB database at path with a users table holding Alice, Bob
+// and Charlie
+func createTestDatabase(tb testing.TB, path string) {
+	tb.Helper()
+	execTestDatabase(tb, path,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name VARCHAR, created_at TIMESTAMP)",
+		"INSERT INTO users VALUES (1, 'Alice', CURRENT_TIMESTAMP), (2, 'Bob', CURRENT_TIMESTAMP), (3, 'Charlie', CURRENT_TIMESTAMP)",
+	)
+}
+
+// execTestDatabase runs statements against the DuckDB database at path
+func execTestDatabase(tb testing.TB, path string, statements ...string) {
+	tb.Helper()
+	db, err := openDuckDB(path)
+	if err != nil {
+		tb.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer db.Close()
+	for _, stmt := range statements {
+		if _, err := db.Exec(stmt); err != nil {
+			tb.Fatalf("failed to run %q: %v", stmt, err)
+		}
+	}
+}
+
+// queryTestInt returns the single integer returned by query against the database at path
+func queryTestInt(tb testing.TB, path, query string) int64 {
+	tb.Helper()
+	db, err := openDuckDB(path)
+	if err != nil {
+		tb.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer db.Close()
+	var n int64
+	if err := db.QueryRow(query).Scan(&n); err != nil {
+		tb.Fatalf("failed to run %q: %v", query, err)
+	}
+	return n
+}
+
+// queryTestStrings returns the first column of every row returned by query against the
+// database at path
+func queryTestStrings(tb testing.TB, path, query string) []string {
+	tb.Helper()
+	db, err := openDuckDB(path)
+	if err != nil {
+		tb.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer db.Close()
+	rows, err := db.Query(query)
+	if err != nil {
+		tb.Fatalf("failed to run %q: %v", query, err)
+	}
+	defer rows.Close()
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			tb.Fatal(err)
+		}
+		values = append(values, value)
+	}
+	if err := rows.Err(); err != nil {
+		tb.Fatal(err)
+	}
+	return values
+}
+
+// storeTestDatabase starts a server, creates a storage handler with opts and stores the test
+// database. It returns the handler and the path of the local database.
+func storeTestDatabase(tb testing.TB, opts ...Option) (*DuckDBStorage, string) {
+	tb.Helper()
+	s := newTestStorage(tb, startTestServer(tb), opts...)
+	path := filepath.Join(tb.TempDir(), "test.db")
+	createTestDatabase(tb, path)
+	if err := s.StoreDuckDB(path); err != nil {
+		tb.Fatalf("failed to store database: %v", err)
+	}
+	return s, path
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ErrReadOnly is returned when writing back through a read-only handle
+var ErrReadOnly = errors.New("database opened read-only")
+
+// InMemoryDB is an in-memory DuckDB database loaded from NATS that can be written back with
+// Checkpoint
+type InMemoryDB struct {
+	storage  *DuckDBStorage
+	db       *sql.DB
+	readOnly bool
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// OpenInMemory loads the stored database into an in-memory DuckDB database. Close writes the
+// final state back to NATS. If nothing is stored yet the database starts empty.
+func (d *DuckDBStorage) OpenInMemory(ctx context.Context) (_ *InMemoryDB, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	return d.openInMemory(ctx, false)
+}
+
+// OpenInMemoryReadOnly is like OpenInMemory, but Close does not write back and Checkpoint fails
+func (d *DuckDBStorage) OpenInMemoryReadOnly(ctx context.Context) (_ *InMemoryDB, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	return d.openInMemory(ctx, true)
+}
+
+func (d *DuckDBStorage) openInMemory(ctx context.Context, readOnly bool) (*InMemoryDB, error) {
+	db, err := openDuckDB("")
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := d.retrieveTemp(ctx)
+	switch {
+	case errors.Is(err, nats.ErrObjectNotFound):
+	case err != nil:
+		db.Close()
+		return nil, err
+	default:
+		defer removeTempDatabase(path)
+		if err := copyDatabase(ctx, db, path, "source", "source", "memory", "READ_ONLY"); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to load database into memory: %w", err)
+		}
+	}
+
+	m := &InMemoryDB{storage: d, db: db, readOnly: readOnly}
+	d.trackResource(m)
+	return m, nil
+}
+
+// copyDatabase attaches path as alias with the given attach options and copies all objects
+// from the from catalog to the to catalog
+func copyDatabase(ctx context.Context, db *sql.DB, path, alias, from, to, options string) error {
+	attach := fmt.Sprintf("ATTACH %s AS %s", quoteLiteral(path), quoteIdent(alias))
+	if options != "" {
+		attach += " (" + options + ")"
+	}
+	if _, err := db.ExecContext(ctx, attach); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, fmt.Sprintf("COPY FROM DATABASE %s TO %s", quoteIdent(from), quoteIdent(to)))
+	if _, detachErr := db.ExecContext(ctx, "DETACH "+quoteIdent(alias)); err == nil {
+		err = detachErr
+	}
+	return err
+}
+
+// DB returns the in-memory database for queries and updates
+func (m *InMemoryDB) DB() *sql.DB {
+	return m.db
+}
+
+// Checkpoint serializes the in-memory state to a database file and stores it in NATS
+func (m *InMemoryDB) Checkpoint() error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, err := tempPath("duckdb-nats-checkpoint-*.db")
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(path)
+
+	if err := copyDatabase(context.Background(), m.db, path, "checkpoint", "memory", "checkpoint", ""); err != nil {
+		return fmt.Errorf("failed to serialize in-memory database: %w", err)
+	}
+
+	if err := m.storage.storeFileLocked(context.Background(), path, true); err != nil && !errors.Is(err, ErrUnchanged) {
+		return err
+	}
+	return nil
+}
+
+// Close performs a final checkpoint unless the database was opened read-only, then releases it
+func (m *InMemoryDB) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	m.mu.Unlock()
+	m.storage.untrackResource(m)
+
+	var err error
+	if !m.readOnly {
+		err = m.Checkpoint()
+	}
+	if closeErr := m.db.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// countUsers returns the number of rows of the users table of m
+func countUsers(t *testing.T, m *InMemoryDB) int {
+	t.Helper()
+	var n int
+	if err := m.DB().QueryRow("SELECT count(*) FROM users").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestInMemoryCheckpoint(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+
+	m, err := s.OpenInMemory(ctx)
+	if err != nil {
+		t.Fatalf("OpenInMemory: %v", err)
+	}
+	defer m.Close()
+	if _, err := m.DB().Exec("INSERT INTO users SELECT i + 10, 'user', now()::TIMESTAMP FROM range(1000) r(i)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint: %v", err)
+	}
+
+	reopened, err := s.OpenInMemoryReadOnly(ctx)
+	if err != nil {
+		t.Fatalf("OpenInMemoryReadOnly: %v", err)
+	}
+	defer reopened.Close()
+	if n := countUsers(t, reopened); n != 1003 {
+		t.Errorf("reopened database has %d rows, want 1003", n)
+	}
+	if err := reopened.Checkpoint(); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Checkpoint of a read-only database: got %v, want ErrReadOnly", err)
+	}
+}
+
+func TestInMemoryCloseFlushes(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+
+	m, err := s.OpenInMemory(ctx)
+	if err != nil {
+		t.Fatalf("OpenInMemory: %v", err)
+	}
+	if _, err := m.DB().Exec("DELETE FROM users WHERE id > 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := m.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+
+	var n int
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("stored database has %d rows after Close, want 1", n)
+	}
+}
+
+func TestInMemoryReadOnlyCloseKeepsStored(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+
+	m, err := s.OpenInMemoryReadOnly(ctx)
+	if err != nil {
+		t.Fatalf("OpenInMemoryReadOnly: %v", err)
+	}
+	if _, err := m.DB().Exec("DELETE FROM users"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	var n int
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM users").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("stored database has %d rows, want 3", n)
+	}
+}
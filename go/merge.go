@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// MergeStrategy selects how MergeDatabase treats source rows whose key exists in the target,
+// and how MergeBuckets treats objects whose name exists in the target bucket
+type MergeStrategy int
+
+const (
+	// MergeInsertOnly keeps existing target rows and skips conflicting source rows
+	MergeInsertOnly MergeStrategy = iota
+	// MergeUpsert replaces conflicting target rows with the source rows
+	MergeUpsert
+	// MergeOverwrite replaces the whole target table with the source table
+	MergeOverwrite
+)
+
+// MergeResult reports the rows affected by MergeDatabase
+type MergeResult struct {
+	InsertedRows int64
+	UpdatedRows  int64
+	SkippedRows  int64
+}
+
+// MergeDatabase merges tableName of the local database at sourceDBPath into the stored database
+// targetName and stores the result. Rows conflict when they have the same conflictKey value.
+// MergeInsertOnly and MergeUpsert need a primary key or unique constraint on conflictKey in
+// the target table. A target without the table gets a copy of the source table.
+func (d *DuckDBStorage) MergeDatabase(ctx context.Context, sourceDBPath, targetName, tableName, conflictKey string, strategy MergeStrategy) (result MergeResult, err error) {
+	op := d.logOperation("merge", "source", sourceDBPath, "target", targetName, "table", tableName)
+	defer func() {
+		op.done(err, "inserted", result.InsertedRows, "updated", result.UpdatedRows, "skipped", result.SkippedRows)
+	}()
+	end, err := d.beginOperation()
+	if err != nil {
+		return result, err
+	}
+	defer func() { end(err) }()
+
+	path, err := tempPath("duckdb-nats-merge-*.db")
+	if err != nil {
+		return result, err
+	}
+	defer removeTempDatabase(path)
+
+	if err := d.getDatabase(ctx, d.objectKey(targetName), path); err != nil {
+		return result, fmt.Errorf("failed to retrieve %s: %w", targetName, err)
+	}
+
+	db, err := openDuckDB(path)
+	if err != nil {
+		return result, err
+	}
+	defer db.Close()
+
+	// Attached databases are per connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	// Looked up before attaching, as the source table would be found as well
+	exists, err := tableExists(ctx, conn, tableName)
+	if err != nil {
+		return result, err
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("ATTACH %s AS merge_src (READ_ONLY)", quoteLiteral(sourceDBPath))); err != nil {
+		return result, fmt.Errorf("failed to attach source database: %w", err)
+	}
+
+	table, source := quoteIdent(tableName), "merge_src."+quoteIdent(tableName)
+
+	var total, conflicts int64
+	if err := conn.QueryRowContext(ctx, "SELECT count(*) FROM "+source).Scan(&total); err != nil {
+		return result, fmt.Errorf("failed to count source rows: %w", err)
+	}
+	if exists {
+		key := quoteIdent(conflictKey)
+		err := conn.QueryRowContext(ctx, fmt.Sprintf(
+			"SELECT count(*) FROM %s WHERE %s IN (SELECT %s FROM main.%s)", source, key, key, table,
+		)).Scan(&conflicts)
+		if err != nil {
+			return result, fmt.Errorf("failed to count conflicting rows: %w", err)
+		}
+	}
+
+	var statements []string
+	switch {
+	case !exists:
+		statements = []string{fmt.Sprintf("CREATE TABLE main.%s AS SELECT * FROM %s", table, source)}
+	case strategy == MergeInsertOnly:
+		statements = []string{fmt.Sprintf("INSERT OR IGNORE INTO main.%s BY NAME SELECT * FROM %s", table, source)}
+	case strategy == MergeUpsert:
+		statements = []string{fmt.Sprintf("INSERT OR REPLACE INTO main.%s BY NAME SELECT * FROM %s", table, source)}
+	case strategy == MergeOverwrite:
+		statements = []string{
+			"DELETE FROM main." + table,
+			fmt.Sprintf("INSERT INTO main.%s BY NAME SELECT * FROM %s", table, source),
+		}
+	default:
+		return result, fmt.Errorf("invalid merge strategy: %d", strategy)
+	}
+	for _, statement := range statements {
+		if _, err := conn.ExecContext(ctx, statement); err != nil {
+			return result, fmt.Errorf("failed to merge table %s: %w", tableName, err)
+		}
+	}
+
+	result.InsertedRows = total - conflicts
+	if strategy == MergeInsertOnly {
+		result.SkippedRows = conflicts
+	} else {
+		result.UpdatedRows = conflicts
+	}
+
+	if _, err := conn.ExecContext(ctx, "DETACH merge_src"); err != nil {
+		return result, fmt.Errorf("failed to detach source database: %w", err)
+	}
+	conn.Close()
+	if err := db.Close(); err != nil {
+		return result, fmt.Errorf("failed to close database: %w", err)
+	}
+
+	if err := d.checkQuota(path, d.objectKey(targetName)); err != nil {
+		return result, err
+	}
+	_, err = d.putDatabase(ctx, d.objectKey(targetName), path, nil)
+	return result, err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestMergeDatabase(t *testing.T) {
+	nc := startTestServer(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.db")
+	execTestDatabase(t, target,
+		"CREATE TABLE u (id INTEGER PRIMARY KEY, v VARCHAR)",
+		"INSERT INTO u VALUES (1, 'a'), (2, 'b'), (3, 'c')",
+	)
+	// Rows 2 and 3 conflict with the target
+	source := filepath.Join(dir, "source.db")
+	execTestDatabase(t, source,
+		"CREATE TABLE u (id INTEGER PRIMARY KEY, v VARCHAR)",
+		"INSERT INTO u VALUES (2, 'B'), (3, 'C'), (4, 'd'), (5, 'e'), (6, 'f')",
+	)
+
+	tests := []struct {
+		name      string
+		strategy  MergeStrategy
+		want      MergeResult
+		wantRows  int64
+		wantValue string
+	}{
+		{"upsert", MergeUpsert, MergeResult{InsertedRows: 3, UpdatedRows: 2}, 6, "B"},
+		{"insert only", MergeInsertOnly, MergeResult{InsertedRows: 3, SkippedRows: 2}, 6, "b"},
+		{"overwrite", MergeOverwrite, MergeResult{InsertedRows: 3, UpdatedRows: 2}, 5, "B"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t, nc, WithDBName("target.db"))
+			if err := s.StoreDuckDB(target); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := s.MergeDatabase(ctx, source, "target.db", "u", "id", tt.strategy)
+			if err != nil {
+				t.Fatalf("MergeDatabase: %v", err)
+			}
+			if result != tt.want {
+				t.Errorf("result = %+v, want %+v", result, tt.want)
+			}
+
+			var rows int64
+			var value string
+			if err := s.QueryRow(ctx, "SELECT count(*), (SELECT v FROM u WHERE id = 2) FROM u").Scan(&rows, &value); err != nil {
+				t.Fatalf("QueryRow: %v", err)
+			}
+			if rows != tt.wantRows || value != tt.wantValue {
+				t.Errorf("stored table has %d rows and v = %q for id 2, want %d and %q", rows, value, tt.wantRows, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestMergeDatabaseNewTable(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx := context.Background()
+	source := filepath.Join(t.TempDir(), "source.db")
+	execTestDatabase(t, source, "CREATE TABLE events AS SELECT range AS id FROM range(4)")
+
+	result, err := s.MergeDatabase(ctx, source, defaultDBName, "events", "id", MergeUpsert)
+	if err != nil {
+		t.Fatalf("MergeDatabase: %v", err)
+	}
+	if result.InsertedRows != 4 {
+		t.Errorf("result = %+v, want 4 inserted rows", result)
+	}
+	var n int64
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM events").Scan(&n); err != nil || n != 4 {
+		t.Errorf("stored events = %d, %v, want 4", n, err)
+	}
+
+	if _, err := s.MergeDatabase(ctx, source, defaultDBName, "events", "id", MergeStrategy(99)); err == nil {
+		t.Error("MergeDatabase accepted an invalid strategy")
+	}
+}
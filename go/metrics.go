@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Operation labels used by Metrics
+const (
+	opStore    = "store"
+	opRetrieve = "retrieve"
+	opQuery    = "query"
+	opDelete   = "delete"
+	opCopy     = "copy"
+	opList     = "list"
+)
+
+// Metrics holds the Prometheus collectors updated by storage operations
+type Metrics struct {
+	StoreDuration    prometheus.Histogram
+	RetrieveDuration prometheus.Histogram
+	QueryDuration    prometheus.Histogram
+	ObjectSize       *prometheus.HistogramVec
+	Errors           *prometheus.CounterVec
+}
+
+// NewMetrics creates an unregistered set of storage metrics
+func NewMetrics() *Metrics {
+	return &Metrics{
+		StoreDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "duckdb_nats_store_duration_seconds",
+			Help:    "Time taken to store a database in the object store.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		RetrieveDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "duckdb_nats_retrieve_duration_seconds",
+			Help:    "Time taken to retrieve a database from the object store.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		QueryDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "duckdb_nats_query_duration_seconds",
+			Help:    "Time taken to run a query against the stored database, including retrieval.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		ObjectSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "duckdb_nats_object_size_bytes",
+			Help:    "Size of stored and retrieved database objects.",
+			Buckets: prometheus.ExponentialBuckets(4096, 4, 10),
+		}, []string{"operation"}),
+		Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "duckdb_nats_errors_total",
+			Help: "Failed storage operations.",
+		}, []string{"operation", "error_type"}),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	m.StoreDuration.Describe(ch)
+	m.RetrieveDuration.Describe(ch)
+	m.QueryDuration.Describe(ch)
+	m.ObjectSize.Describe(ch)
+	m.Errors.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	m.StoreDuration.Collect(ch)
+	m.RetrieveDuration.Collect(ch)
+	m.QueryDuration.Collect(ch)
+	m.ObjectSize.Collect(ch)
+	m.Errors.Collect(ch)
+}
+
+// observe records the duration of a timed operation and counts it as an error if err is set.
+// It is a no-op on a nil Metrics so call sites do not need to check whether metrics are enabled.
+func (m *Metrics) observe(operation string, start time.Time, err error) {
+	if m == nil {
+		return
+	}
+
+	elapsed := time.Since(start).Seconds()
+	switch operation {
+	case opStore:
+		m.StoreDuration.Observe(elapsed)
+	case opRetrieve:
+		m.RetrieveDuration.Observe(elapsed)
+	case opQuery:
+		m.QueryDuration.Observe(elapsed)
+	}
+	m.countError(operation, err)
+}
+
+// observeSize records the size of an object moved by operation
+func (m *Metrics) observeSize(operation string, size int64) {
+	if m == nil {
+		return
+	}
+	m.ObjectSize.WithLabelValues(operation).Observe(float64(size))
+}
+
+// countError increments the error counter for operation if err is set
+func (m *Metrics) countError(operation string, err error) {
+	if m == nil || err == nil {
+		return
+	}
+	m.Errors.WithLabelValues(operation, errorType(err)).Inc()
+}
+
+// errorType maps an error to a low-cardinality label value
+func errorType(err error) string {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, nats.ErrTimeout):
+		return "timeout"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, nats.ErrObjectNotFound), errors.Is(err, ErrObjectNotFound), errors.Is(err, nats.ErrNoObjectsFound):
+		return "not_found"
+	case errors.Is(err, ErrAlreadyExists):
+		return "already_exists"
+	case errors.Is(err, ErrChecksumMismatch):
+		return "checksum"
+	case errors.Is(err, ErrDecryptionFailed):
+		return "decryption"
+	case errors.Is(err, ErrLockHeld), errors.Is(err, ErrLockRequired):
+		return "lock"
+	case errors.Is(err, ErrDatabasePinned):
+		return "pinned"
+	case errors.Is(err, ErrCircuitOpen):
+		return "circuit_open"
+	default:
+		return "other"
+	}
+}
+
+// MetricsHandler returns an HTTP handler exposing the storage metrics in the Prometheus format
+func (d *DuckDBStorage) MetricsHandler() http.Handler {
+	registry := prometheus.NewRegistry()
+	if d.metrics != nil {
+		registry.MustRegister(d.metrics)
+	}
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
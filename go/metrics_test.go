@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// sampleCount returns the number of observations of h
+func sampleCount(t *testing.T, h prometheus.Histogram) uint64 {
+	t.Helper()
+	var m dto.Metric
+	if err := h.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestErrorType(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{context.DeadlineExceeded, "timeout"},
+		{fmt.Errorf("wrapped: %w", nats.ErrTimeout), "timeout"},
+		{context.Canceled, "canceled"},
+		{nats.ErrObjectNotFound, "not_found"},
+		{ErrObjectNotFound, "not_found"},
+		{ErrAlreadyExists, "already_exists"},
+		{ErrChecksumMismatch, "checksum"},
+		{ErrDecryptionFailed, "decryption"},
+		{ErrLockHeld, "lock"},
+		{ErrDatabasePinned, "pinned"},
+		{ErrCircuitOpen, "circuit_open"},
+		{errors.New("boom"), "other"},
+	}
+	for _, tt := range tests {
+		if got := errorType(tt.err); got != tt.want {
+			t.Errorf("errorType(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsStoreRetrieve(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	s, _ := storeTestDatabase(t, WithMetrics(reg))
+	if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := sampleCount(t, s.metrics.StoreDuration); n == 0 {
+		t.Error("no store duration observed")
+	}
+	if n := sampleCount(t, s.metrics.RetrieveDuration); n == 0 {
+		t.Error("no retrieve duration observed")
+	}
+	for _, operation := range []string{opStore, opRetrieve} {
+		observer, err := s.metrics.ObjectSize.GetMetricWithLabelValues(operation)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := sampleCount(t, observer.(prometheus.Histogram)); n == 0 {
+			t.Errorf("no %s object size observed", operation)
+		}
+	}
+
+	// The collectors were registered with the given registry
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) == 0 {
+		t.Error("nothing registered with the registry")
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	s, _ := storeTestDatabase(t, WithMetrics(prometheus.NewRegistry()))
+	if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CopyDatabase("missing.db", "copy.db", false); err == nil {
+		t.Fatal("CopyDatabase of a missing database succeeded")
+	}
+
+	rec := httptest.NewRecorder()
+	s.MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"duckdb_nats_retrieve_duration_seconds_count 1",
+		`duckdb_nats_errors_total{error_type="not_found",operation="copy"} 1`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics do not contain %s:\n%s", want, body)
+		}
+	}
+}
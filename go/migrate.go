@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// migrationPrefix is the object name prefix of stored migration scripts
+const migrationPrefix = "migrations/"
+
+// migrationsTable records the migrations applied to a database
+const migrationsTable = "_schema_migrations"
+
+// StoreMigration uploads a SQL migration script. Migrations are applied in name order, so names
+// should start with a zero-padded number such as 0001_create_users.sql.
+func (d *DuckDBStorage) StoreMigration(name, sql string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	name = strings.TrimPrefix(name, migrationPrefix)
+	op := d.logOperation("store_migration", "migration", name)
+	defer func() { op.done(err) }()
+
+	if name == "" || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid migration name: %q", name)
+	}
+
+	_, err = d.obs.PutString(d.objectKey(migrationPrefix+name), sql)
+	if err != nil {
+		return fmt.Errorf("failed to store migration in NATS: %w", err)
+	}
+	return nil
+}
+
+// MigrateSchema applies the stored migrations missing from the database file in a single
+// transaction, records them in the _schema_migrations table and stores the migrated database.
+// It returns the number of migrations applied.
+func (d *DuckDBStorage) MigrateSchema(ctx context.Context, dbFilePath string) (applied int, err error) {
+	op := d.logOperation("migrate_schema", "path", dbFilePath)
+	defer func() { op.done(err, "applied", applied) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return applied, err
+	}
+	defer func() { end(err) }()
+
+	migrations, err := d.listMigrations(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	db, err := openDuckDB(dbFilePath)
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS "+migrationsTable+
+		" (name VARCHAR PRIMARY KEY, applied_at TIMESTAMP DEFAULT current_timestamp)")
+	if err != nil {
+		return 0, fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT name FROM "+migrationsTable)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+	var done []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to read applied migrations: %w", err)
+		}
+		done = append(done, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, name := range migrations {
+		if slices.Contains(done, name) {
+			continue
+		}
+
+		script, err := d.obs.GetString(d.objectKey(migrationPrefix+name), nats.Context(ctx))
+		if err != nil {
+			return 0, fmt.Errorf("failed to retrieve migration %s from NATS: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			return 0, fmt.Errorf("failed to apply migration %s: %w", name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO "+migrationsTable+" (name) VALUES (?)", name); err != nil {
+			return 0, fmt.Errorf("failed to record migration %s: %w", name, err)
+		}
+		applied++
+	}
+
+	if applied == 0 {
+		return 0, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit migrations: %w", err)
+	}
+	if err := db.Close(); err != nil {
+		return 0, fmt.Errorf("failed to close database: %w", err)
+	}
+
+	if err := d.StoreDuckDB(dbFilePath); err != nil {
+		return 0, err
+	}
+	return applied, nil
+}
+
+// listMigrations returns the names of the stored migrations in the order they are applied
+func (d *DuckDBStorage) listMigrations(ctx context.Context) ([]string, error) {
+	objects, err := d.obs.List(nats.Context(ctx))
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var names []string
+	for _, info := range objects {
+		key, ok := d.logicalName(info.Name)
+		if !ok {
+			continue
+		}
+		if name, ok := strings.CutPrefix(key, migrationPrefix); ok && !strings.Contains(name, "/") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// isMigrationObject reports whether name is a migration script, in any namespace
+func isMigrationObject(name string) bool {
+	return strings.HasPrefix(name, migrationPrefix) || strings.Contains(name, "/"+migrationPrefix)
+}
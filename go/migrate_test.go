@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMigrateSchema(t *testing.T) {
+	s, path := storeTestDatabase(t, WithNamespace("team"))
+	ctx := context.Background()
+
+	// Stored out of order, applied by name
+	if err := s.StoreMigration("0002_add_email.sql", "ALTER TABLE users ADD COLUMN email VARCHAR; UPDATE users SET email = name || '@example.com';"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreMigration("migrations/0001_create_orders.sql", "CREATE TABLE orders (id INTEGER, user_id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := s.MigrateSchema(ctx, path)
+	if err != nil {
+		t.Fatalf("MigrateSchema: %v", err)
+	}
+	if applied != 2 {
+		t.Errorf("MigrateSchema applied %d migrations, want 2", applied)
+	}
+
+	// Running again is a no-op
+	applied, err = s.MigrateSchema(ctx, path)
+	if err != nil {
+		t.Fatalf("second MigrateSchema: %v", err)
+	}
+	if applied != 0 {
+		t.Errorf("second MigrateSchema applied %d migrations, want 0", applied)
+	}
+
+	var email string
+	if err := s.QueryRow(ctx, "SELECT email FROM users WHERE id = 1").Scan(&email); err != nil {
+		t.Fatal(err)
+	}
+	if email != "Alice@example.com" {
+		t.Errorf("email = %q", email)
+	}
+	var recorded int
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM _schema_migrations").Scan(&recorded); err != nil {
+		t.Fatal(err)
+	}
+	if recorded != 2 {
+		t.Errorf("%d migrations recorded, want 2", recorded)
+	}
+
+	// Migration scripts are not listed as databases or namespaces
+	entries, err := s.ListDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("ListDatabases = %v, want only the database", entries)
+	}
+	if namespaces, _ := s.ListAllNamespaces(); len(namespaces) != 1 {
+		t.Errorf("ListAllNamespaces = %v, want only team", namespaces)
+	}
+}
+
+func TestMigrateSchemaRollsBack(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx := context.Background()
+	if err := s.StoreMigration("0001_create_orders.sql", "CREATE TABLE orders (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreMigration("0002_broken.sql", "CREATE TABLE other (id INTEGER); SELEC"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.MigrateSchema(ctx, path); err == nil {
+		t.Fatal("MigrateSchema applied a broken migration")
+	}
+	if n := queryTestInt(t, path, "SELECT count(*) FROM information_schema.tables WHERE table_name IN ('orders', 'other')"); n != 0 {
+		t.Errorf("%d tables of the failed run were kept", n)
+	}
+	if n := queryTestInt(t, path, "SELECT count(*) FROM _schema_migrations"); n != 0 {
+		t.Errorf("%d migrations recorded after a failed run", n)
+	}
+}
+
+func TestStoreMigrationInvalidName(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	for _, name := range []string{"", "migrations/", "a/b.sql"} {
+		if err := s.StoreMigration(name, "SELECT 1"); err == nil {
+			t.Errorf("StoreMigration(%q) succeeded", name)
+		}
+	}
+}
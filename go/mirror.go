@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/sync/errgroup"
+)
+
+// mirrorStore is the bucket of a secondary cluster kept in sync by StoreDuckDB
+type mirrorStore struct {
+	url string
+	nc  *nats.Conn
+	js  nats.JetStreamContext
+	obs nats.ObjectStore
+}
+
+// connectMirrors connects to every configured mirror cluster and binds to its bucket
+func connectMirrors(options StorageOptions) ([]mirrorStore, error) {
+	var mirrors []mirrorStore
+	closeAll := func() {
+		for _, mirror := range mirrors {
+			mirror.nc.Close()
+		}
+	}
+
+	for i, url := range options.MirrorURLs {
+		dialOpts := append([]nats.Option(nil), options.NATSOptions...)
+		if i < len(options.MirrorCredentials) && options.MirrorCredentials[i] != "" {
+			dialOpts = append(dialOpts, nats.UserCredentials(options.MirrorCredentials[i]))
+		}
+
+		nc, err := nats.Connect(url, dialOpts...)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to connect to mirror %s: %w", url, err)
+		}
+		mirrors = append(mirrors, mirrorStore{url: url, nc: nc})
+
+		js, err := nc.JetStream()
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("failed to get JetStream context of mirror %s: %w", url, err)
+		}
+		obs, err := openObjectStore(js, options)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("mirror %s: %w", url, err)
+		}
+		mirrors[len(mirrors)-1].js = js
+		mirrors[len(mirrors)-1].obs = obs
+	}
+
+	return mirrors, nil
+}
+
+// putDatabaseMirrored uploads the database to the primary bucket and every mirror concurrently
+func (d *DuckDBStorage) putDatabaseMirrored(ctx context.Context, name, dbFilePath string, extra nats.Header) (*nats.ObjectInfo, error) {
+	if len(d.mirrors) == 0 {
+		return d.putDatabase(ctx, name, dbFilePath, extra)
+	}
+
+	var info *nats.ObjectInfo
+	g := new(errgroup.Group)
+	g.Go(func() error {
+		var err error
+		info, err = d.putDatabase(ctx, name, dbFilePath, extra)
+		return err
+	})
+	for _, mirror := range d.mirrors {
+		g.Go(func() error {
+			if _, err := d.putDatabaseTo(ctx, mirror.js, mirror.obs, name, dbFilePath, extra); err != nil {
+				return fmt.Errorf("mirror %s: %w", mirror.url, err)
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// openObject returns a reader over the decoded contents of the named database object, reading
+// from the first cluster that serves it. Mirrors are tried in order after the primary bucket.
+func (d *DuckDBStorage) openObject(ctx context.Context, name string) (io.ReadCloser, *nats.ObjectInfo, error) {
+	reader, info, err := d.openObjectFrom(ctx, d.obs, name)
+	if err == nil || len(d.mirrors) == 0 || ctx.Err() != nil {
+		return reader, info, err
+	}
+
+	for _, mirror := range d.mirrors {
+		reader, info, mirrorErr := d.openObjectFrom(ctx, mirror.obs, name)
+		if mirrorErr == nil {
+			d.opts.Logger.Info("read database from mirror", "db", name, "mirror", mirror.url, "primary_error", err)
+			return reader, info, nil
+		}
+	}
+	return nil, nil, err
+}
+
+// ReplicationStatus reports for the primary cluster and every mirror whether its bucket holds
+// the currently stored database. Clusters are keyed by URL.
+func (d *DuckDBStorage) ReplicationStatus() (_ map[string]bool, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	current, err := d.obs.GetInfo(d.dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", d.dbName, err)
+	}
+
+	status := map[string]bool{d.nc.ConnectedUrlRedacted(): true}
+	for _, mirror := range d.mirrors {
+		info, err := mirror.obs.GetInfo(d.dbName)
+		if err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return nil, fmt.Errorf("failed to get %s from mirror %s: %w", d.dbName, mirror.url, err)
+		}
+		status[mirror.url] = err == nil && info.Digest == current.Digest && info.Size == current.Size
+	}
+	return status, nil
+}
+
+// closeMirrors closes the connections to the mirror clusters
+func (d *DuckDBStorage) closeMirrors() {
+	for _, mirror := range d.mirrors {
+		mirror.nc.Close()
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestMirrorClusters(t *testing.T) {
+	primary, mirror := startTestServer(t), startTestServer(t)
+	mirrorURL := mirror.ConnectedUrl()
+	s := newTestStorage(t, primary, WithMirrorClusters([]string{mirrorURL}, nil), WithCompression(CompressionZstd))
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+
+	status, err := s.ReplicationStatus()
+	if err != nil {
+		t.Fatalf("ReplicationStatus: %v", err)
+	}
+	if len(status) != 2 || !status[mirrorURL] {
+		t.Errorf("ReplicationStatus = %v, want both clusters current", status)
+	}
+
+	// Both servers serve the database after a single store
+	for name, nc := range map[string]*nats.Conn{"primary": primary, "mirror": mirror} {
+		out := filepath.Join(t.TempDir(), name+".db")
+		if err := newTestStorage(t, nc).RetrieveDuckDB(out); err != nil {
+			t.Fatalf("RetrieveDuckDB from the %s: %v", name, err)
+		}
+		if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != 3 {
+			t.Errorf("%s holds %d users, want 3", name, n)
+		}
+	}
+
+	// Reads fall back to the mirror when the primary lost the database
+	if err := s.obs.Delete(s.dbName); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(t.TempDir(), "fallback.db")
+	if err := s.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB without the primary copy: %v", err)
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("fallback holds %d users, want 3", n)
+	}
+}
+
+func TestReplicationStatusStale(t *testing.T) {
+	primary, mirror := startTestServer(t), startTestServer(t)
+	mirrorURL := mirror.ConnectedUrl()
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+
+	// Store a change through the primary only
+	s := newTestStorage(t, primary, WithMirrorClusters([]string{mirrorURL}, nil))
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+	execTestDatabase(t, path, "INSERT INTO users VALUES (10, 'Dave', now())")
+	if err := newTestStorage(t, primary).StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := s.ReplicationStatus()
+	if err != nil {
+		t.Fatalf("ReplicationStatus: %v", err)
+	}
+	if status[mirrorURL] {
+		t.Errorf("ReplicationStatus = %v, want the mirror stale", status)
+	}
+}
+
+func TestMirrorUnreachable(t *testing.T) {
+	nc := startTestServer(t)
+	if _, err := NewDuckDBStorage(nc, WithMirrorClusters([]string{"nats://127.0.0.1:1"}, nil)); err == nil {
+		t.Error("NewDuckDBStorage with an unreachable mirror succeeded")
+	}
+}
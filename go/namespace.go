@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// objectKey returns the object name of a database in the configured namespace
+func (d *DuckDBStorage) objectKey(name string) string {
+	if d.opts.Namespace == "" {
+		return name
+	}
+	return d.opts.Namespace + "/" + name
+}
+
+// logicalName strips the namespace from an object name, reporting whether the object belongs
+// to the configured namespace
+func (d *DuckDBStorage) logicalName(key string) (string, bool) {
+	if d.opts.Namespace == "" {
+		return key, true
+	}
+	return strings.CutPrefix(key, d.opts.Namespace+"/")
+}
+
+// ListAllNamespaces returns every namespace that holds at least one database in the bucket
+func (d *DuckDBStorage) ListAllNamespaces() (_ []string, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	objects, err := d.obs.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var namespaces []string
+	for _, info := range objects {
+		name := info.Name
+		if database, ok := manifestDatabase(info); ok {
+			name = database
+		} else if !isDatabaseObject(info) {
+			continue
+		}
+		namespace, _, ok := strings.Cut(name, "/")
+		if ok && !slices.Contains(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	slices.Sort(namespaces)
+	return namespaces, nil
+}
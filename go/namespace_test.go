@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestObjectKey(t *testing.T) {
+	tests := []struct {
+		namespace, name, key string
+	}{
+		{"", "x.db", "x.db"},
+		{"team", "x.db", "team/x.db"},
+		{"org/team", "x.db", "org/team/x.db"},
+	}
+	for _, tt := range tests {
+		d := &DuckDBStorage{opts: StorageOptions{Namespace: tt.namespace}}
+		if got := d.objectKey(tt.name); got != tt.key {
+			t.Errorf("objectKey(%q) in %q = %q, want %q", tt.name, tt.namespace, got, tt.key)
+		}
+		if name, ok := d.logicalName(tt.key); !ok || name != tt.name {
+			t.Errorf("logicalName(%q) in %q = %q, %v", tt.key, tt.namespace, name, ok)
+		}
+	}
+	d := &DuckDBStorage{opts: StorageOptions{Namespace: "team"}}
+	if _, ok := d.logicalName("other/x.db"); ok {
+		t.Error("logicalName accepted a key of another namespace")
+	}
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	base, path := storeTestDatabase(t)
+	alpha := newTestStorage(t, base.nc, WithNamespace("team-alpha"), WithDBName("x.db"))
+	beta := newTestStorage(t, base.nc, WithNamespace("/team-beta/"), WithDBName("y.db"))
+	for _, s := range []*DuckDBStorage{alpha, beta} {
+		if err := s.StoreDuckDB(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if alpha.dbName != "team-alpha/x.db" || beta.dbName != "team-beta/y.db" {
+		t.Errorf("object names %q and %q", alpha.dbName, beta.dbName)
+	}
+
+	for _, tt := range []struct {
+		s    *DuckDBStorage
+		want string
+	}{{alpha, "x.db"}, {beta, "y.db"}} {
+		entries, err := tt.s.ListDatabases()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 || entries[0].Name != tt.want {
+			t.Errorf("namespace %s lists %v, want only %s", tt.s.opts.Namespace, entries, tt.want)
+		}
+	}
+
+	namespaces, err := base.ListAllNamespaces()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(namespaces, []string{"team-alpha", "team-beta"}) {
+		t.Errorf("ListAllNamespaces = %v", namespaces)
+	}
+
+	// Deleting in one namespace leaves the other alone
+	if err := beta.DeleteDatabase(); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := alpha.ListDatabases(); len(entries) != 1 {
+		t.Errorf("namespace team-alpha lists %v after deleting from team-beta", entries)
+	}
+}
+
+func TestNamespaceExports(t *testing.T) {
+	base, path := storeTestDatabase(t)
+	alpha := newTestStorage(t, base.nc, WithNamespace("team-alpha"))
+	beta := newTestStorage(t, base.nc, WithNamespace("team-beta"))
+	ctx := context.Background()
+	if err := alpha.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := alpha.ExportTableToParquet(ctx, path, "users", "users.parquet"); err != nil {
+		t.Fatalf("ExportTableToParquet: %v", err)
+	}
+	if err := alpha.ExportQueryToArrow(ctx, "SELECT 1 AS x", "q.arrow"); err != nil {
+		t.Fatalf("ExportQueryToArrow: %v", err)
+	}
+	if _, err := alpha.ExportQueryToJSONLines(ctx, "SELECT 1 AS x", "q.jsonl"); err != nil {
+		t.Fatalf("ExportQueryToJSONLines: %v", err)
+	}
+	if _, err := alpha.ExportQueryToCSV(ctx, "SELECT 1 AS x", "q.csv", CSVExportOptions{}); err != nil {
+		t.Fatalf("ExportQueryToCSV: %v", err)
+	}
+	for _, name := range []string{"users.parquet", "q.arrow", "q.jsonl", "q.csv"} {
+		if _, err := base.obs.GetInfo("team-alpha/" + name); err != nil {
+			t.Errorf("%s not stored in the namespace: %v", name, err)
+		}
+	}
+
+	imported := filepath.Join(t.TempDir(), "imported.db")
+	if err := alpha.ImportParquetToTable(ctx, "users.parquet", imported, "users"); err != nil {
+		t.Fatalf("ImportParquetToTable: %v", err)
+	}
+	if n := queryTestInt(t, imported, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("imported %d users, want 3", n)
+	}
+	if err := beta.ImportParquetToTable(ctx, "users.parquet", filepath.Join(t.TempDir(), "beta.db"), "users"); err == nil {
+		t.Error("another namespace imported the export")
+	}
+
+	names, err := alpha.ExportParquetPartitioned(ctx, "users", "id")
+	if err != nil {
+		t.Fatalf("ExportParquetPartitioned: %v", err)
+	}
+	listed, err := alpha.ListPartitions("users", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(names)
+	if len(listed) != 3 || !slices.Equal(listed, names) {
+		t.Errorf("ListPartitions() = %v, want %v", listed, names)
+	}
+	if listed, err := beta.ListPartitions("users", "id"); err != nil || len(listed) != 0 {
+		t.Errorf("ListPartitions() in another namespace = %v, %v", listed, err)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// putFile uploads a file as-is to the named object
+func (d *DuckDBStorage) putFile(ctx context.Context, name, path, description string, headers nats.Header) (*nats.ObjectInfo, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := d.obs.Put(&nats.ObjectMeta{
+		Name:        name,
+		Description: description,
+		Headers:     headers,
+	}, file, nats.Context(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("failed to store %s in NATS: %w", name, err)
+	}
+	return info, nil
+}
+
+// getFile downloads the named object as-is to path
+func (d *DuckDBStorage) getFile(ctx context.Context, name, path string) error {
+	obj, err := d.obs.Get(name, nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to retrieve %s from NATS: %w", name, err)
+	}
+	defer obj.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, obj); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return file.Close()
+}
+
+// tempPath reserves a unique path under os.TempDir without leaving a file behind
+func tempPath(pattern string) (string, error) {
+	file, err := os.CreateTemp("", pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp file: %w", err)
+	}
+	file.Close()
+	os.Remove(file.Name())
+
+	if err := RegisterTemp(file.Name()); err != nil {
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// removeTemp removes a temp file and drops it from the cleanup registry
+func removeTemp(path string) {
+	os.Remove(path)
+	tempFiles.Release(path)
+}
+
+// removeTempDir removes a temp directory created at a tempPath and everything in it
+func removeTempDir(path string) {
+	os.RemoveAll(path)
+	tempFiles.Release(path)
+}
+
+// quoteIdent quotes a SQL identifier such as a table name
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteLiteral quotes a SQL string literal such as a file path
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
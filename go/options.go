@@ -0,0 +1,496 @@
+package main
+
+import (
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultBucket      = "DUCKDB"
+	defaultDBName      = "duckdb.db"
+	defaultDescription = "DuckDB database storage"
+)
+
+// StorageOptions controls how DuckDBStorage stores and retrieves database files
+type StorageOptions struct {
+	// Bucket is the object store bucket holding the database
+	Bucket string
+	// DBName is the object name of the database within the bucket
+	DBName string
+	// Description is used when the bucket has to be created
+	Description string
+	// TTL is the maximum age of objects in a newly created bucket
+	TTL time.Duration
+	// Replicas is the replica count of a newly created bucket
+	Replicas int
+	// ChunkSize switches StoreDuckDB and RetrieveDuckDB to the chunked format when positive
+	ChunkSize int64
+	// Compression is applied to the database bytes on store and reversed on retrieve
+	Compression CompressionAlgorithm
+	// MaxSnapshots is the number of scheduler snapshots to keep, zero keeps all of them
+	MaxSnapshots int
+	// EnforceLock makes StoreDuckDB and RetrieveDuckDB require a held Lock
+	EnforceLock bool
+	// IngestBufferSize is the number of ingested rows that triggers an early flush
+	IngestBufferSize int
+	// EncryptionKey enables AES-256-GCM encryption of stored databases when set
+	EncryptionKey []byte
+	// TracerProvider creates the spans of storage operations, the global provider is used when nil
+	TracerProvider trace.TracerProvider
+	// MetricsRegisterer receives the storage Metrics collector when set
+	MetricsRegisterer prometheus.Registerer
+	// Logger receives structured logs of storage operations
+	Logger Logger
+	// RetryAttempts is the number of attempts made for NATS operations failing transiently
+	RetryAttempts int
+	// RetryBaseDelay is the delay before the first retry, doubled on every further attempt
+	RetryBaseDelay time.Duration
+	// MaxCrossQueryDatabases limits how many databases a single CrossQuery may attach
+	MaxCrossQueryDatabases int
+	// BatchParallelism is the number of concurrent transfers in StoreBatch and RetrieveBatch
+	BatchParallelism int
+	// NATSOptions are dial options used by constructors that create their own connection
+	NATSOptions []nats.Option
+	// RevisionHistory is the number of stored revisions kept for RetrieveRevision
+	RevisionHistory int
+	// Namespace prefixes every object key with "Namespace/" so several teams can share a bucket
+	Namespace string
+	// StorageQuota is the maximum number of bytes the bucket may hold after a store, zero disables it
+	StorageQuota int64
+	// DrainTimeout bounds how long Close waits for the NATS connection to drain
+	DrainTimeout time.Duration
+	// DrainConnection makes Close drain a caller-supplied NATS connection as well
+	DrainConnection bool
+	// ProgressCallback is called as database bytes are transferred
+	ProgressCallback ProgressFunc
+	// ProgressInterval is the number of uploaded bytes between progress callbacks
+	ProgressInterval int64
+	// AutoCreateBucket creates the bucket if it does not exist, otherwise the constructor fails
+	AutoCreateBucket bool
+	// MirrorURLs are secondary clusters that every stored database is also written to
+	MirrorURLs []string
+	// MirrorCredentials holds a credentials file per mirror URL, empty for none
+	MirrorCredentials []string
+	// Deduplicate makes StoreDuckDB skip files identical to the stored database
+	Deduplicate bool
+	// ObjectTTL marks each stored database to be removed by PurgeExpired after this duration
+	ObjectTTL time.Duration
+	// AutoRetryOnCorruption is the number of downloads RetrieveDuckDB attempts until DuckDB can
+	// open the file, zero disables the check
+	AutoRetryOnCorruption int
+	// SchemaRegistry rejects stored databases that do not match their registered schema
+	SchemaRegistry *SchemaRegistry
+	// PushgatewayURL is the Prometheus Pushgateway receiving the storage metrics
+	PushgatewayURL string
+	// PushgatewayJob is the job label of pushed metrics
+	PushgatewayJob string
+	// PushInterval is the time between metric pushes, zero only pushes on PushMetricsNow
+	PushInterval time.Duration
+	// AuditSubject receives an AuditEvent after each store, retrieve, delete and query
+	AuditSubject string
+	// CircuitFailureThreshold is the number of consecutive transient failures that open the
+	// circuit breaker, zero disables it
+	CircuitFailureThreshold int
+	// CircuitCooldown is how long the open circuit rejects calls before a trial call
+	CircuitCooldown time.Duration
+	// DeadLetterSubject receives a DeadLetter for every store that failed after all retries
+	DeadLetterSubject string
+	// QueryCacheTTL bounds how long query workers reuse their copy of the database, zero keeps it
+	// until a newer revision is stored
+	QueryCacheTTL time.Duration
+	// S3Client receives a copy of every stored database when set, under S3KeyPrefix in S3Bucket
+	S3Client    S3PutObjectAPI
+	S3Bucket    string
+	S3KeyPrefix string
+	// CompactionThreshold is the size reduction in percent a compacted database must reach to be stored
+	CompactionThreshold float64
+	// WatchDebounce is how long StartFileWatcher waits after the last write before storing
+	WatchDebounce time.Duration
+	// CheckpointTimeout bounds how long StoreDuckDBWithCheckpoint waits for the WAL file to be removed
+	CheckpointTimeout time.Duration
+	// RowFilters hide the rows of the filtered tables not matching their predicates from queries
+	RowFilters []RowFilter
+	// RowFilterFuncs derive additional row filters per table and query
+	RowFilterFuncs []RowFilterFunc
+	// StorageType selects file or memory storage for a newly created bucket
+	StorageType nats.StorageType
+	// MaxBucketSize limits the total bytes of a newly created bucket, zero leaves it unlimited
+	MaxBucketSize int64
+}
+
+// Option configures a DuckDBStorage
+type Option func(*StorageOptions)
+
+func defaultStorageOptions() StorageOptions {
+	return StorageOptions{
+		Bucket:      defaultBucket,
+		DBName:      defaultDBName,
+		Description: defaultDescription,
+
+		IngestBufferSize: defaultIngestBufferSize,
+		Logger:           NewNoopLogger(),
+
+		MaxCrossQueryDatabases: defaultMaxCrossQueryDatabases,
+		BatchParallelism:       defaultBatchParallelism,
+		DrainTimeout:           defaultDrainTimeout,
+		QueryCacheTTL:          defaultQueryCacheTTL,
+		CompactionThreshold:    defaultCompactionThreshold,
+		WatchDebounce:          defaultWatchDebounce,
+		CheckpointTimeout:      defaultCheckpointTimeout,
+		ProgressInterval:       defaultProgressInterval,
+		AutoCreateBucket:       true,
+	}
+}
+
+// WithBucket sets the object store bucket name
+func WithBucket(bucket string) Option {
+	return func(o *StorageOptions) {
+		o.Bucket = bucket
+	}
+}
+
+// WithDBName sets the object name of the database
+func WithDBName(name string) Option {
+	return func(o *StorageOptions) {
+		o.DBName = name
+	}
+}
+
+// WithDescription sets the description of a newly created bucket
+func WithDescription(description string) Option {
+	return func(o *StorageOptions) {
+		o.Description = description
+	}
+}
+
+// WithTTL sets the maximum age of objects in a newly created bucket
+func WithTTL(ttl time.Duration) Option {
+	return func(o *StorageOptions) {
+		o.TTL = ttl
+	}
+}
+
+// WithReplicas sets the replica count of a newly created bucket
+func WithReplicas(replicas int) Option {
+	return func(o *StorageOptions) {
+		o.Replicas = replicas
+	}
+}
+
+// WithChunkSize stores databases as chunks of the given size
+func WithChunkSize(size int64) Option {
+	return func(o *StorageOptions) {
+		o.ChunkSize = size
+	}
+}
+
+// WithCompression sets the compression algorithm used when storing
+func WithCompression(algorithm CompressionAlgorithm) Option {
+	return func(o *StorageOptions) {
+		o.Compression = algorithm
+	}
+}
+
+// WithMaxSnapshots limits how many scheduler snapshots are retained
+func WithMaxSnapshots(n int) Option {
+	return func(o *StorageOptions) {
+		o.MaxSnapshots = n
+	}
+}
+
+// WithLockEnforcement requires callers to hold the advisory lock when storing or retrieving
+func WithLockEnforcement(enforce bool) Option {
+	return func(o *StorageOptions) {
+		o.EnforceLock = enforce
+	}
+}
+
+// WithIngestBufferSize sets how many ingested rows trigger a flush before the flush interval
+func WithIngestBufferSize(rows int) Option {
+	return func(o *StorageOptions) {
+		o.IngestBufferSize = rows
+	}
+}
+
+// WithEncryptionKey encrypts stored databases with the given 32-byte AES-256 key
+func WithEncryptionKey(key []byte) Option {
+	return func(o *StorageOptions) {
+		o.EncryptionKey = key
+	}
+}
+
+// WithTracerProvider sets the OpenTelemetry tracer provider used for storage operation spans
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *StorageOptions) {
+		o.TracerProvider = tp
+	}
+}
+
+// WithMetrics registers the storage Metrics collector with reg
+func WithMetrics(reg prometheus.Registerer) Option {
+	return func(o *StorageOptions) {
+		o.MetricsRegisterer = reg
+	}
+}
+
+// WithLogger sets the logger receiving storage operation logs
+func WithLogger(l Logger) Option {
+	return func(o *StorageOptions) {
+		o.Logger = l
+	}
+}
+
+// WithRetry retries store and retrieve operations failing with a retryable error, see IsRetryable
+func WithRetry(maxAttempts int, baseDelay time.Duration) Option {
+	return func(o *StorageOptions) {
+		o.RetryAttempts = maxAttempts
+		o.RetryBaseDelay = baseDelay
+	}
+}
+
+// WithMaxCrossQueryDatabases sets how many databases a single CrossQuery may attach
+func WithMaxCrossQueryDatabases(n int) Option {
+	return func(o *StorageOptions) {
+		o.MaxCrossQueryDatabases = n
+	}
+}
+
+// WithBatchParallelism sets the number of concurrent transfers in batch operations
+func WithBatchParallelism(n int) Option {
+	return func(o *StorageOptions) {
+		o.BatchParallelism = n
+	}
+}
+
+// WithNATSOptions sets extra dial options, such as credentials or an NKey, for constructors that
+// create the NATS connection
+func WithNATSOptions(opts ...nats.Option) Option {
+	return func(o *StorageOptions) {
+		o.NATSOptions = append(o.NATSOptions, opts...)
+	}
+}
+
+// WithRevisionHistory keeps a copy of the last n stored revisions for point-in-time recovery
+func WithRevisionHistory(n int) Option {
+	return func(o *StorageOptions) {
+		o.RevisionHistory = n
+	}
+}
+
+// WithNamespace stores databases, and the objects exported and imported by name, under the ns/
+// prefix of the bucket
+func WithNamespace(ns string) Option {
+	return func(o *StorageOptions) {
+		o.Namespace = ns
+	}
+}
+
+// WithStorageQuota rejects stores that would grow the bucket beyond maxBytes
+func WithStorageQuota(maxBytes int64) Option {
+	return func(o *StorageOptions) {
+		o.StorageQuota = maxBytes
+	}
+}
+
+// WithDrainTimeout sets how long Close waits for the NATS connection to drain
+func WithDrainTimeout(timeout time.Duration) Option {
+	return func(o *StorageOptions) {
+		o.DrainTimeout = timeout
+	}
+}
+
+// WithConnectionDrain makes Close drain and close the NATS connection even when the caller
+// supplied it
+func WithConnectionDrain(drain bool) Option {
+	return func(o *StorageOptions) {
+		o.DrainConnection = drain
+	}
+}
+
+// WithProgressCallback reports transfer progress of large databases to fn. The callback runs on
+// the goroutine doing the transfer, so it must not block.
+func WithProgressCallback(fn ProgressFunc) Option {
+	return func(o *StorageOptions) {
+		o.ProgressCallback = fn
+	}
+}
+
+// WithProgressInterval sets how many uploaded bytes pass between progress callbacks
+func WithProgressInterval(n int64) Option {
+	return func(o *StorageOptions) {
+		o.ProgressInterval = n
+	}
+}
+
+// WithAutoCreateBucket sets whether a missing bucket is created or makes the constructor fail
+func WithAutoCreateBucket(create bool) Option {
+	return func(o *StorageOptions) {
+		o.AutoCreateBucket = create
+	}
+}
+
+// WithMirrorClusters writes every stored database to the same bucket on the clusters at urls as
+// well, and reads from them when the primary cluster fails. credentials[i] is the credentials
+// file used for urls[i] and may be empty.
+func WithMirrorClusters(urls []string, credentials []string) Option {
+	return func(o *StorageOptions) {
+		o.MirrorURLs = urls
+		o.MirrorCredentials = credentials
+	}
+}
+
+// WithDeduplication makes StoreDuckDB return ErrUnchanged instead of uploading a file whose
+// SHA-256 matches the stored database
+func WithDeduplication(enabled bool) Option {
+	return func(o *StorageOptions) {
+		o.Deduplicate = enabled
+	}
+}
+
+// WithBucketTTL sets the maximum age of objects in a newly created bucket, after which NATS
+// removes them. It is equivalent to WithTTL.
+func WithBucketTTL(ttl time.Duration) Option {
+	return WithTTL(ttl)
+}
+
+// WithObjectTTL records an expiry time on each stored database, after which PurgeExpired
+// deletes it. Unlike WithBucketTTL it also applies to existing buckets.
+func WithObjectTTL(ttl time.Duration) Option {
+	return func(o *StorageOptions) {
+		o.ObjectTTL = ttl
+	}
+}
+
+// WithAutoRetryOnCorruption makes RetrieveDuckDB open the retrieved file and download it again,
+// up to maxAttempts times in total, while DuckDB cannot open it
+func WithAutoRetryOnCorruption(maxAttempts int) Option {
+	return func(o *StorageOptions) {
+		o.AutoRetryOnCorruption = maxAttempts
+	}
+}
+
+// WithSchemaValidation makes StoreDuckDB validate the file against the schema registered in
+// registry for the database name before uploading it. Databases without a registered schema
+// are stored unchecked.
+func WithSchemaValidation(registry *SchemaRegistry) Option {
+	return func(o *StorageOptions) {
+		o.SchemaRegistry = registry
+	}
+}
+
+// WithPushgateway pushes the storage metrics to the Prometheus Pushgateway at url every
+// interval until Close, grouped by jobName, the host name and the database name
+func WithPushgateway(url, jobName string, interval time.Duration) Option {
+	return func(o *StorageOptions) {
+		o.PushgatewayURL = url
+		o.PushgatewayJob = jobName
+		o.PushInterval = interval
+	}
+}
+
+// WithAuditSubject publishes an AuditEvent to subject through JetStream after every store,
+// retrieve, delete and query. A stream must capture the subject.
+func WithAuditSubject(subject string) Option {
+	return func(o *StorageOptions) {
+		o.AuditSubject = subject
+	}
+}
+
+// WithCircuitBreaker makes NATS operations fail fast with ErrCircuitOpen for cooldown after
+// failureThreshold consecutive transient failures
+func WithCircuitBreaker(failureThreshold int, cooldown time.Duration) Option {
+	return func(o *StorageOptions) {
+		o.CircuitFailureThreshold = failureThreshold
+		o.CircuitCooldown = cooldown
+	}
+}
+
+// WithDeadLetterSubject publishes a DeadLetter to subject through JetStream when a store fails
+// after all retries, so it can be replayed with ReplayDeadLetter. A stream must capture the
+// subject.
+func WithDeadLetterSubject(subject string) Option {
+	return func(o *StorageOptions) {
+		o.DeadLetterSubject = subject
+	}
+}
+
+// WithQueryCacheTTL sets how long the workers of StartQueryWorker reuse their copy of the
+// database before retrieving it again. Zero keeps it until a newer revision is stored.
+func WithQueryCacheTTL(ttl time.Duration) Option {
+	return func(o *StorageOptions) {
+		o.QueryCacheTTL = ttl
+	}
+}
+
+// WithS3Replication uploads a copy of the database to s3Bucket after every successful store,
+// at keyPrefix followed by the database name. A failed upload makes the store return
+// ErrReplicationFailed although the database was stored in NATS.
+func WithS3Replication(s3Client S3PutObjectAPI, s3Bucket, keyPrefix string) Option {
+	return func(o *StorageOptions) {
+		o.S3Client = s3Client
+		o.S3Bucket = s3Bucket
+		o.S3KeyPrefix = keyPrefix
+	}
+}
+
+// WithCompactionThreshold sets by how many percent CompactDatabase must shrink the database for
+// the compacted copy to be stored
+func WithCompactionThreshold(pct float64) Option {
+	return func(o *StorageOptions) {
+		o.CompactionThreshold = pct
+	}
+}
+
+// WithWatchDebounce sets how long StartFileWatcher waits for writes to the watched file to stop
+// before storing it
+func WithWatchDebounce(debounce time.Duration) Option {
+	return func(o *StorageOptions) {
+		o.WatchDebounce = debounce
+	}
+}
+
+// WithCheckpointTimeout sets how long StoreDuckDBWithCheckpoint waits for DuckDB to remove the
+// WAL file of the closed database
+func WithCheckpointTimeout(timeout time.Duration) Option {
+	return func(o *StorageOptions) {
+		o.CheckpointTimeout = timeout
+	}
+}
+
+// WithRowFilter hides the rows of table not matching predicate from every query run by the
+// storage: QueryRows and the methods built on it, RunQueryOnLatest, the query service and
+// workers, the exports and the cross-database queries. The rows are deleted from the local copy
+// of the database before the query runs, so views and aliases cannot reach them. Several
+// filters are combined with AND.
+func WithRowFilter(table, predicate string) Option {
+	return func(o *StorageOptions) {
+		o.RowFilters = append(o.RowFilters, RowFilter{Table: table, Predicate: predicate})
+	}
+}
+
+// WithRowFilterFunc restricts query results like WithRowFilter with predicates computed per
+// query. fn is called for every table of the database before the query runs and returns the
+// predicate for it, or an empty string to leave the rows unfiltered.
+func WithRowFilterFunc(fn RowFilterFunc) Option {
+	return func(o *StorageOptions) {
+		o.RowFilterFuncs = append(o.RowFilterFuncs, fn)
+	}
+}
+
+// WithStorageType sets whether a newly created bucket keeps its objects in files or in memory.
+// Memory buckets are faster but lose their contents when the NATS server restarts.
+func WithStorageType(storageType nats.StorageType) Option {
+	return func(o *StorageOptions) {
+		o.StorageType = storageType
+	}
+}
+
+// WithMaxBucketSize limits a newly created bucket to bytes in total, beyond which stores fail
+func WithMaxBucketSize(bytes int64) Option {
+	return func(o *StorageOptions) {
+		o.MaxBucketSize = bytes
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDefaultStorageOptions(t *testing.T) {
+	opts := defaultStorageOptions()
+	if opts.Bucket != defaultBucket || opts.DBName != defaultDBName || opts.Description != defaultDescription {
+		t.Errorf("defaults = %q %q %q", opts.Bucket, opts.DBName, opts.Description)
+	}
+	if opts.Compression != CompressionNone || opts.ChunkSize != 0 || opts.TTL != 0 {
+		t.Errorf("unexpected defaults: compression %q, chunk size %d, ttl %v", opts.Compression, opts.ChunkSize, opts.TTL)
+	}
+	if !opts.AutoCreateBucket {
+		t.Error("AutoCreateBucket disabled by default")
+	}
+}
+
+func TestOptionsApply(t *testing.T) {
+	opts := defaultStorageOptions()
+	for _, opt := range []Option{
+		WithBucket("B"),
+		WithDBName("x.db"),
+		WithDescription("desc"),
+		WithTTL(time.Hour),
+		WithReplicas(3),
+		WithChunkSize(1024),
+		WithCompression(CompressionZstd),
+	} {
+		opt(&opts)
+	}
+	if opts.Bucket != "B" || opts.DBName != "x.db" || opts.Description != "desc" {
+		t.Errorf("names = %q %q %q", opts.Bucket, opts.DBName, opts.Description)
+	}
+	if opts.TTL != time.Hour || opts.Replicas != 3 || opts.ChunkSize != 1024 || opts.Compression != CompressionZstd {
+		t.Errorf("options = %+v", opts)
+	}
+}
+
+func TestNewDuckDBStorageOptions(t *testing.T) {
+	nc := startTestServer(t)
+	if _, err := NewDuckDBStorage(nc, WithBucket("")); err == nil {
+		t.Error("NewDuckDBStorage accepted an empty bucket name")
+	}
+	if _, err := NewDuckDBStorage(nc, WithDBName("")); err == nil {
+		t.Error("NewDuckDBStorage accepted an empty database name")
+	}
+
+	s := newTestStorage(t, nc, WithBucket("OPTIONS"), WithDBName("x.db"), WithDescription("test bucket"))
+	if s.bucket != "OPTIONS" || s.dbName != "x.db" {
+		t.Errorf("bucket %q, database %q", s.bucket, s.dbName)
+	}
+	status, err := s.obs.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Bucket() != "OPTIONS" || status.Description() != "test bucket" {
+		t.Errorf("bucket %q with description %q", status.Bucket(), status.Description())
+	}
+}
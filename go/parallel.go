@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"golang.org/x/sync/errgroup"
+)
+
+// ProgressFunc receives the number of bytes transferred so far and the total size of a transfer
+type ProgressFunc func(doneBytes, totalBytes int64)
+
+// RetrieveDuckDBParallel reassembles a chunked database downloading up to parallelism chunks
+// at a time, each written straight to its offset in a temp file next to outputPath and
+// verified against the manifest hash. The file is moved to outputPath once every chunk is
+// complete. Databases stored as a single object are retrieved as usual.
+func (d *DuckDBStorage) RetrieveDuckDBParallel(outputPath string, parallelism int) (err error) {
+	op := d.logOperation("retrieve_parallel", "path", outputPath, "parallelism", parallelism)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if parallelism <= 0 {
+		return fmt.Errorf("invalid parallelism: %d", parallelism)
+	}
+
+	ctx := context.Background()
+	start := time.Now()
+	manifest, err := d.getManifest()
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return d.retrieveObject(ctx, outputPath)
+	}
+	defer func() { d.metrics.observe(opRetrieve, start, err) }()
+
+	if err != nil {
+		return err
+	}
+
+	err = writeOutput(outputPath, func(file *os.File) error {
+		return d.retrieveChunksAt(ctx, manifest, file, parallelism)
+	})
+	if err != nil {
+		return err
+	}
+	d.metrics.observeSize(opRetrieve, manifest.TotalSize)
+
+	return nil
+}
+
+// retrieveChunksAt downloads up to parallelism chunks at a time, writing each at its offset
+// in file
+func (d *DuckDBStorage) retrieveChunksAt(ctx context.Context, manifest *ChunkManifest, file *os.File, parallelism int) error {
+	if err := file.Truncate(manifest.TotalSize); err != nil {
+		return fmt.Errorf("failed to allocate output file: %w", err)
+	}
+
+	var progressMu sync.Mutex
+	var downloaded int64
+	reportChunk := func(size int64) {
+		if d.opts.ProgressCallback == nil {
+			return
+		}
+		// Serialized so the callback sees increasing totals
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		downloaded += size
+		d.opts.ProgressCallback(downloaded, manifest.TotalSize)
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(parallelism)
+
+	var offset int64
+	for _, chunk := range manifest.Chunks {
+		w := io.NewOffsetWriter(file, offset)
+		offset += chunk.Size
+
+		g.Go(func() error {
+			if err := d.retrieveChunk(gctx, chunk, w); err != nil {
+				return err
+			}
+			reportChunk(chunk.Size)
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
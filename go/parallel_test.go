@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// testChunkSize is the chunk size of the databases stored by storeTestChunks
+const testChunkSize = 16 * 1024
+
+// storeTestChunks stores random data split into chunks full chunks plus a partial one and
+// returns the data
+func storeTestChunks(tb testing.TB, s *DuckDBStorage, chunks int) []byte {
+	tb.Helper()
+	data := make([]byte, chunks*testChunkSize+5)
+	rand.Read(data)
+	path := filepath.Join(tb.TempDir(), "chunks.db")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		tb.Fatal(err)
+	}
+	if err := s.StoreDuckDBChunked(path, testChunkSize); err != nil {
+		tb.Fatalf("StoreDuckDBChunked: %v", err)
+	}
+	return data
+}
+
+func TestRetrieveDuckDBParallel(t *testing.T) {
+	var mu sync.Mutex
+	var progress []int64
+	s := newTestStorage(t, startTestServer(t), WithProgressCallback(func(done, total int64) {
+		mu.Lock()
+		defer mu.Unlock()
+		progress = append(progress, done)
+	}))
+	data := storeTestChunks(t, s, 200)
+	progress = nil
+
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDBParallel(out, 8); err != nil {
+		t.Fatalf("RetrieveDuckDBParallel: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("retrieved database does not match the stored one")
+	}
+
+	if len(progress) != 201 {
+		t.Fatalf("progress reported %d times, want once per chunk", len(progress))
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i] <= progress[i-1] {
+			t.Fatalf("progress went from %d to %d", progress[i-1], progress[i])
+		}
+	}
+	if last := progress[len(progress)-1]; last != int64(len(data)) {
+		t.Errorf("final progress = %d, want %d", last, len(data))
+	}
+}
+
+func TestRetrieveDuckDBParallelCorruptChunk(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	storeTestChunks(t, s, 4)
+	manifest, err := s.getManifest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.obs.PutBytes(manifest.Chunks[2].Name, make([]byte, testChunkSize)); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDBParallel(out, 4); err == nil {
+		t.Fatal("RetrieveDuckDBParallel accepted a corrupted chunk")
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("partial database left at %s", out)
+	}
+	if err := s.RetrieveDuckDBParallel(out, 0); err == nil {
+		t.Error("RetrieveDuckDBParallel accepted a parallelism of 0")
+	}
+}
+
+func TestRetrieveDuckDBParallelSingleObject(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDBParallel(out, 4); err != nil {
+		t.Fatalf("RetrieveDuckDBParallel: %v", err)
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("retrieved %d users, want 3", n)
+	}
+}
+
+// BenchmarkRetrieveChunked retrieves a 200-chunk database one chunk at a time and with
+// several chunks in flight
+func BenchmarkRetrieveChunked(b *testing.B) {
+	s := newTestStorage(b, startTestServer(b))
+	data := storeTestChunks(b, s, 200)
+	out := filepath.Join(b.TempDir(), "out.db")
+
+	for _, parallelism := range []int{1, 4, 16} {
+		name := fmt.Sprintf("parallel=%d", parallelism)
+		if parallelism == 1 {
+			name = "sequential"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				if err := s.RetrieveDuckDBParallel(out, parallelism); err != nil {
+					b.Fatalf("RetrieveDuckDBParallel: %v", err)
+				}
+				b.StopTimer()
+				os.Remove(out)
+				b.StartTimer()
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// sourceTableHeader records the table an exported object was produced from
+const sourceTableHeader = "X-Source-Table"
+
+// ExportTableToParquet writes a table of the local database as Parquet and stores it as a new object
+func (d *DuckDBStorage) ExportTableToParquet(ctx context.Context, dbFilePath, tableName, parquetObjectName string) (err error) {
+	op := d.logOperation("export_parquet", "table", tableName, "object", parquetObjectName)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	db, err := openDuckDB(dbFilePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	parquetPath, err := tempPath("duckdb-nats-*.parquet")
+	if err != nil {
+		return err
+	}
+	defer removeTemp(parquetPath)
+
+	_, err = db.ExecContext(ctx, fmt.Sprintf("COPY %s TO %s (FORMAT PARQUET)",
+		quoteIdent(tableName), quoteLiteral(parquetPath)))
+	if err != nil {
+		return fmt.Errorf("failed to export table %s: %w", tableName, err)
+	}
+
+	_, err = d.putFile(ctx, d.objectKey(parquetObjectName), parquetPath, "Parquet export of "+tableName, nats.Header{
+		"Content-Type":    []string{"application/x-parquet"},
+		sourceTableHeader: []string{tableName},
+	})
+	return err
+}
+
+// ImportParquetToTable loads a stored Parquet object into a table of the local database and
+// stores the updated database
+func (d *DuckDBStorage) ImportParquetToTable(ctx context.Context, parquetObjectName, targetDBPath, tableName string) (err error) {
+	op := d.logOperation("import_parquet", "object", parquetObjectName, "table", tableName)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	parquetPath, err := tempPath("duckdb-nats-*.parquet")
+	if err != nil {
+		return err
+	}
+	defer removeTemp(parquetPath)
+
+	if err := d.getFile(ctx, d.objectKey(parquetObjectName), parquetPath); err != nil {
+		return err
+	}
+
+	db, err := openDuckDB(targetDBPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := loadIntoTable(ctx, conn, tableName, "read_parquet("+quoteLiteral(parquetPath)+")"); err != nil {
+		return err
+	}
+
+	conn.Close()
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return d.StoreDuckDB(targetDBPath)
+}
+
+// loadIntoTable creates tableName from source, or appends to it if the table already exists.
+// It returns the number of rows loaded.
+func loadIntoTable(ctx context.Context, conn *sql.Conn, tableName, source string) (int64, error) {
+	exists, err := tableExists(ctx, conn, tableName)
+	if err != nil {
+		return 0, err
+	}
+
+	query := fmt.Sprintf("CREATE TABLE %s AS SELECT * FROM %s", quoteIdent(tableName), source)
+	if exists {
+		query = fmt.Sprintf("INSERT INTO %s BY NAME SELECT * FROM %s", quoteIdent(tableName), source)
+	}
+
+	result, err := conn.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load table %s: %w", tableName, err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read loaded row count: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParquetRoundTrip(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx := context.Background()
+
+	if err := s.ExportTableToParquet(ctx, path, "users", "users.parquet"); err != nil {
+		t.Fatalf("ExportTableToParquet: %v", err)
+	}
+	info, err := s.obs.GetInfo("users.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Headers.Get(sourceTableHeader); got != "users" {
+		t.Errorf("source table header = %q", got)
+	}
+
+	target := filepath.Join(t.TempDir(), "target.db")
+	createTestDatabase(t, target)
+	if err := s.ImportParquetToTable(ctx, "users.parquet", target, "users_copy"); err != nil {
+		t.Fatalf("ImportParquetToTable: %v", err)
+	}
+
+	want := queryTestStrings(t, path, "SELECT id || ':' || name || ':' || created_at FROM users ORDER BY id")
+	got := queryTestStrings(t, target, "SELECT id || ':' || name || ':' || created_at FROM users_copy ORDER BY id")
+	if !slices.Equal(got, want) {
+		t.Errorf("imported rows = %v, want %v", got, want)
+	}
+
+	// The updated database was stored and a second import appends
+	if err := s.ImportParquetToTable(ctx, "users.parquet", target, "users_copy"); err != nil {
+		t.Fatalf("second ImportParquetToTable: %v", err)
+	}
+	var n int
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM users_copy").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Errorf("stored table has %d rows, want 6", n)
+	}
+}
+
+func TestImportParquetMissing(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	if err := s.ImportParquetToTable(context.Background(), "missing.parquet", path, "t"); err == nil {
+		t.Error("ImportParquetToTable accepted a missing object")
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// hiveDefaultPartition names the partition of NULL values, as in Hive-style layouts
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+// partitionPrefix is the object name prefix of the partitions of a table by a column
+func partitionPrefix(tableName, partitionColumn string) string {
+	return tableName + "/" + partitionColumn + "="
+}
+
+// ExportParquetPartitioned writes one Parquet object per distinct value of partitionColumn of a
+// table of the stored database, named tableName/partitionColumn=<value>.parquet. Values are
+// path-escaped and NULL is written as __HIVE_DEFAULT_PARTITION__. It returns the names of the
+// stored objects.
+func (d *DuckDBStorage) ExportParquetPartitioned(ctx context.Context, tableName, partitionColumn string) (names []string, err error) {
+	op := d.logOperation("export_parquet_partitioned", "table", tableName, "column", partitionColumn)
+	defer func() { op.done(err, "partitions", len(names)) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return names, err
+	}
+	defer func() { end(err) }()
+
+	dbPath, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer removeTempDatabase(dbPath)
+
+	db, err := openDuckDB(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf("SELECT DISTINCT %s FROM %s",
+		quoteIdent(partitionColumn), quoteIdent(tableName)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partitions of %s: %w", tableName, err)
+	}
+	var values []any
+	for rows.Next() {
+		var value any
+		if err := rows.Scan(&value); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read partitions of %s: %w", tableName, err)
+		}
+		values = append(values, value)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read partitions of %s: %w", tableName, err)
+	}
+
+	parquetPath, err := tempPath("duckdb-nats-*.parquet")
+	if err != nil {
+		return nil, err
+	}
+	defer removeTemp(parquetPath)
+
+	for _, value := range values {
+		name := partitionPrefix(tableName, partitionColumn) + partitionValue(value) + ".parquet"
+
+		query := fmt.Sprintf("COPY (SELECT * FROM %s WHERE %s IS NOT DISTINCT FROM ?) TO %s (FORMAT PARQUET)",
+			quoteIdent(tableName), quoteIdent(partitionColumn), quoteLiteral(parquetPath))
+		if _, err := db.ExecContext(ctx, query, value); err != nil {
+			return names, fmt.Errorf("failed to export partition %s: %w", name, err)
+		}
+
+		_, err = d.putFile(ctx, d.objectKey(name), parquetPath, "Parquet partition of "+tableName, nats.Header{
+			"Content-Type":    []string{"application/x-parquet"},
+			sourceTableHeader: []string{tableName},
+		})
+		if err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// ListPartitions returns the names of the partition objects stored by ExportParquetPartitioned
+// for a table and column, in name order
+func (d *DuckDBStorage) ListPartitions(tableName, partitionColumn string) (_ []string, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	objects, err := d.obs.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	prefix := partitionPrefix(tableName, partitionColumn)
+	var names []string
+	for _, info := range objects {
+		name, ok := d.logicalName(info.Name)
+		if ok && strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".parquet") {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// partitionValue renders a partition value for use in an object name
+func partitionValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return hiveDefaultPartition
+	case time.Time:
+		return url.PathEscape(v.Format(time.RFC3339Nano))
+	case []byte:
+		return url.PathEscape(string(v))
+	default:
+		return url.PathEscape(fmt.Sprint(v))
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPartitionValue(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{nil, hiveDefaultPartition},
+		{"eu", "eu"},
+		{"a/p", "a%2Fp"},
+		{int64(7), "7"},
+		{[]byte("raw"), "raw"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), "2024-01-02T03:04:05Z"},
+	}
+	for _, tt := range tests {
+		if got := partitionValue(tt.value); got != tt.want {
+			t.Errorf("partitionValue(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestExportParquetPartitioned(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "sales.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE sales AS SELECT i AS id, ['eu', 'us', 'a/p'][i % 3 + 1] AS region, i % 2 AS parity FROM range(30) r(i)")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+
+	names, err := s.ExportParquetPartitioned(ctx, "sales", "region")
+	if err != nil {
+		t.Fatalf("ExportParquetPartitioned: %v", err)
+	}
+	want := []string{"sales/region=a%2Fp.parquet", "sales/region=eu.parquet", "sales/region=us.parquet"}
+	slices.Sort(names)
+	if !slices.Equal(names, want) {
+		t.Errorf("exported %v, want %v", names, want)
+	}
+
+	// Partitions of another column are not listed
+	if _, err := s.ExportParquetPartitioned(ctx, "sales", "parity"); err != nil {
+		t.Fatalf("ExportParquetPartitioned by parity: %v", err)
+	}
+	listed, err := s.ListPartitions("sales", "region")
+	if err != nil {
+		t.Fatalf("ListPartitions: %v", err)
+	}
+	if !slices.Equal(listed, want) {
+		t.Errorf("listed %v, want %v", listed, want)
+	}
+
+	target := filepath.Join(t.TempDir(), "target.db")
+	createTestDatabase(t, target)
+	if err := s.ImportParquetToTable(ctx, "sales/region=a%2Fp.parquet", target, "ap"); err != nil {
+		t.Fatalf("ImportParquetToTable: %v", err)
+	}
+	var n, regions int
+	if err := s.QueryRow(ctx, "SELECT count(*), count(DISTINCT region) FROM ap WHERE region = 'a/p'").Scan(&n, &regions); err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 || regions != 1 {
+		t.Errorf("partition a/p holds %d rows in %d regions, want 10 in 1", n, regions)
+	}
+}
+
+func TestExportParquetPartitionedNull(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "sales.db")
+	execTestDatabase(t, path, "CREATE TABLE sales AS SELECT 1 AS id, NULL::VARCHAR AS region")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	names, err := s.ExportParquetPartitioned(ctx, "sales", "region")
+	if err != nil {
+		t.Fatalf("ExportParquetPartitioned: %v", err)
+	}
+	if want := []string{"sales/region=" + hiveDefaultPartition + ".parquet"}; !slices.Equal(names, want) {
+		t.Errorf("exported %v, want %v", names, want)
+	}
+}
+
+func TestListPartitionsEmpty(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	if names, err := s.ListPartitions("sales", "region"); err != nil || len(names) != 0 {
+		t.Errorf("ListPartitions of an empty bucket = %v, %v", names, err)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/nats-io/nats.go"
+)
+
+// pinnedHeader marks an object as protected from deletion
+const pinnedHeader = "X-Pinned"
+
+// ErrDatabasePinned is returned when deleting a pinned database without WithForce
+var ErrDatabasePinned = errors.New("database is pinned")
+
+// deleteOptions controls a single delete or purge call
+type deleteOptions struct {
+	force  bool
+	prefix string
+	dryRun bool
+}
+
+// DeleteOption configures DeleteDatabase and SoftDelete
+type DeleteOption func(*deleteOptions)
+
+// WithForce deletes the database even if it is pinned
+func WithForce(force bool) DeleteOption {
+	return func(o *deleteOptions) {
+		o.force = force
+	}
+}
+
+func (d *DuckDBStorage) pinBucket() string {
+	return d.bucket + "-pins"
+}
+
+// Pin protects the named database from DeleteDatabase and SoftDelete. The object is marked
+// first, so a database that does not exist is never recorded as pinned.
+func (d *DuckDBStorage) Pin(name string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	key := d.objectKey(name)
+
+	kv, err := d.keyValue(d.pinBucket())
+	if err != nil {
+		return err
+	}
+	if err := d.setPinnedHeader(key, true); err != nil {
+		return err
+	}
+	if _, err := kv.Put(key, []byte("true")); err != nil {
+		d.setPinnedHeader(key, false)
+		return fmt.Errorf("failed to record pin for %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// Unpin removes the protection added by Pin
+func (d *DuckDBStorage) Unpin(name string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	key := d.objectKey(name)
+
+	kv, err := d.keyValue(d.pinBucket())
+	if err != nil {
+		return err
+	}
+	if err := kv.Delete(key); err != nil {
+		return fmt.Errorf("failed to remove pin for %s: %w", name, err)
+	}
+
+	return d.setPinnedHeader(key, false)
+}
+
+// ListPinned returns the object names of every pinned database in the bucket
+func (d *DuckDBStorage) ListPinned() (_ []string, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	kv, err := d.keyValue(d.pinBucket())
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pins: %w", err)
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+// checkPinned returns ErrDatabasePinned if the object is pinned and force is not set
+func (d *DuckDBStorage) checkPinned(key string, opts []DeleteOption) error {
+	var options deleteOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.force {
+		return nil
+	}
+
+	kv, err := d.keyValue(d.pinBucket())
+	if err != nil {
+		return err
+	}
+	_, err = kv.Get(key)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check pin for %s: %w", key, err)
+	}
+	return fmt.Errorf("%w: %s", ErrDatabasePinned, key)
+}
+
+// setPinnedHeader sets or clears the pinned header on the object metadata
+func (d *DuckDBStorage) setPinnedHeader(key string, pinned bool) error {
+	info, err := d.obs.GetInfo(key)
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", key, err)
+	}
+
+	meta := info.ObjectMeta
+	meta.Headers = cloneHeader(info.Headers)
+	if pinned {
+		meta.Headers.Set(pinnedHeader, "true")
+	} else {
+		meta.Headers.Del(pinnedHeader)
+	}
+	if err := d.obs.UpdateMeta(key, &meta); err != nil {
+		return fmt.Errorf("failed to update %s: %w", key, err)
+	}
+	return nil
+}
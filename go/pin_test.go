@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPinPreventsDeletion(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	if err := s.Pin(s.dbName); err != nil {
+		t.Fatalf("Pin: %v", err)
+	}
+
+	if err := s.DeleteDatabase(); !errors.Is(err, ErrDatabasePinned) {
+		t.Fatalf("DeleteDatabase of a pinned database: got %v, want ErrDatabasePinned", err)
+	}
+	if err := s.SoftDelete(s.dbName, time.Hour); !errors.Is(err, ErrDatabasePinned) {
+		t.Fatalf("SoftDelete of a pinned database: got %v, want ErrDatabasePinned", err)
+	}
+	pinned, err := s.ListPinned()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(pinned, []string{s.dbName}) {
+		t.Errorf("ListPinned = %v", pinned)
+	}
+	info, err := s.GetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Headers.Get(pinnedHeader) != "true" {
+		t.Error("pinned database is not marked")
+	}
+
+	if err := s.Unpin(s.dbName); err != nil {
+		t.Fatalf("Unpin: %v", err)
+	}
+	if err := s.DeleteDatabase(); err != nil {
+		t.Fatalf("DeleteDatabase after Unpin: %v", err)
+	}
+	if _, err := s.GetInfo(); err == nil {
+		t.Error("database still exists after deletion")
+	}
+}
+
+func TestPinForceDelete(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	if err := s.Pin(s.dbName); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteDatabase(WithForce(true)); err != nil {
+		t.Fatalf("DeleteDatabase with force: %v", err)
+	}
+}
+
+func TestPinMissing(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	if err := s.Pin("missing.db"); err == nil {
+		t.Fatal("Pin of a missing database succeeded")
+	}
+	if pinned, err := s.ListPinned(); err != nil || len(pinned) != 0 {
+		t.Errorf("ListPinned = %v, %v, want nothing pinned", pinned, err)
+	}
+}
+
+func TestCopyOfPinnedIsUnpinned(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	if err := s.Pin(s.dbName); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CopyDatabase(s.dbName, "copy.db", false); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := s.obs.GetInfo(s.objectKey("copy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Headers.Get(pinnedHeader) != "" {
+		t.Error("copy carries the pinned header")
+	}
+	if err := s.checkPinned(s.objectKey("copy.db"), nil); err != nil {
+		t.Errorf("copy is pinned: %v", err)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestPipeRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc := startTestServer(t)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"compressed encrypted", []Option{WithCompression(CompressionZstd), WithEncryptionKey(bytes.Repeat([]byte{1}, 32))}},
+		{"chunked", []Option{WithChunkSize(100000)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStorage(t, nc, append(tt.opts, WithDBName(tt.name+".db"))...)
+			n, err := s.ReadFrom(bytes.NewReader(want))
+			if err != nil {
+				t.Fatalf("ReadFrom: %v", err)
+			}
+			if n != int64(len(want)) {
+				t.Errorf("ReadFrom read %d bytes, want %d", n, len(want))
+			}
+
+			var buf bytes.Buffer
+			n, err = s.WriteTo(&buf)
+			if err != nil {
+				t.Fatalf("WriteTo: %v", err)
+			}
+			if n != int64(len(want)) || !bytes.Equal(buf.Bytes(), want) {
+				t.Fatalf("WriteTo wrote %d bytes that do not match the stored %d", n, len(want))
+			}
+
+			// The stream is stored like a file, so it can be retrieved as one
+			out := filepath.Join(t.TempDir(), "out.db")
+			if err := s.RetrieveDuckDB(out); err != nil {
+				t.Fatalf("RetrieveDuckDB: %v", err)
+			}
+			if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != 3 {
+				t.Errorf("retrieved %d users, want 3", n)
+			}
+		})
+	}
+}
+
+func TestStoreReaderDeduplication(t *testing.T) {
+	s, path := storeTestDatabase(t, WithDeduplication(true))
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreReader(bytes.NewReader(data), int64(len(data))); !errors.Is(err, ErrUnchanged) {
+		t.Fatalf("StoreReader of the stored bytes: got %v, want ErrUnchanged", err)
+	}
+	info, err := s.GetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Headers.Get(checksumHeader) == "" {
+		t.Errorf("stored database has no %s header", checksumHeader)
+	}
+}
+
+func TestRetrieveToWriterCancelled(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	var buf bytes.Buffer
+	if _, err := s.RetrieveToWriter(ctx, &buf); !errors.Is(err, context.Canceled) {
+		t.Errorf("RetrieveToWriter with a cancelled context: got %v, want context.Canceled", err)
+	}
+}
+
+func TestStoreFromReaderPipe(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestStorage(t, startTestServer(t), WithCompression(CompressionZstd))
+	ctx := context.Background()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.Write(want)
+		pw.Close()
+	}()
+	headers := nats.Header{"X-Source": {"http"}, compressionHeader: {"bogus"}}
+	if err := s.StoreFromReader(ctx, pr, int64(len(want)), headers); err != nil {
+		t.Fatalf("StoreFromReader: %v", err)
+	}
+	info, err := s.GetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Headers.Get("X-Source"); got != "http" {
+		t.Errorf("X-Source header = %q, want http", got)
+	}
+	// Headers set by the storage take precedence over the caller's
+	if got := CompressionAlgorithm(info.Headers.Get(compressionHeader)); got != CompressionZstd {
+		t.Errorf("%s header = %q, want %q", compressionHeader, got, CompressionZstd)
+	}
+
+	pr, pw = io.Pipe()
+	read := make(chan []byte, 1)
+	go func() {
+		data, _ := io.ReadAll(pr)
+		read <- data
+	}()
+	n, err := s.RetrieveToWriter(ctx, pw)
+	pw.Close()
+	if err != nil {
+		t.Fatalf("RetrieveToWriter: %v", err)
+	}
+	if got := <-read; n != int64(len(want)) || !bytes.Equal(got, want) {
+		t.Errorf("RetrieveToWriter piped %d bytes that do not match the stored %d", n, len(want))
+	}
+}
+
+func TestStoreFromReaderBlockedRead(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go pw.Write(make([]byte, 100))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.StoreFromReader(ctx, pr, -1, nil) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("StoreFromReader of a stalled stream: got %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("StoreFromReader blocked on the stalled stream past its deadline")
+	}
+	if rev, err := s.CurrentRevision(); err != nil || rev != 0 {
+		t.Errorf("revision after the cancelled store = %d, %v, want nothing stored", rev, err)
+	}
+}
+
+func TestRetrieveToWriterBlockedWrite(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	pr, pw := io.Pipe()
+	defer pr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.RetrieveToWriter(ctx, pw)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("RetrieveToWriter to a stalled writer: got %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("RetrieveToWriter blocked on the stalled writer past its deadline")
+	}
+}
+
+func TestStoreFromReaderUnknownSizeQuota(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithStorageQuota(64*1024))
+	ctx := context.Background()
+	if err := s.StoreFromReader(ctx, strings.NewReader("small database"), -1, nil); err != nil {
+		t.Fatalf("StoreFromReader within the quota: %v", err)
+	}
+
+	large := io.LimitReader(zeroReader{}, 1<<20)
+	if err := s.StoreFromReader(ctx, large, -1, nil); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("StoreFromReader of an unknown-size stream over the quota: got %v, want ErrQuotaExceeded", err)
+	}
+	if _, err := s.ReadFrom(io.LimitReader(zeroReader{}, 1<<20)); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("ReadFrom over the quota: got %v, want ErrQuotaExceeded", err)
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.RetrieveToWriter(ctx, &buf); err != nil {
+		t.Fatalf("RetrieveToWriter: %v", err)
+	}
+	if got := buf.String(); got != "small database" {
+		t.Errorf("stored database = %q, want the one stored within the quota", got)
+	}
+}
+
+// zeroReader returns an endless stream of zero bytes
+type zeroReader struct{}
+
+func (zeroReader) Read(b []byte) (int, error) {
+	clear(b)
+	return len(b), nil
+}
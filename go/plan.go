@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// CacheStats reports the use of a cache
+type CacheStats struct {
+	Hits   int
+	Misses int
+	Size   int
+}
+
+// planKey identifies a cached plan, plans are only reused for the revision they were made on
+type planKey struct {
+	revision uint64
+	query    string
+}
+
+// planCache holds the plans returned by QueryPlan
+type planCache struct {
+	plans  sync.Map
+	hits   atomic.Int64
+	misses atomic.Int64
+	size   atomic.Int64
+}
+
+// QueryPlan returns the plan DuckDB's EXPLAIN reports for query on the stored database. Plans
+// are cached per revision, so repeating a query, up to whitespace, while the database is
+// unchanged does not download it again.
+func (d *DuckDBStorage) QueryPlan(ctx context.Context, query string) (plan string, err error) {
+	op := d.logOperation("query_plan", "query", query)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return plan, err
+	}
+	defer func() { end(err) }()
+
+	revision, err := d.CurrentRevision()
+	if err != nil {
+		return "", err
+	}
+	if revision == 0 {
+		return "", fmt.Errorf("%w: %s", ErrObjectNotFound, d.dbName)
+	}
+
+	key := planKey{revision: revision, query: normalizeQuery(query)}
+	if cached, ok := d.plans.plans.Load(key); ok {
+		d.plans.hits.Add(1)
+		return cached.(string), nil
+	}
+	d.plans.misses.Add(1)
+
+	plan, err = d.explain(ctx, query)
+	if err != nil {
+		return "", err
+	}
+	if _, loaded := d.plans.plans.LoadOrStore(key, plan); !loaded {
+		d.plans.size.Add(1)
+		d.plans.evict(revision)
+	}
+	return plan, nil
+}
+
+// evict drops the plans made on revisions other than revision, which no longer return them
+func (c *planCache) evict(revision uint64) {
+	c.plans.Range(func(key, _ any) bool {
+		if key.(planKey).revision != revision {
+			if _, loaded := c.plans.LoadAndDelete(key); loaded {
+				c.size.Add(-1)
+			}
+		}
+		return true
+	})
+}
+
+// PlanCacheStats returns the hits and misses of QueryPlan and the number of cached plans
+func (d *DuckDBStorage) PlanCacheStats() CacheStats {
+	return CacheStats{
+		Hits:   int(d.plans.hits.Load()),
+		Misses: int(d.plans.misses.Load()),
+		Size:   int(d.plans.size.Load()),
+	}
+}
+
+// FlushPlanCache drops every plan cached by QueryPlan
+func (d *DuckDBStorage) FlushPlanCache() {
+	d.plans.plans.Range(func(key, _ any) bool {
+		if _, loaded := d.plans.plans.LoadAndDelete(key); loaded {
+			d.plans.size.Add(-1)
+		}
+		return true
+	})
+}
+
+// explain retrieves the database and returns the physical plan of query, filtered like
+// QueryRows would run it
+func (d *DuckDBStorage) explain(ctx context.Context, query string) (string, error) {
+	path, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer removeTempDatabase(path)
+
+	if err := d.filterDatabase(ctx, path, query); err != nil {
+		return "", err
+	}
+	db, err := openDuckDB(path)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+	rows, err := db.QueryContext(ctx, "EXPLAIN "+query)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	var plan strings.Builder
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return "", fmt.Errorf("failed to read query plan: %w", err)
+		}
+		plan.WriteString(value)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read query plan: %w", err)
+	}
+	return plan.String(), nil
+}
+
+// normalizeQuery collapses whitespace outside string literals and quoted identifiers and drops
+// a trailing semicolon so equivalent spellings of a query share a cache entry
+func normalizeQuery(query string) string {
+	var b strings.Builder
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case strings.IndexByte(" \t\r\n\f\v", c) >= 0:
+			space = true
+			continue
+		case space && b.Len() > 0:
+			b.WriteByte(' ')
+		}
+		space = false
+		if c == '\'' || c == '"' {
+			end := quoteEnd(query, i)
+			b.WriteString(query[i : end+1])
+			i = end
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return strings.TrimSpace(strings.TrimSuffix(b.String(), ";"))
+}
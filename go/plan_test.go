@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestNormalizeQuery(t *testing.T) {
+	tests := []struct {
+		query, want string
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"  SELECT *\n\tFROM users ;", "SELECT * FROM users"},
+		{"SELECT 1;", "SELECT 1"},
+		{"SELECT  'a  b'\n FROM  t", "SELECT 'a  b' FROM t"},
+		{"SELECT 'a\n\tb';", "SELECT 'a\n\tb'"},
+		{`SELECT "my  col" FROM t WHERE name = 'it''s  here'`, `SELECT "my  col" FROM t WHERE name = 'it''s  here'`},
+		{"SELECT 'open  literal", "SELECT 'open  literal"},
+	}
+	for _, tt := range tests {
+		if got := normalizeQuery(tt.query); got != tt.want {
+			t.Errorf("normalizeQuery(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQueryPlanCache(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	var gets atomic.Int32
+	s.obs = countingObjectStore{s.obs, &gets}
+	ctx := context.Background()
+
+	first, err := s.QueryPlan(ctx, "SELECT * FROM users WHERE id > 1")
+	if err != nil {
+		t.Fatalf("QueryPlan: %v", err)
+	}
+	if !strings.Contains(first, "users") {
+		t.Errorf("plan does not scan users:\n%s", first)
+	}
+	second, err := s.QueryPlan(ctx, "SELECT *  FROM users\n WHERE id > 1 ;")
+	if err != nil {
+		t.Fatalf("second QueryPlan: %v", err)
+	}
+	if second != first {
+		t.Errorf("cached plan differs:\n%s\nwant\n%s", second, first)
+	}
+	if got, want := s.PlanCacheStats(), (CacheStats{Hits: 1, Misses: 1, Size: 1}); got != want {
+		t.Errorf("PlanCacheStats() = %+v, want %+v", got, want)
+	}
+	downloads := gets.Load()
+	if downloads != 1 {
+		t.Errorf("database downloaded %d times for a repeated query, want once", downloads)
+	}
+
+	// A new revision is planned again
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	if _, err := s.QueryPlan(ctx, "SELECT * FROM users WHERE id > 1"); err != nil {
+		t.Fatalf("QueryPlan on the new revision: %v", err)
+	}
+	// and the plans of the previous revision are evicted
+	if got, want := s.PlanCacheStats(), (CacheStats{Hits: 1, Misses: 2, Size: 1}); got != want {
+		t.Errorf("PlanCacheStats() after a store = %+v, want %+v", got, want)
+	}
+	if gets.Load() == downloads {
+		t.Error("new revision planned without downloading it")
+	}
+
+	// Literals that differ only in whitespace are different queries
+	if _, err := s.QueryPlan(ctx, "SELECT * FROM users WHERE name = 'a  b'"); err != nil {
+		t.Fatalf("QueryPlan: %v", err)
+	}
+	if _, err := s.QueryPlan(ctx, "SELECT * FROM users WHERE name = 'a b'"); err != nil {
+		t.Fatalf("QueryPlan: %v", err)
+	}
+	if got, want := s.PlanCacheStats(), (CacheStats{Hits: 1, Misses: 4, Size: 3}); got != want {
+		t.Errorf("PlanCacheStats() after two literals = %+v, want %+v", got, want)
+	}
+
+	s.FlushPlanCache()
+	if got := s.PlanCacheStats(); got.Size != 0 {
+		t.Errorf("%d plans cached after FlushPlanCache", got.Size)
+	}
+}
+
+func TestQueryPlanErrors(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	if _, err := s.QueryPlan(context.Background(), "SELECT 1"); err == nil {
+		t.Error("QueryPlan succeeded without a stored database")
+	}
+
+	s, _ = storeTestDatabase(t)
+	if _, err := s.QueryPlan(context.Background(), "SELECT * FROM missing"); err == nil {
+		t.Error("QueryPlan planned a query on a missing table")
+	}
+	if got := s.PlanCacheStats(); got.Size != 0 {
+		t.Errorf("failed plan cached: %+v", got)
+	}
+}
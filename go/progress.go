@@ -0,0 +1,35 @@
+package main
+
+import "io"
+
+// defaultProgressInterval is how many bytes pass between progress callbacks of a single upload
+const defaultProgressInterval = 1 << 20
+
+// progressReader reports the bytes read from r every interval bytes and once r is exhausted
+type progressReader struct {
+	r        io.Reader
+	fn       ProgressFunc
+	total    int64
+	interval int64
+
+	read     int64
+	reported int64
+}
+
+// newProgressReader wraps r to report progress to fn, returning r unchanged when fn is nil
+func newProgressReader(r io.Reader, total, interval int64, fn ProgressFunc) io.Reader {
+	if fn == nil {
+		return r
+	}
+	return &progressReader{r: r, fn: fn, total: total, interval: max(interval, 1)}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if p.read-p.reported >= p.interval || (err == io.EOF && p.read > p.reported) {
+		p.reported = p.read
+		p.fn(p.read, p.total)
+	}
+	return n, err
+}
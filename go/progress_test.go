@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+// progressEvent is one progress callback
+type progressEvent struct {
+	done, total int64
+}
+
+// recordProgress returns a callback appending to events
+func recordProgress(events *[]progressEvent) ProgressFunc {
+	return func(done, total int64) {
+		*events = append(*events, progressEvent{done, total})
+	}
+}
+
+// checkProgress fails unless events increase and end with total bytes done
+func checkProgress(t *testing.T, events []progressEvent, total int64) {
+	t.Helper()
+	if len(events) < 2 {
+		t.Fatalf("got %d progress events, want several", len(events))
+	}
+	for i, event := range events {
+		if event.total != total {
+			t.Errorf("event %d total = %d, want %d", i, event.total, total)
+		}
+		if i > 0 && event.done <= events[i-1].done {
+			t.Errorf("progress went from %d to %d", events[i-1].done, event.done)
+		}
+	}
+	if last := events[len(events)-1]; last.done != total {
+		t.Errorf("final progress = %d, want %d", last.done, total)
+	}
+}
+
+func TestProgressReader(t *testing.T) {
+	data := make([]byte, 1000)
+	var events []progressEvent
+	r := newProgressReader(iotest.OneByteReader(bytes.NewReader(data)), int64(len(data)), 300, recordProgress(&events))
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+	want := []progressEvent{{300, 1000}, {600, 1000}, {900, 1000}, {1000, 1000}}
+	if len(events) != len(want) {
+		t.Fatalf("events = %v, want %v", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %v, want %v", i, events[i], want[i])
+		}
+	}
+
+	plain := bytes.NewReader(data)
+	if newProgressReader(plain, 0, 300, nil) != io.Reader(plain) {
+		t.Error("newProgressReader wrapped a reader without a callback")
+	}
+}
+
+func TestStoreProgress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nc := startTestServer(t)
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{"single", []Option{WithProgressInterval(100000)}},
+		{"compressed", []Option{WithProgressInterval(100000), WithCompression(CompressionZstd)}},
+		{"chunked", []Option{WithChunkSize(fi.Size() / 4)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []progressEvent
+			opts := append(tt.opts, WithDBName(tt.name+".db"), WithProgressCallback(recordProgress(&events)))
+			s := newTestStorage(t, nc, opts...)
+			if err := s.StoreDuckDB(path); err != nil {
+				t.Fatalf("StoreDuckDB: %v", err)
+			}
+			checkProgress(t, events, fi.Size())
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// PurgeOption configures PurgeBucket. It is the same type as DeleteOption, so WithForce also
+// lets PurgeBucket delete pinned databases; the purge options are ignored by other deletes.
+type PurgeOption = DeleteOption
+
+// WithPurgePrefix restricts PurgeBucket to objects whose name starts with prefix
+func WithPurgePrefix(prefix string) PurgeOption {
+	return func(o *deleteOptions) {
+		o.prefix = prefix
+	}
+}
+
+// WithPurgeDryRun makes PurgeBucket report what it would delete without deleting anything
+func WithPurgeDryRun(dryRun bool) PurgeOption {
+	return func(o *deleteOptions) {
+		o.dryRun = dryRun
+	}
+}
+
+// PurgeReport lists the objects removed by PurgeBucket, or the objects that would be removed
+// in a dry run
+type PurgeReport struct {
+	Deleted []string
+	DryRun  bool
+}
+
+// PurgeBucket deletes every object of the namespace, including chunks, versions and other
+// internal objects, optionally limited to a name prefix. It refuses to run if a pinned
+// database is in scope unless WithForce is passed. It returns the number of objects deleted.
+func (d *DuckDBStorage) PurgeBucket(ctx context.Context, opts ...PurgeOption) (deleted int, report PurgeReport, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return deleted, report, err
+	}
+	defer func() { end(err) }()
+
+	var options deleteOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	report.DryRun = options.dryRun
+
+	op := d.logOperation("purge_bucket", "prefix", options.prefix, "dry_run", options.dryRun)
+	defer func() { op.done(err, "deleted", deleted) }()
+
+	objects, err := d.obs.List(nats.Context(ctx))
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return 0, report, nil
+	}
+	if err != nil {
+		return 0, report, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var keys []string
+	for _, info := range objects {
+		name, ok := d.logicalName(info.Name)
+		if ok && strings.HasPrefix(name, options.prefix) {
+			keys = append(keys, info.Name)
+		}
+	}
+	slices.Sort(keys)
+
+	if !options.force {
+		pinned, err := d.ListPinned()
+		if err != nil {
+			return 0, report, err
+		}
+		for _, key := range keys {
+			if slices.Contains(pinned, key) {
+				return 0, report, fmt.Errorf("%w: %s", ErrDatabasePinned, key)
+			}
+		}
+	}
+
+	for _, key := range keys {
+		if !options.dryRun {
+			if err := ctx.Err(); err != nil {
+				return deleted, report, err
+			}
+			if err := d.obs.Delete(key); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+				return deleted, report, fmt.Errorf("failed to delete %s: %w", key, err)
+			}
+			deleted++
+		}
+		report.Deleted = append(report.Deleted, key)
+	}
+
+	return deleted, report, nil
+}
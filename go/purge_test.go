@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// objectNames returns the sorted names of every object in the bucket of s
+func objectNames(t *testing.T, s *DuckDBStorage) []string {
+	t.Helper()
+	objects, err := s.obs.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range objects {
+		names = append(names, info.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestPurgeBucket(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	for _, name := range []string{"logs-a.db", "logs-b.db", "keep-1.db", "keep-2.db"} {
+		if err := s.CopyDatabase(s.dbName, name, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := context.Background()
+
+	deleted, report, err := s.PurgeBucket(ctx, WithPurgePrefix("logs-"), WithPurgeDryRun(true))
+	if err != nil {
+		t.Fatalf("PurgeBucket dry run: %v", err)
+	}
+	want := []string{"logs-a.db", "logs-b.db"}
+	if deleted != 0 || !report.DryRun || !slices.Equal(report.Deleted, want) {
+		t.Errorf("dry run = %d, %+v, want 0 deleted and %v listed", deleted, report, want)
+	}
+	if got := objectNames(t, s); len(got) != 5 {
+		t.Fatalf("objects after the dry run = %v, want all 5", got)
+	}
+
+	if err := s.Pin("logs-a.db"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.PurgeBucket(ctx, WithPurgePrefix("logs-")); !errors.Is(err, ErrDatabasePinned) {
+		t.Fatalf("PurgeBucket with a pinned database: got %v, want ErrDatabasePinned", err)
+	}
+	// Pins outside the prefix do not block the purge
+	if _, _, err := s.PurgeBucket(ctx, WithPurgePrefix("logs-b"), WithPurgeDryRun(true)); err != nil {
+		t.Fatalf("PurgeBucket of an unpinned prefix: %v", err)
+	}
+
+	deleted, report, err = s.PurgeBucket(ctx, WithPurgePrefix("logs-"), WithForce(true))
+	if err != nil {
+		t.Fatalf("PurgeBucket with force: %v", err)
+	}
+	if deleted != 2 || report.DryRun || !slices.Equal(report.Deleted, want) {
+		t.Errorf("purge = %d, %+v, want %v deleted", deleted, report, want)
+	}
+	if got, want := objectNames(t, s), []string{"duckdb.db", "keep-1.db", "keep-2.db"}; !slices.Equal(got, want) {
+		t.Errorf("objects after the purge = %v, want %v", got, want)
+	}
+}
+
+func TestPurgeBucketNamespace(t *testing.T) {
+	nc := startTestServer(t)
+	a := newTestStorage(t, nc, WithNamespace("a"))
+	b := newTestStorage(t, nc, WithNamespace("b"))
+	for _, s := range []*DuckDBStorage{a, b} {
+		if _, err := s.obs.PutString(s.objectKey("one.db"), "data"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, _, err := a.PurgeBucket(context.Background())
+	if err != nil {
+		t.Fatalf("PurgeBucket: %v", err)
+	}
+	if deleted != 1 {
+		t.Errorf("PurgeBucket deleted %d objects, want only the namespace one", deleted)
+	}
+	if got, want := objectNames(t, b), []string{"b/one.db"}; !slices.Equal(got, want) {
+		t.Errorf("objects after purging namespace a = %v, want %v", got, want)
+	}
+}
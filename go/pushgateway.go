@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/push"
+)
+
+// pushTimeout bounds a single push to the Pushgateway
+const pushTimeout = 10 * time.Second
+
+// ErrPushgatewayDisabled is returned by PushMetricsNow when WithPushgateway was not used
+var ErrPushgatewayDisabled = errors.New("pushgateway not configured")
+
+// newPusher creates the Pushgateway client for the storage metrics, grouped by job, host name
+// and database name
+func newPusher(options StorageOptions, metrics *Metrics) *push.Pusher {
+	instance, err := os.Hostname()
+	if err != nil {
+		instance = "unknown"
+	}
+	return push.New(options.PushgatewayURL, options.PushgatewayJob).
+		Collector(metrics).
+		Grouping("instance", instance).
+		Grouping("db_name", options.DBName)
+}
+
+// PushMetricsNow pushes the current storage metrics to the configured Pushgateway
+func (d *DuckDBStorage) PushMetricsNow() error {
+	if d.pusher == nil {
+		return ErrPushgatewayDisabled
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pushTimeout)
+	defer cancel()
+	if err := d.pusher.PushContext(ctx); err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	return nil
+}
+
+// startPushing pushes the metrics every PushInterval until the storage is closed, with a final
+// push so the last operations are not lost
+func (d *DuckDBStorage) startPushing() error {
+	return d.goBackground(context.Background(), func(ctx context.Context) {
+		ticker := time.NewTicker(d.opts.PushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				if err := d.PushMetricsNow(); err != nil {
+					d.opts.Logger.Error("final metrics push failed", "db", d.dbName, "error", err)
+				}
+				return
+			case <-ticker.C:
+				if err := d.PushMetricsNow(); err != nil {
+					d.opts.Logger.Error("metrics push failed", "db", d.dbName, "error", err)
+				}
+			}
+		}
+	})
+}
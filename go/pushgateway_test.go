@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// pushRequest is a request received by the fake Pushgateway
+type pushRequest struct {
+	method, path string
+	body         string
+}
+
+// pushLabels returns the grouping labels encoded in a Pushgateway push path
+func pushLabels(path string) map[string]string {
+	parts := strings.Split(strings.TrimPrefix(path, "/metrics/"), "/")
+	labels := make(map[string]string, len(parts)/2)
+	for i := 0; i+1 < len(parts); i += 2 {
+		labels[parts[i]] = parts[i+1]
+	}
+	return labels
+}
+
+// startTestPushgateway starts an HTTP server recording the pushes it receives
+func startTestPushgateway(t *testing.T) (string, func() []pushRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []pushRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		requests = append(requests, pushRequest{r.Method, r.URL.Path, string(body)})
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, func() []pushRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]pushRequest(nil), requests...)
+	}
+}
+
+func TestPushMetricsNow(t *testing.T) {
+	url, requests := startTestPushgateway(t)
+	// The interval is long enough that only explicit pushes happen during the test
+	s, _ := storeTestDatabase(t, WithPushgateway(url, "storage", time.Hour))
+	if err := s.PushMetricsNow(); err != nil {
+		t.Fatalf("PushMetricsNow: %v", err)
+	}
+
+	pushed := requests()
+	if len(pushed) != 1 {
+		t.Fatalf("Pushgateway received %d requests, want 1", len(pushed))
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The client orders grouping labels arbitrarily
+	want := map[string]string{"job": "storage", "instance": host, "db_name": defaultDBName}
+	if got := pushLabels(pushed[0].path); !reflect.DeepEqual(got, want) {
+		t.Errorf("push path %s groups by %v, want %v", pushed[0].path, got, want)
+	}
+	if pushed[0].method != http.MethodPut {
+		t.Errorf("push method = %s, want PUT", pushed[0].method)
+	}
+	if !strings.Contains(pushed[0].body, "duckdb_nats_store_duration_seconds") {
+		t.Error("push does not hold the store duration of the stored database")
+	}
+
+	// Close pushes a final time and stops the background pushes
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if n := len(requests()); n != 2 {
+		t.Errorf("Pushgateway received %d requests after Close, want 2", n)
+	}
+}
+
+func TestPushgatewayInterval(t *testing.T) {
+	url, requests := startTestPushgateway(t)
+	s := newTestStorage(t, startTestServer(t), WithPushgateway(url, "storage", 20*time.Millisecond))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(requests()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Pushgateway received %d periodic pushes, want 2", len(requests()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	closed := len(requests())
+	time.Sleep(60 * time.Millisecond)
+	if n := len(requests()); n != closed {
+		t.Errorf("%d pushes after Close", n-closed)
+	}
+}
+
+func TestPushMetricsDisabled(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	if err := s.PushMetricsNow(); !errors.Is(err, ErrPushgatewayDisabled) {
+		t.Errorf("PushMetricsNow without a Pushgateway: got %v, want ErrPushgatewayDisabled", err)
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+// Rows wraps the result of a query against temporary copies of stored databases.
+// Closing it also closes the database and removes the temporary copies.
+type Rows struct {
+	*sql.Rows
+	db    *sql.DB
+	paths []string
+}
+
+// Close closes the rows and the underlying database, then removes the temporary copies
+func (r *Rows) Close() error {
+	err := r.Rows.Close()
+	if closeErr := r.db.Close(); err == nil {
+		err = closeErr
+	}
+	for _, path := range r.paths {
+		removeTempDatabase(path)
+	}
+	return err
+}
+
+// Row is the result of QueryRow
+type Row struct {
+	rows *Rows
+	err  error
+}
+
+// Scan copies the first result row into dest and releases the query resources
+func (r *Row) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	defer r.rows.Close()
+
+	if !r.rows.Next() {
+		if err := r.rows.Err(); err != nil {
+			return err
+		}
+		return sql.ErrNoRows
+	}
+	return r.rows.Scan(dest...)
+}
+
+// Err returns the error, if any, that was encountered while running the query
+func (r *Row) Err() error {
+	return r.err
+}
+
+// QueryRows runs a query against the stored database without the caller managing temp files
+func (d *DuckDBStorage) QueryRows(ctx context.Context, query string, args ...any) (_ *Rows, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	start := time.Now()
+	op := d.logOperation(opQuery, "query", query)
+	ctx, span := d.startSpan(ctx, spanQuery)
+	size := int64(-1)
+	defer func() {
+		endSpan(span, err)
+		d.metrics.observe(opQuery, start, err)
+		op.done(err, "size", size)
+		d.audit(opQuery, start, size, err)
+	}()
+
+	path, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	size = fileSize(path)
+	setSize(span, size)
+
+	if err := d.filterDatabase(ctx, path, query); err != nil {
+		removeTempDatabase(path)
+		return nil, err
+	}
+	db, err := openDuckDB(path)
+	if err != nil {
+		removeTempDatabase(path)
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		db.Close()
+		removeTempDatabase(path)
+		return nil, fmt.Errorf("failed to query database: %w", err)
+	}
+
+	return &Rows{Rows: rows, db: db, paths: []string{path}}, nil
+}
+
+// QueryRow runs a query that is expected to return at most one row
+func (d *DuckDBStorage) QueryRow(ctx context.Context, query string, args ...any) *Row {
+	rows, err := d.QueryRows(ctx, query, args...)
+	return &Row{rows: rows, err: err}
+}
+
+// retrieveTemp retrieves the database into a new file under os.TempDir
+func (d *DuckDBStorage) retrieveTemp(ctx context.Context) (string, error) {
+	path, err := tempPath("duckdb-nats-*.db")
+	if err != nil {
+		return "", err
+	}
+
+	if err := d.retrieve(ctx, path); err != nil {
+		removeTempDatabase(path)
+		return "", err
+	}
+
+	return path, nil
+}
+
+// removeTempDatabase removes a temporary database copy and its write-ahead log
+func removeTempDatabase(path string) {
+	os.Remove(path + ".wal")
+	removeTemp(path)
+}
+
+// openDuckDB opens the DuckDB database at path
+func openDuckDB(path string) (*sql.DB, error) {
+	connector, err := duckdb.NewConnector(path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// openDuckDBReadOnly opens the DuckDB database at path for untrusted queries: writes, access to
+// files and other databases and extension loading are refused, and the configuration is locked
+// so a query cannot lift the restrictions
+func openDuckDBReadOnly(path string) (*sql.DB, error) {
+	return openDuckDB(path + "?access_mode=READ_ONLY&enable_external_access=false&lock_configuration=true")
+}
+
+// queryRower is satisfied by *sql.DB, *sql.Conn and *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// tableExists reports whether the database has a table with the given name
+func tableExists(ctx context.Context, db queryRower, tableName string) (bool, error) {
+	var count int
+	err := db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM information_schema.tables WHERE table_name = ?", tableName,
+	).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up table %s: %w", tableName, err)
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// tempTestDatabases points os.TempDir at a new directory and returns a function listing the
+// temporary database copies in it
+func tempTestDatabases(t *testing.T) func() []string {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	return func() []string {
+		t.Helper()
+		paths, err := filepath.Glob(filepath.Join(dir, "duckdb-nats-*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return paths
+	}
+}
+
+func TestQueryRows(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	temps := tempTestDatabases(t)
+
+	rows, err := s.QueryRows(context.Background(), "SELECT name FROM users WHERE id > ? ORDER BY id", 1)
+	if err != nil {
+		t.Fatalf("QueryRows: %v", err)
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(names, []string{"Bob", "Charlie"}) {
+		t.Errorf("QueryRows returned %v, want Bob and Charlie", names)
+	}
+
+	if len(temps()) == 0 {
+		t.Fatal("no temporary copy while the rows are open")
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if left := temps(); len(left) != 0 {
+		t.Errorf("Close left %v behind", left)
+	}
+}
+
+func TestQueryRow(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	temps := tempTestDatabases(t)
+	ctx := context.Background()
+
+	var name string
+	if err := s.QueryRow(ctx, "SELECT name FROM users WHERE id = ?", 2).Scan(&name); err != nil {
+		t.Fatalf("QueryRow: %v", err)
+	}
+	if name != "Bob" {
+		t.Errorf("QueryRow returned %s, want Bob", name)
+	}
+	if err := s.QueryRow(ctx, "SELECT name FROM users WHERE id = ?", 9).Scan(&name); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("QueryRow without a result: got %v, want sql.ErrNoRows", err)
+	}
+	row := s.QueryRow(ctx, "SELECT name FROM missing")
+	if row.Err() == nil || row.Scan(&name) == nil {
+		t.Error("QueryRow on a missing table succeeded")
+	}
+	if left := temps(); len(left) != 0 {
+		t.Errorf("QueryRow left %v behind", left)
+	}
+}
+
+func TestQueryRowsCancelled(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	temps := tempTestDatabases(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.QueryRows(ctx, "SELECT * FROM users"); !errors.Is(err, context.Canceled) {
+		t.Errorf("QueryRows with a cancelled context: got %v, want context.Canceled", err)
+	}
+	var id int
+	if err := s.QueryRow(ctx, "SELECT id FROM users").Scan(&id); !errors.Is(err, context.Canceled) {
+		t.Errorf("QueryRow with a cancelled context: got %v, want context.Canceled", err)
+	}
+	if left := temps(); len(left) != 0 {
+		t.Errorf("cancelled queries left %v behind", left)
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/marcboeker/go-duckdb"
+)
+
+// kvKeyColumn is the column QueryFromKV stores the key of each entry in
+const kvKeyColumn = "_key"
+
+// QueryToKV runs a query against the stored database and puts every result row into the KV
+// bucket kvBucket, keyed by the value of keyColumn. The other columns are stored as a JSON
+// object. Keys must be valid NATS KV keys; a later row overwrites an earlier one with the
+// same key.
+func (d *DuckDBStorage) QueryToKV(ctx context.Context, query, kvBucket, keyColumn string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	count := 0
+	op := d.logOperation("query_to_kv", "query", query, "kv_bucket", kvBucket)
+	defer func() { op.done(err, "rows", count) }()
+
+	kv, err := d.keyValue(kvBucket)
+	if err != nil {
+		return err
+	}
+
+	rows, err := d.QueryRows(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to read columns: %w", err)
+	}
+	keyIndex := -1
+	for i, column := range columns {
+		if column == keyColumn {
+			keyIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		return fmt.Errorf("query has no column %s", keyColumn)
+	}
+
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return fmt.Errorf("failed to scan row %d: %w", count, err)
+		}
+		if values[keyIndex] == nil {
+			return fmt.Errorf("row %d has a NULL key", count)
+		}
+
+		record := make(map[string]any, len(columns)-1)
+		for i, column := range columns {
+			if i != keyIndex {
+				record[column] = jsonValue(values[i])
+			}
+		}
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode row %d: %w", count, err)
+		}
+
+		key := fmt.Sprint(values[keyIndex])
+		if _, err := kv.Put(key, data); err != nil {
+			return fmt.Errorf("failed to put key %s: %w", key, err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows after %d rows: %w", count, err)
+	}
+	return nil
+}
+
+// QueryFromKV fetches the JSON entries written by QueryToKV for keys and loads them into
+// destTable of the stored database, with the key in the _key column. Rows are appended if
+// the table already exists. The updated database is stored.
+func (d *DuckDBStorage) QueryFromKV(ctx context.Context, kvBucket string, keys []string, destTable string) (err error) {
+	op := d.logOperation("query_from_kv", "kv_bucket", kvBucket, "keys", len(keys), "table", destTable)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	kv, err := d.keyValue(kvBucket)
+	if err != nil {
+		return err
+	}
+
+	var batch bytes.Buffer
+	for _, key := range keys {
+		entry, err := kv.Get(key)
+		if err != nil {
+			return fmt.Errorf("failed to get key %s: %w", key, err)
+		}
+
+		var record map[string]any
+		if err := json.Unmarshal(entry.Value(), &record); err != nil {
+			return fmt.Errorf("failed to decode key %s: %w", key, err)
+		}
+		record[kvKeyColumn] = key
+		data, err := json.Marshal(record)
+		if err != nil {
+			return fmt.Errorf("failed to encode key %s: %w", key, err)
+		}
+		batch.Write(data)
+		batch.WriteByte('\n')
+	}
+
+	batchPath, err := tempPath("duckdb-nats-kv-*.ndjson")
+	if err != nil {
+		return err
+	}
+	defer removeTemp(batchPath)
+
+	if err := os.WriteFile(batchPath, batch.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write entries: %w", err)
+	}
+
+	dbPath, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(dbPath)
+
+	db, err := openDuckDB(dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := loadIntoTable(ctx, conn, destTable, "read_ndjson_auto("+quoteLiteral(batchPath)+")"); err != nil {
+		return err
+	}
+
+	conn.Close()
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return d.StoreDuckDB(dbPath)
+}
+
+// jsonValue converts driver values without a useful JSON encoding
+func jsonValue(value any) any {
+	if decimal, ok := value.(duckdb.Decimal); ok {
+		return decimal.Float64()
+	}
+	return value
+}
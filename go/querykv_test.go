@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+)
+
+func TestQueryKVRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "users.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE users AS SELECT i AS id, 'user' || i AS name, i * 1.5 AS score FROM range(10) r(i)")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+
+	if err := s.QueryToKV(ctx, "SELECT * FROM users", "users-kv", "id"); err != nil {
+		t.Fatalf("QueryToKV: %v", err)
+	}
+	kv, err := s.keyValue("users-kv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := kv.Keys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 10 {
+		t.Errorf("stored %d keys, want 10", len(keys))
+	}
+	entry, err := kv.Get("3")
+	if err != nil {
+		t.Fatalf("failed to get key 3: %v", err)
+	}
+	var record map[string]any
+	if err := json.Unmarshal(entry.Value(), &record); err != nil {
+		t.Fatalf("failed to decode key 3: %v", err)
+	}
+	if _, ok := record["id"]; ok {
+		t.Error("entry includes the key column")
+	}
+	if record["name"] != "user3" || record["score"] != 4.5 {
+		t.Errorf("entry 3 = %v, want name user3 and score 4.5", record)
+	}
+
+	if err := s.QueryFromKV(ctx, "users-kv", []string{"1", "3", "5"}, "picked"); err != nil {
+		t.Fatalf("QueryFromKV: %v", err)
+	}
+	var n int
+	var name string
+	if err := s.QueryRow(ctx, "SELECT count(*), max(name) FROM picked").Scan(&n, &name); err != nil {
+		t.Fatalf("failed to query picked: %v", err)
+	}
+	if n != 3 || name != "user5" {
+		t.Errorf("picked holds %d rows with max name %q, want 3 and user5", n, name)
+	}
+	var key string
+	if err := s.QueryRow(ctx, "SELECT _key FROM picked WHERE name = 'user1'").Scan(&key); err != nil || key != "1" {
+		t.Errorf("key of user1 = %q, %v, want 1", key, err)
+	}
+}
+
+func TestQueryKVErrors(t *testing.T) {
+	ctx := context.Background()
+	s, _ := storeTestDatabase(t)
+
+	if err := s.QueryToKV(ctx, "SELECT * FROM users", "users-kv", "missing"); err == nil {
+		t.Error("QueryToKV accepted a missing key column")
+	}
+	if err := s.QueryToKV(ctx, "SELECT NULL AS id, name FROM users", "users-kv", "id"); err == nil {
+		t.Error("QueryToKV accepted a NULL key")
+	}
+	if err := s.QueryToKV(ctx, "SELECT * FROM users", "users-kv", "id"); err != nil {
+		t.Fatalf("QueryToKV: %v", err)
+	}
+	if err := s.QueryFromKV(ctx, "users-kv", []string{"99"}, "picked"); err == nil {
+		t.Error("QueryFromKV accepted a missing key")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ErrQuotaExceeded is returned when a store would grow the bucket beyond WithStorageQuota
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// StorageUsage returns the total size in bytes of all objects in the bucket
+func (d *DuckDBStorage) StorageUsage() (_ int64, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { end(err) }()
+
+	return d.storageUsage()
+}
+
+// storageUsage is StorageUsage for operations already registered with Close
+func (d *DuckDBStorage) storageUsage() (int64, error) {
+	objects, err := d.obs.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var usage int64
+	for _, info := range objects {
+		usage += int64(info.Size)
+	}
+	return usage, nil
+}
+
+// checkQuota rejects storing dbFilePath as the object name when the bucket usage plus the file
+// size, less the size of the stored object it replaces, exceeds the configured storage quota
+func (d *DuckDBStorage) checkQuota(dbFilePath, name string) error {
+	if d.opts.StorageQuota <= 0 {
+		return nil
+	}
+
+	stat, err := os.Stat(dbFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to stat database file: %w", err)
+	}
+	return d.checkQuotaSize(stat.Size(), name)
+}
+
+// checkQuotaSize rejects storing size bytes as the object name when it would exceed the
+// configured storage quota
+func (d *DuckDBStorage) checkQuotaSize(size int64, name string) error {
+	if d.opts.StorageQuota <= 0 {
+		return nil
+	}
+
+	allowance, usage, err := d.quotaAllowance(name)
+	if err != nil {
+		return err
+	}
+	if size > allowance {
+		return fmt.Errorf("%w: usage %d bytes, limit %d bytes, rejected file %d bytes",
+			ErrQuotaExceeded, usage, d.opts.StorageQuota, size)
+	}
+	return nil
+}
+
+// quotaAllowance returns how many bytes may be stored as the object name within the configured
+// storage quota, counting the space of the stored object it replaces, and the current usage
+func (d *DuckDBStorage) quotaAllowance(name string) (allowance, usage int64, err error) {
+	usage, err = d.storageUsage()
+	if err != nil {
+		return 0, 0, err
+	}
+	replaced, err := d.storedSize(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return d.opts.StorageQuota - usage + replaced, usage, nil
+}
+
+// quotaReader fails with ErrQuotaExceeded once more than allowance bytes are read through it,
+// enforcing the storage quota on streams of unknown size
+type quotaReader struct {
+	r         io.Reader
+	allowance int64
+	usage     int64
+	limit     int64
+	n         int64
+}
+
+func (q *quotaReader) Read(b []byte) (int, error) {
+	n, err := q.r.Read(b)
+	q.n += int64(n)
+	if q.n > q.allowance {
+		return n, fmt.Errorf("%w: usage %d bytes, limit %d bytes, rejected stream of more than %d bytes",
+			ErrQuotaExceeded, q.usage, q.limit, q.allowance)
+	}
+	return n, err
+}
+
+// storedSize returns the bytes the object name takes in the bucket, counting the manifest and
+// chunks of a chunked database, or 0 if it is not stored
+func (d *DuckDBStorage) storedSize(name string) (int64, error) {
+	var size int64
+	info, err := d.obs.GetInfo(name)
+	if err == nil {
+		size += int64(info.Size)
+	} else if !errors.Is(err, nats.ErrObjectNotFound) {
+		return 0, fmt.Errorf("failed to get info for %s: %w", name, err)
+	}
+
+	manifest, err := d.getManifestOf(name)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return size, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	info, err = d.obs.GetInfo(manifestNameOf(name))
+	if err == nil {
+		size += int64(info.Size)
+	}
+	for _, chunk := range manifest.Chunks {
+		info, err := d.obs.GetInfo(chunk.Name)
+		if err == nil {
+			size += int64(info.Size)
+		}
+	}
+	return size, nil
+}
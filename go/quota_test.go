@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testDatabaseSize creates the test database at path and returns its size
+func testDatabaseSize(t *testing.T, path string) int64 {
+	t.Helper()
+	createTestDatabase(t, path)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fi.Size()
+}
+
+func TestStorageQuota(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	size := testDatabaseSize(t, path)
+	s := newTestStorage(t, startTestServer(t), WithStorageQuota(size*3/2))
+
+	if usage, err := s.StorageUsage(); err != nil || usage != 0 {
+		t.Fatalf("StorageUsage of an empty bucket = %d, %v, want 0", usage, err)
+	}
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	// Replacing the stored database only counts the difference
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB replacing the database: %v", err)
+	}
+	if err := s.StoreVersion(path, "v1"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("StoreVersion over the quota: got %v, want ErrQuotaExceeded", err)
+	}
+
+	usage, err := s.StorageUsage()
+	if err != nil {
+		t.Fatalf("StorageUsage: %v", err)
+	}
+	if usage != size {
+		t.Errorf("StorageUsage = %d, want %d", usage, size)
+	}
+}
+
+func TestStorageQuotaChunked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	size := testDatabaseSize(t, path)
+	s := newTestStorage(t, startTestServer(t), WithChunkSize(size/3), WithStorageQuota(size*3/2))
+
+	// The chunks and manifest being replaced are not counted against the quota
+	for i := 0; i < 3; i++ {
+		if err := s.StoreDuckDB(path); err != nil {
+			t.Fatalf("StoreDuckDB #%d: %v", i+1, err)
+		}
+	}
+	if err := s.StoreVersion(path, "v1"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("StoreVersion over the quota: got %v, want ErrQuotaExceeded", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// renamedFromHeader records the previous name of a renamed database
+const renamedFromHeader = "X-Renamed-From"
+
+// renameOptions controls a single rename call
+type renameOptions struct {
+	overwrite bool
+}
+
+// RenameOption configures RenameDatabase
+type RenameOption func(*renameOptions)
+
+// WithOverwrite replaces an existing database at the new name
+func WithOverwrite(overwrite bool) RenameOption {
+	return func(o *renameOptions) {
+		o.overwrite = overwrite
+	}
+}
+
+// RenameDatabase moves a stored database to a new name. The copy under newName is written and
+// verified before oldName is deleted, so there is never a moment where neither name exists.
+// Pinned databases cannot be renamed, as that deletes the old name.
+func (d *DuckDBStorage) RenameDatabase(oldName, newName string, opts ...RenameOption) (err error) {
+	op := d.logOperation("rename", "old", oldName, "new", newName)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	var options renameOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	oldKey, newKey := d.objectKey(oldName), d.objectKey(newName)
+	if oldKey == newKey {
+		return fmt.Errorf("cannot rename %s to itself", oldName)
+	}
+
+	source, err := d.obs.GetInfo(oldKey)
+	if err != nil {
+		return fmt.Errorf("failed to get %s: %w", oldName, err)
+	}
+	if err := d.checkPinned(oldKey, nil); err != nil {
+		return err
+	}
+
+	if !options.overwrite {
+		_, err := d.obs.GetInfo(newKey)
+		if err == nil {
+			return fmt.Errorf("%w: %s", ErrAlreadyExists, newName)
+		}
+		if !errors.Is(err, nats.ErrObjectNotFound) {
+			return fmt.Errorf("failed to check destination: %w", err)
+		}
+	}
+
+	renamed, err := copyObjectTo(context.Background(), d.obs, oldKey, d.obs, newKey, nats.Header{
+		renamedFromHeader: []string{oldName},
+	})
+	if err != nil {
+		return err
+	}
+	if renamed.Digest != source.Digest || renamed.Size != source.Size {
+		return fmt.Errorf("failed to rename %s: copy does not match the original", oldName)
+	}
+
+	if err := d.obs.Delete(oldKey); err != nil {
+		return fmt.Errorf("failed to delete %s after renaming: %w", oldName, err)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRenameDatabase(t *testing.T) {
+	for _, namespace := range []string{"", "tenant"} {
+		t.Run("namespace="+namespace, func(t *testing.T) {
+			s, path := storeTestDatabase(t, WithNamespace(namespace))
+			stored, err := s.GetInfo()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := s.RenameDatabase(defaultDBName, "renamed.db"); err != nil {
+				t.Fatalf("RenameDatabase: %v", err)
+			}
+			if _, err := s.GetInfo(); err == nil {
+				t.Error("GetInfo found the old name after the rename")
+			}
+			info, err := s.obs.GetInfo(s.objectKey("renamed.db"))
+			if err != nil {
+				t.Fatalf("GetInfo of the new name: %v", err)
+			}
+			if got := info.Headers.Get(renamedFromHeader); got != defaultDBName {
+				t.Errorf("%s = %q, want %q", renamedFromHeader, got, defaultDBName)
+			}
+			if got, want := info.Headers.Get(checksumHeader), stored.Headers.Get(checksumHeader); got != want {
+				t.Errorf("%s = %q, want the original %q", checksumHeader, got, want)
+			}
+
+			if err := s.StoreDuckDB(path); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.RenameDatabase(defaultDBName, "renamed.db"); !errors.Is(err, ErrAlreadyExists) {
+				t.Fatalf("RenameDatabase onto an existing name: got %v, want ErrAlreadyExists", err)
+			}
+			if err := s.RenameDatabase(defaultDBName, "renamed.db", WithOverwrite(true)); err != nil {
+				t.Fatalf("RenameDatabase with overwrite: %v", err)
+			}
+		})
+	}
+}
+
+func TestRenameDatabaseRejected(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	if err := s.RenameDatabase("missing.db", "renamed.db"); err == nil {
+		t.Error("RenameDatabase of a missing database succeeded")
+	}
+	if err := s.RenameDatabase(defaultDBName, defaultDBName); err == nil {
+		t.Error("RenameDatabase onto itself succeeded")
+	}
+
+	if err := s.Pin(defaultDBName); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RenameDatabase(defaultDBName, "renamed.db"); !errors.Is(err, ErrDatabasePinned) {
+		t.Fatalf("RenameDatabase of a pinned database: got %v, want ErrDatabasePinned", err)
+	}
+	if _, err := s.GetInfo(); err != nil {
+		t.Errorf("pinned database missing after the rejected rename: %v", err)
+	}
+}
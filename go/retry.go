@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// maxRetryDelay caps the backoff between two attempts
+const maxRetryDelay = 30 * time.Second
+
+// RetryStats describes the attempts made by a retried operation
+type RetryStats struct {
+	Attempts   int
+	TotalDelay time.Duration
+}
+
+// RetryError is returned by a retried operation that failed, with the attempts it made. It
+// unwraps to the error of the last attempt.
+type RetryError struct {
+	RetryStats
+	Err error
+}
+
+func (e *RetryError) Error() string {
+	if e.Attempts > 1 {
+		return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+	}
+	return e.Err.Error()
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// IsRetryable reports whether err is a transient NATS failure worth retrying
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	switch {
+	case errors.Is(err, nats.ErrTimeout),
+		errors.Is(err, nats.ErrNoResponders),
+		errors.Is(err, nats.ErrNoServers),
+		errors.Is(err, nats.ErrConnectionClosed),
+		errors.Is(err, nats.ErrConnectionDraining),
+		errors.Is(err, nats.ErrConnectionReconnecting),
+		errors.Is(err, nats.ErrDisconnected),
+		errors.Is(err, nats.ErrStaleConnection),
+		errors.Is(err, context.DeadlineExceeded):
+		return true
+	}
+
+	// Only network operations, as syscall.Errno satisfies net.Error for local file errors too
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// withRetry runs fn until it succeeds, fails with a non-retryable error, runs out of attempts
+// or ctx is done, and returns the attempts it made. A failure is returned as a *RetryError.
+// Delays grow exponentially from the configured base with jitter. Every attempt passes through
+// the circuit breaker, if one is configured.
+func (d *DuckDBStorage) withRetry(ctx context.Context, fn func() error) (RetryStats, error) {
+	attempts := max(d.opts.RetryAttempts, 1)
+	stats := RetryStats{}
+	fail := func(err error) (RetryStats, error) {
+		return stats, &RetryError{RetryStats: stats, Err: err}
+	}
+
+	for {
+		if err := d.breaker.allow(); err != nil {
+			return fail(err)
+		}
+		stats.Attempts++
+		err := fn()
+		d.breaker.record(err)
+		if err == nil {
+			return stats, nil
+		}
+		if stats.Attempts >= attempts || !IsRetryable(err) || ctx.Err() != nil {
+			return fail(err)
+		}
+
+		delay := backoffDelay(d.opts.RetryBaseDelay, stats.Attempts)
+		d.opts.Logger.Debug("retrying after transient error",
+			"db", d.dbName, "attempt", stats.Attempts, "delay", delay, "error", err)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fail(err)
+		case <-timer.C:
+		}
+		stats.TotalDelay += delay
+	}
+}
+
+// backoffDelay returns the jittered delay before the attempt following attempt
+func backoffDelay(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	delay := base
+	for i := 1; i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxRetryDelay)
+	// Jitter into [delay/2, delay] so that clients failing together do not retry together
+	half := delay / 2
+	return half + rand.N(half+1)
+}
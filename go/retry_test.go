@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// flakyOperation fails with err for the first failures calls and succeeds afterwards
+type flakyOperation struct {
+	failures int
+	err      error
+	calls    int
+}
+
+func (f *flakyOperation) run() error {
+	f.calls++
+	if f.calls <= f.failures {
+		return f.err
+	}
+	return nil
+}
+
+func TestIsRetryable(t *testing.T) {
+	_, openErr := os.Open(filepath.Join(t.TempDir(), "missing.db"))
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED}
+	for _, err := range []error{nats.ErrTimeout, nats.ErrNoResponders, nats.ErrDisconnected, context.DeadlineExceeded, fmt.Errorf("put: %w", nats.ErrConnectionReconnecting), dialErr} {
+		if !IsRetryable(err) {
+			t.Errorf("IsRetryable(%v) = false", err)
+		}
+	}
+	for _, err := range []error{nil, os.ErrNotExist, openErr, nats.ErrObjectNotFound, ErrChecksumMismatch, context.Canceled} {
+		if IsRetryable(err) {
+			t.Errorf("IsRetryable(%v) = true", err)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	base := 100 * time.Millisecond
+	for attempt := 1; attempt <= 4; attempt++ {
+		want := base << (attempt - 1)
+		if got := backoffDelay(base, attempt); got < want/2 || got > want {
+			t.Errorf("backoffDelay(%v, %d) = %v, want within [%v, %v]", base, attempt, got, want/2, want)
+		}
+	}
+	if got := backoffDelay(time.Hour, 40); got < maxRetryDelay/2 || got > maxRetryDelay {
+		t.Errorf("backoffDelay is not capped: %v", got)
+	}
+	if got := backoffDelay(0, 3); got != 0 {
+		t.Errorf("backoffDelay without a base = %v", got)
+	}
+}
+
+func TestWithRetry(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithRetry(5, 10*time.Millisecond))
+	ctx := context.Background()
+
+	t.Run("succeeds on third attempt", func(t *testing.T) {
+		op := &flakyOperation{failures: 2, err: nats.ErrTimeout}
+		stats, err := s.withRetry(ctx, op.run)
+		if err != nil {
+			t.Fatalf("withRetry: %v", err)
+		}
+		if op.calls != 3 || stats.Attempts != 3 {
+			t.Errorf("%d calls, %d attempts reported, want 3", op.calls, stats.Attempts)
+		}
+		if stats.TotalDelay < 10*time.Millisecond {
+			t.Errorf("total delay %v, want at least one backoff", stats.TotalDelay)
+		}
+	})
+
+	t.Run("permanent error", func(t *testing.T) {
+		op := &flakyOperation{failures: 5, err: os.ErrNotExist}
+		_, err := s.withRetry(ctx, op.run)
+		var retryErr *RetryError
+		if !errors.As(err, &retryErr) || !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("withRetry: got %v, want a RetryError wrapping os.ErrNotExist", err)
+		}
+		if op.calls != 1 || retryErr.Attempts != 1 {
+			t.Errorf("permanent error retried: %d calls", op.calls)
+		}
+	})
+
+	t.Run("attempts exhausted", func(t *testing.T) {
+		op := &flakyOperation{failures: 10, err: nats.ErrTimeout}
+		_, err := s.withRetry(ctx, op.run)
+		var retryErr *RetryError
+		if !errors.As(err, &retryErr) || retryErr.Attempts != 5 || op.calls != 5 {
+			t.Fatalf("withRetry: got %v after %d calls, want 5 attempts", err, op.calls)
+		}
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		op := &flakyOperation{failures: 10, err: nats.ErrTimeout}
+		if _, err := s.withRetry(ctx, op.run); !errors.Is(err, nats.ErrTimeout) || op.calls != 1 {
+			t.Fatalf("withRetry: got %v after %d calls", err, op.calls)
+		}
+	})
+}
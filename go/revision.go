@@ -0,0 +1,193 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ErrRevisionNotFound is returned for revisions that were never recorded or have been pruned
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// RevisionInfo describes a stored revision of the database
+type RevisionInfo struct {
+	// Revision is the stream sequence of the object's metadata when the revision was stored
+	Revision  uint64
+	Size      int64
+	Timestamp time.Time
+	Current   bool
+}
+
+func (d *DuckDBStorage) revisionPrefix() string {
+	return d.dbName + ".revision."
+}
+
+func (d *DuckDBStorage) revisionName(revision uint64) string {
+	return d.revisionPrefix() + strconv.FormatUint(revision, 10)
+}
+
+// metaRevision returns the stream sequence of the latest metadata message of the named object.
+// The object store only keeps the latest copy of an object, so this sequence is what
+// identifies a revision.
+func (d *DuckDBStorage) metaRevision(name string) (uint64, error) {
+	msg, err := d.js.GetLastMsg("OBJ_"+d.bucket, d.metaSubject(name))
+	if errors.Is(err, nats.ErrMsgNotFound) {
+		return 0, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up revision of %s: %w", name, err)
+	}
+	return msg.Sequence, nil
+}
+
+// metaSubject returns the subject of the metadata messages of the named object
+func (d *DuckDBStorage) metaSubject(name string) string {
+	return fmt.Sprintf("$O.%s.M.%s", d.bucket, base64.URLEncoding.EncodeToString([]byte(name)))
+}
+
+// recordRevision keeps a copy of the just stored database under its revision and prunes
+// revisions beyond the limit set by WithRevisionHistory
+func (d *DuckDBStorage) recordRevision(ctx context.Context) error {
+	revision, err := d.metaRevision(d.dbName)
+	if err != nil {
+		return err
+	}
+	if _, err := d.copyObject(ctx, d.dbName, d.revisionName(revision)); err != nil {
+		return fmt.Errorf("failed to record revision %d: %w", revision, err)
+	}
+
+	revisions, err := d.recordedRevisions()
+	if err != nil {
+		return err
+	}
+	for len(revisions) > d.opts.RevisionHistory {
+		if err := d.obs.Delete(d.revisionName(revisions[0].Revision)); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return fmt.Errorf("failed to prune revision %d: %w", revisions[0].Revision, err)
+		}
+		revisions = revisions[1:]
+	}
+	return nil
+}
+
+// recordedRevisions returns the recorded revision copies, oldest first
+func (d *DuckDBStorage) recordedRevisions() ([]RevisionInfo, error) {
+	objects, err := d.obs.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var revisions []RevisionInfo
+	for _, info := range objects {
+		suffix, ok := strings.CutPrefix(info.Name, d.revisionPrefix())
+		if !ok {
+			continue
+		}
+		revision, err := strconv.ParseUint(suffix, 10, 64)
+		if err != nil {
+			continue
+		}
+		revisions = append(revisions, RevisionInfo{
+			Revision:  revision,
+			Size:      int64(info.Size),
+			Timestamp: info.ModTime,
+		})
+	}
+
+	slices.SortFunc(revisions, func(a, b RevisionInfo) int {
+		return cmp.Compare(a.Revision, b.Revision)
+	})
+	return revisions, nil
+}
+
+// ListRevisions returns the revisions of the database that can be retrieved, oldest first. Only
+// the current revision is available unless history is kept with WithRevisionHistory.
+func (d *DuckDBStorage) ListRevisions() (_ []RevisionInfo, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	revisions, err := d.recordedRevisions()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := d.obs.GetInfo(d.dbName)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return revisions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database info: %w", err)
+	}
+	current, err := d.metaRevision(d.dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range revisions {
+		if revisions[i].Revision == current {
+			revisions[i].Current = true
+			return revisions, nil
+		}
+	}
+	return append(revisions, RevisionInfo{
+		Revision:  current,
+		Size:      int64(info.Size),
+		Timestamp: info.ModTime,
+		Current:   true,
+	}), nil
+}
+
+// RetrieveRevision retrieves the database as it was stored at revision, see ListRevisions
+func (d *DuckDBStorage) RetrieveRevision(outputPath string, revision uint64) (err error) {
+	op := d.logOperation("retrieve_revision", "revision", revision, "path", outputPath)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	ctx := context.Background()
+
+	err = d.getDatabase(ctx, d.revisionName(revision), outputPath)
+	if !errors.Is(err, nats.ErrObjectNotFound) {
+		return err
+	}
+
+	// Without a recorded copy only the current revision can be served
+	current, err := d.metaRevision(d.dbName)
+	if err != nil {
+		return err
+	}
+	if current != revision {
+		return fmt.Errorf("%w: %d", ErrRevisionNotFound, revision)
+	}
+	return d.getDatabase(ctx, d.dbName, outputPath)
+}
+
+// RecoverToRevision retrieves the database at revision for point-in-time recovery, logging the
+// recovery so operators can tell which state was restored
+func (d *DuckDBStorage) RecoverToRevision(outputPath string, revision uint64) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	d.opts.Logger.Info("recovering database to revision",
+		"db", d.dbName, "bucket", d.bucket, "revision", revision, "path", outputPath)
+	return d.RetrieveRevision(outputPath, revision)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecoverToRevision(t *testing.T) {
+	s, path := storeTestDatabase(t, WithRevisionHistory(3))
+	execTestDatabase(t, path, "INSERT INTO users VALUES (4, 'Dave', now())")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+	execTestDatabase(t, path, "INSERT INTO users VALUES (5, 'Eve', now())")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := s.ListRevisions()
+	if err != nil {
+		t.Fatalf("ListRevisions: %v", err)
+	}
+	if len(revisions) != 3 {
+		t.Fatalf("ListRevisions = %v, want 3 revisions", revisions)
+	}
+	for i, revision := range revisions {
+		if revision.Current != (i == 2) {
+			t.Errorf("revision %d: Current = %v", revision.Revision, revision.Current)
+		}
+		if i > 0 && revision.Revision <= revisions[i-1].Revision {
+			t.Errorf("revisions not sorted oldest first: %v", revisions)
+		}
+	}
+
+	for i, want := range []int64{3, 4, 5} {
+		out := filepath.Join(t.TempDir(), "recovered.db")
+		if err := s.RecoverToRevision(out, revisions[i].Revision); err != nil {
+			t.Fatalf("RecoverToRevision(%d): %v", revisions[i].Revision, err)
+		}
+		if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != want {
+			t.Errorf("revision %d has %d users, want %d", revisions[i].Revision, n, want)
+		}
+	}
+
+	// Recorded revisions are not listed as databases
+	entries, err := s.ListDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("ListDatabases = %v, want only the database", entries)
+	}
+}
+
+func TestRevisionHistoryPruned(t *testing.T) {
+	s, path := storeTestDatabase(t, WithRevisionHistory(2))
+	first, err := s.ListRevisions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := s.StoreDuckDB(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	revisions, err := s.ListRevisions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || !revisions[1].Current || revisions[0].Revision == first[0].Revision {
+		t.Fatalf("ListRevisions = %v, want the last 2 revisions", revisions)
+	}
+	if err := s.RetrieveRevision(filepath.Join(t.TempDir(), "out.db"), first[0].Revision); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("RetrieveRevision of a pruned revision: got %v, want ErrRevisionNotFound", err)
+	}
+}
+
+func TestRetrieveRevisionWithoutHistory(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	revisions, err := s.ListRevisions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 || !revisions[0].Current {
+		t.Fatalf("ListRevisions = %v, want only the current revision", revisions)
+	}
+	if err := s.RetrieveRevision(filepath.Join(t.TempDir(), "out.db"), revisions[0].Revision); err != nil {
+		t.Errorf("RetrieveRevision of the current revision: %v", err)
+	}
+	if err := s.RetrieveRevision(filepath.Join(t.TempDir(), "old.db"), revisions[0].Revision-1); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("RetrieveRevision of an unrecorded revision: got %v, want ErrRevisionNotFound", err)
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// RowFilter hides the rows of Table not matching Predicate from queries
+type RowFilter struct {
+	Table     string
+	Predicate string
+}
+
+// RowFilterFunc returns the predicate restricting the rows of table for query, or an empty
+// string for none
+type RowFilterFunc func(table, query string) string
+
+// hasRowFilters reports whether any row filter is configured
+func (d *DuckDBStorage) hasRowFilters() bool {
+	return len(d.opts.RowFilters) > 0 || len(d.opts.RowFilterFuncs) > 0
+}
+
+// rowPredicates returns the predicates of the row filters for table when running query
+func (d *DuckDBStorage) rowPredicates(table, query string) []string {
+	var predicates []string
+	for _, filter := range d.opts.RowFilters {
+		if strings.EqualFold(filter.Table, table) {
+			predicates = append(predicates, filter.Predicate)
+		}
+	}
+	for _, fn := range d.opts.RowFilterFuncs {
+		if predicate := fn(table, query); predicate != "" {
+			predicates = append(predicates, predicate)
+		}
+	}
+	return predicates
+}
+
+// filterDatabase deletes the rows the row filters hide from query from the database at path,
+// which must be a private copy. Removing the rows before any user SQL runs keeps them out of
+// reach of views, subqueries and aliases alike. A predicate that fails to evaluate fails the
+// query rather than leaving rows unfiltered.
+func (d *DuckDBStorage) filterDatabase(ctx context.Context, path, query string) error {
+	if !d.hasRowFilters() {
+		return nil
+	}
+
+	db, err := openDuckDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var catalog string
+	if err := db.QueryRowContext(ctx, "SELECT current_database()").Scan(&catalog); err != nil {
+		return fmt.Errorf("failed to read database name: %w", err)
+	}
+	tables, err := attachedTables(ctx, db, catalog)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		predicates := d.rowPredicates(table.name, query)
+		if len(predicates) == 0 {
+			continue
+		}
+		// Rows for which the predicate is NULL are hidden as well
+		_, err := db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s.%s WHERE ((%s)) IS NOT TRUE",
+			quoteIdent(table.schema), quoteIdent(table.name), strings.Join(predicates, ") AND (")))
+		if err != nil {
+			return fmt.Errorf("failed to apply row filter to %s: %w", table, err)
+		}
+	}
+
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
+
+// openFiltered opens a read-only copy of the database at path with the rows the row filters
+// hide from query removed. Without row filters the database at path itself is opened
+// read-only. release closes the database and removes the copy.
+func (d *DuckDBStorage) openFiltered(ctx context.Context, path, query string) (*sql.DB, func(), error) {
+	if !d.hasRowFilters() {
+		db, err := openDuckDBReadOnly(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return db, func() { db.Close() }, nil
+	}
+
+	filtered, err := tempPath("duckdb-nats-filtered-*.db")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := copyFile(path, filtered); err != nil {
+		removeTempDatabase(filtered)
+		return nil, nil, err
+	}
+	if err := d.filterDatabase(ctx, filtered, query); err != nil {
+		removeTempDatabase(filtered)
+		return nil, nil, err
+	}
+	db, err := openDuckDBReadOnly(filtered)
+	if err != nil {
+		removeTempDatabase(filtered)
+		return nil, nil, err
+	}
+	return db, func() {
+		db.Close()
+		removeTempDatabase(filtered)
+	}, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// storeTenantDatabase stores an orders table holding rows of the acme and globex tenants
+func storeTenantDatabase(t *testing.T, opts ...Option) *DuckDBStorage {
+	t.Helper()
+	nc := startTestServer(t)
+	path := filepath.Join(t.TempDir(), "tenants.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE orders (id INTEGER, tenant_id VARCHAR, amount INTEGER)",
+		"INSERT INTO orders VALUES (1, 'acme', 10), (2, 'globex', 20), (3, 'acme', 30), (4, NULL, 40)",
+		"CREATE VIEW all_orders AS SELECT * FROM orders",
+	)
+	if err := newTestStorage(t, nc).StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	return newTestStorage(t, nc, opts...)
+}
+
+func TestRowFilter(t *testing.T) {
+	s := storeTenantDatabase(t, WithRowFilter("orders", "tenant_id = 'acme'"))
+	ctx := context.Background()
+
+	tests := []struct {
+		name  string
+		query string
+		args  []any
+		want  []string
+	}{
+		{"select", "SELECT id FROM orders ORDER BY id", nil, []string{"1", "3"}},
+		{"quoted identifier", `SELECT id FROM "ORDERS" WHERE amount > ?`, []any{15}, []string{"3"}},
+		{"aggregate", "SELECT sum(amount) FROM orders", nil, []string{"40"}},
+		{"view", "SELECT id FROM all_orders ORDER BY id", nil, []string{"1", "3"}},
+		{"subquery", "SELECT id FROM (SELECT * FROM orders) o WHERE tenant_id <> 'acme' OR tenant_id IS NULL", nil, nil},
+		{"unrelated", "SELECT 'orders' AS id", nil, []string{"orders"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := s.FetchAndQuery(ctx, tt.query, tt.args...)
+			if err != nil {
+				t.Fatalf("FetchAndQuery: %v", err)
+			}
+			var got []string
+			for _, row := range rows {
+				for _, v := range row {
+					got = append(got, fmt.Sprint(v))
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("FetchAndQuery(%q) = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	var n int
+	if err := s.QueryRow(ctx, "SELECT count(*) FROM orders WHERE tenant_id = 'globex'").Scan(&n); err != nil {
+		t.Fatalf("QueryRow: %v", err)
+	}
+	if n != 0 {
+		t.Errorf("QueryRow counted %d globex rows, want 0", n)
+	}
+}
+
+func TestRowFilterFunc(t *testing.T) {
+	var queries []string
+	s := storeTenantDatabase(t,
+		WithRowFilter("orders", "amount > 15"),
+		WithRowFilterFunc(func(table, query string) string {
+			queries = append(queries, query)
+			if table == "orders" {
+				return "tenant_id = 'globex'"
+			}
+			return ""
+		}),
+	)
+	query := "SELECT id, tenant_id FROM orders"
+	rows, err := s.FetchAndQuery(context.Background(), query)
+	if err != nil {
+		t.Fatalf("FetchAndQuery: %v", err)
+	}
+	// Both filters apply and compose with AND
+	if len(rows) != 1 || rows[0]["tenant_id"] != "globex" {
+		t.Errorf("FetchAndQuery = %v, want only the globex order", rows)
+	}
+	if len(queries) == 0 || queries[0] != query {
+		t.Errorf("filter func called with %q, want %q", queries, query)
+	}
+}
+
+func TestRowFilterInvalidPredicate(t *testing.T) {
+	s := storeTenantDatabase(t, WithRowFilter("orders", "no_such_column = 1"))
+	if rows, err := s.FetchAndQuery(context.Background(), "SELECT * FROM orders"); err == nil {
+		t.Errorf("FetchAndQuery with an invalid predicate returned %v, want an error", rows)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"time"
+)
+
+// opReplicate is the audit operation of an S3 replication
+const opReplicate = "replicate_s3"
+
+// ErrReplicationFailed is returned when the database was stored in NATS but could not be
+// replicated to S3
+var ErrReplicationFailed = errors.New("replication failed")
+
+// S3PutObjectInput is the request of S3PutObjectAPI.PutObject, a subset of the AWS SDK input
+type S3PutObjectInput struct {
+	Bucket        string
+	Key           string
+	Body          io.Reader
+	ContentLength int64
+	ContentType   string
+	Metadata      map[string]string
+}
+
+// S3PutObjectOutput is the result of S3PutObjectAPI.PutObject
+type S3PutObjectOutput struct {
+	ETag      string
+	VersionID string
+}
+
+// S3PutObjectAPI uploads objects to S3-compatible storage. It has the shape of the PutObject
+// method of the AWS SDK v2 client, which can be adapted by copying the fields of the input.
+type S3PutObjectAPI interface {
+	PutObject(ctx context.Context, input *S3PutObjectInput) (*S3PutObjectOutput, error)
+}
+
+// ReplicateToS3 uploads a copy of the stored database to s3Key in s3Bucket
+func (d *DuckDBStorage) ReplicateToS3(ctx context.Context, s3Bucket, s3Key string, s3Client S3PutObjectAPI) (err error) {
+	op := d.logOperation(opReplicate, "s3_bucket", s3Bucket, "s3_key", s3Key)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	dbPath, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(dbPath)
+
+	return d.replicateFile(ctx, dbPath, s3Bucket, s3Key, s3Client)
+}
+
+// replicate uploads a just stored database file to the S3 replica set by WithS3Replication,
+// if any, and records a failure in the audit log
+func (d *DuckDBStorage) replicate(ctx context.Context, dbFilePath string) error {
+	if d.opts.S3Client == nil {
+		return nil
+	}
+
+	start := time.Now()
+	key := path.Join(d.opts.S3KeyPrefix, d.dbName)
+	err := d.replicateFile(ctx, dbFilePath, d.opts.S3Bucket, key, d.opts.S3Client)
+	if err != nil {
+		d.audit(opReplicate, start, fileSize(dbFilePath), err)
+	}
+	return err
+}
+
+// replicateFile uploads a database file to s3Key in s3Bucket
+func (d *DuckDBStorage) replicateFile(ctx context.Context, dbFilePath, s3Bucket, s3Key string, s3Client S3PutObjectAPI) error {
+	checksum, err := hashFile(dbFilePath)
+	if err != nil {
+		return err
+	}
+	file, err := os.Open(dbFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open database file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = s3Client.PutObject(ctx, &S3PutObjectInput{
+		Bucket:        s3Bucket,
+		Key:           s3Key,
+		Body:          file,
+		ContentLength: fileSize(dbFilePath),
+		ContentType:   "application/octet-stream",
+		Metadata:      map[string]string{"sha256": checksum},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: s3://%s/%s: %w", ErrReplicationFailed, s3Bucket, s3Key, err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// fakeS3 keeps the objects put to it in memory
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	inputs  []*S3PutObjectInput
+	err     error
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{objects: make(map[string][]byte)}
+}
+
+func (f *fakeS3) PutObject(ctx context.Context, input *S3PutObjectInput) (*S3PutObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.err != nil {
+		return nil, f.err
+	}
+	data, err := io.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.objects[input.Bucket+"/"+input.Key] = data
+	f.inputs = append(f.inputs, input)
+	return &S3PutObjectOutput{ETag: "etag"}, nil
+}
+
+// object returns the object stored at bucket/key
+func (f *fakeS3) object(bucket, key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[bucket+"/"+key]
+	return data, ok
+}
+
+func TestStoreReplicatesToS3(t *testing.T) {
+	s3 := newFakeS3()
+	_, path := storeTestDatabase(t, WithS3Replication(s3, "backups", "duckdb"))
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, ok := s3.object("backups", "duckdb/"+defaultDBName)
+	if !ok {
+		t.Fatal("StoreDuckDB did not replicate to S3")
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("replica does not match the stored database")
+	}
+	checksum, err := hashFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := s3.inputs[0]
+	if input.ContentLength != int64(len(want)) || input.Metadata["sha256"] != checksum {
+		t.Errorf("replica uploaded with length %d and checksum %q, want %d and %q", input.ContentLength, input.Metadata["sha256"], len(want), checksum)
+	}
+}
+
+func TestReplicateToS3(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	s3 := newFakeS3()
+	if err := s.ReplicateToS3(context.Background(), "other", "copy.db", s3); err != nil {
+		t.Fatalf("ReplicateToS3: %v", err)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := s3.object("other", "copy.db"); !ok || !bytes.Equal(got, want) {
+		t.Error("replica does not match the stored database")
+	}
+}
+
+func TestReplicationFailure(t *testing.T) {
+	s3 := newFakeS3()
+	s, path := storeTestDatabase(t, WithS3Replication(s3, "backups", ""), WithAuditSubject("audit.duckdb"))
+	if _, err := s.js.AddStream(&nats.StreamConfig{Name: "AUDIT", Subjects: []string{"audit.>"}}); err != nil {
+		t.Fatalf("failed to create audit stream: %v", err)
+	}
+	sub, err := s.js.SubscribeSync("audit.duckdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.CurrentRevision()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s3.err = errors.New("access denied")
+	if err := s.ForceStore(path); !errors.Is(err, ErrReplicationFailed) {
+		t.Fatalf("ForceStore with a failing replica: got %v, want ErrReplicationFailed", err)
+	}
+	if rev, err := s.CurrentRevision(); err != nil || rev == stored {
+		t.Errorf("revision after a failed replication = %d, %v, want the new revision stored in NATS", rev, err)
+	}
+
+	for {
+		msg, err := sub.NextMsg(time.Second)
+		if err != nil {
+			t.Fatal("no audit event recorded the failed replication")
+		}
+		event, err := AuditEventFromMsg(msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.Operation == opReplicate {
+			if event.Error == "" {
+				t.Error("replication audit event has no error")
+			}
+			break
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// sampleColumnGenerators maps the column types GenerateSampleDatabase supports to the SQL
+// producing a random value of the type. UUIDs are derived from random() rather than
+// gen_random_uuid(), which ignores the seed.
+var sampleColumnGenerators = map[string]string{
+	"INTEGER":   "(random() * 1000000)::INTEGER",
+	"BIGINT":    "(random() * 1000000000000)::BIGINT",
+	"DOUBLE":    "random() * 1000",
+	"BOOLEAN":   "random() < 0.5",
+	"VARCHAR":   "md5(random()::VARCHAR)",
+	"DATE":      "DATE '2020-01-01' + (random() * 1825)::INTEGER",
+	"TIMESTAMP": "TIMESTAMP '2020-01-01' + to_seconds((random() * 157680000)::BIGINT)",
+	"UUID":      "md5(random()::VARCHAR)::UUID",
+}
+
+// SampleDBConfig describes the database written by GenerateSampleDatabase
+type SampleDBConfig struct {
+	TableCount   int
+	RowsPerTable int
+	// ColumnTypes are the types of the columns every table has besides its id. Supported are
+	// INTEGER, BIGINT, DOUBLE, BOOLEAN, VARCHAR, DATE, TIMESTAMP and UUID. Empty picks 3 to 5
+	// of them at random for each table.
+	ColumnTypes []string
+	// Seed makes the generated schema and data reproducible
+	Seed int64
+}
+
+// GenerateSampleDatabase writes a database of random data to path for demos and load tests.
+// Table i is named table_i and has an id column filled from a sequence followed by a column
+// per type named after the type and its position, such as varchar_2.
+func GenerateSampleDatabase(path string, cfg SampleDBConfig) error {
+	if cfg.TableCount <= 0 {
+		return fmt.Errorf("invalid table count: %d", cfg.TableCount)
+	}
+	if cfg.RowsPerTable < 0 {
+		return fmt.Errorf("invalid rows per table: %d", cfg.RowsPerTable)
+	}
+	for _, columnType := range cfg.ColumnTypes {
+		if _, ok := sampleColumnGenerators[strings.ToUpper(columnType)]; !ok {
+			return fmt.Errorf("unsupported column type: %q", columnType)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	db, err := openDuckDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	// The seed and thread count are per connection
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	// random() only repeats its sequence when a single thread draws from it
+	random := rand.New(rand.NewSource(cfg.Seed))
+	if _, err := conn.ExecContext(ctx, "SET threads = 1"); err != nil {
+		return fmt.Errorf("failed to configure database: %w", err)
+	}
+	if _, err := conn.ExecContext(ctx, "SELECT setseed(?)", random.Float64()); err != nil {
+		return fmt.Errorf("failed to seed random generator: %w", err)
+	}
+
+	supported := make([]string, 0, len(sampleColumnGenerators))
+	for columnType := range sampleColumnGenerators {
+		supported = append(supported, columnType)
+	}
+	slices.Sort(supported)
+
+	for i := 1; i <= cfg.TableCount; i++ {
+		columnTypes := cfg.ColumnTypes
+		if len(columnTypes) == 0 {
+			columnTypes = make([]string, 3+random.Intn(3))
+			for j := range columnTypes {
+				columnTypes[j] = supported[random.Intn(len(supported))]
+			}
+		}
+		if err := generateSampleTable(ctx, conn, fmt.Sprintf("table_%d", i), columnTypes, cfg.RowsPerTable); err != nil {
+			return err
+		}
+	}
+
+	conn.Close()
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+	return nil
+}
+
+// generateSampleTable creates a table with an id column and a column per type and fills it
+// with rows of random values
+func generateSampleTable(ctx context.Context, conn *sql.Conn, table string, columnTypes []string, rows int) error {
+	sequence := table + "_id_seq"
+	columns := []string{"id BIGINT PRIMARY KEY DEFAULT nextval(" + quoteLiteral(sequence) + ")"}
+	var names, values []string
+	for i, columnType := range columnTypes {
+		columnType = strings.ToUpper(columnType)
+		name := fmt.Sprintf("%s_%d", strings.ToLower(columnType), i+1)
+		columns = append(columns, quoteIdent(name)+" "+columnType)
+		names = append(names, quoteIdent(name))
+		values = append(values, sampleColumnGenerators[columnType])
+	}
+
+	create := fmt.Sprintf("CREATE SEQUENCE %s; CREATE TABLE %s (%s)", quoteIdent(sequence), quoteIdent(table), strings.Join(columns, ", "))
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("failed to create table %s: %w", table, err)
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	insert := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM range(?)",
+		quoteIdent(table), strings.Join(names, ", "), strings.Join(values, ", "))
+	if _, err := conn.ExecContext(ctx, insert, rows); err != nil {
+		return fmt.Errorf("failed to fill table %s: %w", table, err)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestGenerateSampleDatabase(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "sample.db")
+	if err := GenerateSampleDatabase(path, SampleDBConfig{TableCount: 5, RowsPerTable: 1000, Seed: 42}); err != nil {
+		t.Fatalf("GenerateSampleDatabase: %v", err)
+	}
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB: %v", err)
+	}
+
+	for i := 1; i <= 5; i++ {
+		table := fmt.Sprintf("table_%d", i)
+		if n := queryTestInt(t, out, "SELECT count(*) FROM "+table); n != 1000 {
+			t.Errorf("%s has %d rows, want 1000", table, n)
+		}
+		if n := queryTestInt(t, out, "SELECT count(DISTINCT id) FROM "+table); n != 1000 {
+			t.Errorf("%s has %d distinct ids, want 1000", table, n)
+		}
+		if n := queryTestInt(t, out, "SELECT count(*) FROM duckdb_columns() WHERE table_name = '"+table+"'"); n < 4 || n > 6 {
+			t.Errorf("%s has %d columns, want an id and 3 to 5 random ones", table, n)
+		}
+	}
+}
+
+func TestGenerateSampleDatabaseSeed(t *testing.T) {
+	cfg := SampleDBConfig{TableCount: 2, RowsPerTable: 50, Seed: 7}
+	dump := func(path string) []string {
+		return queryTestStrings(t, path, "SELECT table_1::VARCHAR FROM table_1 ORDER BY id")
+	}
+	first, second := filepath.Join(t.TempDir(), "first.db"), filepath.Join(t.TempDir(), "second.db")
+	for _, path := range []string{first, second} {
+		if err := GenerateSampleDatabase(path, cfg); err != nil {
+			t.Fatalf("GenerateSampleDatabase: %v", err)
+		}
+	}
+	if got, want := dump(second), dump(first); !slices.Equal(got, want) {
+		t.Errorf("same seed generated different data:\n%v\n%v", got, want)
+	}
+
+	cfg.Seed = 8
+	other := filepath.Join(t.TempDir(), "other.db")
+	if err := GenerateSampleDatabase(other, cfg); err != nil {
+		t.Fatalf("GenerateSampleDatabase: %v", err)
+	}
+	if slices.Equal(dump(other), dump(first)) {
+		t.Error("different seeds generated the same data")
+	}
+}
+
+func TestGenerateSampleDatabaseColumnTypes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "typed.db")
+	if err := GenerateSampleDatabase(path, SampleDBConfig{TableCount: 1, RowsPerTable: 5, ColumnTypes: []string{"uuid", "date"}}); err != nil {
+		t.Fatalf("GenerateSampleDatabase: %v", err)
+	}
+	got := queryTestStrings(t, path, "SELECT column_name || ' ' || data_type FROM duckdb_columns() WHERE table_name = 'table_1' ORDER BY column_index")
+	if want := []string{"id BIGINT", "uuid_1 UUID", "date_2 DATE"}; !slices.Equal(got, want) {
+		t.Errorf("columns = %v, want %v", got, want)
+	}
+
+	for _, cfg := range []SampleDBConfig{
+		{TableCount: 0},
+		{TableCount: 1, RowsPerTable: -1},
+		{TableCount: 1, ColumnTypes: []string{"BLOB"}},
+	} {
+		if err := GenerateSampleDatabase(filepath.Join(t.TempDir(), "invalid.db"), cfg); err == nil {
+			t.Errorf("GenerateSampleDatabase accepted %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// snapshotPrefix marks versions created by the snapshot scheduler
+const snapshotPrefix = "snapshot-"
+
+// StartSnapshotScheduler periodically stores dbFilePath until ctx is cancelled or the storage is
+// closed. Every snapshot is also kept as a timestamped version, pruned to the limit set by
+// WithMaxSnapshots.
+func (d *DuckDBStorage) StartSnapshotScheduler(ctx context.Context, dbFilePath string, interval time.Duration) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if interval <= 0 {
+		return fmt.Errorf("invalid snapshot interval: %v", interval)
+	}
+	if _, err := os.Stat(dbFilePath); err != nil {
+		return fmt.Errorf("failed to stat database file: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.schedulerErrs != nil {
+		return errors.New("snapshot scheduler already running")
+	}
+	errs := make(chan error, 16)
+
+	err = d.goBackgroundLocked(ctx, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		defer func() {
+			d.mu.Lock()
+			d.schedulerErrs = nil
+			d.mu.Unlock()
+			close(errs)
+		}()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := d.snapshot(dbFilePath); err != nil {
+					d.opts.Logger.Error("snapshot failed", "db", d.dbName, "path", dbFilePath, "error", err)
+					// Never block the scheduler on a slow reader
+					select {
+					case errs <- err:
+					default:
+					}
+				}
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	d.schedulerErrs = errs
+
+	return nil
+}
+
+// SchedulerErrors returns the channel on which snapshot failures are reported. The channel is
+// closed when the scheduler stops and is nil if no scheduler is running.
+func (d *DuckDBStorage) SchedulerErrors() <-chan error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.schedulerErrs
+}
+
+// snapshot stores the database and records it as a new timestamped version. With lock
+// enforcement enabled the lock is held until the version is recorded, so the version is a copy
+// of the database this snapshot stored. A snapshot in flight when the scheduler is cancelled
+// still completes; only Close aborts it.
+func (d *DuckDBStorage) snapshot(dbFilePath string) error {
+	ctx, cancel := d.lifetime(context.Background())
+	defer cancel()
+
+	var locks []Lock
+	if d.opts.EnforceLock {
+		lock, err := d.acquireLock(ctx, storeLockTTL)
+		if err != nil {
+			return fmt.Errorf("snapshot failed: %w", err)
+		}
+		defer lock.Release()
+		locks = []Lock{lock}
+	}
+
+	err := d.storeFile(ctx, dbFilePath, d.opts.Deduplicate, d.opts.ChunkSize, locks, nil)
+	if errors.Is(err, ErrUnchanged) {
+		// Nothing changed since the last snapshot
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("snapshot failed: %w", err)
+	}
+
+	version := snapshotPrefix + time.Now().UTC().Format("20060102T150405.000000000Z")
+	if err := d.storeSnapshot(ctx, dbFilePath, version); err != nil {
+		return fmt.Errorf("snapshot failed: %w", err)
+	}
+
+	return d.pruneSnapshots()
+}
+
+// storeSnapshot records the database just stored from dbFilePath as version. A single database
+// object is copied within the bucket rather than uploaded again; versions are single objects,
+// so a chunked database is uploaded from the file.
+func (d *DuckDBStorage) storeSnapshot(ctx context.Context, dbFilePath, version string) error {
+	name := d.versionName(version)
+	if err := d.checkQuota(dbFilePath, name); err != nil {
+		return err
+	}
+
+	var err error
+	if d.opts.ChunkSize > 0 {
+		_, err = d.putDatabase(ctx, name, dbFilePath, versionHeaders(version))
+	} else {
+		_, err = copyObjectTo(ctx, d.obs, d.dbName, d.obs, name, versionHeaders(version))
+	}
+	if err != nil {
+		return err
+	}
+	if err := d.storeSchemaFor(ctx, name, dbFilePath); err != nil {
+		d.opts.Logger.Error("failed to store version schema", "db", d.dbName, "version", version, "error", err)
+	}
+
+	return d.addVersion(version)
+}
+
+// pruneSnapshots deletes the oldest scheduler snapshots beyond MaxSnapshots
+func (d *DuckDBStorage) pruneSnapshots() error {
+	if d.opts.MaxSnapshots <= 0 {
+		return nil
+	}
+
+	versions, err := d.listVersions()
+	if err != nil {
+		return err
+	}
+
+	var snapshots []string
+	for _, version := range versions {
+		if strings.HasPrefix(version, snapshotPrefix) {
+			snapshots = append(snapshots, version)
+		}
+	}
+	if len(snapshots) <= d.opts.MaxSnapshots {
+		return nil
+	}
+
+	expired := snapshots[:len(snapshots)-d.opts.MaxSnapshots]
+	for _, version := range expired {
+		if err := d.obs.Delete(d.versionName(version)); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return fmt.Errorf("failed to delete snapshot %s: %w", version, err)
+		}
+		if err := d.obs.Delete(schemaName(d.versionName(version))); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return fmt.Errorf("failed to delete schema of snapshot %s: %w", version, err)
+		}
+	}
+
+	return d.putVersions(slices.DeleteFunc(versions, func(version string) bool {
+		return slices.Contains(expired, version)
+	}))
+}
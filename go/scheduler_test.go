@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSnapshotScheduler(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := s.StartSnapshotScheduler(ctx, path, 100*time.Millisecond); err != nil {
+		t.Fatalf("StartSnapshotScheduler: %v", err)
+	}
+	if err := s.StartSnapshotScheduler(ctx, path, 100*time.Millisecond); err == nil {
+		t.Error("a second scheduler was started")
+	}
+	errs := s.SchedulerErrors()
+
+	deadline := time.Now().Add(5 * time.Second)
+	var snapshots []string
+	for len(snapshots) < 3 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+		versions, err := s.ListVersions()
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshots = snapshots[:0]
+		for _, version := range versions {
+			if strings.HasPrefix(version, snapshotPrefix) {
+				snapshots = append(snapshots, version)
+			}
+		}
+	}
+	if len(snapshots) < 3 {
+		t.Fatalf("%d snapshots created, want 3", len(snapshots))
+	}
+
+	cancel()
+	for err := range errs {
+		t.Errorf("snapshot failed: %v", err)
+	}
+	if s.SchedulerErrors() != nil {
+		t.Error("scheduler still running after cancellation")
+	}
+}
+
+func TestSnapshotSchedulerPrunes(t *testing.T) {
+	s, path := storeTestDatabase(t, WithMaxSnapshots(2))
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := s.StartSnapshotScheduler(ctx, path, 50*time.Millisecond); err != nil {
+		t.Fatalf("StartSnapshotScheduler: %v", err)
+	}
+	errs := s.SchedulerErrors()
+	time.Sleep(400 * time.Millisecond)
+	cancel()
+	for err := range errs {
+		t.Errorf("snapshot failed: %v", err)
+	}
+
+	versions, err := s.ListVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 {
+		t.Errorf("ListVersions = %v, want 2 snapshots", versions)
+	}
+}
+
+func TestSnapshotSchedulerInvalid(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	if err := s.StartSnapshotScheduler(context.Background(), path, 0); err == nil {
+		t.Error("StartSnapshotScheduler accepted a zero interval")
+	}
+	if err := s.StartSnapshotScheduler(context.Background(), path+".missing", time.Second); err == nil {
+		t.Error("StartSnapshotScheduler accepted a missing file")
+	}
+}
+
+func TestSnapshotSchedulerLocked(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithLockEnforcement(true))
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := s.StartSnapshotScheduler(ctx, path, 50*time.Millisecond); err != nil {
+		t.Fatalf("StartSnapshotScheduler: %v", err)
+	}
+	errs := s.SchedulerErrors()
+	time.Sleep(300 * time.Millisecond)
+	cancel()
+	for err := range errs {
+		t.Errorf("snapshot failed: %v", err)
+	}
+
+	versions, err := s.ListVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) == 0 {
+		t.Fatal("no snapshots created with lock enforcement")
+	}
+	out := filepath.Join(t.TempDir(), "snapshot.db")
+	if err := s.RetrieveVersion(versions[len(versions)-1], out); err != nil {
+		t.Fatalf("RetrieveVersion: %v", err)
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("snapshot holds %d users, want 3", n)
+	}
+}
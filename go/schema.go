@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// schemaVersionHeader carries the MD5 of the DDL stored in a schema object
+const schemaVersionHeader = "X-Schema-Version"
+
+// schemaQuery returns the DDL of every user table, view and index in a stable order
+const schemaQuery = `
+	SELECT sql FROM (
+		SELECT 0 AS kind, schema_name, table_name AS name, sql FROM duckdb_tables() WHERE NOT internal AND NOT temporary
+		UNION ALL
+		SELECT 1, schema_name, view_name, sql FROM duckdb_views() WHERE NOT internal AND NOT temporary
+		UNION ALL
+		SELECT 2, schema_name, index_name, sql FROM duckdb_indexes() WHERE sql IS NOT NULL
+	)
+	ORDER BY kind, schema_name, name`
+
+// SchemaDiff lists the DDL statements that differ between two schemas. A changed table shows
+// up as its old statement in Removed and its new statement in Added.
+type SchemaDiff struct {
+	Added   []string
+	Removed []string
+}
+
+// Identical reports whether both schemas have the same DDL
+func (s SchemaDiff) Identical() bool {
+	return len(s.Added) == 0 && len(s.Removed) == 0
+}
+
+// schemaName is the object holding the DDL of the named database object
+func schemaName(name string) string {
+	return name + ".schema"
+}
+
+// StoreSchema stores the DDL of the database file as a text object next to the database
+func (d *DuckDBStorage) StoreSchema(dbFilePath string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	return d.storeSchemaFor(context.Background(), d.dbName, dbFilePath)
+}
+
+// RetrieveSchema returns the stored DDL without retrieving the database itself
+func (d *DuckDBStorage) RetrieveSchema() (_ string, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return "", err
+	}
+	defer func() { end(err) }()
+
+	data, err := d.obs.GetBytes(schemaName(d.dbName))
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve schema from NATS: %w", err)
+	}
+	return string(data), nil
+}
+
+// CompareSchemas diffs the DDL of two stored versions. Versions stored before schema snapshots
+// existed are retrieved in full to extract their DDL.
+func (d *DuckDBStorage) CompareSchemas(versionA, versionB string) (_ SchemaDiff, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+	defer func() { end(err) }()
+
+	ctx := context.Background()
+
+	a, err := d.versionSchema(ctx, versionA)
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+	b, err := d.versionSchema(ctx, versionB)
+	if err != nil {
+		return SchemaDiff{}, err
+	}
+
+	statementsA, statementsB := splitDDL(a), splitDDL(b)
+	var diff SchemaDiff
+	for _, statement := range statementsB {
+		if !slices.Contains(statementsA, statement) {
+			diff.Added = append(diff.Added, statement)
+		}
+	}
+	for _, statement := range statementsA {
+		if !slices.Contains(statementsB, statement) {
+			diff.Removed = append(diff.Removed, statement)
+		}
+	}
+	return diff, nil
+}
+
+// versionSchema returns the DDL of a stored version
+func (d *DuckDBStorage) versionSchema(ctx context.Context, version string) (string, error) {
+	if err := validateVersion(version); err != nil {
+		return "", err
+	}
+
+	data, err := d.obs.GetBytes(schemaName(d.versionName(version)), nats.Context(ctx))
+	if err == nil {
+		return string(data), nil
+	}
+	if !errors.Is(err, nats.ErrObjectNotFound) {
+		return "", fmt.Errorf("failed to retrieve schema of version %s: %w", version, err)
+	}
+
+	path, err := tempPath("duckdb-nats-*.db")
+	if err != nil {
+		return "", err
+	}
+	defer removeTempDatabase(path)
+
+	if err := d.getDatabase(ctx, d.versionName(version), path); err != nil {
+		return "", err
+	}
+	return readSchema(ctx, path)
+}
+
+// storeSchemaFor stores the DDL of the database file as the schema object of name
+func (d *DuckDBStorage) storeSchemaFor(ctx context.Context, name, dbFilePath string) error {
+	ddl, err := readSchema(ctx, dbFilePath)
+	if err != nil {
+		return err
+	}
+
+	sum := md5.Sum([]byte(ddl))
+	_, err = d.obs.Put(&nats.ObjectMeta{
+		Name:        schemaName(name),
+		Description: "DuckDB schema DDL",
+		Headers: nats.Header{
+			"Content-Type":      []string{"text/plain"},
+			schemaVersionHeader: []string{hex.EncodeToString(sum[:])},
+		},
+	}, bytes.NewReader([]byte(ddl)), nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to store schema in NATS: %w", err)
+	}
+	return nil
+}
+
+// readSchema returns the DDL of the database file, one statement per line
+func readSchema(ctx context.Context, dbFilePath string) (string, error) {
+	db, err := openDuckDB(dbFilePath)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	rows, err := db.QueryContext(ctx, schemaQuery)
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema: %w", err)
+	}
+	defer rows.Close()
+
+	var ddl strings.Builder
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			return "", fmt.Errorf("failed to read schema: %w", err)
+		}
+		ddl.WriteString(strings.TrimSuffix(strings.TrimSpace(statement), ";"))
+		ddl.WriteString(";\n")
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read schema: %w", err)
+	}
+	return ddl.String(), nil
+}
+
+// splitDDL splits DDL written by readSchema into its statements. Semicolons and line breaks in
+// string literals, such as column defaults, do not end a statement.
+func splitDDL(ddl string) []string {
+	var statements []string
+	for _, statement := range splitStatements(ddl) {
+		statements = append(statements, statement.text)
+	}
+	return statements
+}
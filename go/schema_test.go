@@ -0,0 +1,81 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSplitDDL(t *testing.T) {
+	ddl := "CREATE TABLE a (id INTEGER);\nCREATE TABLE b (note VARCHAR DEFAULT 'x;\ny');\n"
+	want := []string{"CREATE TABLE a (id INTEGER)", "CREATE TABLE b (note VARCHAR DEFAULT 'x;\ny')"}
+	if got := splitDDL(ddl); !slices.Equal(got, want) {
+		t.Errorf("splitDDL() = %q, want %q", got, want)
+	}
+}
+
+func TestStoreSchema(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	if _, err := s.RetrieveSchema(); err == nil {
+		t.Error("RetrieveSchema succeeded before StoreSchema")
+	}
+	if err := s.StoreSchema(path); err != nil {
+		t.Fatalf("StoreSchema: %v", err)
+	}
+	ddl, err := s.RetrieveSchema()
+	if err != nil {
+		t.Fatalf("RetrieveSchema: %v", err)
+	}
+	if statements := splitDDL(ddl); len(statements) != 1 || !strings.HasPrefix(statements[0], "CREATE TABLE users") {
+		t.Errorf("RetrieveSchema() = %q, want the users table", ddl)
+	}
+}
+
+func TestCompareSchemas(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	if err := s.StoreVersion(path, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	execTestDatabase(t, path,
+		"ALTER TABLE users ADD COLUMN email VARCHAR",
+		"CREATE TABLE notes (id INTEGER, body VARCHAR DEFAULT 'first;\nsecond')",
+	)
+	if err := s.StoreVersion(path, "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	diff, err := s.CompareSchemas("v1", "v2")
+	if err != nil {
+		t.Fatalf("CompareSchemas: %v", err)
+	}
+	if len(diff.Added) != 2 || len(diff.Removed) != 1 {
+		t.Fatalf("CompareSchemas() = %+v, want 2 added and 1 removed statements", diff)
+	}
+	if !slices.ContainsFunc(diff.Added, func(statement string) bool {
+		return strings.HasPrefix(statement, "CREATE TABLE notes") && strings.Contains(statement, "'first;\nsecond'")
+	}) {
+		t.Errorf("added statements %q do not hold the notes table intact", diff.Added)
+	}
+	if !strings.HasPrefix(diff.Removed[0], "CREATE TABLE users") || strings.Contains(diff.Removed[0], "email") {
+		t.Errorf("removed statement %q, want the previous users table", diff.Removed[0])
+	}
+
+	// Versions without a schema snapshot are compared from the database itself
+	if err := s.obs.Delete(schemaName(s.versionName("v1"))); err != nil {
+		t.Fatal(err)
+	}
+	fallback, err := s.CompareSchemas("v1", "v2")
+	if err != nil {
+		t.Fatalf("CompareSchemas without a snapshot: %v", err)
+	}
+	if !slices.Equal(fallback.Added, diff.Added) || !slices.Equal(fallback.Removed, diff.Removed) {
+		t.Errorf("CompareSchemas without a snapshot = %+v, want %+v", fallback, diff)
+	}
+
+	if same, err := s.CompareSchemas("v2", "v2"); err != nil || !same.Identical() {
+		t.Errorf("CompareSchemas of a version with itself = %+v, %v", same, err)
+	}
+	if _, err := s.CompareSchemas("v1", "missing"); err == nil {
+		t.Error("CompareSchemas of a missing version succeeded")
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	// ErrSchemaNotRegistered is returned by SchemaRegistry.Lookup for a database without a schema
+	ErrSchemaNotRegistered = errors.New("schema not registered")
+	// ErrSchemaViolation is returned when a database does not match its registered schema
+	ErrSchemaViolation = errors.New("schema violation")
+)
+
+// SchemaRegistry stores the expected DDL of databases in the <bucket>-schemas KV bucket
+type SchemaRegistry struct {
+	kv nats.KeyValue
+}
+
+// columnRef identifies a column of a table
+type columnRef struct {
+	table  tableRef
+	column string
+}
+
+// NewSchemaRegistry opens the schema registry of the object store bucket, so it can be passed to
+// WithSchemaValidation of a storage using that bucket
+func NewSchemaRegistry(js nats.JetStreamContext, bucket string) (*SchemaRegistry, error) {
+	kv, err := keyValueBucket(js, bucket+"-schemas")
+	if err != nil {
+		return nil, err
+	}
+	return &SchemaRegistry{kv: kv}, nil
+}
+
+// Register records the CREATE TABLE statements a database named dbName must satisfy. The DDL
+// is executed against an empty database first, so invalid statements are rejected.
+func (sr *SchemaRegistry) Register(dbName, ddl string) error {
+	if _, err := ddlColumns(context.Background(), ddl); err != nil {
+		return err
+	}
+	if _, err := sr.kv.PutString(dbName, ddl); err != nil {
+		return fmt.Errorf("failed to register schema for %s: %w", dbName, err)
+	}
+	return nil
+}
+
+// Lookup returns the DDL registered for dbName
+func (sr *SchemaRegistry) Lookup(dbName string) (string, error) {
+	entry, err := sr.kv.Get(dbName)
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return "", fmt.Errorf("%w: %s", ErrSchemaNotRegistered, dbName)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to look up schema for %s: %w", dbName, err)
+	}
+	return string(entry.Value()), nil
+}
+
+// Validate checks that the database file has every table and column registered for dbName
+// with the registered column types. Additional tables and columns are allowed.
+func (sr *SchemaRegistry) Validate(dbFilePath, dbName string) error {
+	ctx := context.Background()
+
+	ddl, err := sr.Lookup(dbName)
+	if err != nil {
+		return err
+	}
+	expected, err := ddlColumns(ctx, ddl)
+	if err != nil {
+		return err
+	}
+
+	db, err := openDuckDB(dbFilePath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	actual, err := databaseColumns(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	var violations []string
+	for _, column := range expected.order {
+		want := expected.types[column]
+		got, ok := actual.types[column]
+		switch {
+		case !ok:
+			violations = append(violations, fmt.Sprintf("%s.%s is missing", column.table, column.column))
+		case got != want:
+			violations = append(violations, fmt.Sprintf("%s.%s is %s, expected %s", column.table, column.column, got, want))
+		}
+	}
+	if len(violations) > 0 {
+		return fmt.Errorf("%w: %s: %s", ErrSchemaViolation, dbName, strings.Join(violations, "; "))
+	}
+	return nil
+}
+
+// columnTypes maps columns to their DuckDB type name, keeping the column order of the DDL
+type columnTypes struct {
+	order []columnRef
+	types map[columnRef]string
+}
+
+// ddlColumns runs ddl against an empty in-memory database and returns the resulting columns
+func ddlColumns(ctx context.Context, ddl string) (columnTypes, error) {
+	db, err := openDuckDB("")
+	if err != nil {
+		return columnTypes{}, err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, ddl); err != nil {
+		return columnTypes{}, fmt.Errorf("invalid schema DDL: %w", err)
+	}
+	return databaseColumns(ctx, db)
+}
+
+// databaseColumns returns the columns of every user table of the default database
+func databaseColumns(ctx context.Context, db *sql.DB) (columnTypes, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT schema_name, table_name, column_name, data_type FROM duckdb_columns()
+		WHERE database_name = current_database() AND NOT internal
+		ORDER BY schema_name, table_name, column_index`)
+	if err != nil {
+		return columnTypes{}, fmt.Errorf("failed to list columns: %w", err)
+	}
+	defer rows.Close()
+
+	columns := columnTypes{types: make(map[columnRef]string)}
+	for rows.Next() {
+		var column columnRef
+		var dataType string
+		if err := rows.Scan(&column.table.schema, &column.table.name, &column.column, &dataType); err != nil {
+			return columnTypes{}, fmt.Errorf("failed to list columns: %w", err)
+		}
+		columns.order = append(columns.order, column)
+		columns.types[column] = dataType
+	}
+	if err := rows.Err(); err != nil {
+		return columnTypes{}, fmt.Errorf("failed to list columns: %w", err)
+	}
+	return columns, nil
+}
+
+// validateSchema checks the file against the registered schema of the database, if any
+func (d *DuckDBStorage) validateSchema(dbFilePath string) error {
+	if d.opts.SchemaRegistry == nil {
+		return nil
+	}
+	err := d.opts.SchemaRegistry.Validate(dbFilePath, d.opts.DBName)
+	if errors.Is(err, ErrSchemaNotRegistered) {
+		return nil
+	}
+	return err
+}
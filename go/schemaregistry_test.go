@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestSchemaRegistry opens the schema registry of the default bucket on a new server
+func newTestSchemaRegistry(t *testing.T) (*SchemaRegistry, *DuckDBStorage) {
+	t.Helper()
+	s := newTestStorage(t, startTestServer(t))
+	js, err := s.nc.JetStream()
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry, err := NewSchemaRegistry(js, s.bucket)
+	if err != nil {
+		t.Fatalf("NewSchemaRegistry: %v", err)
+	}
+	return registry, s
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	registry, _ := newTestSchemaRegistry(t)
+	ddl := "CREATE TABLE users (id INTEGER, name VARCHAR, email VARCHAR)"
+	if err := registry.Register(defaultDBName, ddl); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if got, err := registry.Lookup(defaultDBName); err != nil || got != ddl {
+		t.Errorf("Lookup = %q, %v, want %q", got, err, ddl)
+	}
+	if _, err := registry.Lookup("other.db"); !errors.Is(err, ErrSchemaNotRegistered) {
+		t.Errorf("Lookup of an unregistered database: got %v, want ErrSchemaNotRegistered", err)
+	}
+	if err := registry.Register("broken.db", "CREATE TABLE ("); err == nil {
+		t.Error("Register accepted invalid DDL")
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name      string
+		ddl       string
+		violation string
+	}{
+		{"matching", "CREATE TABLE users (id INTEGER, name VARCHAR, email VARCHAR)", ""},
+		{"extra column and table", "CREATE TABLE users (id INTEGER, name VARCHAR, email VARCHAR, age INTEGER); CREATE TABLE logs (i INTEGER)", ""},
+		{"missing column", "CREATE TABLE users (id INTEGER, name VARCHAR)", "users.email is missing"},
+		{"wrong type", "CREATE TABLE users (id BIGINT, name VARCHAR, email VARCHAR)", "users.id is BIGINT, expected INTEGER"},
+		{"missing table", "CREATE TABLE accounts (id INTEGER)", "users.id is missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.ReplaceAll(tt.name, " ", "-")+".db")
+			execTestDatabase(t, path, tt.ddl)
+			err := registry.Validate(path, defaultDBName)
+			if tt.violation == "" {
+				if err != nil {
+					t.Errorf("Validate: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrSchemaViolation) || !strings.Contains(err.Error(), tt.violation) {
+				t.Errorf("Validate: got %v, want a violation naming %q", err, tt.violation)
+			}
+		})
+	}
+}
+
+func TestStoreSchemaValidation(t *testing.T) {
+	registry, s := newTestSchemaRegistry(t)
+	if err := registry.Register(defaultDBName, "CREATE TABLE users (id INTEGER, name VARCHAR, email VARCHAR)"); err != nil {
+		t.Fatal(err)
+	}
+	validated := newTestStorage(t, s.nc, WithSchemaValidation(registry))
+
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.db")
+	execTestDatabase(t, bad, "CREATE TABLE users (id INTEGER, name VARCHAR)")
+	if err := validated.StoreDuckDB(bad); !errors.Is(err, ErrSchemaViolation) {
+		t.Fatalf("StoreDuckDB of a database missing a column: got %v, want ErrSchemaViolation", err)
+	}
+	if _, err := validated.GetInfo(); err == nil {
+		t.Error("rejected database was uploaded")
+	}
+
+	good := filepath.Join(dir, "good.db")
+	execTestDatabase(t, good, "CREATE TABLE users (id INTEGER, name VARCHAR, email VARCHAR)")
+	if err := validated.StoreDuckDB(good); err != nil {
+		t.Fatalf("StoreDuckDB of a matching database: %v", err)
+	}
+
+	// Databases without a registered schema are stored unchecked
+	other := newTestStorage(t, s.nc, WithSchemaValidation(registry), WithDBName("other.db"))
+	if err := other.StoreDuckDB(bad); err != nil {
+		t.Errorf("StoreDuckDB without a registered schema: %v", err)
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// defaultQueryTimeout bounds remote queries whose request carries no timeout
+const defaultQueryTimeout = 30 * time.Second
+
+// queryRequest is the payload of a remote query
+type queryRequest struct {
+	Query string `json:"query"`
+	Args  []any  `json:"args,omitempty"`
+	// Timeout is a time.ParseDuration string
+	Timeout string `json:"timeout,omitempty"`
+}
+
+// queryResponse is the reply to a remote query
+type queryResponse struct {
+	Rows  []map[string]any `json:"rows"`
+	Error string           `json:"error,omitempty"`
+}
+
+// queryOpener provides the database query runs against, with the rows hidden by the row
+// filters removed. release must be called once the query is done with the database.
+type queryOpener interface {
+	open(ctx context.Context, d *DuckDBStorage, query string) (db *sql.DB, release func(), err error)
+}
+
+// queryCache keeps a local copy of the database between remote queries and refreshes it
+// whenever the stored object changes. Queries against it run one at a time.
+type queryCache struct {
+	mu   sync.Mutex
+	path string
+	nuid string
+	db   *sql.DB
+}
+
+// StartQueryService answers JSON query requests on subject until ctx is cancelled or the storage
+// is closed. Requests look like {"query":"SELECT ...","args":[...],"timeout":"5s"} and the reply
+// carries the result rows. Queries run against a read-only copy of the database without access
+// to files, other databases or extensions.
+func (d *DuckDBStorage) StartQueryService(ctx context.Context, subject string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	ctx, cancel := d.lifetime(ctx)
+	cache := &queryCache{}
+
+	sub, err := d.nc.Subscribe(subject, func(msg *nats.Msg) {
+		d.respondQuery(msg, d.handleQuery(ctx, cache, msg.Data))
+	})
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to subscribe to %s: %w", subject, err)
+	}
+
+	err = d.goBackground(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		cancel()
+		sub.Unsubscribe()
+
+		cache.mu.Lock()
+		cache.close()
+		cache.mu.Unlock()
+	})
+	if err != nil {
+		cancel()
+		sub.Unsubscribe()
+		return err
+	}
+
+	return nil
+}
+
+// respondQuery sends the reply to a remote query request
+func (d *DuckDBStorage) respondQuery(msg *nats.Msg, response queryResponse) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		data, _ = json.Marshal(queryResponse{Error: fmt.Sprintf("failed to encode result: %v", err)})
+	}
+	if err := msg.Respond(data); err != nil {
+		d.opts.Logger.Error("failed to send query reply", "subject", msg.Subject, "error", err)
+	}
+}
+
+// handleQuery runs a single remote query request
+func (d *DuckDBStorage) handleQuery(ctx context.Context, cache queryOpener, payload []byte) queryResponse {
+	var request queryRequest
+	if err := json.Unmarshal(payload, &request); err != nil {
+		return queryResponse{Error: fmt.Sprintf("invalid request: %v", err)}
+	}
+
+	timeout := defaultQueryTimeout
+	if request.Timeout != "" {
+		parsed, err := time.ParseDuration(request.Timeout)
+		if err != nil {
+			return queryResponse{Error: fmt.Sprintf("invalid timeout: %v", err)}
+		}
+		timeout = parsed
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	db, release, err := cache.open(ctx, d, request.Query)
+	if err != nil {
+		return queryResponse{Error: err.Error()}
+	}
+	defer release()
+
+	rows, err := db.QueryContext(ctx, request.Query, request.Args...)
+	if err != nil {
+		return queryResponse{Error: fmt.Sprintf("failed to query database: %v", err)}
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return queryResponse{Error: fmt.Sprintf("failed to read columns: %v", err)}
+	}
+
+	result := []map[string]any{}
+	for rows.Next() {
+		row, err := scanRowMap(rows, columns)
+		if err != nil {
+			return queryResponse{Error: fmt.Sprintf("failed to scan row %d: %v", len(result), err)}
+		}
+		result = append(result, row)
+	}
+	if err := rows.Err(); err != nil {
+		return queryResponse{Error: fmt.Sprintf("failed to read rows: %v", err)}
+	}
+
+	return queryResponse{Rows: result}
+}
+
+// open locks the cache and returns the cached database for query, retrieving a fresh copy if
+// the stored object changed. release unlocks the cache.
+func (c *queryCache) open(ctx context.Context, d *DuckDBStorage, query string) (*sql.DB, func(), error) {
+	c.mu.Lock()
+	db, err := c.load(ctx, d)
+	if err != nil {
+		c.mu.Unlock()
+		return nil, nil, err
+	}
+	return d.filterCached(ctx, db, c.path, query, c.mu.Unlock)
+}
+
+// filterCached returns the cached database db for query, or with row filters configured a
+// filtered copy of the database at path. release is extended to close the copy.
+func (d *DuckDBStorage) filterCached(ctx context.Context, db *sql.DB, path, query string, release func()) (*sql.DB, func(), error) {
+	if !d.hasRowFilters() {
+		return db, release, nil
+	}
+
+	filtered, closeFiltered, err := d.openFiltered(ctx, path, query)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	return filtered, func() {
+		closeFiltered()
+		release()
+	}, nil
+}
+
+// load returns the cached database, retrieving a fresh copy if the stored object changed
+func (c *queryCache) load(ctx context.Context, d *DuckDBStorage) (*sql.DB, error) {
+	name := d.dbName
+	if d.opts.ChunkSize > 0 {
+		name = d.manifestName()
+	}
+	info, err := d.obs.GetInfo(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database info: %w", err)
+	}
+	if c.db != nil && info.NUID == c.nuid {
+		return c.db, nil
+	}
+
+	path, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db, err := openDuckDBReadOnly(path)
+	if err != nil {
+		removeTempDatabase(path)
+		return nil, err
+	}
+
+	c.close()
+	c.path, c.nuid, c.db = path, info.NUID, db
+	return db, nil
+}
+
+// close releases the cached database copy
+func (c *queryCache) close() {
+	if c.db == nil {
+		return
+	}
+	c.db.Close()
+	removeTempDatabase(c.path)
+	c.db = nil
+}
+
+// QueryRemote sends a query to a StartQueryService listening on serverSubject and returns the
+// result rows. The deadline of ctx, if any, is forwarded as the server-side timeout.
+func (d *DuckDBStorage) QueryRemote(ctx context.Context, serverSubject, query string, args ...any) (_ []map[string]any, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	request := queryRequest{Query: query, Args: args}
+	if deadline, ok := ctx.Deadline(); ok {
+		request.Timeout = time.Until(deadline).String()
+	}
+
+	data, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query request: %w", err)
+	}
+
+	msg, err := d.nc.RequestWithContext(ctx, serverSubject, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send query request: %w", err)
+	}
+
+	var response queryResponse
+	if err := json.Unmarshal(msg.Data, &response); err != nil {
+		return nil, fmt.Errorf("failed to decode query response: %w", err)
+	}
+	if response.Error != "" {
+		return nil, errors.New("remote query failed: " + response.Error)
+	}
+
+	return response.Rows, nil
+}
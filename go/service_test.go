@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+func TestQueryService(t *testing.T) {
+	server, _ := storeTestDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.StartQueryService(ctx, "query.service"); err != nil {
+		t.Fatalf("StartQueryService: %v", err)
+	}
+
+	// The client runs on a connection of its own
+	type result struct {
+		rows []map[string]any
+		err  error
+	}
+	nc, err := nats.Connect(server.nc.ConnectedUrl())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	client := newTestStorage(t, nc)
+	results := make(chan result, 2)
+	go func() {
+		rows, err := client.QueryRemote(ctx, "query.service", "SELECT name FROM users WHERE id > ? ORDER BY id", 1)
+		results <- result{rows, err}
+		rows, err = client.QueryRemote(ctx, "query.service", "SELECT missing FROM users")
+		results <- result{rows, err}
+	}()
+
+	ok := <-results
+	if ok.err != nil {
+		t.Fatalf("QueryRemote: %v", ok.err)
+	}
+	if len(ok.rows) != 2 || ok.rows[0]["name"] != "Bob" || ok.rows[1]["name"] != "Charlie" {
+		t.Errorf("rows = %v, want Bob and Charlie", ok.rows)
+	}
+	if failed := <-results; failed.err == nil {
+		t.Error("QueryRemote of an invalid query succeeded")
+	}
+}
+
+func TestQueryServiceReadOnly(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.StartQueryService(ctx, "query.service"); err != nil {
+		t.Fatalf("StartQueryService: %v", err)
+	}
+
+	dir := t.TempDir()
+	for _, query := range []string{
+		"COPY users TO " + quoteLiteral(filepath.Join(dir, "users.csv")),
+		"SELECT * FROM read_csv('/etc/passwd')",
+		"ATTACH " + quoteLiteral(filepath.Join(dir, "other.db")) + " AS other",
+		"INSTALL httpfs",
+		"SET enable_external_access = true",
+		"DELETE FROM users",
+	} {
+		if _, err := s.QueryRemote(ctx, "query.service", query); err == nil {
+			t.Errorf("%s succeeded", query)
+		}
+	}
+
+	rows, err := s.QueryRemote(ctx, "query.service", "SELECT count(*) AS n FROM users")
+	if err != nil {
+		t.Fatalf("QueryRemote: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["n"] != float64(3) {
+		t.Errorf("rows = %v, want a count of 3", rows)
+	}
+}
+
+func TestQueryServiceClosed(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	if err := s.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StartQueryService(context.Background(), "query.service"); !errors.Is(err, ErrStorageClosed) {
+		t.Fatalf("StartQueryService after Close: got %v, want ErrStorageClosed", err)
+	}
+}
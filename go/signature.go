@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/nats-io/nats.go"
+)
+
+// signatureHeader holds the base64 DER encoded ECDSA signature of the stored object
+const signatureHeader = "X-Content-Signature"
+
+var (
+	// ErrSignatureMissing is returned by VerifySignature for databases that were never signed
+	ErrSignatureMissing = errors.New("signature missing")
+	// ErrSignatureInvalid is returned by VerifySignature if the signature does not match the content
+	ErrSignatureInvalid = errors.New("signature invalid")
+)
+
+// SignDatabase signs the SHA-256 of the stored database object with privateKey and records the
+// signature in the object metadata. The signature covers the object as stored, after compression
+// and encryption, and is dropped by the next store.
+func (d *DuckDBStorage) SignDatabase(privateKey *ecdsa.PrivateKey) (err error) {
+	op := d.logOperation("sign_database")
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	digest, info, err := d.objectDigest(context.Background(), d.dbName)
+	if err != nil {
+		return err
+	}
+
+	signature, err := ecdsa.SignASN1(rand.Reader, privateKey, digest)
+	if err != nil {
+		return fmt.Errorf("failed to sign %s: %w", d.dbName, err)
+	}
+
+	meta := info.ObjectMeta
+	meta.Headers = cloneHeader(info.Headers)
+	meta.Headers.Set(signatureHeader, base64.StdEncoding.EncodeToString(signature))
+	if err := d.obs.UpdateMeta(d.dbName, &meta); err != nil {
+		return fmt.Errorf("failed to update %s: %w", d.dbName, err)
+	}
+	return nil
+}
+
+// VerifySignature checks the signature recorded by SignDatabase against the stored database
+// object. It returns ErrSignatureMissing if the database is not signed and ErrSignatureInvalid if
+// the object was modified or signed with a different key.
+func (d *DuckDBStorage) VerifySignature(publicKey *ecdsa.PublicKey) (err error) {
+	op := d.logOperation("verify_signature")
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	digest, info, err := d.objectDigest(context.Background(), d.dbName)
+	if err != nil {
+		return err
+	}
+
+	encoded := info.Headers.Get(signatureHeader)
+	if encoded == "" {
+		return fmt.Errorf("%w: %s", ErrSignatureMissing, d.dbName)
+	}
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrSignatureInvalid, d.dbName, err)
+	}
+	if !ecdsa.VerifyASN1(publicKey, digest, signature) {
+		return fmt.Errorf("%w: %s", ErrSignatureInvalid, d.dbName)
+	}
+	return nil
+}
+
+// objectDigest returns the SHA-256 of the raw bytes of the named object along with its info
+func (d *DuckDBStorage) objectDigest(ctx context.Context, name string) ([]byte, *nats.ObjectInfo, error) {
+	result, err := d.obs.Get(name, nats.Context(ctx))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s: %w", name, err)
+	}
+	defer result.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, result); err != nil {
+		return nil, nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	info, err := result.Info()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get %s: %w", name, err)
+	}
+	return hash.Sum(nil), info, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+)
+
+// generateTestKey returns a new P-256 key
+func generateTestKey(tb testing.TB) *ecdsa.PrivateKey {
+	tb.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return key
+}
+
+func TestSignDatabase(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	key := generateTestKey(t)
+	if err := s.VerifySignature(&key.PublicKey); !errors.Is(err, ErrSignatureMissing) {
+		t.Fatalf("VerifySignature of an unsigned database: got %v, want ErrSignatureMissing", err)
+	}
+
+	if err := s.SignDatabase(key); err != nil {
+		t.Fatalf("SignDatabase: %v", err)
+	}
+	if err := s.VerifySignature(&key.PublicKey); err != nil {
+		t.Errorf("VerifySignature: %v", err)
+	}
+	if err := s.VerifySignature(&generateTestKey(t).PublicKey); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("VerifySignature with another key: got %v, want ErrSignatureInvalid", err)
+	}
+
+	// The next store drops the signature
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	if err := s.VerifySignature(&key.PublicKey); !errors.Is(err, ErrSignatureMissing) {
+		t.Errorf("VerifySignature after a store: got %v, want ErrSignatureMissing", err)
+	}
+}
+
+func TestVerifySignatureTampered(t *testing.T) {
+	for _, algorithm := range []CompressionAlgorithm{CompressionNone, CompressionGzip} {
+		t.Run(algorithmName(algorithm), func(t *testing.T) {
+			s, _ := storeTestDatabase(t, WithCompression(algorithm))
+			key := generateTestKey(t)
+			if err := s.SignDatabase(key); err != nil {
+				t.Fatalf("SignDatabase: %v", err)
+			}
+
+			// Replace the content while keeping the headers holding the signature
+			info, err := s.obs.GetInfo(s.dbName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data, err := s.obs.GetBytes(s.dbName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			data[len(data)/2] ^= 0xff
+			if _, err := s.obs.Put(&nats.ObjectMeta{Name: info.Name, Headers: info.Headers}, bytes.NewReader(data)); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.VerifySignature(&key.PublicKey); !errors.Is(err, ErrSignatureInvalid) {
+				t.Errorf("VerifySignature of tampered content: got %v, want ErrSignatureInvalid", err)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureMalformed(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	info, err := s.obs.GetInfo(s.dbName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := info.ObjectMeta
+	meta.Headers = cloneHeader(info.Headers)
+	meta.Headers.Set(signatureHeader, "not base64!")
+	if err := s.obs.UpdateMeta(s.dbName, &meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.VerifySignature(&generateTestKey(t).PublicKey); !errors.Is(err, ErrSignatureInvalid) {
+		t.Errorf("VerifySignature of a malformed signature: got %v, want ErrSignatureInvalid", err)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ErrObjectNotFound is returned when the requested object does not exist in the bucket
+var ErrObjectNotFound = errors.New("object not found")
+
+// GetObjectSize returns the stored size of the named object without retrieving it
+func (d *DuckDBStorage) GetObjectSize(name string) (_ int64, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { end(err) }()
+
+	info, err := d.obs.GetInfo(d.objectKey(name))
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return 0, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get info for %s: %w", name, err)
+	}
+	return int64(info.Size), nil
+}
+
+// EstimateRetrieveDuration gives a rough download time for the named object at networkMBps
+// megabytes per second
+func (d *DuckDBStorage) EstimateRetrieveDuration(name string, networkMBps float64) (_ time.Duration, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { end(err) }()
+
+	if networkMBps <= 0 {
+		return 0, fmt.Errorf("invalid network throughput: %v MB/s", networkMBps)
+	}
+
+	size, err := d.GetObjectSize(name)
+	if err != nil {
+		return 0, err
+	}
+
+	seconds := float64(size) / (networkMBps * 1e6)
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetObjectSize(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	size, err := s.GetObjectSize(s.dbName)
+	if err != nil {
+		t.Fatalf("GetObjectSize: %v", err)
+	}
+	if size != fi.Size() {
+		t.Errorf("GetObjectSize = %d, want %d", size, fi.Size())
+	}
+
+	duration, err := s.EstimateRetrieveDuration(s.dbName, 1)
+	if err != nil {
+		t.Fatalf("EstimateRetrieveDuration: %v", err)
+	}
+	if want := time.Duration(float64(size) / 1e6 * float64(time.Second)); duration != want {
+		t.Errorf("EstimateRetrieveDuration = %v, want %v", duration, want)
+	}
+	if _, err := s.EstimateRetrieveDuration(s.dbName, 0); err == nil {
+		t.Error("EstimateRetrieveDuration accepted a zero throughput")
+	}
+}
+
+func TestGetObjectSizeNotFound(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	_, err := s.GetObjectSize("missing.db")
+	if !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("GetObjectSize: got %v, want ErrObjectNotFound", err)
+	}
+	if _, err := s.EstimateRetrieveDuration("missing.db", 1); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("EstimateRetrieveDuration: got %v, want ErrObjectNotFound", err)
+	}
+
+	// Other failures are not reported as a missing object
+	s.nc.Close()
+	if _, err := s.GetObjectSize(s.dbName); err == nil || errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("GetObjectSize on a closed connection: got %v", err)
+	}
+}
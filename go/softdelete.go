@@ -0,0 +1,280 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// deletedAtHeader marks an object as soft-deleted
+const deletedAtHeader = "X-Deleted-At"
+
+// DeletedEntry describes a soft-deleted database awaiting purge. The entry only applies while
+// the object still carries the deletion mark set with it, storing the database again unmarks it.
+type DeletedEntry struct {
+	Name      string    `json:"name"`
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (d *DuckDBStorage) tombstoneBucket() string {
+	return d.bucket + "-tombstones"
+}
+
+// SoftDelete marks a database as deleted and keeps it for retainFor before PurgeExpired removes
+// it. A zero retention deletes the object immediately. Pinned databases are only deleted when
+// WithForce is passed.
+func (d *DuckDBStorage) SoftDelete(dbName string, retainFor time.Duration, opts ...DeleteOption) (err error) {
+	op := d.logOperation("soft_delete", "name", dbName, "retain_for", retainFor)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	return d.softDelete(d.objectKey(dbName), retainFor, opts)
+}
+
+// softDelete soft-deletes the object named dbName, which already includes the namespace
+func (d *DuckDBStorage) softDelete(dbName string, retainFor time.Duration, opts []DeleteOption) error {
+	if err := d.checkPinned(dbName, opts); err != nil {
+		return err
+	}
+	if retainFor <= 0 {
+		return d.deleteDatabaseObjects(dbName)
+	}
+
+	infos, err := d.databaseInfos(dbName)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		return fmt.Errorf("failed to get %s: %w", dbName, nats.ErrObjectNotFound)
+	}
+
+	now := time.Now().UTC()
+	entry := DeletedEntry{Name: dbName, DeletedAt: now, ExpiresAt: now.Add(retainFor)}
+
+	kv, err := d.keyValue(d.tombstoneBucket())
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to encode tombstone: %w", err)
+	}
+	if _, err := kv.Put(dbName, data); err != nil {
+		return fmt.Errorf("failed to store tombstone: %w", err)
+	}
+
+	for _, info := range infos {
+		meta := info.ObjectMeta
+		meta.Headers = cloneHeader(info.Headers)
+		meta.Headers.Set(deletedAtHeader, now.Format(time.RFC3339))
+		if err := d.obs.UpdateMeta(info.Name, &meta); err != nil {
+			return fmt.Errorf("failed to mark %s as deleted: %w", info.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// databaseInfos returns the info of the database object name and of its chunk manifest, leaving
+// out the ones that do not exist. The deletion mark of a chunked database is kept on its
+// manifest.
+func (d *DuckDBStorage) databaseInfos(name string) ([]*nats.ObjectInfo, error) {
+	var infos []*nats.ObjectInfo
+	for _, object := range []string{name, manifestNameOf(name)} {
+		info, err := d.obs.GetInfo(object)
+		if errors.Is(err, nats.ErrObjectNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", object, err)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// PurgeExpired permanently deletes soft-deleted databases whose retention has passed and
+// objects whose WithObjectTTL expiry has passed, across all namespaces of the bucket
+func (d *DuckDBStorage) PurgeExpired() (purged int, err error) {
+	op := d.logOperation("purge_expired")
+	defer func() { op.done(err, "purged", purged) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return purged, err
+	}
+	defer func() { end(err) }()
+
+	entries, kv, err := d.tombstones()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, entry := range entries {
+		marked, err := d.markedDeleted(entry)
+		if err != nil {
+			return purged, err
+		}
+		if marked && now.Before(entry.ExpiresAt) {
+			continue
+		}
+		// A database stored again after the soft delete lost its mark and is kept
+		if marked {
+			if err := d.deleteDatabaseObjects(entry.Name); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+				return purged, fmt.Errorf("failed to purge %s: %w", entry.Name, err)
+			}
+		}
+		if err := kv.Delete(entry.Name); err != nil {
+			return purged, fmt.Errorf("failed to remove tombstone for %s: %w", entry.Name, err)
+		}
+		if marked {
+			purged++
+		}
+	}
+
+	expired, err := d.purgeExpiredObjects()
+	purged += expired
+	return purged, err
+}
+
+// markedDeleted reports whether the objects of a tombstone still carry the deletion mark of
+// that soft delete
+func (d *DuckDBStorage) markedDeleted(entry DeletedEntry) (bool, error) {
+	infos, err := d.databaseInfos(entry.Name)
+	if err != nil {
+		return false, err
+	}
+	for _, info := range infos {
+		if info.Headers.Get(deletedAtHeader) != entry.DeletedAt.Format(time.RFC3339) {
+			return false, nil
+		}
+	}
+	return len(infos) > 0, nil
+}
+
+// deleteDatabaseObjects deletes the database object name together with its chunks and
+// manifest if it was stored in chunks
+func (d *DuckDBStorage) deleteDatabaseObjects(name string) error {
+	chunked, err := d.deleteChunked(name)
+	if err != nil {
+		return err
+	}
+	err = d.obs.Delete(name)
+	if chunked && errors.Is(err, nats.ErrObjectNotFound) {
+		return nil
+	}
+	return err
+}
+
+// RestoreDeleted undoes a soft delete that has not been purged yet
+func (d *DuckDBStorage) RestoreDeleted(dbName string) (err error) {
+	op := d.logOperation("restore_deleted", "name", dbName)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	dbName = d.objectKey(dbName)
+
+	kv, err := d.keyValue(d.tombstoneBucket())
+	if err != nil {
+		return err
+	}
+	if err := kv.Delete(dbName); err != nil {
+		return fmt.Errorf("failed to remove tombstone for %s: %w", dbName, err)
+	}
+
+	infos, err := d.databaseInfos(dbName)
+	if err != nil {
+		return err
+	}
+	if len(infos) == 0 {
+		return fmt.Errorf("%s was already purged: %w", dbName, nats.ErrObjectNotFound)
+	}
+
+	for _, info := range infos {
+		meta := info.ObjectMeta
+		meta.Headers = cloneHeader(info.Headers)
+		meta.Headers.Del(deletedAtHeader)
+		if err := d.obs.UpdateMeta(info.Name, &meta); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", info.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// ListDeleted returns the soft-deleted databases of the namespace with their expiry time
+func (d *DuckDBStorage) ListDeleted() (_ []DeletedEntry, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	entries, _, err := d.tombstones()
+	if err != nil {
+		return nil, err
+	}
+
+	var deleted []DeletedEntry
+	for _, entry := range entries {
+		name, ok := d.logicalName(entry.Name)
+		if !ok {
+			continue
+		}
+		marked, err := d.markedDeleted(entry)
+		if err != nil {
+			return nil, err
+		}
+		if marked {
+			entry.Name = name
+			deleted = append(deleted, entry)
+		}
+	}
+	return deleted, nil
+}
+
+func (d *DuckDBStorage) tombstones() ([]DeletedEntry, nats.KeyValue, error) {
+	kv, err := d.keyValue(d.tombstoneBucket())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keys, err := kv.Keys()
+	if errors.Is(err, nats.ErrNoKeysFound) {
+		return nil, kv, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list tombstones: %w", err)
+	}
+
+	var entries []DeletedEntry
+	for _, key := range keys {
+		value, err := kv.Get(key)
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read tombstone %s: %w", key, err)
+		}
+
+		var entry DeletedEntry
+		if err := json.Unmarshal(value.Value(), &entry); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode tombstone %s: %w", key, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, kv, nil
+}
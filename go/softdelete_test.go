@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listedTestDatabases returns the number of databases ListDatabases reports for s
+func listedTestDatabases(t *testing.T, s *DuckDBStorage) int {
+	t.Helper()
+	entries, err := s.ListDatabases()
+	if err != nil {
+		t.Fatalf("ListDatabases: %v", err)
+	}
+	return len(entries)
+}
+
+func TestSoftDelete(t *testing.T) {
+	for _, chunkSize := range []int64{0, 16 << 10} {
+		t.Run(fmt.Sprintf("chunk=%d", chunkSize), func(t *testing.T) {
+			s, _ := storeTestDatabase(t, WithChunkSize(chunkSize))
+			if err := s.SoftDelete(s.dbName, time.Hour); err != nil {
+				t.Fatalf("SoftDelete: %v", err)
+			}
+			if n := listedTestDatabases(t, s); n != 0 {
+				t.Errorf("%d databases listed after SoftDelete, want 0", n)
+			}
+			deleted, err := s.ListDeleted()
+			if err != nil || len(deleted) != 1 || deleted[0].Name != s.dbName {
+				t.Fatalf("ListDeleted() = %v, %v, want %s", deleted, err, s.dbName)
+			}
+
+			// Nothing is purged within the retention
+			if purged, err := s.PurgeExpired(); err != nil || purged != 0 {
+				t.Fatalf("PurgeExpired within the retention = %d, %v, want 0", purged, err)
+			}
+			if err := s.RestoreDeleted(s.dbName); err != nil {
+				t.Fatalf("RestoreDeleted: %v", err)
+			}
+			if deleted, err := s.ListDeleted(); err != nil || len(deleted) != 0 {
+				t.Errorf("ListDeleted() after RestoreDeleted = %v, %v", deleted, err)
+			}
+			if n := listedTestDatabases(t, s); n != 1 {
+				t.Errorf("%d databases listed after RestoreDeleted, want 1", n)
+			}
+			if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); err != nil {
+				t.Errorf("RetrieveDuckDB after RestoreDeleted: %v", err)
+			}
+		})
+	}
+}
+
+func TestSoftDeletePurgeExpired(t *testing.T) {
+	for _, chunkSize := range []int64{0, 16 << 10} {
+		t.Run(fmt.Sprintf("chunk=%d", chunkSize), func(t *testing.T) {
+			s, path := storeTestDatabase(t, WithChunkSize(chunkSize))
+			if err := s.SoftDelete(s.dbName, 50*time.Millisecond); err != nil {
+				t.Fatalf("SoftDelete: %v", err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if purged, err := s.PurgeExpired(); err != nil || purged != 1 {
+				t.Fatalf("PurgeExpired after the retention = %d, %v, want 1", purged, err)
+			}
+			if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); err == nil {
+				t.Error("purged database can still be retrieved")
+			}
+			if chunks := storedChunks(t, s); len(chunks) != 0 {
+				t.Errorf("purge left chunks %v", chunks)
+			}
+			if err := s.RestoreDeleted(s.dbName); err == nil {
+				t.Error("RestoreDeleted of a purged database succeeded")
+			}
+
+			// A database stored again after the soft delete is kept
+			if err := s.StoreDuckDB(path); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SoftDelete(s.dbName, 50*time.Millisecond); err != nil {
+				t.Fatalf("SoftDelete: %v", err)
+			}
+			if err := s.StoreDuckDB(path); err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(100 * time.Millisecond)
+			if purged, err := s.PurgeExpired(); err != nil || purged != 0 {
+				t.Fatalf("PurgeExpired of a stored again database = %d, %v, want 0", purged, err)
+			}
+			if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); err != nil {
+				t.Errorf("RetrieveDuckDB of a stored again database: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// MigrationReport lists the rows copied per table by MigrateFromSQLite
+type MigrationReport struct {
+	Tables []TableMigration
+}
+
+// TableMigration reports the rows copied for a single table
+type TableMigration struct {
+	Name         string
+	RowsMigrated int64
+}
+
+// TotalRows returns the number of rows copied across all tables
+func (r MigrationReport) TotalRows() int64 {
+	var total int64
+	for _, table := range r.Tables {
+		total += table.RowsMigrated
+	}
+	return total
+}
+
+// MigrateFromSQLite copies every table of a SQLite database into a fresh DuckDB database and
+// stores it as outputDBName. The SQLite file is read through DuckDB's sqlite extension, which
+// is installed on first use. Column types are mapped by the extension: TEXT becomes VARCHAR,
+// BLOB stays BLOB and NULLs are kept.
+func (d *DuckDBStorage) MigrateFromSQLite(ctx context.Context, sqliteFilePath, outputDBName string) (report MigrationReport, err error) {
+	op := d.logOperation("migrate_sqlite", "path", sqliteFilePath, "output", outputDBName)
+	defer func() { op.done(err, "tables", len(report.Tables), "rows", report.TotalRows()) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return report, err
+	}
+	defer func() { end(err) }()
+
+	path, err := tempPath("duckdb-nats-sqlite-*.db")
+	if err != nil {
+		return report, err
+	}
+	defer removeTempDatabase(path)
+
+	db, err := openDuckDB(path)
+	if err != nil {
+		return report, err
+	}
+	defer db.Close()
+
+	// Attached databases and loaded extensions are per connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return report, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "INSTALL sqlite; LOAD sqlite"); err != nil {
+		return report, fmt.Errorf("failed to load the sqlite extension: %w", err)
+	}
+	attach := fmt.Sprintf("ATTACH %s AS sqlite_src (TYPE SQLITE, READ_ONLY)", quoteLiteral(sqliteFilePath))
+	if _, err := conn.ExecContext(ctx, attach); err != nil {
+		return report, fmt.Errorf("failed to attach SQLite database: %w", err)
+	}
+
+	tables, err := sqliteTables(ctx, conn)
+	if err != nil {
+		return report, err
+	}
+	for _, table := range tables {
+		result, err := conn.ExecContext(ctx, fmt.Sprintf("CREATE TABLE main.%s AS SELECT * FROM sqlite_src.%s",
+			quoteIdent(table), quoteIdent(table)))
+		if err != nil {
+			return report, fmt.Errorf("failed to migrate table %s: %w", table, err)
+		}
+		rows, err := result.RowsAffected()
+		if err != nil {
+			return report, fmt.Errorf("failed to read migrated row count: %w", err)
+		}
+		report.Tables = append(report.Tables, TableMigration{Name: table, RowsMigrated: rows})
+	}
+
+	if _, err := conn.ExecContext(ctx, "DETACH sqlite_src"); err != nil {
+		return report, fmt.Errorf("failed to detach SQLite database: %w", err)
+	}
+	conn.Close()
+	if err := db.Close(); err != nil {
+		return report, fmt.Errorf("failed to close database: %w", err)
+	}
+
+	if err := d.checkQuota(path, d.objectKey(outputDBName)); err != nil {
+		return report, err
+	}
+	if _, err := d.putDatabase(ctx, d.objectKey(outputDBName), path, nil); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+// sqliteTables returns the tables of the attached SQLite database in name order
+func sqliteTables(ctx context.Context, conn *sql.Conn) ([]string, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT table_name FROM duckdb_tables()
+		WHERE database_name = 'sqlite_src'
+		ORDER BY table_name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SQLite tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			return nil, fmt.Errorf("failed to list SQLite tables: %w", err)
+		}
+		tables = append(tables, table)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list SQLite tables: %w", err)
+	}
+	return tables, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// createTestSQLite creates a SQLite database at path with a notes table holding text, NULL and
+// BLOB values and an empty table
+func createTestSQLite(t *testing.T, path string) {
+	t.Helper()
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	for _, stmt := range []string{
+		"CREATE TABLE notes (id INTEGER PRIMARY KEY, title TEXT, body VARCHAR(100), data BLOB)",
+		"CREATE TABLE empty (id INTEGER)",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("failed to run %q: %v", stmt, err)
+		}
+	}
+	notes := []struct {
+		id    int
+		title any
+		body  any
+		data  any
+	}{
+		{1, "first", "hello", []byte{0x00, 0x01, 0xff}},
+		{2, "second", nil, nil},
+		{3, nil, "no title", []byte("text")},
+	}
+	for _, note := range notes {
+		if _, err := db.Exec("INSERT INTO notes VALUES (?, ?, ?, ?)", note.id, note.title, note.body, note.data); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestMigrateFromSQLite(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "notes.sqlite")
+	createTestSQLite(t, path)
+
+	report, err := s.MigrateFromSQLite(ctx, path, "notes.db")
+	if err != nil && strings.Contains(err.Error(), "sqlite extension") {
+		t.Skipf("sqlite extension unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("MigrateFromSQLite: %v", err)
+	}
+	want := []TableMigration{{Name: "empty"}, {Name: "notes", RowsMigrated: 3}}
+	if len(report.Tables) != len(want) {
+		t.Fatalf("report = %+v, want %+v", report.Tables, want)
+	}
+	for i := range want {
+		if report.Tables[i] != want[i] {
+			t.Errorf("table %d = %+v, want %+v", i, report.Tables[i], want[i])
+		}
+	}
+	if report.TotalRows() != 3 {
+		t.Errorf("TotalRows = %d, want 3", report.TotalRows())
+	}
+
+	migrated := newTestStorage(t, s.nc, WithDBName("notes.db"))
+	rows, err := migrated.QueryRows(ctx, "SELECT id, title, body, data, typeof(title), typeof(data) FROM notes ORDER BY id")
+	if err != nil {
+		t.Fatalf("QueryRows: %v", err)
+	}
+	defer rows.Close()
+
+	var got int
+	for rows.Next() {
+		var id int
+		var title, body sql.NullString
+		var data []byte
+		var titleType, dataType string
+		if err := rows.Scan(&id, &title, &body, &data, &titleType, &dataType); err != nil {
+			t.Fatal(err)
+		}
+		if titleType != "VARCHAR" || dataType != "BLOB" {
+			t.Errorf("column types = %s, %s, want VARCHAR, BLOB", titleType, dataType)
+		}
+		switch id {
+		case 1:
+			if title.String != "first" || body.String != "hello" || !bytes.Equal(data, []byte{0x00, 0x01, 0xff}) {
+				t.Errorf("row 1 = %v, %v, %x", title, body, data)
+			}
+		case 2:
+			if title.String != "second" || body.Valid || data != nil {
+				t.Errorf("row 2 = %v, %v, %x, want NULL body and data", title, body, data)
+			}
+		case 3:
+			if title.Valid || body.String != "no title" || string(data) != "text" {
+				t.Errorf("row 3 = %v, %v, %q, want NULL title", title, body, data)
+			}
+		}
+		got++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if got != 3 {
+		t.Errorf("migrated %d notes, want 3", got)
+	}
+}
+
+func TestMigrateFromSQLiteMissing(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	if _, err := s.MigrateFromSQLite(context.Background(), filepath.Join(t.TempDir(), "missing.sqlite"), "out.db"); err == nil {
+		t.Error("MigrateFromSQLite of a missing file succeeded")
+	}
+}
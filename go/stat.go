@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ObjectStat describes the stored database object without its content
+type ObjectStat struct {
+	Name    string
+	Size    int64
+	Digest  string
+	ModTime time.Time
+	// Revision is the stream sequence of the object's metadata, as reported by CurrentRevision
+	Revision        uint64
+	Headers         map[string][]string
+	Compressed      bool
+	Encrypted       bool
+	CompressionAlgo string
+	// SchemaVersion is the hash of the DDL stored by StoreSchema, empty if no schema is stored
+	SchemaVersion string
+}
+
+// Stat returns the metadata of the stored database without transferring the object content
+func (d *DuckDBStorage) Stat() (stat ObjectStat, err error) {
+	op := d.logOperation("stat")
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return stat, err
+	}
+	defer func() { end(err) }()
+
+	info, err := d.obs.GetInfo(d.dbName)
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return stat, fmt.Errorf("%w: %s", ErrObjectNotFound, d.dbName)
+	}
+	if err != nil {
+		return stat, fmt.Errorf("failed to get %s: %w", d.dbName, err)
+	}
+	revision, err := d.metaRevision(d.dbName)
+	if err != nil {
+		return stat, err
+	}
+
+	algorithm := info.Headers.Get(compressionHeader)
+	stat = ObjectStat{
+		Name:            info.Name,
+		Size:            int64(info.Size),
+		Digest:          info.Digest,
+		ModTime:         info.ModTime,
+		Revision:        revision,
+		Headers:         cloneHeader(info.Headers),
+		Compressed:      algorithm != "",
+		Encrypted:       info.Headers.Get(encryptionHeader) != "",
+		CompressionAlgo: algorithm,
+	}
+
+	schema, err := d.obs.GetInfo(schemaName(d.dbName))
+	if err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+		return stat, fmt.Errorf("failed to get schema of %s: %w", d.dbName, err)
+	}
+	if err == nil {
+		stat.SchemaVersion = schema.Headers.Get(schemaVersionHeader)
+	}
+	return stat, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStat(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       func(t *testing.T) []Option
+		compressed bool
+		encrypted  bool
+		algorithm  string
+	}{
+		{"plain", func(*testing.T) []Option { return nil }, false, false, ""},
+		{"compressed and encrypted", func(t *testing.T) []Option {
+			return []Option{WithCompression(CompressionZstd), WithEncryptionKey(testKey(t))}
+		}, true, true, "zstd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, path := storeTestDatabase(t, tt.opts(t)...)
+			stat, err := s.Stat()
+			if err != nil {
+				t.Fatalf("Stat: %v", err)
+			}
+			if stat.Compressed != tt.compressed || stat.Encrypted != tt.encrypted || stat.CompressionAlgo != tt.algorithm {
+				t.Errorf("stat compressed = %v, encrypted = %v, algorithm = %q, want %v, %v, %q",
+					stat.Compressed, stat.Encrypted, stat.CompressionAlgo, tt.compressed, tt.encrypted, tt.algorithm)
+			}
+
+			info, err := s.GetInfo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			revision, err := s.CurrentRevision()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stat.Name != defaultDBName || stat.Size != int64(info.Size) || stat.Digest != info.Digest || !stat.ModTime.Equal(info.ModTime) || stat.Revision != revision {
+				t.Errorf("stat = %+v, want the object info at revision %d", stat, revision)
+			}
+			if got := stat.Headers[checksumHeader]; len(got) != 1 || got[0] != info.Headers.Get(checksumHeader) {
+				t.Errorf("stat checksum header = %v, want %q", got, info.Headers.Get(checksumHeader))
+			}
+			if stat.SchemaVersion != "" {
+				t.Errorf("schema version without a stored schema = %q", stat.SchemaVersion)
+			}
+
+			if err := s.StoreSchema(path); err != nil {
+				t.Fatalf("StoreSchema: %v", err)
+			}
+			if stat, err := s.Stat(); err != nil || stat.SchemaVersion == "" {
+				t.Errorf("schema version after StoreSchema = %q, %v", stat.SchemaVersion, err)
+			}
+		})
+	}
+}
+
+func TestStatMissing(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	if _, err := s.Stat(); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("Stat without a database: got %v, want ErrObjectNotFound", err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// DatabaseStats summarizes the contents of the stored database
+type DatabaseStats struct {
+	Tables    []TableStats
+	TotalRows int64
+	// FileSizeBytes is the stored size of the database, after compression and encryption
+	FileSizeBytes int64
+	DuckDBVersion string
+}
+
+// TableStats describes one table of the stored database
+type TableStats struct {
+	Name       string
+	RowCount   int64
+	IndexCount int
+}
+
+// DatabaseStats retrieves the database and counts the rows and indexes of every table. Every
+// table is counted in full, so this is expensive for large databases.
+func (d *DuckDBStorage) DatabaseStats(ctx context.Context) (stats *DatabaseStats, err error) {
+	op := d.logOperation("database_stats")
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return stats, err
+	}
+	defer func() { end(err) }()
+
+	stats = &DatabaseStats{}
+	if d.opts.ChunkSize > 0 {
+		manifest, err := d.getManifest()
+		if err != nil {
+			return nil, err
+		}
+		stats.FileSizeBytes = manifest.TotalSize
+	} else {
+		info, err := d.GetInfo()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get %s: %w", d.dbName, err)
+		}
+		stats.FileSizeBytes = int64(info.Size)
+	}
+
+	path, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer removeTempDatabase(path)
+
+	db, err := openDuckDB("")
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, fmt.Sprintf("ATTACH %s AS db (READ_ONLY)", quoteLiteral(path))); err != nil {
+		return nil, fmt.Errorf("failed to attach database: %w", err)
+	}
+
+	if err := db.QueryRowContext(ctx, "SELECT version()").Scan(&stats.DuckDBVersion); err != nil {
+		return nil, fmt.Errorf("failed to read DuckDB version: %w", err)
+	}
+
+	tables, err := attachedTables(ctx, db, "db")
+	if err != nil {
+		return nil, err
+	}
+	for _, table := range tables {
+		ts := TableStats{Name: table.String()}
+
+		qualified := quoteIdent(table.schema) + "." + quoteIdent(table.name)
+		if err := db.QueryRowContext(ctx, "SELECT count(*) FROM db."+qualified).Scan(&ts.RowCount); err != nil {
+			return nil, fmt.Errorf("failed to count rows of %s: %w", table, err)
+		}
+		err := db.QueryRowContext(ctx, `
+			SELECT count(*) FROM duckdb_indexes()
+			WHERE database_name = 'db' AND schema_name = ? AND table_name = ?`,
+			table.schema, table.name,
+		).Scan(&ts.IndexCount)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count indexes of %s: %w", table, err)
+		}
+
+		stats.Tables = append(stats.Tables, ts)
+		stats.TotalRows += ts.RowCount
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestDatabaseStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE a (i INTEGER)",
+		"INSERT INTO a SELECT * FROM range(5)",
+		"CREATE INDEX a_i ON a (i)",
+		"CREATE SCHEMA s",
+		"CREATE TABLE s.b (i INTEGER)",
+		"INSERT INTO s.b VALUES (1), (2)",
+	)
+	nc := startTestServer(t)
+
+	for _, chunkSize := range []int64{0, 100000} {
+		t.Run(fmt.Sprintf("chunk=%d", chunkSize), func(t *testing.T) {
+			s := newTestStorage(t, nc, WithDBName(fmt.Sprintf("stats-%d.db", chunkSize)), WithChunkSize(chunkSize))
+			if err := s.StoreDuckDB(path); err != nil {
+				t.Fatal(err)
+			}
+
+			stats, err := s.DatabaseStats(context.Background())
+			if err != nil {
+				t.Fatalf("DatabaseStats: %v", err)
+			}
+			want := []TableStats{
+				{Name: "a", RowCount: 5, IndexCount: 1},
+				{Name: "s.b", RowCount: 2},
+			}
+			if len(stats.Tables) != len(want) {
+				t.Fatalf("Tables = %+v, want %+v", stats.Tables, want)
+			}
+			for i := range want {
+				if stats.Tables[i] != want[i] {
+					t.Errorf("table %d = %+v, want %+v", i, stats.Tables[i], want[i])
+				}
+			}
+			if stats.TotalRows != 7 {
+				t.Errorf("TotalRows = %d, want 7", stats.TotalRows)
+			}
+			if stats.FileSizeBytes <= 0 {
+				t.Errorf("FileSizeBytes = %d, want the stored size", stats.FileSizeBytes)
+			}
+			if stats.DuckDBVersion == "" {
+				t.Error("DuckDBVersion is empty")
+			}
+		})
+	}
+}
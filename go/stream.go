@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// streamDone is the final message published by StreamQueryResults
+type streamDone struct {
+	Done bool  `json:"_done"`
+	Rows int64 `json:"rows"`
+}
+
+// StreamQueryResults runs a query and publishes each result row as a JSON object to subject.
+// A final {"_done":true,"rows":N} message tells subscribers the result set is complete.
+func (d *DuckDBStorage) StreamQueryResults(ctx context.Context, query string, subject string) (err error) {
+	op := d.logOperation("stream_query", "query", query, "subject", subject)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	rows, err := d.QueryRows(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return fmt.Errorf("failed to read columns: %w", err)
+	}
+
+	var count int64
+	for rows.Next() {
+		row, err := scanRowMap(rows.Rows, columns)
+		if err != nil {
+			return fmt.Errorf("failed to scan row %d: %w", count, err)
+		}
+
+		data, err := json.Marshal(row)
+		if err != nil {
+			return fmt.Errorf("failed to encode row %d: %w", count, err)
+		}
+
+		if err := d.nc.Publish(subject, data); err != nil {
+			return fmt.Errorf("failed to publish row after %d rows: %w", count, err)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read rows after %d rows: %w", count, err)
+	}
+
+	data, err := json.Marshal(streamDone{Done: true, Rows: count})
+	if err != nil {
+		return fmt.Errorf("failed to encode completion message: %w", err)
+	}
+	if err := d.nc.Publish(subject, data); err != nil {
+		return fmt.Errorf("failed to publish completion message after %d rows: %w", count, err)
+	}
+
+	return d.nc.Flush()
+}
+
+// scanRowMap scans the current row into a map keyed by column name
+func scanRowMap(rows *sql.Rows, columns []string) (map[string]any, error) {
+	values := make([]any, len(columns))
+	pointers := make([]any, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	if err := rows.Scan(pointers...); err != nil {
+		return nil, err
+	}
+
+	row := make(map[string]any, len(columns))
+	for i, column := range columns {
+		row[column] = values[i]
+	}
+	return row, nil
+}
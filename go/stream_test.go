@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestStreamQueryResults(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	sub, err := s.nc.SubscribeSync("query.results")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.StreamQueryResults(context.Background(), "SELECT id, name FROM users ORDER BY id", "query.results"); err != nil {
+		t.Fatalf("StreamQueryResults: %v", err)
+	}
+
+	for _, want := range []string{"Alice", "Bob", "Charlie"} {
+		msg, err := sub.NextMsg(time.Second)
+		if err != nil {
+			t.Fatalf("waiting for %s: %v", want, err)
+		}
+		var row struct {
+			ID   int64  `json:"id"`
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(msg.Data, &row); err != nil {
+			t.Fatalf("row %s is not JSON: %v", msg.Data, err)
+		}
+		if row.Name != want {
+			t.Errorf("row name = %q, want %q", row.Name, want)
+		}
+	}
+
+	msg, err := sub.NextMsg(time.Second)
+	if err != nil {
+		t.Fatalf("waiting for the completion message: %v", err)
+	}
+	var done streamDone
+	if err := json.Unmarshal(msg.Data, &done); err != nil {
+		t.Fatal(err)
+	}
+	if !done.Done || done.Rows != 3 {
+		t.Errorf("completion message = %s, want 3 rows", msg.Data)
+	}
+}
+
+func TestStreamQueryResultsInvalidQuery(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	sub, err := s.nc.SubscribeSync("query.results")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.StreamQueryResults(context.Background(), "SELECT * FROM missing", "query.results"); err == nil {
+		t.Fatal("StreamQueryResults accepted a query on a missing table")
+	}
+	if msg, err := sub.NextMsg(100 * time.Millisecond); err == nil {
+		t.Errorf("unexpected message %s", msg.Data)
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ErrTagNotFound is returned for tags that are not in the tag index
+var ErrTagNotFound = errors.New("tag not found")
+
+// maxTagIndexUpdates bounds the attempts to update the tag index against concurrent writers
+const maxTagIndexUpdates = 10
+
+func (d *DuckDBStorage) tagBucket() string {
+	return d.bucket + "-tags"
+}
+
+func (d *DuckDBStorage) tagIndexKey() string {
+	return d.dbName + ".tags"
+}
+
+// StoreWithTag stores the database under tag at dbName@tag and records the revision of the
+// stored object in the tag index. Tags share their objects with StoreVersion and must be valid
+// version labels.
+func (d *DuckDBStorage) StoreWithTag(dbFilePath, tag string) (err error) {
+	op := d.logOperation("store_tag", "path", dbFilePath, "tag", tag)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if err := validateVersion(tag); err != nil {
+		return err
+	}
+	if err := d.checkQuota(dbFilePath, d.versionName(tag)); err != nil {
+		return err
+	}
+
+	name := d.versionName(tag)
+	if _, err := d.putDatabase(context.Background(), name, dbFilePath, versionHeaders(tag)); err != nil {
+		return err
+	}
+	revision, err := d.metaRevision(name)
+	if err != nil {
+		return err
+	}
+
+	return d.updateTags(func(tags map[string]uint64) bool {
+		tags[tag] = revision
+		return true
+	})
+}
+
+// GetByTag retrieves the revision recorded for tag to outputPath. It fails with
+// ErrTagNotFound for unknown tags and ErrRevisionNotFound if the tagged object was replaced
+// since.
+func (d *DuckDBStorage) GetByTag(tag, outputPath string) (err error) {
+	op := d.logOperation("retrieve_tag", "tag", tag, "path", outputPath)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	tags, err := d.ListTags()
+	if err != nil {
+		return err
+	}
+	revision, ok := tags[tag]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrTagNotFound, tag)
+	}
+
+	name := d.versionName(tag)
+	current, err := d.metaRevision(name)
+	if errors.Is(err, ErrObjectNotFound) || err == nil && current != revision {
+		return fmt.Errorf("%w: %d of tag %s", ErrRevisionNotFound, revision, tag)
+	}
+	if err != nil {
+		return err
+	}
+	return d.getDatabase(context.Background(), name, outputPath)
+}
+
+// DeleteTag removes tag from the tag index, the tagged object is kept
+func (d *DuckDBStorage) DeleteTag(tag string) (err error) {
+	op := d.logOperation("delete_tag", "tag", tag)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	found := false
+	err = d.updateTags(func(tags map[string]uint64) bool {
+		_, found = tags[tag]
+		delete(tags, tag)
+		return found
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", ErrTagNotFound, tag)
+	}
+	return nil
+}
+
+// ListTags returns the tag index, mapping each tag to the revision it was stored at
+func (d *DuckDBStorage) ListTags() (_ map[string]uint64, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	kv, err := d.keyValue(d.tagBucket())
+	if err != nil {
+		return nil, err
+	}
+	tags, _, err := d.readTags(kv)
+	return tags, err
+}
+
+// readTags returns the tag index and the KV revision it was read at, zero if there is none yet
+func (d *DuckDBStorage) readTags(kv nats.KeyValue) (map[string]uint64, uint64, error) {
+	tags := make(map[string]uint64)
+	entry, err := kv.Get(d.tagIndexKey())
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return tags, 0, nil
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read tag index: %w", err)
+	}
+	if err := json.Unmarshal(entry.Value(), &tags); err != nil {
+		return nil, 0, fmt.Errorf("failed to decode tag index: %w", err)
+	}
+	return tags, entry.Revision(), nil
+}
+
+// updateTags applies change to the tag index, retrying when another writer updated it first.
+// change reports whether it modified the index.
+func (d *DuckDBStorage) updateTags(change func(tags map[string]uint64) bool) error {
+	kv, err := d.keyValue(d.tagBucket())
+	if err != nil {
+		return err
+	}
+
+	for range maxTagIndexUpdates {
+		tags, revision, err := d.readTags(kv)
+		if err != nil {
+			return err
+		}
+		if !change(tags) {
+			return nil
+		}
+		data, err := json.Marshal(tags)
+		if err != nil {
+			return fmt.Errorf("failed to encode tag index: %w", err)
+		}
+
+		if revision == 0 {
+			_, err = kv.Create(d.tagIndexKey(), data)
+		} else {
+			_, err = kv.Update(d.tagIndexKey(), data, revision)
+		}
+		if err == nil {
+			return nil
+		}
+		// A create or update losing the race reports the key as existing
+		if !errors.Is(err, nats.ErrKeyExists) {
+			return fmt.Errorf("failed to update tag index: %w", err)
+		}
+	}
+	return fmt.Errorf("failed to update tag index: too many concurrent updates")
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestStoreWithTag(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	for i, tag := range []string{"v1", "v2", "v3"} {
+		execTestDatabase(t, path, fmt.Sprintf("INSERT INTO users VALUES (%d, 'User', now())", 10+i))
+		if err := s.StoreWithTag(path, tag); err != nil {
+			t.Fatalf("StoreWithTag(%s): %v", tag, err)
+		}
+	}
+	tags, err := s.ListTags()
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	if len(tags) != 3 || tags["v1"] == 0 || tags["v1"] >= tags["v2"] || tags["v2"] >= tags["v3"] {
+		t.Errorf("tags = %v, want increasing revisions for v1, v2 and v3", tags)
+	}
+
+	if err := s.DeleteTag("v2"); err != nil {
+		t.Fatalf("DeleteTag: %v", err)
+	}
+	if err := s.DeleteTag("v2"); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("second DeleteTag: got %v, want ErrTagNotFound", err)
+	}
+	out := filepath.Join(t.TempDir(), "out.db")
+	if err := s.GetByTag("v2", out); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("GetByTag of a deleted tag: got %v, want ErrTagNotFound", err)
+	}
+	if _, err := s.obs.GetInfo(s.versionName("v2")); err != nil {
+		t.Errorf("object of the deleted tag is gone: %v", err)
+	}
+
+	for tag, want := range map[string]int64{"v1": 4, "v3": 6} {
+		if err := s.GetByTag(tag, out); err != nil {
+			t.Fatalf("GetByTag(%s): %v", tag, err)
+		}
+		if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != want {
+			t.Errorf("tag %s holds %d users, want %d", tag, n, want)
+		}
+	}
+}
+
+func TestStoreWithTagReplaced(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	if err := s.StoreWithTag(path, "v1"); err != nil {
+		t.Fatalf("StoreWithTag: %v", err)
+	}
+	tags, err := s.ListTags()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Storing the version behind the index's back leaves the tag pointing at a revision that
+	// no longer exists
+	if err := s.StoreVersion(path, "v1"); err != nil {
+		t.Fatalf("StoreVersion: %v", err)
+	}
+	if err := s.GetByTag("v1", filepath.Join(t.TempDir(), "out.db")); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("GetByTag of a replaced object: got %v, want ErrRevisionNotFound", err)
+	}
+	if err := s.StoreWithTag(path, "v1"); err != nil {
+		t.Fatalf("StoreWithTag: %v", err)
+	}
+	if retagged, err := s.ListTags(); err != nil || retagged["v1"] <= tags["v1"] {
+		t.Errorf("revision of the retagged v1 = %v, %v, want newer than %d", retagged, err, tags["v1"])
+	}
+}
+
+func TestStoreWithTagConcurrent(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	var wg sync.WaitGroup
+	for i := range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.StoreWithTag(path, fmt.Sprintf("v%d", i)); err != nil {
+				t.Errorf("StoreWithTag: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if tags, err := s.ListTags(); err != nil || len(tags) != 5 {
+		t.Errorf("tags = %v, %v, want 5 tags", tags, err)
+	}
+}
+
+func TestStoreWithTagInvalid(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	for _, tag := range []string{"", "a/b", "a b"} {
+		if err := s.StoreWithTag(path, tag); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("StoreWithTag(%q): got %v, want ErrInvalidVersion", tag, err)
+		}
+	}
+	if tags, err := s.ListTags(); err != nil || len(tags) != 0 {
+		t.Errorf("tags = %v, %v, want none", tags, err)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// tempManifestPrefix names the per-process cleanup manifests in the registry directory
+const tempManifestPrefix = ".duckdb_nats_cleanup_"
+
+// tempNamePattern matches the names tempPath gives temp files, and their write-ahead logs
+var tempNamePattern = regexp.MustCompile(`^duckdb-nats-([a-z]+-)*[0-9]+(\.[a-z]+)?(\.wal)?$`)
+
+// TempFileRegistry records the temp files of this process in a manifest on disk so that files
+// left behind by a crashed process can be removed later by CleanupOrphans
+type TempFileRegistry struct {
+	dir string
+
+	mu    sync.Mutex
+	paths map[string]struct{}
+}
+
+// tempFiles is the registry used by all storage handlers of the process
+var tempFiles = NewTempFileRegistry(os.TempDir())
+
+// NewTempFileRegistry returns a registry keeping its manifests in dir
+func NewTempFileRegistry(dir string) *TempFileRegistry {
+	return &TempFileRegistry{dir: dir, paths: make(map[string]struct{})}
+}
+
+// manifestPath is the manifest of the process with the given pid
+func (r *TempFileRegistry) manifestPath(pid int) string {
+	return filepath.Join(r.dir, tempManifestPrefix+strconv.Itoa(pid))
+}
+
+// RegisterTemp records path in the manifest of this process
+func (r *TempFileRegistry) RegisterTemp(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.paths[path] = struct{}{}
+	return r.writeManifest()
+}
+
+// Release removes path from the manifest once the file has been cleaned up
+func (r *TempFileRegistry) Release(path string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.paths[path]; !ok {
+		return nil
+	}
+	delete(r.paths, path)
+	return r.writeManifest()
+}
+
+// writeManifest replaces the manifest of this process with the registered paths
+func (r *TempFileRegistry) writeManifest() error {
+	manifest := r.manifestPath(os.Getpid())
+	if len(r.paths) == 0 {
+		if err := os.Remove(manifest); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove cleanup manifest: %w", err)
+		}
+		return nil
+	}
+
+	var data strings.Builder
+	for path := range r.paths {
+		data.WriteString(path)
+		data.WriteByte('\n')
+	}
+
+	// Write and rename so a crash never leaves a half-written manifest
+	tmp := manifest + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data.String()), 0600); err != nil {
+		return fmt.Errorf("failed to write cleanup manifest: %w", err)
+	}
+	if err := os.Rename(tmp, manifest); err != nil {
+		return fmt.Errorf("failed to write cleanup manifest: %w", err)
+	}
+	return nil
+}
+
+// CleanupOrphans removes the temp files listed in the manifests of processes that are no longer
+// running and returns the number of files removed. As the registry directory may be shared,
+// only manifests owned by this user and readable by no one else are trusted, and of the paths
+// they list only regular files named like our temp files directly in the directory are removed.
+func (r *TempFileRegistry) CleanupOrphans() (int, error) {
+	manifests, err := filepath.Glob(filepath.Join(r.dir, tempManifestPrefix+"*"))
+	if err != nil {
+		return 0, fmt.Errorf("failed to find cleanup manifests: %w", err)
+	}
+
+	removed := 0
+	for _, manifest := range manifests {
+		pid, err := strconv.Atoi(strings.TrimPrefix(filepath.Base(manifest), tempManifestPrefix))
+		if err != nil || pid == os.Getpid() || processAlive(pid) {
+			continue
+		}
+
+		data, err := readManifest(manifest)
+		if errors.Is(err, errUntrustedManifest) {
+			continue
+		}
+		if err != nil {
+			return removed, fmt.Errorf("failed to read cleanup manifest: %w", err)
+		}
+		for _, path := range strings.Split(string(data), "\n") {
+			if path == "" {
+				continue
+			}
+			if !r.isOrphan(path) {
+				continue
+			}
+			if err := os.Remove(path); err == nil {
+				removed++
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return removed, fmt.Errorf("failed to remove orphaned temp file: %w", err)
+			}
+			if r.isOrphan(path + ".wal") {
+				os.Remove(path + ".wal")
+			}
+		}
+
+		if err := os.Remove(manifest); err != nil {
+			return removed, fmt.Errorf("failed to remove cleanup manifest: %w", err)
+		}
+	}
+
+	return removed, nil
+}
+
+// errUntrustedManifest is returned by readManifest for a manifest another user could have written
+var errUntrustedManifest = errors.New("untrusted cleanup manifest")
+
+// readManifest returns the contents of a cleanup manifest if it is a regular file owned by this
+// user with mode 0600
+func readManifest(manifest string) ([]byte, error) {
+	file, err := os.OpenFile(manifest, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if errors.Is(err, syscall.ELOOP) {
+		return nil, errUntrustedManifest
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !info.Mode().IsRegular() || info.Mode().Perm() != 0600 || !ok || int(stat.Uid) != os.Getuid() {
+		return nil, errUntrustedManifest
+	}
+	return io.ReadAll(file)
+}
+
+// isOrphan reports whether a path listed in a manifest is a regular file named like our temp
+// files directly in the registry directory, the only paths CleanupOrphans removes
+func (r *TempFileRegistry) isOrphan(path string) bool {
+	if filepath.Dir(path) != filepath.Clean(r.dir) || !tempNamePattern.MatchString(filepath.Base(path)) {
+		return false
+	}
+	info, err := os.Lstat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// processAlive reports whether a process with the given pid is running
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// Signal 0 only checks for existence, EPERM means the process exists but is not ours
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// RegisterTemp records a temp file of this process for crash cleanup
+func RegisterTemp(path string) error {
+	return tempFiles.RegisterTemp(path)
+}
+
+// CleanupOrphans removes temp files left behind by crashed processes
+func CleanupOrphans() (int, error) {
+	return tempFiles.CleanupOrphans()
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// deadPID returns the pid of a process that has exited
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("cannot run a child process: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+// writeTestFile creates a file at path with the given permissions
+func writeTestFile(t *testing.T, path string, perm os.FileMode) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte("x"), perm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTempNamePattern(t *testing.T) {
+	for _, name := range []string{"duckdb-nats-123.db", "duckdb-nats-export-42", "duckdb-nats-stream-7.db.wal", "duckdb-nats-1.parquet"} {
+		if !tempNamePattern.MatchString(name) {
+			t.Errorf("%s does not match", name)
+		}
+	}
+	for _, name := range []string{"orphan.db", "duckdb-nats-.db", "duckdb-nats-1/x", "../duckdb-nats-1.db", "mydb.db"} {
+		if tempNamePattern.MatchString(name) {
+			t.Errorf("%s matches", name)
+		}
+	}
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	dir := t.TempDir()
+	r := NewTempFileRegistry(dir)
+
+	// A crashed process left a temp database with its WAL behind
+	orphan := filepath.Join(dir, "duckdb-nats-123456.db")
+	writeTestFile(t, orphan, 0600)
+	writeTestFile(t, orphan+".wal", 0600)
+	// Paths that must survive even though the manifest lists them
+	unrelated := filepath.Join(dir, "orphan.db")
+	writeTestFile(t, unrelated, 0600)
+	outside := filepath.Join(t.TempDir(), "duckdb-nats-7.db")
+	writeTestFile(t, outside, 0600)
+	link := filepath.Join(dir, "duckdb-nats-8.db")
+	if err := os.Symlink(outside, link); err != nil {
+		t.Fatal(err)
+	}
+	subdir := filepath.Join(dir, "duckdb-nats-export-42")
+	if err := os.Mkdir(subdir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	pid := deadPID(t)
+	if err := os.WriteFile(r.manifestPath(pid), []byte(orphan+"\n"+unrelated+"\n"+outside+"\n"+link+"\n"+subdir+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A manifest others can write is not trusted
+	victim := filepath.Join(dir, "duckdb-nats-9.db")
+	writeTestFile(t, victim, 0600)
+	loose := r.manifestPath(pid + 1)
+	writeTestFile(t, loose, 0666)
+	if err := os.WriteFile(loose, []byte(victim+"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files of this process are kept
+	live := filepath.Join(dir, "duckdb-nats-1.db")
+	writeTestFile(t, live, 0600)
+	if err := r.RegisterTemp(live); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := r.CleanupOrphans()
+	if err != nil {
+		t.Fatalf("CleanupOrphans: %v", err)
+	}
+	if removed != 1 {
+		t.Errorf("CleanupOrphans removed %d files, want 1", removed)
+	}
+	for _, path := range []string{orphan, orphan + ".wal", r.manifestPath(pid)} {
+		if _, err := os.Lstat(path); !os.IsNotExist(err) {
+			t.Errorf("%s was not removed", path)
+		}
+	}
+	for _, path := range []string{unrelated, outside, link, subdir, victim, loose, live} {
+		if _, err := os.Lstat(path); err != nil {
+			t.Errorf("%s was removed", path)
+		}
+	}
+
+	if err := r.Release(live); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(r.manifestPath(os.Getpid())); !os.IsNotExist(err) {
+		t.Error("manifest left after releasing every file")
+	}
+}
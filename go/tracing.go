@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "jon-ton/duckdb_in_nats"
+
+// Span names for the traced storage operations
+const (
+	spanStore    = "duckdb_nats.store"
+	spanRetrieve = "duckdb_nats.retrieve"
+	spanQuery    = "duckdb_nats.query"
+	spanDelete   = "duckdb_nats.delete"
+)
+
+// Span attribute keys
+const (
+	attrDBName    = attribute.Key("db.name")
+	attrBucket    = attribute.Key("nats.bucket")
+	attrSizeBytes = attribute.Key("db.size_bytes")
+	attrError     = attribute.Key("error")
+)
+
+// tracer returns the tracer of the configured provider, or of the global one
+func (d *DuckDBStorage) tracer() trace.Tracer {
+	tp := d.opts.TracerProvider
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(tracerName)
+}
+
+// startSpan starts a span for a storage operation tagged with the database and bucket
+func (d *DuckDBStorage) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return d.tracer().Start(ctx, name, trace.WithAttributes(
+		attrDBName.String(d.dbName),
+		attrBucket.String(d.bucket),
+	))
+}
+
+// fileSize returns the size of the file at path, or -1 if it cannot be determined
+func fileSize(path string) int64 {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return -1
+	}
+	return stat.Size()
+}
+
+// setSize records a known database size on span
+func setSize(span trace.Span, size int64) {
+	if size >= 0 {
+		span.SetAttributes(attrSizeBytes.Int64(size))
+	}
+}
+
+// endSpan records err on span, if any, and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attrError.String(err.Error()))
+	}
+	span.End()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// spanAttributes returns the attributes of span keyed by name
+func spanAttributes(span tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestTracingSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	s, _ := storeTestDatabase(t, WithTracerProvider(tp), WithBucket("TRACED"), WithDBName("traced.db"))
+
+	if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := s.QueryRow(context.Background(), "SELECT count(*) FROM users").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteDatabase(); err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string]bool{}
+	for _, span := range exporter.GetSpans() {
+		found[span.Name] = true
+		attrs := spanAttributes(span)
+		if got := attrs[attrDBName].AsString(); got != "traced.db" {
+			t.Errorf("%s: %s = %q", span.Name, attrDBName, got)
+		}
+		if got := attrs[attrBucket].AsString(); got != "TRACED" {
+			t.Errorf("%s: %s = %q", span.Name, attrBucket, got)
+		}
+		if span.Name == spanStore || span.Name == spanRetrieve {
+			if size, ok := attrs[attrSizeBytes]; !ok || size.AsInt64() <= 0 {
+				t.Errorf("%s: %s = %v", span.Name, attrSizeBytes, size.Emit())
+			}
+		}
+		if span.Status.Code == codes.Error {
+			t.Errorf("%s failed: %s", span.Name, span.Status.Description)
+		}
+	}
+	for _, name := range []string{spanStore, spanRetrieve, spanQuery, spanDelete} {
+		if !found[name] {
+			t.Errorf("no %s span emitted", name)
+		}
+	}
+}
+
+func TestTracingRecordsErrors(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	s := newTestStorage(t, startTestServer(t), WithTracerProvider(tp))
+
+	if err := s.RetrieveDuckDB(filepath.Join(t.TempDir(), "out.db")); err == nil {
+		t.Fatal("RetrieveDuckDB of a missing database succeeded")
+	}
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != spanRetrieve {
+		t.Fatalf("spans = %v, want one %s span", spans, spanRetrieve)
+	}
+	if spans[0].Status.Code != codes.Error {
+		t.Error("span status not set to error")
+	}
+	if _, ok := spanAttributes(spans[0])[attrError]; !ok {
+		t.Errorf("span has no %s attribute", attrError)
+	}
+}
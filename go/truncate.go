@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// rewriteLockTTL is the lease of the lock taken by operations that rewrite the stored database
+// when locks are enforced
+const rewriteLockTTL = 30 * time.Second
+
+// ErrTableNotFound is returned for tables missing from the stored database
+var ErrTableNotFound = errors.New("table not found")
+
+// TruncateTable removes all rows of a table of the stored database and stores the result. It
+// returns ErrTableNotFound if the database has no such table.
+func (d *DuckDBStorage) TruncateTable(ctx context.Context, tableName string) (err error) {
+	op := d.logOperation("truncate_table", "table", tableName)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	return d.rewrite(ctx, func(db *sql.DB) error {
+		exists, err := tableExists(ctx, db, tableName)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrTableNotFound, tableName)
+		}
+
+		if _, err := db.ExecContext(ctx, "TRUNCATE "+quoteIdent(tableName)); err != nil {
+			return fmt.Errorf("failed to truncate table %s: %w", tableName, err)
+		}
+		return nil
+	})
+}
+
+// TruncateAllTables removes all rows of every user table of the stored database, stores the
+// result and returns the number of tables truncated
+func (d *DuckDBStorage) TruncateAllTables(ctx context.Context) (count int, err error) {
+	op := d.logOperation("truncate_all_tables")
+	defer func() { op.done(err, "tables", count) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return count, err
+	}
+	defer func() { end(err) }()
+
+	err = d.rewrite(ctx, func(db *sql.DB) error {
+		var alias string
+		if err := db.QueryRowContext(ctx, "SELECT current_database()").Scan(&alias); err != nil {
+			return fmt.Errorf("failed to read database name: %w", err)
+		}
+		tables, err := attachedTables(ctx, db, alias)
+		if err != nil {
+			return err
+		}
+
+		for _, table := range tables {
+			query := fmt.Sprintf("TRUNCATE %s.%s", quoteIdent(table.schema), quoteIdent(table.name))
+			if _, err := db.ExecContext(ctx, query); err != nil {
+				return fmt.Errorf("failed to truncate table %s: %w", table, err)
+			}
+		}
+		count = len(tables)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// rewrite retrieves the stored database, applies change to it and stores the result. It holds
+// the database lock throughout when locks are enforced.
+func (d *DuckDBStorage) rewrite(ctx context.Context, change func(db *sql.DB) error) error {
+	locks, release, err := d.rewriteLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	path, err := tempPath("duckdb-nats-rewrite-*.db")
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(path)
+
+	if err := d.RetrieveDuckDBContext(ctx, path, locks...); err != nil {
+		return err
+	}
+
+	db, err := openDuckDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := change(db); err != nil {
+		return err
+	}
+	if err := db.Close(); err != nil {
+		return fmt.Errorf("failed to close database: %w", err)
+	}
+
+	return d.StoreDuckDBContext(ctx, path, locks...)
+}
+
+// rewriteLock acquires the database lock for a retrieve and store when locks are enforced. The
+// returned locks are to be passed to both and release must be called once they are done.
+func (d *DuckDBStorage) rewriteLock(ctx context.Context) ([]Lock, func(), error) {
+	if !d.opts.EnforceLock {
+		return nil, func() {}, nil
+	}
+
+	lock, err := d.waitForLock(ctx, rewriteLockTTL)
+	if err != nil {
+		return nil, nil, err
+	}
+	return []Lock{lock}, func() { lock.Release() }, nil
+}
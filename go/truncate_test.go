@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTruncateTable(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, startTestServer(t), WithLockEnforcement(true))
+	path := filepath.Join(t.TempDir(), "test.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE events AS SELECT i AS id FROM range(100) r(i)",
+		"CREATE TABLE kept AS SELECT 1 AS id")
+	lock, err := s.AcquireLock(ctx, 10*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	if err := s.StoreDuckDB(path, lock); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	lock.Release()
+
+	if err := s.TruncateTable(ctx, "events"); err != nil {
+		t.Fatalf("TruncateTable: %v", err)
+	}
+	out := filepath.Join(t.TempDir(), "out.db")
+	lock, err = s.AcquireLock(ctx, 10*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	if err := s.RetrieveDuckDB(out, lock); err != nil {
+		t.Fatalf("RetrieveDuckDB: %v", err)
+	}
+	lock.Release()
+	if n := queryTestInt(t, out, "SELECT count(*) FROM events"); n != 0 {
+		t.Errorf("truncated table holds %d rows, want 0", n)
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM kept"); n != 1 {
+		t.Errorf("other table holds %d rows, want 1", n)
+	}
+
+	if err := s.TruncateTable(ctx, "missing"); !errors.Is(err, ErrTableNotFound) {
+		t.Errorf("TruncateTable of a missing table: got %v, want ErrTableNotFound", err)
+	}
+}
+
+func TestTruncateTableLockHeld(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithLockEnforcement(true))
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	lock, err := s.AcquireLock(context.Background(), 10*time.Second)
+	if err != nil {
+		t.Fatalf("AcquireLock: %v", err)
+	}
+	defer lock.Release()
+	if err := s.StoreDuckDB(path, lock); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := s.TruncateTable(ctx, "users"); !errors.Is(err, ErrLockHeld) {
+		t.Errorf("TruncateTable while the lock is held: got %v, want ErrLockHeld", err)
+	}
+}
+
+func TestTruncateAllTables(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "test.db")
+	execTestDatabase(t, path,
+		"CREATE TABLE events AS SELECT i AS id FROM range(100) r(i)",
+		"CREATE SCHEMA archive",
+		"CREATE TABLE archive.events AS SELECT i AS id FROM range(10) r(i)",
+		"CREATE VIEW recent AS SELECT * FROM events")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+
+	count, err := s.TruncateAllTables(ctx)
+	if err != nil {
+		t.Fatalf("TruncateAllTables: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("truncated %d tables, want 2", count)
+	}
+	var n int
+	if err := s.QueryRow(ctx, "SELECT (SELECT count(*) FROM events) + (SELECT count(*) FROM archive.events)").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("%d rows left after TruncateAllTables, want 0", n)
+	}
+}
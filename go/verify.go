@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// TableExpectation describes a table the stored database must contain
+type TableExpectation struct {
+	TableName       string
+	MinRows         int64
+	RequiredColumns []string
+}
+
+// Violation is a single failed expectation
+type Violation struct {
+	TableName string
+	Message   string
+}
+
+// VerificationError lists every expectation the stored database failed
+type VerificationError struct {
+	Violations []Violation
+}
+
+func (e *VerificationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.TableName + ": " + v.Message
+	}
+	return fmt.Sprintf("database verification failed: %s", strings.Join(messages, "; "))
+}
+
+// VerifyStoredDatabase retrieves the stored database and checks it against expectations. All
+// violations are collected into a *VerificationError rather than stopping at the first one.
+func (d *DuckDBStorage) VerifyStoredDatabase(expectations []TableExpectation) (err error) {
+	op := d.logOperation("verify", "tables", len(expectations))
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	ctx := context.Background()
+	path, err := d.retrieveTemp(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeTempDatabase(path)
+
+	db, err := openDuckDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var violations []Violation
+	for _, expectation := range expectations {
+		table := expectation.TableName
+
+		exists, err := tableExists(ctx, db, table)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			violations = append(violations, Violation{TableName: table, Message: "table does not exist"})
+			continue
+		}
+
+		columns, err := tableColumns(ctx, db, table)
+		if err != nil {
+			return err
+		}
+		for _, column := range expectation.RequiredColumns {
+			if !columns[column] {
+				violations = append(violations, Violation{
+					TableName: table,
+					Message:   fmt.Sprintf("missing column %s", column),
+				})
+			}
+		}
+
+		var count int64
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+quoteIdent(table)).Scan(&count); err != nil {
+			return fmt.Errorf("failed to count rows in %s: %w", table, err)
+		}
+		if count < expectation.MinRows {
+			violations = append(violations, Violation{
+				TableName: table,
+				Message:   fmt.Sprintf("has %d rows, expected at least %d", count, expectation.MinRows),
+			})
+		}
+	}
+
+	if len(violations) > 0 {
+		return &VerificationError{Violations: violations}
+	}
+	return nil
+}
+
+// tableColumns returns the set of column names of a table
+func tableColumns(ctx context.Context, db *sql.DB, tableName string) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx,
+		"SELECT column_name FROM information_schema.columns WHERE table_name = ?", tableName,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up columns of %s: %w", tableName, err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to read column of %s: %w", tableName, err)
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestVerifyStoredDatabase(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	path := filepath.Join(t.TempDir(), "verify.db")
+	createTestDatabase(t, path)
+	execTestDatabase(t, path, "CREATE TABLE empty (id INTEGER)")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		expectations []TableExpectation
+		violations   []Violation
+	}{
+		{
+			name: "satisfied",
+			expectations: []TableExpectation{
+				{TableName: "users", MinRows: 3, RequiredColumns: []string{"id", "name"}},
+				{TableName: "empty"},
+			},
+		},
+		{
+			name:         "zero-row table",
+			expectations: []TableExpectation{{TableName: "empty", MinRows: 1}},
+			violations:   []Violation{{TableName: "empty", Message: "has 0 rows, expected at least 1"}},
+		},
+		{
+			name:         "missing table",
+			expectations: []TableExpectation{{TableName: "orders"}},
+			violations:   []Violation{{TableName: "orders", Message: "table does not exist"}},
+		},
+		{
+			name:         "missing column",
+			expectations: []TableExpectation{{TableName: "users", RequiredColumns: []string{"id", "email"}}},
+			violations:   []Violation{{TableName: "users", Message: "missing column email"}},
+		},
+		{
+			name: "all violations collected",
+			expectations: []TableExpectation{
+				{TableName: "users", MinRows: 4, RequiredColumns: []string{"email"}},
+				{TableName: "orders"},
+			},
+			violations: []Violation{
+				{TableName: "users", Message: "missing column email"},
+				{TableName: "users", Message: "has 3 rows, expected at least 4"},
+				{TableName: "orders", Message: "table does not exist"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.VerifyStoredDatabase(tt.expectations)
+			if tt.violations == nil {
+				if err != nil {
+					t.Fatalf("VerifyStoredDatabase: %v", err)
+				}
+				return
+			}
+			var verr *VerificationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("VerifyStoredDatabase: got %v, want a VerificationError", err)
+			}
+			if !slices.Equal(verr.Violations, tt.violations) {
+				t.Errorf("violations = %v, want %v", verr.Violations, tt.violations)
+			}
+		})
+	}
+}
+
+func TestVerifyStoredDatabaseMissing(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t))
+	err := s.VerifyStoredDatabase([]TableExpectation{{TableName: "users"}})
+	var verr *VerificationError
+	if err == nil || errors.As(err, &verr) {
+		t.Fatalf("VerifyStoredDatabase without a stored database: got %v", err)
+	}
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+
+	"github.com/nats-io/nats.go"
+)
+
+// ErrInvalidVersion is returned for version labels outside the allowed character set
+var ErrInvalidVersion = errors.New("invalid version")
+
+// versionPattern accepts semver-like labels such as 1.2.0 or 2024-01-rc1
+var versionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9.-]*$`)
+
+// versionHeader records the label of a version object, telling it apart from a database whose
+// name happens to contain "@"
+const versionHeader = "X-Version"
+
+func versionHeaders(version string) nats.Header {
+	return nats.Header{versionHeader: []string{version}}
+}
+
+func (d *DuckDBStorage) versionName(version string) string {
+	return d.dbName + "@" + version
+}
+
+func (d *DuckDBStorage) versionsName() string {
+	return d.dbName + ".versions"
+}
+
+func validateVersion(version string) error {
+	if !versionPattern.MatchString(version) {
+		return fmt.Errorf("%w: %q", ErrInvalidVersion, version)
+	}
+	return nil
+}
+
+// StoreVersion stores the database under an explicit version label
+func (d *DuckDBStorage) StoreVersion(dbFilePath, version string) (err error) {
+	op := d.logOperation("store_version", "path", dbFilePath, "version", version)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if err := validateVersion(version); err != nil {
+		return err
+	}
+	if err := d.checkQuota(dbFilePath, d.versionName(version)); err != nil {
+		return err
+	}
+
+	if _, err := d.putDatabase(context.Background(), d.versionName(version), dbFilePath, versionHeaders(version)); err != nil {
+		return err
+	}
+	// The schema snapshot only speeds up CompareSchemas, which can fall back to the database
+	if err := d.storeSchemaFor(context.Background(), d.versionName(version), dbFilePath); err != nil {
+		d.opts.Logger.Error("failed to store version schema", "db", d.dbName, "version", version, "error", err)
+	}
+
+	return d.addVersion(version)
+}
+
+// addVersion appends version to the version list unless it is already listed
+func (d *DuckDBStorage) addVersion(version string) error {
+	versions, err := d.listVersions()
+	if err != nil {
+		return err
+	}
+	if slices.Contains(versions, version) {
+		return nil
+	}
+
+	return d.putVersions(append(versions, version))
+}
+
+// RetrieveVersion retrieves a specific version of the database to outputPath
+func (d *DuckDBStorage) RetrieveVersion(version, outputPath string) (err error) {
+	op := d.logOperation("retrieve_version", "version", version, "path", outputPath)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if err := validateVersion(version); err != nil {
+		return err
+	}
+	return d.getDatabase(context.Background(), d.versionName(version), outputPath)
+}
+
+// ListVersions returns the stored version labels in insertion order
+func (d *DuckDBStorage) ListVersions() (_ []string, err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { end(err) }()
+
+	return d.listVersions()
+}
+
+// listVersions reads the version list without registering an operation with Close
+func (d *DuckDBStorage) listVersions() ([]string, error) {
+	data, err := d.obs.GetBytes(d.versionsName())
+	if errors.Is(err, nats.ErrObjectNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve version list: %w", err)
+	}
+
+	var versions []string
+	if err := json.Unmarshal(data, &versions); err != nil {
+		return nil, fmt.Errorf("failed to decode version list: %w", err)
+	}
+	return versions, nil
+}
+
+// PromoteVersion makes the given version the canonical database
+func (d *DuckDBStorage) PromoteVersion(version string) (err error) {
+	op := d.logOperation("promote_version", "version", version)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if err := validateVersion(version); err != nil {
+		return err
+	}
+
+	if _, err := d.copyObject(context.Background(), d.versionName(version), d.dbName); err != nil {
+		return fmt.Errorf("failed to promote version %s: %w", version, err)
+	}
+	return nil
+}
+
+func (d *DuckDBStorage) putVersions(versions []string) error {
+	data, err := json.Marshal(versions)
+	if err != nil {
+		return fmt.Errorf("failed to encode version list: %w", err)
+	}
+
+	if _, err := d.obs.PutBytes(d.versionsName(), data); err != nil {
+		return fmt.Errorf("failed to store version list: %w", err)
+	}
+	return nil
+}
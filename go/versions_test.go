@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestValidateVersion(t *testing.T) {
+	for _, version := range []string{"1.0.0", "v2", "2024-01-01", "snapshot-20240101T000000.000000000Z"} {
+		if err := validateVersion(version); err != nil {
+			t.Errorf("validateVersion(%q): %v", version, err)
+		}
+	}
+	for _, version := range []string{"", "bad version", "../1", "a/b"} {
+		if err := validateVersion(version); !errors.Is(err, ErrInvalidVersion) {
+			t.Errorf("validateVersion(%q) = %v, want ErrInvalidVersion", version, err)
+		}
+	}
+}
+
+func TestPromoteVersion(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithCompression(CompressionZstd))
+	dir := t.TempDir()
+	versions := []string{"1.0.0", "1.1.0", "2.0.0"}
+	for _, version := range versions {
+		path := filepath.Join(dir, version+".db")
+		if err := os.WriteFile(path, []byte("content-"+version), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.StoreVersion(path, version); err != nil {
+			t.Fatalf("StoreVersion(%s): %v", version, err)
+		}
+	}
+
+	listed, err := s.ListVersions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(listed, versions) {
+		t.Errorf("ListVersions = %v, want %v", listed, versions)
+	}
+
+	if err := s.PromoteVersion("1.1.0"); err != nil {
+		t.Fatalf("PromoteVersion: %v", err)
+	}
+	out := filepath.Join(dir, "out.db")
+	if err := s.RetrieveDuckDB(out); err != nil {
+		t.Fatalf("RetrieveDuckDB: %v", err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "content-1.1.0" {
+		t.Errorf("canonical database = %q, want the promoted version", got)
+	}
+
+	// The promoted copy is the canonical database, not another version
+	info, err := s.GetInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := info.Headers.Get(versionHeader); v != "" {
+		t.Errorf("canonical database carries version header %q", v)
+	}
+	entries, err := s.ListDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != s.dbName {
+		t.Errorf("ListDatabases = %v, want only %s", entries, s.dbName)
+	}
+
+	out = filepath.Join(dir, "v2.db")
+	if err := s.RetrieveVersion("2.0.0", out); err != nil {
+		t.Fatalf("RetrieveVersion: %v", err)
+	}
+	if got, _ := os.ReadFile(out); string(got) != "content-2.0.0" {
+		t.Errorf("version 2.0.0 = %q", got)
+	}
+}
+
+func TestStoreVersionInvalid(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	if err := s.StoreVersion(path, "bad version"); !errors.Is(err, ErrInvalidVersion) {
+		t.Fatalf("StoreVersion: got %v, want ErrInvalidVersion", err)
+	}
+	if versions, err := s.ListVersions(); err != nil || len(versions) != 0 {
+		t.Errorf("ListVersions = %v, %v", versions, err)
+	}
+}
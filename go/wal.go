@@ -0,0 +1,197 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// walSequenceHeader records the sequence number of a stored WAL file
+const walSequenceHeader = "X-WAL-Sequence"
+
+func (d *DuckDBStorage) walPrefix() string {
+	return d.dbName + ".wal."
+}
+
+func (d *DuckDBStorage) walName(seq uint64) string {
+	return d.walPrefix() + strconv.FormatUint(seq, 10)
+}
+
+// StoreWAL uploads a DuckDB write-ahead log file holding the changes made since the previous
+// sequence number, so changes can be shipped without uploading the full database
+func (d *DuckDBStorage) StoreWAL(walFilePath string, sequenceNum uint64) (err error) {
+	op := d.logOperation("store_wal", "path", walFilePath, "seq", sequenceNum)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	_, err = d.putFile(context.Background(), d.walName(sequenceNum), walFilePath, "DuckDB WAL", nats.Header{
+		walSequenceHeader: []string{strconv.FormatUint(sequenceNum, 10)},
+	})
+	return err
+}
+
+// ReplayWAL writes the database at baseDBPath with the stored WAL files fromSeq through toSeq
+// applied to outputPath. The stored database is retrieved to baseDBPath first if the file does
+// not exist. WAL files are applied in sequence order by letting DuckDB replay each of them on
+// open and checkpointing it into the database. Every sequence of the range must be stored, a gap
+// fails the replay naming the first missing sequence.
+func (d *DuckDBStorage) ReplayWAL(baseDBPath, outputPath string, fromSeq, toSeq uint64) (err error) {
+	op := d.logOperation("replay_wal", "base", baseDBPath, "output", outputPath, "from", fromSeq, "to", toSeq)
+	defer func() { op.done(err) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if fromSeq > toSeq {
+		return fmt.Errorf("invalid WAL range: %d > %d", fromSeq, toSeq)
+	}
+
+	stored, err := d.walSequences()
+	if err != nil {
+		return err
+	}
+	var sequences []uint64
+	next := fromSeq
+	for _, seq := range stored {
+		if seq < fromSeq || seq > toSeq {
+			continue
+		}
+		if seq != next {
+			break
+		}
+		sequences = append(sequences, seq)
+		next++
+	}
+	if len(sequences) == 0 || sequences[len(sequences)-1] != toSeq {
+		return fmt.Errorf("%w: WAL sequence %d", ErrObjectNotFound, next)
+	}
+
+	ctx := context.Background()
+	if _, err := os.Stat(baseDBPath); errors.Is(err, os.ErrNotExist) {
+		if err := d.retrieve(ctx, baseDBPath); err != nil {
+			return err
+		}
+	}
+	if err := copyFile(baseDBPath, outputPath); err != nil {
+		return err
+	}
+
+	walPath := outputPath + ".wal"
+	for _, seq := range sequences {
+		if err := d.getFile(ctx, d.walName(seq), walPath); err != nil {
+			return err
+		}
+		if err := checkpointDatabase(ctx, outputPath); err != nil {
+			return fmt.Errorf("failed to replay WAL %d: %w", seq, err)
+		}
+	}
+
+	return nil
+}
+
+// PurgeWALsBefore deletes the stored WAL files with a sequence number below seq and returns the
+// number deleted
+func (d *DuckDBStorage) PurgeWALsBefore(seq uint64) (purged int, err error) {
+	op := d.logOperation("purge_wals", "before", seq)
+	defer func() { op.done(err, "purged", purged) }()
+	end, err := d.beginOperation()
+	if err != nil {
+		return purged, err
+	}
+	defer func() { end(err) }()
+
+	sequences, err := d.walSequences()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, s := range sequences {
+		if s >= seq {
+			break
+		}
+		if err := d.obs.Delete(d.walName(s)); err != nil && !errors.Is(err, nats.ErrObjectNotFound) {
+			return purged, fmt.Errorf("failed to delete WAL %d: %w", s, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// walSequences returns the sequence numbers of the stored WAL files in ascending order
+func (d *DuckDBStorage) walSequences() ([]uint64, error) {
+	objects, err := d.obs.List()
+	if errors.Is(err, nats.ErrNoObjectsFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects: %w", err)
+	}
+
+	var sequences []uint64
+	for _, info := range objects {
+		suffix, ok := strings.CutPrefix(info.Name, d.walPrefix())
+		if !ok {
+			continue
+		}
+		seq, err := strconv.ParseUint(suffix, 10, 64)
+		if err != nil {
+			continue
+		}
+		sequences = append(sequences, seq)
+	}
+	slices.SortFunc(sequences, cmp.Compare[uint64])
+	return sequences, nil
+}
+
+// checkpointDatabase opens the database, which replays a WAL file next to it, and checkpoints
+// the replayed changes into the database file
+func checkpointDatabase(ctx context.Context, path string) error {
+	db, err := openDuckDB(path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, "CHECKPOINT"); err != nil {
+		return fmt.Errorf("failed to checkpoint database: %w", err)
+	}
+	return db.Close()
+}
+
+// copyFile copies the file at src to dst, replacing dst and any stale WAL file next to it
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", src, err)
+	}
+	defer in.Close()
+
+	if err := os.Remove(dst + ".wal"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale WAL file: %w", err)
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", dst, err)
+	}
+	defer out.Close()
+
+	if _, err := io.Copy(out, in); err != nil {
+		return fmt.Errorf("failed to write %s: %w", dst, err)
+	}
+	return out.Close()
+}
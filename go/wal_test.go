@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureWAL applies stmt to the database at path without checkpointing it and copies the
+// resulting WAL file to walPath
+func captureWAL(t *testing.T, path, stmt, walPath string) {
+	t.Helper()
+	db, err := openDuckDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	for _, query := range []string{
+		"CHECKPOINT",
+		"PRAGMA disable_checkpoint_on_shutdown",
+		"SET checkpoint_threshold = '10GB'",
+		stmt,
+	} {
+		if _, err := db.Exec(query); err != nil {
+			db.Close()
+			t.Fatalf("failed to run %q: %v", query, err)
+		}
+	}
+	db.Close()
+
+	data, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(walPath, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplayWAL(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	dir := t.TempDir()
+	wal1, wal2 := filepath.Join(dir, "1.wal"), filepath.Join(dir, "2.wal")
+	captureWAL(t, path, "INSERT INTO users VALUES (10, 'Dave', now())", wal1)
+	captureWAL(t, path, "INSERT INTO users VALUES (11, 'Eve', now())", wal2)
+	if err := s.StoreWAL(wal1, 1); err != nil {
+		t.Fatalf("StoreWAL(1): %v", err)
+	}
+	if err := s.StoreWAL(wal2, 2); err != nil {
+		t.Fatalf("StoreWAL(2): %v", err)
+	}
+
+	// The base is retrieved from the stored database
+	base, out := filepath.Join(dir, "base.db"), filepath.Join(dir, "out.db")
+	if err := s.ReplayWAL(base, out, 1, 2); err != nil {
+		t.Fatalf("ReplayWAL: %v", err)
+	}
+	if n := queryTestInt(t, out, "SELECT count(*) FROM users"); n != 5 {
+		t.Errorf("replayed database has %d users, want 5", n)
+	}
+	if n := queryTestInt(t, base, "SELECT count(*) FROM users"); n != 3 {
+		t.Errorf("base database has %d users, want 3", n)
+	}
+
+	// WAL files are not listed as databases
+	entries, err := s.ListDatabases()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("ListDatabases = %v, want only the database", entries)
+	}
+
+	purged, err := s.PurgeWALsBefore(2)
+	if err != nil {
+		t.Fatalf("PurgeWALsBefore: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("PurgeWALsBefore purged %d files, want 1", purged)
+	}
+	if err := s.ReplayWAL(base, filepath.Join(dir, "purged.db"), 1, 2); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("ReplayWAL of a purged sequence: got %v, want ErrObjectNotFound", err)
+	}
+}
+
+func TestReplayWALGap(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	dir := t.TempDir()
+	wal := filepath.Join(dir, "1.wal")
+	captureWAL(t, path, "INSERT INTO users VALUES (10, 'Dave', now())", wal)
+	for _, seq := range []uint64{1, 2, 4} {
+		if err := s.StoreWAL(wal, seq); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		from, to, missing uint64
+	}{
+		{1, 4, 3},
+		{0, 2, 0},
+		{1, 5, 3},
+		{4, 5, 5},
+	}
+	for _, tt := range tests {
+		err := s.ReplayWAL(path, filepath.Join(dir, "out.db"), tt.from, tt.to)
+		if !errors.Is(err, ErrObjectNotFound) || !strings.Contains(err.Error(), fmt.Sprintf("sequence %d", tt.missing)) {
+			t.Errorf("ReplayWAL(%d, %d): got %v, want sequence %d missing", tt.from, tt.to, err, tt.missing)
+		}
+	}
+	if err := s.ReplayWAL(path, filepath.Join(dir, "out.db"), 2, 1); err == nil {
+		t.Error("ReplayWAL accepted an inverted range")
+	}
+}
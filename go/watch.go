@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+)
+
+// Watch calls onUpdate from a background goroutine every time the database object is stored or
+// deleted. The watch stops when ctx is cancelled, the storage is closed or onUpdate returns an error.
+func (d *DuckDBStorage) Watch(ctx context.Context, onUpdate func(info *nats.ObjectInfo) error) error {
+	return d.watch(ctx, func(info *nats.ObjectInfo) bool { return info.Name == d.dbName }, onUpdate)
+}
+
+// WatchAll is like Watch but reports updates of every database whose name starts with prefix
+func (d *DuckDBStorage) WatchAll(ctx context.Context, prefix string, onUpdate func(info *nats.ObjectInfo) error) error {
+	return d.watch(ctx, func(info *nats.ObjectInfo) bool {
+		return strings.HasPrefix(info.Name, d.objectKey(prefix)) && isDatabaseObject(info)
+	}, onUpdate)
+}
+
+// watch starts an updates-only object store watcher delivering objects accepted by match
+func (d *DuckDBStorage) watch(ctx context.Context, match func(info *nats.ObjectInfo) bool, onUpdate func(info *nats.ObjectInfo) error) error {
+	watcher, err := d.obs.Watch(nats.UpdatesOnly(), nats.Context(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to watch object store: %w", err)
+	}
+
+	err = d.goBackground(ctx, func(ctx context.Context) {
+		defer watcher.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case info, ok := <-watcher.Updates():
+				if !ok {
+					return
+				}
+				// A nil entry marks the end of the initial values, there are none with UpdatesOnly
+				if info == nil || !match(info) {
+					continue
+				}
+				if err := onUpdate(info); err != nil {
+					d.opts.Logger.Error("watch callback failed, stopping watch", "db", info.Name, "error", err)
+					return
+				}
+			}
+		}
+	})
+	if err != nil {
+		watcher.Stop()
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// receiveNames returns the names sent on ch until nothing arrives for within
+func receiveNames(ch <-chan string, within time.Duration) []string {
+	var names []string
+	for {
+		select {
+		case name := <-ch:
+			names = append(names, name)
+		case <-time.After(within):
+			return names
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan string, 10)
+	err := s.Watch(ctx, func(info *nats.ObjectInfo) error {
+		updates <- info.Name
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	// Only the watched database is reported, not its versions
+	if err := s.StoreVersion(path, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+	names := receiveNames(updates, 300*time.Millisecond)
+	if len(names) != 2 || names[0] != s.dbName || names[1] != s.dbName {
+		t.Errorf("updates = %v, want two of %s", names, s.dbName)
+	}
+
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatal(err)
+	}
+	if names := receiveNames(updates, 200*time.Millisecond); len(names) != 0 {
+		t.Errorf("updates after cancellation: %v", names)
+	}
+}
+
+func TestWatchStopsOnError(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	updates := make(chan string, 10)
+	err := s.Watch(context.Background(), func(info *nats.ObjectInfo) error {
+		updates <- info.Name
+		return errors.New("stop")
+	})
+	if err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := s.StoreDuckDB(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := receiveNames(updates, 300*time.Millisecond); len(names) != 1 {
+		t.Errorf("updates = %v, want the watch to stop after the first", names)
+	}
+}
+
+func TestWatchAll(t *testing.T) {
+	s, path := storeTestDatabase(t, WithDBName("sales-eu.db"))
+	other := newTestStorage(t, s.nc, WithDBName("sales-us.db"))
+	unrelated := newTestStorage(t, s.nc, WithDBName("users.db"))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan string, 10)
+	err := s.WatchAll(ctx, "sales", func(info *nats.ObjectInfo) error {
+		updates <- info.Name
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WatchAll: %v", err)
+	}
+
+	for _, storage := range []*DuckDBStorage{s, other, unrelated} {
+		if err := storage.StoreDuckDB(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.StoreVersion(path, "v1"); err != nil {
+		t.Fatal(err)
+	}
+	names := receiveNames(updates, 300*time.Millisecond)
+	if len(names) != 2 || names[0] != "sales-eu.db" || names[1] != "sales-us.db" {
+		t.Errorf("updates = %v, want sales-eu.db and sales-us.db", names)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// defaultWatchDebounce is how long StartFileWatcher waits for writes to settle by default
+const defaultWatchDebounce = 500 * time.Millisecond
+
+// WatchEvent reports a store triggered by StartFileWatcher
+type WatchEvent struct {
+	Timestamp time.Time
+	// Event is the last file system event before the store
+	Event      fsnotify.Event
+	StoreError error
+}
+
+// StartFileWatcher stores dbFilePath whenever it is written to, until ctx is cancelled or the
+// storage is closed. Stores wait until no write happened for the duration set by
+// WithWatchDebounce, so a burst of writes results in a single store. The directory of the file is
+// watched rather than the file itself so that the file can be replaced by a rename. fsnotify
+// does not report closes on every platform, the last write of a close is caught by the debounce
+// instead. A write still waiting for its store when the watcher stops is stored before it stops.
+func (d *DuckDBStorage) StartFileWatcher(ctx context.Context, dbFilePath string) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if d.opts.WatchDebounce <= 0 {
+		return fmt.Errorf("invalid watch debounce: %v", d.opts.WatchDebounce)
+	}
+	dbFilePath, err = filepath.Abs(dbFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve database path: %w", err)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.watcherEvents != nil {
+		return errors.New("file watcher already running")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(dbFilePath)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("failed to watch %s: %w", dbFilePath, err)
+	}
+	events := make(chan WatchEvent, 16)
+
+	err = d.goBackgroundLocked(ctx, func(ctx context.Context) {
+		defer func() {
+			watcher.Close()
+			d.mu.Lock()
+			d.watcherEvents = nil
+			d.mu.Unlock()
+			close(events)
+		}()
+		d.watchFile(ctx, watcher, dbFilePath, events)
+	})
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	d.watcherEvents = events
+
+	return nil
+}
+
+// WatcherEvents returns the channel on which the stores of the file watcher are reported. The
+// channel is closed when the watcher stops and is nil if no watcher is running.
+func (d *DuckDBStorage) WatcherEvents() <-chan WatchEvent {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.watcherEvents
+}
+
+// watchFile stores dbFilePath once its writes settle until ctx is cancelled
+func (d *DuckDBStorage) watchFile(ctx context.Context, watcher *fsnotify.Watcher, dbFilePath string, events chan<- WatchEvent) {
+	debounce := time.NewTimer(d.opts.WatchDebounce)
+	debounce.Stop()
+	defer debounce.Stop()
+
+	var last fsnotify.Event
+	pending := false
+	store := func() {
+		pending = false
+		// The watcher is tracked by Close, which lets the final store run while it waits
+		err := d.storeFileLocked(context.Background(), dbFilePath, d.opts.Deduplicate)
+		if errors.Is(err, ErrUnchanged) {
+			// The write did not change the content
+			return
+		}
+		if err != nil {
+			d.opts.Logger.Error("failed to store watched file", "db", d.dbName, "path", dbFilePath, "error", err)
+		}
+		// Never block the watcher on a slow reader
+		select {
+		case events <- WatchEvent{Timestamp: time.Now(), Event: last, StoreError: err}:
+		default:
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Pick up writes the watcher has reported but not yet delivered
+			for drained := false; !drained; {
+				select {
+				case event, ok := <-watcher.Events:
+					if ok && isWatchedWrite(event, dbFilePath) {
+						last, pending = event, true
+					}
+					drained = !ok
+				default:
+					drained = true
+				}
+			}
+			if pending {
+				store()
+			}
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if isWatchedWrite(event, dbFilePath) {
+				last, pending = event, true
+				debounce.Reset(d.opts.WatchDebounce)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			d.opts.Logger.Error("file watcher error", "db", d.dbName, "path", dbFilePath, "error", err)
+		case <-debounce.C:
+			if pending {
+				store()
+			}
+		}
+	}
+}
+
+// isWatchedWrite reports whether event writes or replaces dbFilePath
+func isWatchedWrite(event fsnotify.Event, dbFilePath string) bool {
+	return filepath.Clean(event.Name) == dbFilePath && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestIsWatchedWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	tests := []struct {
+		event fsnotify.Event
+		want  bool
+	}{
+		{fsnotify.Event{Name: path, Op: fsnotify.Write}, true},
+		{fsnotify.Event{Name: path, Op: fsnotify.Create}, true},
+		{fsnotify.Event{Name: path, Op: fsnotify.Chmod}, false},
+		{fsnotify.Event{Name: path, Op: fsnotify.Remove}, false},
+		{fsnotify.Event{Name: path + ".wal", Op: fsnotify.Write}, false},
+	}
+	for _, tt := range tests {
+		if got := isWatchedWrite(tt.event, path); got != tt.want {
+			t.Errorf("isWatchedWrite(%v) = %v, want %v", tt.event, got, tt.want)
+		}
+	}
+}
+
+func TestStartFileWatcher(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithWatchDebounce(100*time.Millisecond))
+	path := filepath.Join(t.TempDir(), "test.db")
+	createTestDatabase(t, path)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := s.StartFileWatcher(ctx, path); err != nil {
+		t.Fatalf("StartFileWatcher: %v", err)
+	}
+	if err := s.StartFileWatcher(ctx, path); err == nil {
+		t.Error("second StartFileWatcher succeeded")
+	}
+
+	// Writes to other files of the directory are ignored
+	if err := os.WriteFile(filepath.Join(filepath.Dir(path), "other"), []byte("x"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	execTestDatabase(t, path, "INSERT INTO users VALUES (4, 'Dave', now())", "CHECKPOINT")
+
+	events := s.WatcherEvents()
+	select {
+	case event := <-events:
+		if event.StoreError != nil {
+			t.Fatalf("store of the watched file: %v", event.StoreError)
+		}
+		if filepath.Clean(event.Event.Name) != path || event.Timestamp.IsZero() {
+			t.Errorf("event = %+v, want a write of %s", event, path)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no store reported after a write")
+	}
+	var n int
+	if err := s.QueryRow(context.Background(), "SELECT count(*) FROM users").Scan(&n); err != nil || n != 4 {
+		t.Fatalf("stored database holds %d users, %v, want 4", n, err)
+	}
+
+	// A write still waiting for its debounce is stored when the watcher stops
+	execTestDatabase(t, path, "INSERT INTO users VALUES (5, 'Eve', now())", "CHECKPOINT")
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	stored := false
+	for event := range events {
+		if event.StoreError != nil {
+			t.Errorf("final store: %v", event.StoreError)
+		}
+		stored = true
+	}
+	if !stored {
+		t.Fatal("pending write not stored when the watcher stopped")
+	}
+	if err := s.QueryRow(context.Background(), "SELECT count(*) FROM users").Scan(&n); err != nil || n != 5 {
+		t.Errorf("stored database holds %d users, %v, want 5", n, err)
+	}
+	if s.WatcherEvents() != nil {
+		t.Error("WatcherEvents is not nil after the watcher stopped")
+	}
+}
+
+func TestStartFileWatcherInvalid(t *testing.T) {
+	s := newTestStorage(t, startTestServer(t), WithWatchDebounce(0))
+	if err := s.StartFileWatcher(context.Background(), filepath.Join(t.TempDir(), "test.db")); err == nil {
+		t.Error("StartFileWatcher accepted a debounce of 0")
+	}
+
+	s = newTestStorage(t, startTestServer(t))
+	if err := s.StartFileWatcher(context.Background(), filepath.Join(t.TempDir(), "missing", "test.db")); err == nil {
+		t.Error("StartFileWatcher accepted a missing directory")
+	}
+	if s.WatcherEvents() != nil {
+		t.Error("WatcherEvents is not nil without a watcher")
+	}
+}
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// queryWorkerQueue is the queue group the query workers subscribe with
+const queryWorkerQueue = "query-workers"
+
+// defaultQueryCacheTTL bounds how long query workers reuse their copy of the database
+const defaultQueryCacheTTL = 5 * time.Minute
+
+// queryWorkerPollInterval bounds how long a stopped worker waits for its remaining messages
+const queryWorkerPollInterval = 100 * time.Millisecond
+
+// ErrWorkersNotStarted is returned by SetWorkerCount before StartQueryWorker was called
+var ErrWorkersNotStarted = errors.New("query workers not started")
+
+// queryWorkerPool is the set of goroutines started by StartQueryWorker
+type queryWorkerPool struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	subject string
+	cache   *workerCache
+	// stops holds one function per running worker that makes it finish its pending messages and exit
+	stops []context.CancelFunc
+}
+
+// workerCache is the database copy shared by the query workers. Queries run concurrently and a
+// refresh waits for them to finish.
+type workerCache struct {
+	mu     sync.RWMutex
+	ttl    time.Duration
+	path   string
+	db     *sql.DB
+	loaded time.Time
+	// stale is set by the watcher when a newer revision was stored
+	stale  atomic.Bool
+	closed bool
+}
+
+// StartQueryWorker answers query requests on subject with a pool of workers in the
+// "query-workers" queue group until ctx is cancelled or the storage is closed. Requests use the
+// format of StartQueryService. The workers share a local copy of the database that is
+// retrieved on first use and again once it is older than the configured query cache TTL or a
+// newer revision is stored. Like the service, the copy is opened read-only without access to
+// files, other databases or extensions. Use SetWorkerCount to resize the pool.
+func (d *DuckDBStorage) StartQueryWorker(ctx context.Context, subject string, workers int) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if workers <= 0 {
+		return fmt.Errorf("invalid worker count: %d", workers)
+	}
+
+	ctx, cancel := d.lifetime(ctx)
+	pool := &queryWorkerPool{
+		ctx:     ctx,
+		subject: subject,
+		cache:   &workerCache{ttl: d.opts.QueryCacheTTL},
+	}
+
+	d.mu.Lock()
+	if d.workers != nil {
+		d.mu.Unlock()
+		cancel()
+		return errors.New("query workers already started")
+	}
+	d.workers = pool
+	d.mu.Unlock()
+
+	err = d.Watch(ctx, func(*nats.ObjectInfo) error {
+		pool.cache.stale.Store(true)
+		return nil
+	})
+	if err == nil {
+		err = d.SetWorkerCount(workers)
+	}
+	if err != nil {
+		d.mu.Lock()
+		d.workers = nil
+		d.mu.Unlock()
+		cancel()
+		return err
+	}
+
+	err = d.goBackground(ctx, func(ctx context.Context) {
+		<-ctx.Done()
+		cancel()
+
+		d.mu.Lock()
+		if d.workers == pool {
+			d.workers = nil
+		}
+		d.mu.Unlock()
+
+		pool.cache.close()
+	})
+	if err != nil {
+		d.mu.Lock()
+		d.workers = nil
+		d.mu.Unlock()
+		cancel()
+		return err
+	}
+
+	return nil
+}
+
+// SetWorkerCount starts or stops query workers until n are running. Stopped workers answer the
+// requests already delivered to them before they exit.
+func (d *DuckDBStorage) SetWorkerCount(n int) (err error) {
+	end, err := d.beginOperation()
+	if err != nil {
+		return err
+	}
+	defer func() { end(err) }()
+
+	if n <= 0 {
+		return fmt.Errorf("invalid worker count: %d", n)
+	}
+
+	d.mu.Lock()
+	pool := d.workers
+	d.mu.Unlock()
+	if pool == nil {
+		return ErrWorkersNotStarted
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+
+	for len(pool.stops) > n {
+		last := len(pool.stops) - 1
+		pool.stops[last]()
+		pool.stops = pool.stops[:last]
+	}
+	for len(pool.stops) < n {
+		if err := d.startQueryWorker(pool); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WorkerCount returns the number of running query workers
+func (d *DuckDBStorage) WorkerCount() int {
+	d.mu.Lock()
+	pool := d.workers
+	d.mu.Unlock()
+	if pool == nil {
+		return 0
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return len(pool.stops)
+}
+
+// startQueryWorker subscribes one more worker to the pool subject
+func (d *DuckDBStorage) startQueryWorker(pool *queryWorkerPool) error {
+	sub, err := d.nc.QueueSubscribeSync(pool.subject, queryWorkerQueue)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", pool.subject, err)
+	}
+
+	stopCtx, stop := context.WithCancel(pool.ctx)
+
+	err = d.goBackground(pool.ctx, func(ctx context.Context) {
+		for {
+			msg, err := sub.NextMsgWithContext(stopCtx)
+			if err != nil {
+				break
+			}
+			d.respondQuery(msg, d.handleQuery(ctx, pool.cache, msg.Data))
+		}
+
+		// Answer what was delivered before the interest was removed
+		if err := sub.Drain(); err != nil {
+			return
+		}
+		for ctx.Err() == nil {
+			msg, err := sub.NextMsg(queryWorkerPollInterval)
+			if err != nil {
+				return
+			}
+			d.respondQuery(msg, d.handleQuery(ctx, pool.cache, msg.Data))
+		}
+		sub.Unsubscribe()
+	})
+	if err != nil {
+		stop()
+		sub.Unsubscribe()
+		return err
+	}
+	pool.stops = append(pool.stops, stop)
+
+	return nil
+}
+
+// open returns the shared database for query, retrieving a fresh copy if it expired or a newer
+// revision was stored. release must be called once the query is done.
+func (c *workerCache) open(ctx context.Context, d *DuckDBStorage, query string) (*sql.DB, func(), error) {
+	c.mu.RLock()
+	if c.fresh() {
+		return d.filterCached(ctx, c.db, c.path, query, c.mu.RUnlock)
+	}
+	c.mu.RUnlock()
+
+	c.mu.Lock()
+	if !c.fresh() {
+		if err := c.refresh(ctx, d); err != nil {
+			c.mu.Unlock()
+			return nil, nil, err
+		}
+	}
+	c.mu.Unlock()
+
+	c.mu.RLock()
+	if c.db == nil {
+		c.mu.RUnlock()
+		return nil, nil, errors.New("query workers stopped")
+	}
+	return d.filterCached(ctx, c.db, c.path, query, c.mu.RUnlock)
+}
+
+// fresh reports whether the cached copy can be queried, the caller must hold the lock
+func (c *workerCache) fresh() bool {
+	return c.db != nil && !c.stale.Load() && (c.ttl <= 0 || time.Since(c.loaded) < c.ttl)
+}
+
+// refresh replaces the cached copy with the stored database, the caller must hold the write lock
+func (c *workerCache) refresh(ctx context.Context, d *DuckDBStorage) error {
+	if c.closed {
+		return errors.New("query workers stopped")
+	}
+
+	// Clear the flag first so an update stored during the download triggers another refresh
+	c.stale.Store(false)
+	path, err := d.retrieveTemp(ctx)
+	if err != nil {
+		c.stale.Store(true)
+		return err
+	}
+	db, err := openDuckDBReadOnly(path)
+	if err != nil {
+		removeTempDatabase(path)
+		c.stale.Store(true)
+		return err
+	}
+
+	c.release()
+	c.path, c.db, c.loaded = path, db, time.Now()
+	return nil
+}
+
+// close releases the cached copy once running queries are done
+func (c *workerCache) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.release()
+}
+
+// release closes and removes the cached copy, the caller must hold the write lock
+func (c *workerCache) release() {
+	if c.db == nil {
+		return
+	}
+	c.db.Close()
+	removeTempDatabase(c.path)
+	c.db = nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQueryWorker(t *testing.T) {
+	s, path := storeTestDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.StartQueryWorker(ctx, "query.workers", 2); err != nil {
+		t.Fatalf("StartQueryWorker: %v", err)
+	}
+	if err := s.StartQueryWorker(ctx, "query.workers", 2); err == nil {
+		t.Error("second StartQueryWorker succeeded")
+	}
+
+	count := func() any {
+		t.Helper()
+		rows, err := s.QueryRemote(ctx, "query.workers", "SELECT count(*) AS n FROM users")
+		if err != nil {
+			t.Fatalf("QueryRemote: %v", err)
+		}
+		return rows[0]["n"]
+	}
+	if n := count(); n != float64(3) {
+		t.Fatalf("count = %v, want 3", n)
+	}
+
+	// Storing a new revision makes the workers retrieve it again
+	execTestDatabase(t, path, "INSERT INTO users VALUES (4, 'Dave', now())")
+	if err := s.StoreDuckDB(path); err != nil {
+		t.Fatalf("StoreDuckDB: %v", err)
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for count() != float64(4) {
+		if time.Now().After(deadline) {
+			t.Fatal("workers still answer from the previous revision")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	if _, err := s.QueryRemote(ctx, "query.workers", "DELETE FROM users"); err == nil {
+		t.Error("workers ran a write")
+	}
+}
+
+func TestSetWorkerCount(t *testing.T) {
+	s, _ := storeTestDatabase(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.SetWorkerCount(2); !errors.Is(err, ErrWorkersNotStarted) {
+		t.Fatalf("SetWorkerCount before StartQueryWorker: got %v, want ErrWorkersNotStarted", err)
+	}
+	if err := s.StartQueryWorker(ctx, "query.workers", 0); err == nil {
+		t.Error("StartQueryWorker accepted 0 workers")
+	}
+	if err := s.StartQueryWorker(ctx, "query.workers", 2); err != nil {
+		t.Fatalf("StartQueryWorker: %v", err)
+	}
+	if err := s.SetWorkerCount(0); err == nil {
+		t.Error("SetWorkerCount accepted 0 workers")
+	}
+
+	// Resizing while queries are in flight must not lose any of them
+	var wg sync.WaitGroup
+	errs := make(chan error, 200)
+	for i := 0; i < 200; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.QueryRemote(ctx, "query.workers", "SELECT 1"); err != nil {
+				errs <- err
+			}
+		}()
+		switch i {
+		case 50:
+			if err := s.SetWorkerCount(8); err != nil {
+				t.Fatalf("SetWorkerCount(8): %v", err)
+			}
+		case 120:
+			if err := s.SetWorkerCount(1); err != nil {
+				t.Fatalf("SetWorkerCount(1): %v", err)
+			}
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("QueryRemote during a resize: %v", err)
+	}
+	if n := s.WorkerCount(); n != 1 {
+		t.Errorf("WorkerCount = %d, want 1", n)
+	}
+
+	cancel()
+	deadline := time.Now().Add(3 * time.Second)
+	for s.WorkerCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("workers still running after the context was cancelled")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// BenchmarkQueryWorker measures the throughput of concurrent remote queries answered by pools
+// of different sizes
+func BenchmarkQueryWorker(b *testing.B) {
+	s, _ := storeTestDatabase(b)
+	ctx := context.Background()
+	if err := s.StartQueryWorker(ctx, "query.workers", 1); err != nil {
+		b.Fatalf("StartQueryWorker: %v", err)
+	}
+
+	for _, workers := range []int{1, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			if err := s.SetWorkerCount(workers); err != nil {
+				b.Fatalf("SetWorkerCount: %v", err)
+			}
+			// Load the cached copy before measuring
+			if _, err := s.QueryRemote(ctx, "query.workers", "SELECT 1"); err != nil {
+				b.Fatalf("QueryRemote: %v", err)
+			}
+			b.SetParallelism(4)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if _, err := s.QueryRemote(ctx, "query.workers", "SELECT count(*) FROM users, range(20000)"); err != nil {
+						b.Errorf("QueryRemote: %v", err)
+						return
+					}
+				}
+			})
+		})
+	}
+}